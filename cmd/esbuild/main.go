@@ -26,7 +26,7 @@ Options:
   --platform=...        Platform target (browser or node, default browser)
   --external:M          Exclude module M from the bundle
   --format=...          Output format (iife, cjs, esm)
-  --splitting           Enable code splitting (currently only for esm)
+  --splitting           Enable code splitting
   --color=...           Force use of color terminal escapes (true or false)
   --global-name=...     The name of the global for the IIFE format
 
@@ -51,9 +51,30 @@ Advanced options:
   --log-level=...           Disable logging (info, warning, error, silent)
   --resolve-extensions=...  A comma-separated list of implicit extensions
   --metafile=...            Write metadata about the build to a JSON file
+  --manifest=...            Write a JSON file mapping entry points to outputs
+  --depfile=...             Write the files read by the build for Make/Ninja
+  --clean-outdir            Delete stale output files from the previous build
+  --analyze                 Print the size of each input
+  --analyze-html=...        Write a treemap of input sizes to an HTML file
+  --why=...                 Explain why a file or package is in the bundle
+  --ast=json                Print the syntax tree of stdin as ESTree JSON (no
+                            TypeScript types, only enums and namespaces)
+  --warn-circular-imports   Warn about files that import each other in a cycle
+  --allow-circular-import=. Don't warn about a cycle between these files (a,b)
+  --dedupe-packages         Use one copy of packages installed more than once
+  --max-size=...            Fail if the output is bigger (e.g. 100kb,gzip,warn)
+  --max-size:E=...          Like --max-size but for the output of entry point E
+  --max-size-match:P=...    Like --max-size but for each output matching P
   --strict                  Transforms handle edge cases but have more overhead
   --pure=N                  Mark the name N as a pure function for tree shaking
   --tsconfig=...            Use this tsconfig.json file instead of other ones
+  --manual-chunk:N=P        Put modules with paths matching P in chunk N
+  --min-chunk-size=...      Merge chunks with less input than this many bytes
+  --preload-dynamic-imports Preload the dependencies of import() chunks
+  --drop:...                Remove "console" calls or "debugger" statements
+  --mangle-props=...        Rename all properties matching a regular expression
+  --reserve-props=...       Do not mangle these properties
+  --mangle-cache=...        Read and update mangled names in this JSON file
 
 Examples:
   # Produces dist/entry_point.js and dist/entry_point.js.map
@@ -6,12 +6,11 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
-	"path/filepath"
 	"runtime/debug"
 	"sync"
 
@@ -26,6 +25,12 @@ type serviceType struct {
 	callbacks       map[uint32]responseCallback
 	nextID          uint32
 	outgoingPackets chan outgoingPacket
+	activeBuilds    map[int]*activeBuild
+}
+
+type activeBuild struct {
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 type outgoingPacket struct {
@@ -37,6 +42,7 @@ func runService() {
 	service := serviceType{
 		callbacks:       make(map[uint32]responseCallback),
 		outgoingPackets: make(chan outgoingPacket),
+		activeBuilds:    make(map[int]*activeBuild),
 	}
 	buffer := make([]byte, 4096)
 	stream := []byte{}
@@ -80,9 +86,14 @@ func runService() {
 
 			// Clone the input and run it on another goroutine
 			clone := append([]byte{}, message...)
+			p, ok := decodePacket(clone)
+			if !ok {
+				continue
+			}
+			service.startBuildIfNeeded(p)
 			waitGroup.Add(1)
 			go func() {
-				if result := service.handleIncomingMessage(clone); result != nil {
+				if result := service.handleIncomingPacket(p); result != nil {
 					service.outgoingPackets <- outgoingPacket{bytes: result, isFinal: true}
 				} else {
 					waitGroup.Done()
@@ -123,12 +134,7 @@ func (service *serviceType) sendRequest(request interface{}) interface{} {
 	return <-result
 }
 
-func (service *serviceType) handleIncomingMessage(bytes []byte) (result []byte) {
-	p, ok := decodePacket(bytes)
-	if !ok {
-		return nil
-	}
-
+func (service *serviceType) handleIncomingPacket(p packet) (result []byte) {
 	if p.isRequest {
 		// Catch panics in the code below so they get passed to the caller
 		defer func() {
@@ -151,6 +157,24 @@ func (service *serviceType) handleIncomingMessage(bytes []byte) (result []byte)
 		case "transform":
 			return service.handleTransformRequest(p.id, data[1].(map[string]interface{}))
 
+		case "transform-many":
+			return service.handleTransformManyRequest(p.id, data[1].(map[string]interface{}))
+
+		case "cancel":
+			key := data[1].(map[string]interface{})["key"].(int)
+			service.mutex.Lock()
+			build, ok := service.activeBuilds[key]
+			service.mutex.Unlock()
+
+			// Builds that have already finished have nothing to cancel
+			if ok {
+				build.cancel()
+			}
+			return encodePacket(packet{
+				id:    p.id,
+				value: map[string]interface{}{},
+			})
+
 		default:
 			return encodePacket(packet{
 				id: p.id,
@@ -173,11 +197,43 @@ func (service *serviceType) handleIncomingMessage(bytes []byte) (result []byte)
 	return nil
 }
 
+// Incoming messages are handled in parallel, so a "cancel" request could be
+// handled before the "build" request it refers to. To avoid this, the context
+// for each build is created while reading messages in order, before any later
+// "cancel" request is read.
+func (service *serviceType) startBuildIfNeeded(p packet) {
+	if !p.isRequest {
+		return
+	}
+	if data, ok := p.value.([]interface{}); ok && len(data) == 2 && data[0] == "build" {
+		if request, ok := data[1].(map[string]interface{}); ok {
+			if key, ok := request["key"].(int); ok {
+				ctx, cancel := context.WithCancel(context.Background())
+				service.mutex.Lock()
+				service.activeBuilds[key] = &activeBuild{ctx: ctx, cancel: cancel}
+				service.mutex.Unlock()
+			}
+		}
+	}
+}
+
 func (service *serviceType) handleBuildRequest(id uint32, request map[string]interface{}) []byte {
+	key := request["key"].(int)
+	service.mutex.Lock()
+	build := service.activeBuilds[key]
+	service.mutex.Unlock()
+	defer func() {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+		delete(service.activeBuilds, key)
+		build.cancel()
+	}()
+
 	write := request["write"].(bool)
 	flags := decodeStringArray(request["flags"].([]interface{}))
 	stdin, hasStdin := request["stdin"].(string)
 	resolveDir, hasResolveDir := request["resolveDir"].(string)
+	mangleCache, _ := request["mangleCache"].(map[string]interface{})
 
 	options, err := cli.ParseBuildOptions(flags)
 	if err == nil && write && options.Outfile == "" && options.Outdir == "" {
@@ -191,6 +247,13 @@ func (service *serviceType) handleBuildRequest(id uint32, request map[string]int
 			},
 		})
 	}
+	options.MangleCache = mangleCache
+	options.Write = write
+
+	// Plugins run in the host process and are called using requests
+	if plugins, ok := request["plugins"].([]interface{}); ok {
+		options.Plugins = service.convertPlugins(key, plugins)
+	}
 
 	// Optionally allow input from the stdin channel
 	if hasStdin {
@@ -203,24 +266,21 @@ func (service *serviceType) handleBuildRequest(id uint32, request map[string]int
 		}
 	}
 
-	result := api.Build(options)
+	result := api.BuildWithContext(build.ctx, options)
 	response := map[string]interface{}{
 		"errors":   encodeMessages(result.Errors),
 		"warnings": encodeMessages(result.Warnings),
 	}
+	if result.MangleCache != nil {
+		response["mangleCache"] = result.MangleCache
+	}
+	dependencies := make([]interface{}, len(result.Dependencies))
+	for i, path := range result.Dependencies {
+		dependencies[i] = path
+	}
+	response["dependencies"] = dependencies
 
-	if write {
-		// Write the output files to disk
-		for _, outputFile := range result.OutputFiles {
-			if err := os.MkdirAll(filepath.Dir(outputFile.Path), 0755); err != nil {
-				result.Errors = append(result.Errors, api.Message{Text: fmt.Sprintf(
-					"Failed to create output directory: %s", err.Error())})
-			} else if err := ioutil.WriteFile(outputFile.Path, outputFile.Contents, 0644); err != nil {
-				result.Errors = append(result.Errors, api.Message{Text: fmt.Sprintf(
-					"Failed to write to output file: %s", err.Error())})
-			}
-		}
-	} else {
+	if !write {
 		// Pass the output files back to the caller
 		response["outputFiles"] = encodeOutputFiles(result.OutputFiles)
 	}
@@ -234,6 +294,7 @@ func (service *serviceType) handleBuildRequest(id uint32, request map[string]int
 func (service *serviceType) handleTransformRequest(id uint32, request map[string]interface{}) []byte {
 	input := request["input"].(string)
 	flags := decodeStringArray(request["flags"].([]interface{}))
+	mangleCache, _ := request["mangleCache"].(map[string]interface{})
 
 	options, err := cli.ParseTransformOptions(flags)
 	if err != nil {
@@ -244,19 +305,184 @@ func (service *serviceType) handleTransformRequest(id uint32, request map[string
 			},
 		})
 	}
+	options.MangleCache = mangleCache
 
 	result := api.Transform(input, options)
+	response := map[string]interface{}{
+		"errors":      encodeMessages(result.Errors),
+		"warnings":    encodeMessages(result.Warnings),
+		"js":          string(result.JS),
+		"jsSourceMap": string(result.JSSourceMap),
+	}
+	if result.MangleCache != nil {
+		response["mangleCache"] = result.MangleCache
+	}
 	return encodePacket(packet{
-		id: id,
-		value: map[string]interface{}{
+		id:    id,
+		value: response,
+	})
+}
+
+func (service *serviceType) handleTransformManyRequest(id uint32, request map[string]interface{}) []byte {
+	flags := decodeStringArray(request["flags"].([]interface{}))
+	mangleCache, _ := request["mangleCache"].(map[string]interface{})
+
+	options, err := cli.ParseTransformOptions(flags)
+	var inputs []api.TransformInput
+	if err == nil {
+		for _, value := range request["inputs"].([]interface{}) {
+			input := value.(map[string]interface{})
+			contents := input["contents"].(string)
+			sourcefile, _ := input["sourcefile"].(string)
+
+			// Reuse the flag parser for the loader of each input
+			loader := api.LoaderJS
+			if text, ok := input["loader"].(string); ok {
+				var loaderOptions api.TransformOptions
+				if loaderOptions, err = cli.ParseTransformOptions([]string{"--loader=" + text}); err != nil {
+					break
+				}
+				loader = loaderOptions.Loader
+			}
+
+			inputs = append(inputs, api.TransformInput{Contents: contents, Sourcefile: sourcefile, Loader: loader})
+		}
+	}
+	if err != nil {
+		return encodePacket(packet{
+			id: id,
+			value: map[string]interface{}{
+				"error": err.Error(),
+			},
+		})
+	}
+	options.MangleCache = mangleCache
+
+	results := api.TransformMany(inputs, options)
+	values := make([]interface{}, len(results))
+	for i, result := range results {
+		value := map[string]interface{}{
 			"errors":      encodeMessages(result.Errors),
 			"warnings":    encodeMessages(result.Warnings),
 			"js":          string(result.JS),
 			"jsSourceMap": string(result.JSSourceMap),
+		}
+		if result.MangleCache != nil {
+			value["mangleCache"] = result.MangleCache
+		}
+		values[i] = value
+	}
+	return encodePacket(packet{
+		id: id,
+		value: map[string]interface{}{
+			"results": values,
 		},
 	})
 }
 
+// Each callback registered by a plugin in the host process has an ID. When a
+// filter matches, the build calls back into the host process with a request
+// containing that ID and waits for the response.
+func (service *serviceType) convertPlugins(key int, jsPlugins []interface{}) []api.Plugin {
+	type filteredCallback struct {
+		id        int
+		filter    string
+		namespace string
+	}
+
+	decodeCallbacks := func(values []interface{}) []filteredCallback {
+		callbacks := make([]filteredCallback, len(values))
+		for i, value := range values {
+			item := value.(map[string]interface{})
+			callbacks[i] = filteredCallback{
+				id:        item["id"].(int),
+				filter:    item["filter"].(string),
+				namespace: item["namespace"].(string),
+			}
+		}
+		return callbacks
+	}
+
+	plugins := make([]api.Plugin, len(jsPlugins))
+	for i, value := range jsPlugins {
+		jsPlugin := value.(map[string]interface{})
+		onResolve := decodeCallbacks(jsPlugin["onResolve"].([]interface{}))
+		onLoad := decodeCallbacks(jsPlugin["onLoad"].([]interface{}))
+
+		plugins[i] = api.Plugin{
+			Name: jsPlugin["name"].(string),
+			Setup: func(build api.PluginBuild) {
+				for _, item := range onResolve {
+					id := item.id
+					build.OnResolve(api.OnResolveOptions{Filter: item.filter, Namespace: item.namespace},
+						func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+							response := service.sendRequest([]interface{}{"resolve", map[string]interface{}{
+								"key":        key,
+								"id":         id,
+								"path":       args.Path,
+								"importer":   args.Importer,
+								"namespace":  args.Namespace,
+								"resolveDir": args.ResolveDir,
+							}}).(map[string]interface{})
+							if text, ok := response["error"].(string); ok {
+								return api.OnResolveResult{}, errors.New(text)
+							}
+
+							result := api.OnResolveResult{}
+							if value, ok := response["path"].(string); ok {
+								result.Path = value
+							}
+							if value, ok := response["external"].(bool); ok {
+								result.External = value
+							}
+							if value, ok := response["namespace"].(string); ok {
+								result.Namespace = value
+							}
+							return result, nil
+						})
+				}
+
+				for _, item := range onLoad {
+					id := item.id
+					build.OnLoad(api.OnLoadOptions{Filter: item.filter, Namespace: item.namespace},
+						func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+							response := service.sendRequest([]interface{}{"load", map[string]interface{}{
+								"key":       key,
+								"id":        id,
+								"path":      args.Path,
+								"namespace": args.Namespace,
+							}}).(map[string]interface{})
+							if text, ok := response["error"].(string); ok {
+								return api.OnLoadResult{}, errors.New(text)
+							}
+
+							result := api.OnLoadResult{Loader: api.LoaderNone}
+							switch value := response["contents"].(type) {
+							case string:
+								result.Contents = &value
+							case []byte:
+								contents := string(value)
+								result.Contents = &contents
+							}
+							if value, ok := response["resolveDir"].(string); ok {
+								result.ResolveDir = value
+							}
+							if value, ok := response["loader"].(string); ok {
+								loader, err := cli.ParseLoader(value)
+								if err != nil {
+									return api.OnLoadResult{}, err
+								}
+								result.Loader = loader
+							}
+							return result, nil
+						})
+				}
+			},
+		}
+	}
+	return plugins
+}
+
 func decodeStringArray(values []interface{}) []string {
 	strings := make([]string, len(values))
 	for i, value := range values {
@@ -282,6 +508,7 @@ func encodeMessages(msgs []api.Message) []interface{} {
 		value := make(map[string]interface{})
 		values[i] = value
 		value["text"] = msg.Text
+		value["id"] = msg.ID
 
 		// Some messages won't have a location
 		loc := msg.Location
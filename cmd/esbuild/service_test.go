@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func handleTestRequest(t *testing.T, request []interface{}) map[string]interface{} {
+	t.Helper()
+	service := serviceType{
+		callbacks:       make(map[uint32]responseCallback),
+		outgoingPackets: make(chan outgoingPacket),
+		activeBuilds:    make(map[int]*activeBuild),
+	}
+
+	// The service reads the length prefix before handling each message
+	bytes := encodePacket(packet{id: 1, isRequest: true, value: request})
+	message, _, ok := readLengthPrefixedSlice(bytes)
+	if !ok {
+		t.Fatal("Invalid request")
+	}
+	p, ok := decodePacket(message)
+	if !ok {
+		t.Fatal("Invalid request")
+	}
+
+	message, _, ok = readLengthPrefixedSlice(service.handleIncomingPacket(p))
+	if !ok {
+		t.Fatal("Invalid response")
+	}
+	response, ok := decodePacket(message)
+	if !ok || response.isRequest || response.id != 1 {
+		t.Fatal("Invalid response")
+	}
+	return response.value.(map[string]interface{})
+}
+
+func TestServiceTransformMany(t *testing.T) {
+	response := handleTestRequest(t, []interface{}{"transform-many", map[string]interface{}{
+		"flags": []interface{}{"--minify-whitespace", "--sourcemap=external", "--log-level=silent"},
+		"inputs": []interface{}{
+			map[string]interface{}{"contents": "let a: number = 1", "sourcefile": "a.ts", "loader": "ts"},
+			map[string]interface{}{"contents": "let b = (", "sourcefile": "b.js"},
+			map[string]interface{}{"contents": "let c = 3", "sourcefile": "c.js"},
+		},
+	}})
+
+	results := response["results"].([]interface{})
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	expectJS := func(index int, expected string) {
+		t.Helper()
+		result := results[index].(map[string]interface{})
+		if js := result["js"].(string); js != expected {
+			t.Fatalf("%q != %q", js, expected)
+		}
+	}
+	expectErrors := func(index int, expected int) {
+		t.Helper()
+		result := results[index].(map[string]interface{})
+		if errors := result["errors"].([]interface{}); len(errors) != expected {
+			t.Fatalf("Expected %d errors, got %d", expected, len(errors))
+		}
+	}
+
+	// Results are in input order and each has its own errors and source map
+	expectJS(0, "let a=1;\n")
+	expectErrors(0, 0)
+	expectJS(1, "")
+	expectErrors(1, 1)
+	expectJS(2, "let c=3;\n")
+	expectErrors(2, 0)
+	for index, source := range map[int]string{0: "a.ts", 2: "c.js"} {
+		sourceMap := results[index].(map[string]interface{})["jsSourceMap"].(string)
+		if expected := fmt.Sprintf(`"sources": [%q]`, source); !strings.Contains(sourceMap, expected) {
+			t.Fatalf("Expected %q in %q", expected, sourceMap)
+		}
+	}
+}
+
+func TestServiceTransformManyErrors(t *testing.T) {
+	expectError := func(request map[string]interface{}, expected string) {
+		t.Helper()
+		response := handleTestRequest(t, []interface{}{"transform-many", request})
+		if text, _ := response["error"].(string); text != expected {
+			t.Fatalf("%q != %q", text, expected)
+		}
+	}
+
+	expectError(map[string]interface{}{
+		"flags":  []interface{}{"--bundle"},
+		"inputs": []interface{}{},
+	}, "Invalid transform flag: \"--bundle\"")
+
+	expectError(map[string]interface{}{
+		"flags": []interface{}{},
+		"inputs": []interface{}{
+			map[string]interface{}{"contents": "", "loader": "js"},
+			map[string]interface{}{"contents": "", "loader": "nope"},
+		},
+	}, "Invalid loader: \"nope\" (valid: js, jsx, ts, tsx, json, text, base64, dataurl, file, binary)")
+}
@@ -0,0 +1,252 @@
+// This package summarizes the metadata file generated by the bundler. It
+// reports how many bytes each input contributes to each output, with inputs
+// from the same package under "node_modules" grouped together.
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// This must match the version written by the bundler. Metadata files without
+// a version are from before it was added and can still be read.
+const supportedVersion = 2
+
+type metadata struct {
+	Version int `json:"version"`
+	Outputs map[string]struct {
+		Bytes  int `json:"bytes"`
+		Inputs map[string]struct {
+			BytesInOutput int `json:"bytesInOutput"`
+		} `json:"inputs"`
+	} `json:"outputs"`
+}
+
+// Outputs are the top-level nodes. Their children are either input files or
+// packages, and the children of a package are its input files. Children are
+// sorted by size with the largest first.
+type Node struct {
+	Name     string
+	Bytes    int
+	Children []Node
+}
+
+func Analyze(metafile []byte) ([]Node, error) {
+	var meta metadata
+	if err := json.Unmarshal(metafile, &meta); err != nil {
+		return nil, fmt.Errorf("Invalid metafile: %s", err.Error())
+	}
+	if meta.Version > supportedVersion {
+		return nil, fmt.Errorf("Unsupported metafile version: %d", meta.Version)
+	}
+	if meta.Outputs == nil {
+		return nil, fmt.Errorf("Invalid metafile: missing \"outputs\"")
+	}
+
+	outputs := []Node{}
+	for outputPath, output := range meta.Outputs {
+		// Skip outputs such as source maps that don't contain any inputs
+		if len(output.Inputs) == 0 {
+			continue
+		}
+
+		children := []Node{}
+		packages := make(map[string]int)
+		for inputPath, input := range output.Inputs {
+			node := Node{Name: inputPath, Bytes: input.BytesInOutput}
+			packageDir, ok := packageDirForPath(inputPath)
+			if !ok {
+				children = append(children, node)
+				continue
+			}
+			index, ok := packages[packageDir]
+			if !ok {
+				index = len(children)
+				packages[packageDir] = index
+				children = append(children, Node{Name: packageDir})
+			}
+			children[index].Bytes += node.Bytes
+			children[index].Children = append(children[index].Children, node)
+		}
+
+		for _, index := range packages {
+			sortNodes(children[index].Children)
+		}
+		sortNodes(children)
+		outputs = append(outputs, Node{Name: outputPath, Bytes: output.Bytes, Children: children})
+	}
+
+	// Outputs are sorted by path instead of by size so they are easy to find
+	sort.Slice(outputs, func(i int, j int) bool {
+		return outputs[i].Name < outputs[j].Name
+	})
+	return outputs, nil
+}
+
+// This returns the directory of the innermost package containing the path.
+// For example, "node_modules/@scope/pkg/lib/index.js" is in the package
+// "node_modules/@scope/pkg".
+func packageDirForPath(path string) (string, bool) {
+	slashPath := strings.ReplaceAll(path, "\\", "/")
+	i := strings.LastIndex(slashPath, "node_modules/")
+	if i == -1 || (i > 0 && slashPath[i-1] != '/') {
+		return "", false
+	}
+	start := i + len("node_modules/")
+	parts := strings.SplitN(slashPath[start:], "/", 3)
+	count := 1
+	if strings.HasPrefix(parts[0], "@") {
+		count = 2
+	}
+	if len(parts) <= count {
+		return "", false
+	}
+	end := start + len(strings.Join(parts[:count], "/"))
+	return path[:end], true
+}
+
+func sortNodes(nodes []Node) {
+	sort.Slice(nodes, func(i int, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Name < b.Name
+	})
+}
+
+func formatSize(bytes int) string {
+	switch {
+	case bytes < 1024:
+		return fmt.Sprintf("%db", bytes)
+	case bytes < 1024*1024:
+		return fmt.Sprintf("%.1fkb", float64(bytes)/1024)
+	default:
+		return fmt.Sprintf("%.1fmb", float64(bytes)/(1024*1024))
+	}
+}
+
+func formatPercent(bytes int, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(bytes)*100/float64(total))
+}
+
+// This prints a table for each output. Packages show how many input files
+// they contain instead of listing them.
+func Text(outputs []Node) string {
+	type row struct {
+		name    string
+		size    string
+		percent string
+	}
+
+	sb := strings.Builder{}
+	for _, output := range outputs {
+		rows := []row{{name: output.Name, size: formatSize(output.Bytes), percent: formatPercent(output.Bytes, output.Bytes)}}
+		for i, child := range output.Children {
+			prefix := " ├ "
+			if i+1 == len(output.Children) {
+				prefix = " └ "
+			}
+			name := child.Name
+			if len(child.Children) == 1 {
+				name += " (1 file)"
+			} else if len(child.Children) > 1 {
+				name += fmt.Sprintf(" (%d files)", len(child.Children))
+			}
+			rows = append(rows, row{name: prefix + name, size: formatSize(child.Bytes), percent: formatPercent(child.Bytes, output.Bytes)})
+		}
+
+		// Align the columns using the width of the widest row
+		nameWidth, sizeWidth, percentWidth := 0, 0, 0
+		for _, r := range rows {
+			if n := len([]rune(r.name)); n > nameWidth {
+				nameWidth = n
+			}
+			if len(r.size) > sizeWidth {
+				sizeWidth = len(r.size)
+			}
+			if len(r.percent) > percentWidth {
+				percentWidth = len(r.percent)
+			}
+		}
+
+		sb.WriteString("\n")
+		for _, r := range rows {
+			padding := strings.Repeat(" ", nameWidth-len([]rune(r.name)))
+			sb.WriteString(fmt.Sprintf("  %s%s  %*s  %*s\n", r.name, padding, sizeWidth, r.size, percentWidth, r.percent))
+		}
+	}
+	return sb.String()
+}
+
+// This generates a standalone HTML page with a treemap of all outputs. The
+// layout is computed here so the page doesn't need any JavaScript. Each level
+// is split along alternating axes in proportion to the size of each node.
+func HTML(outputs []Node) string {
+	sb := strings.Builder{}
+	sb.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Bundle analysis</title>
+<style>
+  html, body { margin: 0; height: 100%; font: 12px sans-serif; }
+  .treemap { position: relative; width: 100%; height: 100%; }
+  .node { position: absolute; box-sizing: border-box; overflow: hidden; border: 1px solid #fff; }
+  .node > span { display: block; padding: 2px 4px; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+  .depth0 { background: #4a6fa5; color: #fff; }
+  .depth1 { background: #9cc3e6; color: #000; }
+  .depth2 { background: #d7e8f5; color: #000; }
+</style>
+</head>
+<body>
+<div class="treemap">
+`)
+	writeTreemapNodes(&sb, outputs, 0)
+	sb.WriteString("</div>\n</body>\n</html>\n")
+	return sb.String()
+}
+
+func writeTreemapNodes(sb *strings.Builder, nodes []Node, depth int) {
+	total := 0
+	for _, node := range nodes {
+		total += node.Bytes
+	}
+	if total == 0 {
+		return
+	}
+
+	// Leave room at the top of each parent for its label
+	top := 0.0
+	height := 100.0
+	if depth > 0 {
+		top = 16
+		height = 84
+	}
+
+	offset := 0.0
+	for _, node := range nodes {
+		if node.Bytes == 0 {
+			continue
+		}
+		fraction := float64(node.Bytes) / float64(total) * 100
+		var style string
+		if depth%2 == 0 {
+			style = fmt.Sprintf("left:%.4f%%;top:%.4f%%;width:%.4f%%;height:%.4f%%", offset, top, fraction, height)
+		} else {
+			style = fmt.Sprintf("left:0;top:%.4f%%;width:100%%;height:%.4f%%", top+offset*height/100, fraction*height/100)
+		}
+		offset += fraction
+
+		label := html.EscapeString(fmt.Sprintf("%s (%s, %s)", node.Name, formatSize(node.Bytes), formatPercent(node.Bytes, total)))
+		sb.WriteString(fmt.Sprintf("<div class=\"node depth%d\" style=\"%s\" title=\"%s\"><span>%s</span>\n", depth%3, style, label, label))
+		writeTreemapNodes(sb, node.Children, depth+1)
+		sb.WriteString("</div>\n")
+	}
+}
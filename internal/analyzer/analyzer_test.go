@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	outputs, err := Analyze([]byte(`{
+		"version": 1,
+		"inputs": {},
+		"outputs": {
+			"out/b.js": {
+				"imports": [],
+				"exports": [],
+				"inputs": {
+					"src/b.js": { "bytesInOutput": 100 }
+				},
+				"bytes": 120
+			},
+			"out/b.js.map": {
+				"imports": [],
+				"exports": [],
+				"inputs": {},
+				"bytes": 300
+			},
+			"out/a.js": {
+				"imports": [],
+				"exports": [],
+				"inputs": {
+					"src/a.js": { "bytesInOutput": 200 },
+					"node_modules/react/index.js": { "bytesInOutput": 50 },
+					"node_modules/react/cjs/react.js": { "bytesInOutput": 1500 },
+					"node_modules/@scope/pkg/index.js": { "bytesInOutput": 200 },
+					"node_modules/@scope/pkg/node_modules/dep/index.js": { "bytesInOutput": 10 }
+				},
+				"bytes": 2000
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+  out/a.js                                              2.0kb  100.0%
+   ├ node_modules/react (2 files)                       1.5kb   77.5%
+   ├ node_modules/@scope/pkg (1 file)                    200b   10.0%
+   ├ src/a.js                                            200b   10.0%
+   └ node_modules/@scope/pkg/node_modules/dep (1 file)    10b    0.5%
+
+  out/b.js     120b  100.0%
+   └ src/b.js  100b   83.3%
+`
+	text := Text(outputs)
+	if text != expected {
+		t.Fatalf("Unexpected text:\n%s", text)
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	_, err := Analyze([]byte(`{"version": 3, "inputs": {}, "outputs": {}}`))
+	if err == nil || err.Error() != "Unsupported metafile version: 3" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestPackageDirForPath(t *testing.T) {
+	expected := map[string]string{
+		"node_modules/react/index.js":            "node_modules/react",
+		"/root/node_modules/@scope/pkg/lib/x.js": "/root/node_modules/@scope/pkg",
+		"node_modules/a/node_modules/b/index.js": "node_modules/a/node_modules/b",
+		"node_modules\\win\\index.js":            "node_modules\\win",
+		"src/not_node_modules/pkg/index.js":      "",
+		"node_modules/no-file":                   "",
+	}
+	for path, dir := range expected {
+		actual, ok := packageDirForPath(path)
+		if actual != dir || ok != (dir != "") {
+			t.Fatalf("Expected %q for %q but got %q", dir, path, actual)
+		}
+	}
+}
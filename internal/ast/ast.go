@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"math"
 	"strings"
 
 	"github.com/evanw/esbuild/internal/compat"
@@ -326,6 +327,10 @@ type Fn struct {
 	IsAsync     bool
 	IsGenerator bool
 	HasRestArg  bool
+
+	// True if there is a comment containing "@__NO_SIDE_EFFECTS__" or
+	// "#__NO_SIDE_EFFECTS__" preceding this function declaration
+	HasNoSideEffectsComment bool
 }
 
 type FnBody struct {
@@ -468,6 +473,11 @@ type EDot struct {
 	// unwrapped if the resulting value is unused. Unwrapping means discarding
 	// the call target but keeping any arguments with side effects.
 	CallCanBeUnwrappedIfUnused bool
+
+	// If true, this property name was written in the source code and matched
+	// the property mangling pattern. Property accesses generated by esbuild
+	// itself are never mangled.
+	MangleProp bool
 }
 
 type EIndex struct {
@@ -553,7 +563,13 @@ type EObject struct {
 
 type ESpread struct{ Value Expr }
 
-type EString struct{ Value []uint16 }
+type EString struct {
+	Value []uint16
+
+	// If true, this string is a property name that was written in the source
+	// code and matched the property mangling pattern
+	MangleProp bool
+}
 
 type TemplatePart struct {
 	Value   Expr
@@ -634,7 +650,7 @@ func Assign(a Expr, b Expr) Expr {
 }
 
 func AssignStmt(a Expr, b Expr) Stmt {
-	return Stmt{a.Loc, &SExpr{Expr{a.Loc, &EBinary{BinOpAssign, a, b}}}}
+	return Stmt{a.Loc, &SExpr{Value: Expr{a.Loc, &EBinary{BinOpAssign, a, b}}}}
 }
 
 func JoinWithComma(a Expr, b Expr) Expr {
@@ -649,6 +665,30 @@ func JoinAllWithComma(all []Expr) Expr {
 	return result
 }
 
+func ToBooleanWithoutSideEffects(data E) (bool, bool) {
+	switch e := data.(type) {
+	case *ENull, *EUndefined:
+		return false, true
+
+	case *EBoolean:
+		return e.Value, true
+
+	case *ENumber:
+		return e.Value != 0 && !math.IsNaN(e.Value), true
+
+	case *EBigInt:
+		return e.Value != "0", true
+
+	case *EString:
+		return len(e.Value) > 0, true
+
+	case *EFunction, *EArrow:
+		return true, true
+	}
+
+	return false, false
+}
+
 type ExprOrStmt struct {
 	Expr *Expr
 	Stmt *Stmt
@@ -723,6 +763,11 @@ type SLazyExport struct {
 
 type SExpr struct {
 	Value Expr
+
+	// This is set for generated code that only initializes a symbol declared in
+	// the same part, such as the closure for a TypeScript enum. It means this
+	// statement doesn't stop the part from being removed if it's unused.
+	DoesNotAffectTreeShaking bool
 }
 
 type EnumValue struct {
@@ -936,6 +981,49 @@ func IsSuperCall(stmt Stmt) bool {
 	return false
 }
 
+func StatementCaresAboutScope(stmt Stmt) bool {
+	switch s := stmt.Data.(type) {
+	case *SBlock, *SEmpty, *SDebugger, *SExpr, *SIf,
+		*SFor, *SForIn, *SForOf, *SDoWhile, *SWhile,
+		*SWith, *STry, *SSwitch, *SReturn, *SThrow,
+		*SBreak, *SContinue, *SDirective:
+		return false
+
+	case *SLocal:
+		return s.Kind != LocalVar
+
+	default:
+		return true
+	}
+}
+
+// Code that never runs can be removed unless it contains hoisted declarations
+// ("var" and "function"), which still affect the enclosing scope. For example,
+// removing "if (false) { var x }" would turn a later "x = 1" into an
+// assignment to a global variable.
+func CanRemoveDeadStmt(stmt Stmt) bool {
+	switch s := stmt.Data.(type) {
+	case *SEmpty, *SExpr, *SReturn, *SThrow, *SBreak, *SContinue, *SDebugger, *SClass:
+		return true
+
+	case *SLocal:
+		return s.Kind != LocalVar
+
+	case *SBlock:
+		for _, child := range s.Stmts {
+			if !CanRemoveDeadStmt(child) {
+				return false
+			}
+		}
+		return true
+
+	case *SIf:
+		return CanRemoveDeadStmt(s.Yes) && (s.No == nil || CanRemoveDeadStmt(*s.No))
+	}
+
+	return false
+}
+
 type ClauseItem struct {
 	Alias    string
 	AliasLoc Loc
@@ -1113,6 +1201,11 @@ type Symbol struct {
 	// even without updating after parsing it's still a pretty good heuristic.
 	UseCountEstimate uint32
 
+	// This is true for functions marked with a "@__NO_SIDE_EFFECTS__" comment.
+	// Calls to these functions can be removed if the result is unused, even
+	// when the call is in a different file than the function declaration.
+	CallCanBeUnwrappedIfUnused bool
+
 	Name string
 
 	// Used by the parser for single pass parsing. Symbols that have been merged
@@ -1235,6 +1328,19 @@ type ImportRecord struct {
 	// case we shouldn't generate an error if the path could not be resolved.
 	IsInsideTryBody bool
 
+	// If true, this import statement has imported names but they are only used
+	// in code that was proven to be dead by constant folding (e.g. in the body
+	// of "if (process.env.NODE_ENV !== 'production')" after defines have been
+	// substituted). The linker treats the import as unused when tree shaking.
+	// This is also set for "require()" and "import()" calls that the linker
+	// removed because of constants inlined from other files.
+	IsOnlyUsedInDeadCode bool
+
+	// If this is an "import()" of another chunk, these are the paths of the
+	// chunks it depends on that aren't loaded yet. The printer passes them to
+	// the "__preload()" helper so they are loaded in parallel.
+	PreloadPaths []string
+
 	Kind ImportKind
 }
 
@@ -1250,8 +1356,9 @@ type AST struct {
 	UsesModuleRef     bool
 
 	// This is a list of ES6 features
-	HasES6Imports bool
-	HasES6Exports bool
+	HasES6Imports  bool
+	HasES6Exports  bool
+	UsesImportMeta bool
 
 	Hashbang    string
 	Directive   string
@@ -1273,6 +1380,27 @@ type AST struct {
 	NamedExports            map[string]Ref
 	TopLevelSymbolToParts   map[Ref][]uint32
 	ExportStarImportRecords []uint32
+
+	// These are only filled in when syntax mangling is enabled. They hold the
+	// values of top-level constants and TypeScript enums so that the linker can
+	// inline them into other files.
+	ConstValues map[Ref]Expr
+	EnumValues  map[Ref]map[string]float64
+
+	// These are only filled in when property mangling is enabled. The first map
+	// counts the uses of each property name that will be mangled and the second
+	// holds all other property names, which the mangled names must avoid.
+	MangledProps  map[string]uint32
+	ReservedProps map[string]bool
+
+	// These are only filled in when the parser is preserving syntax. Tokens
+	// and comments are in source order. Node ends are keyed by the "Data" field
+	// of an expression or statement and are the offset just past the last
+	// token of that node. Ends for nodes without any fields aren't reliable
+	// since pointers to zero-sized values aren't guaranteed to be distinct.
+	Tokens   []Range
+	Comments []Range
+	NodeEnds map[interface{}]int32
 }
 
 func (ast *AST) HasCommonJSFeatures() bool {
@@ -1299,6 +1427,11 @@ type NamedImport struct {
 	// It's useful to flag exported imports because if they are in a TypeScript
 	// file, we can't tell if they are a type or a value.
 	IsExported bool
+
+	// This counts uses of this import that are property reads with a static
+	// name (e.g. "Enum.Member"). It's only filled in when syntax mangling is
+	// enabled, and is used to tell if all uses of an enum can be inlined.
+	PropertyReads map[string]uint32
 }
 
 // Each file is made up of multiple parts, and each part consists of one or
@@ -1330,6 +1463,11 @@ type Part struct {
 	// don't have this flag enabled must be included.
 	CanBeRemovedIfUnused bool
 
+	// If this is non-empty, this part can also be removed if unused as long as
+	// all of these imported symbols turn out to be functions marked with a
+	// "@__NO_SIDE_EFFECTS__" comment. That can only be known after linking.
+	CanBeRemovedIfImportedCallsAreUnused []Ref
+
 	// If true, this is the automatically-generated part for this file's ES6
 	// exports. It may hold the "const exports = {};" statement and also the
 	// "__export(exports, { ... })" call to initialize the getters.
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"mime"
 	"net/http"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -35,6 +36,10 @@ type file struct {
 	// file in one of our containing directories with a "sideEffects" field.
 	ignoreIfUnused bool
 
+	// The package containing this file according to the nearest enclosing
+	// "package.json" file with a name. The name is empty if there isn't one.
+	pkg packageInfo
+
 	// If "AbsMetadataFile" is present, this will be filled out with information
 	// about this file in JSON format. This is a partial JSON file that will be
 	// fully assembled later.
@@ -47,6 +52,13 @@ type Bundle struct {
 	sources     []logging.Source
 	files       []file
 	entryPoints []uint32
+
+	// This is only computed if it will be warned about or written to the
+	// metadata file
+	circularImports []circularImport
+
+	// This is only set after "Compile" is called with property mangling enabled
+	mangleCache map[string]interface{}
 }
 
 type parseFlags struct {
@@ -55,6 +67,7 @@ type parseFlags struct {
 	isEntryPoint      bool
 	ignoreIfUnused    bool
 	strictClassFields bool
+	pkg               packageInfo
 }
 
 type parseArgs struct {
@@ -86,6 +99,12 @@ type parseResult struct {
 }
 
 func parseFile(args parseArgs) {
+	// Don't bother parsing anything if the build was cancelled
+	if args.options.Cancel.DidCancel() {
+		args.results <- parseResult{}
+		return
+	}
+
 	source := logging.Source{
 		Index:      args.sourceIndex,
 		KeyPath:    args.keyPath,
@@ -103,6 +122,16 @@ func parseFile(args parseArgs) {
 
 	var loader config.Loader
 	stdin := args.options.Stdin
+	var pluginResult *config.OnLoadResult
+
+	if stdin == nil {
+		var ok bool
+		pluginResult, ok = runOnLoadPlugins(args)
+		if !ok {
+			args.results <- parseResult{}
+			return
+		}
+	}
 
 	if stdin != nil {
 		// Special-case stdin
@@ -112,6 +141,19 @@ func parseFile(args parseArgs) {
 			source.PrettyPath = stdin.SourceFile
 		}
 		loader = stdin.Loader
+	} else if pluginResult != nil {
+		// A plugin provided the contents of this module
+		source.Contents = *pluginResult.Contents
+		loader = pluginResult.Loader
+		if loader == config.LoaderNone {
+			loader = loaderFromFileExtension(args.options.ExtensionToLoader, args.baseName)
+			if loader == config.LoaderNone && !args.keyPath.IsAbsolute {
+				loader = config.LoaderJS
+			}
+		}
+		if pluginResult.ResolveDir != "" {
+			args.absResolveDir = pluginResult.ResolveDir
+		}
 	} else if args.keyPath.IsAbsolute {
 		// Read normal modules from disk
 		var ok bool
@@ -123,12 +165,15 @@ func parseFile(args parseArgs) {
 			return
 		}
 		loader = loaderFromFileExtension(args.options.ExtensionToLoader, args.baseName)
-	} else {
-		// Right now the only non-absolute modules are disabled ones
-		if !strings.HasPrefix(args.keyPath.Text, "disabled:") {
-			panic("Internal error")
-		}
+	} else if strings.HasPrefix(args.keyPath.Text, "disabled:") {
+		// Disabled modules are empty
 		loader = config.LoaderJS
+	} else {
+		// Other non-absolute modules come from plugins, which must load them
+		args.log.AddRangeError(args.importSource, args.pathRange,
+			fmt.Sprintf("Could not load %q because no plugin returned its contents", args.prettyPath))
+		args.results <- parseResult{}
+		return
 	}
 
 	// Allow certain properties to be overridden
@@ -146,6 +191,7 @@ func parseFile(args parseArgs) {
 		source: source,
 		file: file{
 			ignoreIfUnused: args.flags.ignoreIfUnused,
+			pkg:            args.flags.pkg,
 		},
 		ok: true,
 	}
@@ -242,12 +288,13 @@ func parseFile(args parseArgs) {
 		result.resolveResults = make([]*resolver.ResolveResult, len(result.file.ast.ImportRecords))
 
 		// Resolve relative to the parent directory of the source file with the
-		// import path. Just use the current directory if the source file is virtual.
+		// import path unless a different directory was provided. Just use the
+		// current directory if the source file is virtual.
 		var sourceDir string
-		if source.KeyPath.IsAbsolute {
-			sourceDir = args.fs.Dir(source.KeyPath.Text)
-		} else if args.absResolveDir != "" {
+		if args.absResolveDir != "" {
 			sourceDir = args.absResolveDir
+		} else if source.KeyPath.IsAbsolute {
+			sourceDir = args.fs.Dir(source.KeyPath.Text)
 		} else {
 			sourceDir = args.fs.Cwd()
 		}
@@ -260,8 +307,17 @@ func parseFile(args parseArgs) {
 					continue
 				}
 
+				// Give plugins a chance to resolve the path first
+				resolveResult, ok := runOnResolvePlugins(args.log, args.fs, args.options.Plugins,
+					record.Path.Text, &source, source.RangeOfString(record.Loc), sourceDir)
+				if !ok {
+					continue
+				}
+
 				// Run the resolver and log an error if the path couldn't be resolved
-				resolveResult := args.res.Resolve(sourceDir, record.Path.Text)
+				if resolveResult == nil {
+					resolveResult = args.res.Resolve(sourceDir, record.Path.Text)
+				}
 				if resolveResult == nil {
 					// Failed imports inside a try/catch are silently turned into
 					// external imports instead of causing errors. This matches a common
@@ -282,6 +338,109 @@ func parseFile(args parseArgs) {
 	args.results <- result
 }
 
+// Paths that aren't in the file system are stored as "namespace:path" in key
+// paths that aren't marked as absolute. For example, the resolver uses the
+// "disabled" namespace for modules disabled by the "browser" field.
+func namespaceAndPath(keyPath ast.Path) (string, string) {
+	if keyPath.IsAbsolute {
+		return "file", keyPath.Text
+	}
+	if i := strings.IndexByte(keyPath.Text, ':'); i != -1 {
+		return keyPath.Text[:i], keyPath.Text[i+1:]
+	}
+	return "", keyPath.Text
+}
+
+func pluginFilterMatches(filter *regexp.Regexp, filterNamespace string, namespace string, path string) bool {
+	return (filterNamespace == "" || filterNamespace == namespace) && filter.MatchString(path)
+}
+
+// This returns a nil result if no plugin resolved the path, and false if a
+// plugin failed (in which case an error has already been logged). Entry points
+// have no importer and are resolved from the "file" namespace.
+func runOnResolvePlugins(
+	log logging.Log,
+	fs fs.FS,
+	plugins []config.Plugin,
+	path string,
+	importSource *logging.Source,
+	pathRange ast.Range,
+	resolveDir string,
+) (*resolver.ResolveResult, bool) {
+	importerNamespace, importer := "file", ""
+	if importSource != nil {
+		importerNamespace, importer = namespaceAndPath(importSource.KeyPath)
+	}
+
+	for _, plugin := range plugins {
+		for _, onResolve := range plugin.OnResolve {
+			if !pluginFilterMatches(onResolve.Filter, onResolve.Namespace, importerNamespace, path) {
+				continue
+			}
+
+			result, err := onResolve.Callback(config.OnResolveArgs{
+				Path:       path,
+				Importer:   importer,
+				Namespace:  importerNamespace,
+				ResolveDir: resolveDir,
+			})
+			if err != nil {
+				log.AddRangeError(importSource, pathRange, fmt.Sprintf("[%s] %s", plugin.Name, err.Error()))
+				return nil, false
+			}
+			if result.Path == "" {
+				continue
+			}
+
+			// External paths are left as-is
+			if result.External {
+				return &resolver.ResolveResult{Path: ast.Path{Text: result.Path}, IsExternal: true}, true
+			}
+
+			if result.Namespace == "" || result.Namespace == "file" {
+				absPath, ok := fs.Abs(result.Path)
+				if !ok {
+					log.AddRangeError(importSource, pathRange, fmt.Sprintf("[%s] Invalid path: %s", plugin.Name, result.Path))
+					return nil, false
+				}
+				return &resolver.ResolveResult{Path: ast.Path{Text: absPath, IsAbsolute: true}}, true
+			}
+
+			return &resolver.ResolveResult{Path: ast.Path{Text: result.Namespace + ":" + result.Path}}, true
+		}
+	}
+
+	return nil, true
+}
+
+// This returns a nil result if no plugin loaded the file, and false if a
+// plugin failed (in which case an error has already been logged)
+func runOnLoadPlugins(args parseArgs) (*config.OnLoadResult, bool) {
+	namespace, path := namespaceAndPath(args.keyPath)
+
+	for _, plugin := range args.options.Plugins {
+		for _, onLoad := range plugin.OnLoad {
+			if !pluginFilterMatches(onLoad.Filter, onLoad.Namespace, namespace, path) {
+				continue
+			}
+
+			result, err := onLoad.Callback(config.OnLoadArgs{
+				Path:      path,
+				Namespace: namespace,
+			})
+			if err != nil {
+				args.log.AddRangeError(args.importSource, args.pathRange, fmt.Sprintf("[%s] %s", plugin.Name, err.Error()))
+				return nil, false
+			}
+			if result.Contents != nil {
+				return &result, true
+			}
+		}
+	}
+
+	return nil, true
+}
+
 func loaderFromFileExtension(extensionToLoader map[string]config.Loader, base string) config.Loader {
 	// Pick the loader with the longest matching extension. So if there's an
 	// extension for ".css" and for ".module.css", we want to match the one for
@@ -386,6 +545,11 @@ func ScanBundle(log logging.Log, fs fs.FS, res resolver.Resolver, entryPaths []s
 				jsxFactory:        resolveResult.JSXFactory,
 				jsxFragment:       resolveResult.JSXFragment,
 				strictClassFields: resolveResult.StrictClassFields,
+				pkg: packageInfo{
+					name:    resolveResult.PackageName,
+					version: resolveResult.PackageVersion,
+					absDir:  resolveResult.PackageDir,
+				},
 			}
 			remaining++
 			optionsClone := options
@@ -422,22 +586,41 @@ func ScanBundle(log logging.Log, fs fs.FS, res resolver.Resolver, entryPaths []s
 	}
 
 	// Add any remaining entry points
-	for _, absPath := range entryPaths {
-		prettyPath := res.PrettyPath(absPath)
-		lowerAbsPath := lowerCaseAbsPathForWindows(absPath)
+	for _, entryPath := range entryPaths {
+		// Give plugins a chance to resolve the entry point first
+		resolveResult, ok := runOnResolvePlugins(log, fs, options.Plugins, entryPath, nil, ast.Range{}, fs.Cwd())
+		if !ok {
+			continue
+		}
 
-		if duplicateEntryPoints[lowerAbsPath] {
-			log.AddError(nil, ast.Loc{}, "Duplicate entry point: "+prettyPath)
+		if resolveResult == nil {
+			absPath, ok := fs.Abs(entryPath)
+			if !ok {
+				log.AddError(nil, ast.Loc{}, "Invalid path: "+entryPath)
+				continue
+			}
+			resolveResult = res.ResolveAbs(absPath)
+			if resolveResult == nil {
+				log.AddError(nil, ast.Loc{}, "Could not resolve: "+res.PrettyPath(absPath))
+				continue
+			}
+		} else if resolveResult.IsExternal {
+			log.AddError(nil, ast.Loc{}, "Entry points can't be external: "+entryPath)
 			continue
 		}
 
-		duplicateEntryPoints[lowerAbsPath] = true
-		resolveResult := res.ResolveAbs(absPath)
+		prettyPath := resolveResult.Path.Text
+		visitedKey := resolveResult.Path.Text
+		if resolveResult.Path.IsAbsolute {
+			prettyPath = res.PrettyPath(prettyPath)
+			visitedKey = lowerCaseAbsPathForWindows(visitedKey)
+		}
 
-		if resolveResult == nil {
-			log.AddError(nil, ast.Loc{}, "Could not resolve: "+prettyPath)
+		if duplicateEntryPoints[visitedKey] {
+			log.AddError(nil, ast.Loc{}, "Duplicate entry point: "+prettyPath)
 			continue
 		}
+		duplicateEntryPoints[visitedKey] = true
 
 		sourceIndex := maybeParseFile(*resolveResult, prettyPath, nil, ast.Range{}, "", inputKindEntryPoint)
 		entryPoints = append(entryPoints, sourceIndex)
@@ -446,7 +629,10 @@ func ScanBundle(log logging.Log, fs fs.FS, res resolver.Resolver, entryPaths []s
 	for remaining > 0 {
 		result := <-results
 		remaining--
-		if !result.ok {
+
+		// Stop following imports once the build has been cancelled, but keep
+		// waiting for the files that are already being parsed
+		if !result.ok || options.Cancel.DidCancel() {
 			continue
 		}
 
@@ -472,27 +658,32 @@ func ScanBundle(log logging.Log, fs fs.FS, res resolver.Resolver, entryPaths []s
 						continue
 					}
 
+					prettyPath := resolveResult.Path.Text
+					if resolveResult.Path.IsAbsolute {
+						prettyPath = res.PrettyPath(prettyPath)
+					}
+
+					// Generate metadata about each import
+					if options.AbsMetadataFile != "" {
+						if isFirstImport {
+							isFirstImport = false
+							j.AddString("\n        ")
+						} else {
+							j.AddString(",\n        ")
+						}
+						j.AddString(fmt.Sprintf("{\n          \"path\": %s,\n          \"kind\": %s",
+							printer.QuoteForJSON(prettyPath), printer.QuoteForJSON(metadataImportKind(record.Kind))))
+						if resolveResult.IsExternal {
+							j.AddString(",\n          \"external\": true")
+						}
+						j.AddString("\n        }")
+					}
+
 					if !resolveResult.IsExternal {
 						// Handle a path within the bundle
-						prettyPath := resolveResult.Path.Text
-						if resolveResult.Path.IsAbsolute {
-							prettyPath = res.PrettyPath(prettyPath)
-						}
 						pathRange := source.RangeOfString(record.Loc)
 						sourceIndex := maybeParseFile(*resolveResult, prettyPath, &source, pathRange, "", inputKindNormal)
 						record.SourceIndex = &sourceIndex
-
-						// Generate metadata about each import
-						if options.AbsMetadataFile != "" {
-							if isFirstImport {
-								isFirstImport = false
-								j.AddString("\n        ")
-							} else {
-								j.AddString(",\n        ")
-							}
-							j.AddString(fmt.Sprintf("{\n          \"path\": %s\n        }",
-								printer.QuoteForJSON(prettyPath)))
-						}
 					} else {
 						// If the path to the external module is relative to the source
 						// file, rewrite the path to be relative to the working directory
@@ -520,7 +711,215 @@ func ScanBundle(log logging.Log, fs fs.FS, res resolver.Resolver, entryPaths []s
 		files[source.Index] = result.file
 	}
 
-	return Bundle{fs, res, sources, files, entryPoints}
+	if options.DedupePackages {
+		dedupePackages(fs, sources, files, visited)
+	}
+
+	bundle := Bundle{fs: fs, res: res, sources: sources, files: files, entryPoints: entryPoints}
+	if options.WarnAboutCircularImports || options.AbsMetadataFile != "" {
+		bundle.circularImports = findCircularImports(sources, files)
+		if options.WarnAboutCircularImports {
+			warnAboutCircularImports(log, sources, files, bundle.circularImports, options.AllowedCircularImports)
+		}
+	}
+	return bundle
+}
+
+// Each group of files that import each other is reported using the shortest
+// cycle through the file in the group with the first path. Each step in the
+// cycle is an import of the next file, and the last step imports the first
+// file again.
+type circularImport struct {
+	steps []circularImportStep
+	group []uint32 // All files that import each other, sorted by path
+}
+
+type circularImportStep struct {
+	sourceIndex       uint32
+	importRecordIndex uint32
+}
+
+// Dynamic imports don't cause problems with initialization order since the
+// imported file is evaluated later
+func isCircularImportEdge(record *ast.ImportRecord) bool {
+	return record.SourceIndex != nil && record.Kind != ast.ImportDynamic
+}
+
+// This uses Tarjan's algorithm to find the strongly-connected components of
+// the import graph. Each component with more than one file, or with a file
+// that imports itself, contains at least one cycle.
+func findCircularImports(sources []logging.Source, files []file) []circularImport {
+	order := make([]int, len(files)) // Zero means not visited yet
+	lowLink := make([]int, len(files))
+	onStack := make([]bool, len(files))
+	stack := []uint32{}
+	nextOrder := 1
+	var groups [][]uint32
+
+	var visit func(uint32)
+	visit = func(v uint32) {
+		order[v] = nextOrder
+		lowLink[v] = nextOrder
+		nextOrder++
+		stack = append(stack, v)
+		onStack[v] = true
+		importsItself := false
+
+		for i := range files[v].ast.ImportRecords {
+			record := &files[v].ast.ImportRecords[i]
+			if !isCircularImportEdge(record) {
+				continue
+			}
+			w := *record.SourceIndex
+			if w == v {
+				importsItself = true
+			}
+			if order[w] == 0 {
+				visit(w)
+				if lowLink[w] < lowLink[v] {
+					lowLink[v] = lowLink[w]
+				}
+			} else if onStack[w] && order[w] < lowLink[v] {
+				lowLink[v] = order[w]
+			}
+		}
+
+		if lowLink[v] == order[v] {
+			var group []uint32
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				group = append(group, w)
+				if w == v {
+					break
+				}
+			}
+			if len(group) > 1 || importsItself {
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	for v := range files {
+		if order[v] == 0 {
+			visit(uint32(v))
+		}
+	}
+
+	// Source indices depend on the order files finished parsing, so sort by
+	// path for determinism
+	for _, group := range groups {
+		sort.Slice(group, func(i int, j int) bool {
+			return sources[group[i]].KeyPath.Text < sources[group[j]].KeyPath.Text
+		})
+	}
+	sort.Slice(groups, func(i int, j int) bool {
+		return sources[groups[i][0]].KeyPath.Text < sources[groups[j][0]].KeyPath.Text
+	})
+
+	circularImports := make([]circularImport, len(groups))
+	for i, group := range groups {
+		circularImports[i] = circularImport{steps: shortestCycle(files, group), group: group}
+	}
+	return circularImports
+}
+
+// This does a breadth-first search from the first file in the group until it
+// finds an import of that file
+func shortestCycle(files []file, group []uint32) []circularImportStep {
+	start := group[0]
+	inGroup := make(map[uint32]bool)
+	for _, sourceIndex := range group {
+		inGroup[sourceIndex] = true
+	}
+	reachedBy := make(map[uint32]circularImportStep)
+	visited := map[uint32]bool{start: true}
+	queue := []uint32{start}
+	var last circularImportStep
+
+search:
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for i := range files[v].ast.ImportRecords {
+			record := &files[v].ast.ImportRecords[i]
+			if !isCircularImportEdge(record) || !inGroup[*record.SourceIndex] {
+				continue
+			}
+			w := *record.SourceIndex
+			step := circularImportStep{sourceIndex: v, importRecordIndex: uint32(i)}
+			if w == start {
+				last = step
+				break search
+			}
+			if !visited[w] {
+				visited[w] = true
+				reachedBy[w] = step
+				queue = append(queue, w)
+			}
+		}
+	}
+
+	// Walk backward from the import of the first file
+	steps := []circularImportStep{last}
+	for v := last.sourceIndex; v != start; {
+		step := reachedBy[v]
+		steps = append(steps, step)
+		v = step.sourceIndex
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps
+}
+
+func warnAboutCircularImports(log logging.Log, sources []logging.Source, files []file, circularImports []circularImport, allowed [][]string) {
+	allowedSets := make([]map[string]bool, len(allowed))
+	for i, paths := range allowed {
+		allowedSets[i] = make(map[string]bool)
+		for _, path := range paths {
+			allowedSets[i][lowerCaseAbsPathForWindows(path)] = true
+		}
+	}
+
+	for _, circularImport := range circularImports {
+		// Skip this group if all of its files are allowed together
+		isAllowed := false
+		for _, allowedSet := range allowedSets {
+			isAllowed = true
+			for _, sourceIndex := range circularImport.group {
+				keyPath := sources[sourceIndex].KeyPath
+				if !keyPath.IsAbsolute || !allowedSet[lowerCaseAbsPathForWindows(keyPath.Text)] {
+					isAllowed = false
+					break
+				}
+			}
+			if isAllowed {
+				break
+			}
+		}
+		if isAllowed {
+			continue
+		}
+
+		// The warning is at the first import and the text lists every import
+		sb := strings.Builder{}
+		sb.WriteString("Circular import: ")
+		var ranges []ast.Range
+		for _, step := range circularImport.steps {
+			source := &sources[step.sourceIndex]
+			r := source.RangeOfString(files[step.sourceIndex].ast.ImportRecords[step.importRecordIndex].Loc)
+			loc := logging.LocationOrNil(source, r)
+			ranges = append(ranges, r)
+			sb.WriteString(fmt.Sprintf("%s:%d:%d -> ", loc.File, loc.Line, loc.Column))
+		}
+		sb.WriteString(sources[circularImport.group[0]].PrettyPath)
+		if len(circularImport.group) > len(circularImport.steps) {
+			sb.WriteString(fmt.Sprintf(" (%d files import each other in this group)", len(circularImport.group)))
+		}
+		log.AddRangeWarningWithID("circular-import", &sources[circularImport.steps[0].sourceIndex], ranges[0], sb.String())
+	}
 }
 
 func DefaultExtensionToLoaderMap() map[string]config.Loader {
@@ -544,6 +943,24 @@ type OutputFile struct {
 	// about this file in JSON format. This is a partial JSON file that will be
 	// fully assembled later.
 	jsonMetadataChunk []byte
+
+	// The number of bytes each input file contributed to this output file. This
+	// is used to find out how much duplicate packages add to the bundle.
+	bytesInOutput map[uint32]int
+
+	// If this is the JavaScript file generated for an entry point, this is the
+	// source index of that entry point and the paths of every chunk it loads,
+	// directly or indirectly. The relative paths are relative to the output
+	// directory.
+	entryPointSourceIndex    *uint32
+	transitiveImportRelPaths []string
+
+	isSourceMap bool
+
+	// If "AbsManifestFile" is present, these will be filled out for the output
+	// files of chunks. The relative paths are relative to the output directory.
+	dynamicImportRelPaths []string
+	assetAbsPaths         []string
 }
 
 type lineColumnOffset struct {
@@ -571,7 +988,13 @@ type compileResult struct {
 	quotedSource string
 }
 
-func (b *Bundle) Compile(log logging.Log, options config.Options) []OutputFile {
+// This returns the property mangling cache that should be passed to the next
+// build to keep mangled names stable. It's nil if properties weren't mangled.
+func (b *Bundle) MangleCache() map[string]interface{} {
+	return b.mangleCache
+}
+
+func applyLinkerDefaults(options *config.Options) {
 	if options.ExtensionToLoader == nil {
 		options.ExtensionToLoader = DefaultExtensionToLoaderMap()
 	}
@@ -580,6 +1003,14 @@ func (b *Bundle) Compile(log logging.Log, options config.Options) []OutputFile {
 	if options.IsBundling && options.OutputFormat == config.FormatPreserve {
 		options.OutputFormat = config.FormatESModule
 	}
+}
+
+func (b *Bundle) Compile(log logging.Log, options config.Options) []OutputFile {
+	if options.Cancel.DidCancel() {
+		return []OutputFile{}
+	}
+
+	applyLinkerDefaults(&options)
 
 	// Determine the lowest common ancestor of all entry points
 	entryPointAbsPaths := make([]string, 0, len(b.entryPoints))
@@ -591,6 +1022,12 @@ func (b *Bundle) Compile(log logging.Log, options config.Options) []OutputFile {
 	}
 	lcaAbsPath := lowestCommonAncestorDirectory(b.fs, entryPointAbsPaths)
 
+	// Property names must be mangled the same way in every linking operation
+	var mangledProps map[string]string
+	if options.MangleProps != nil {
+		mangledProps, b.mangleCache = mangleProps(b.files, options.MangleCache)
+	}
+
 	type linkGroup struct {
 		outputFiles    []OutputFile
 		reachableFiles []uint32
@@ -600,6 +1037,7 @@ func (b *Bundle) Compile(log logging.Log, options config.Options) []OutputFile {
 	if options.CodeSplitting {
 		// If code splitting is enabled, link all entry points together
 		c := newLinkerContext(&options, log, b.fs, b.res, b.sources, b.files, b.entryPoints, lcaAbsPath)
+		c.mangledProps = mangledProps
 		resultGroups = []linkGroup{{
 			outputFiles:    c.link(),
 			reachableFiles: c.reachableFiles,
@@ -612,6 +1050,7 @@ func (b *Bundle) Compile(log logging.Log, options config.Options) []OutputFile {
 			waitGroup.Add(1)
 			go func(i int, entryPoint uint32) {
 				c := newLinkerContext(&options, log, b.fs, b.res, b.sources, b.files, []uint32{entryPoint}, lcaAbsPath)
+				c.mangledProps = mangledProps
 				resultGroups[i] = linkGroup{
 					outputFiles:    c.link(),
 					reachableFiles: c.reachableFiles,
@@ -622,12 +1061,28 @@ func (b *Bundle) Compile(log logging.Log, options config.Options) []OutputFile {
 		waitGroup.Wait()
 	}
 
+	// Stop now if the build was cancelled while linking
+	if options.Cancel.DidCancel() {
+		return []OutputFile{}
+	}
+
 	// Join the results in entry point order for determinism
 	var outputFiles []OutputFile
 	for _, group := range resultGroups {
 		outputFiles = append(outputFiles, group.outputFiles...)
 	}
 
+	if options.IsBundling {
+		bundles := make([][]OutputFile, len(resultGroups))
+		for i, group := range resultGroups {
+			bundles[i] = group.outputFiles
+		}
+		b.warnAboutDuplicatePackages(log, bundles)
+	}
+	if len(options.SizeLimits) > 0 {
+		b.checkSizeLimits(log, options, outputFiles)
+	}
+
 	// Also generate the metadata file if necessary
 	if options.AbsMetadataFile != "" {
 		outputFiles = append(outputFiles, OutputFile{
@@ -636,6 +1091,14 @@ func (b *Bundle) Compile(log logging.Log, options config.Options) []OutputFile {
 		})
 	}
 
+	// Also generate the manifest file if necessary
+	if options.AbsManifestFile != "" {
+		outputFiles = append(outputFiles, OutputFile{
+			AbsPath:  options.AbsManifestFile,
+			Contents: b.generateManifestJSON(options, outputFiles),
+		})
+	}
+
 	if !options.WriteToStdout {
 		// Make sure an output file never overwrites an input file
 		sourceAbsPaths := make(map[string]uint32)
@@ -719,6 +1182,35 @@ func lowestCommonAncestorDirectory(fs fs.FS, absPaths []string) string {
 	return lowestAbsDir
 }
 
+// This is incremented whenever the format of the metadata file changes in a
+// way that's not backward-compatible, or when fields are added to it.
+const metadataVersion = 2
+
+func metadataImportKind(kind ast.ImportKind) string {
+	switch kind {
+	case ast.ImportStmt:
+		return "import-statement"
+	case ast.ImportRequire:
+		return "require-call"
+	case ast.ImportDynamic:
+		return "dynamic-import"
+	default:
+		panic("Internal error")
+	}
+}
+
+func sortedUniqueStrings(values []string) []string {
+	sort.Strings(values)
+	end := 0
+	for i, value := range values {
+		if i == 0 || value != values[end-1] {
+			values[end] = value
+			end++
+		}
+	}
+	return values[:end]
+}
+
 func (b *Bundle) generateMetadataJSON(results []OutputFile) []byte {
 	// Sort files by key path for determinism
 	sorted := make(indexAndPathArray, 0, len(b.sources))
@@ -730,7 +1222,7 @@ func (b *Bundle) generateMetadataJSON(results []OutputFile) []byte {
 	sort.Sort(sorted)
 
 	j := printer.Joiner{}
-	j.AddString("{\n  \"inputs\": {")
+	j.AddString(fmt.Sprintf("{\n  \"version\": %d,\n  \"inputs\": {", metadataVersion))
 
 	// Write inputs
 	for i, item := range sorted {
@@ -759,7 +1251,113 @@ func (b *Bundle) generateMetadataJSON(results []OutputFile) []byte {
 		}
 	}
 
-	j.AddString("\n  }\n}\n")
+	j.AddString("\n  },\n  \"circularImports\": [")
+
+	// Write each cycle as the list of files in import order
+	for i, circularImport := range b.circularImports {
+		if i > 0 {
+			j.AddString(",")
+		}
+		j.AddString("\n    [")
+		for k, step := range circularImport.steps {
+			if k > 0 {
+				j.AddString(",")
+			}
+			j.AddString("\n      " + printer.QuoteForJSON(b.sources[step.sourceIndex].PrettyPath))
+		}
+		j.AddString("\n    ]")
+	}
+	if len(b.circularImports) > 0 {
+		j.AddString("\n  ")
+	}
+
+	j.AddString("]\n}\n")
+	return j.Done()
+}
+
+// The manifest maps the path of each entry point to the output files needed to
+// load it. All output paths are relative to the output directory so they can
+// be turned into URLs. The "imports" array lists every chunk the entry point
+// loads, directly or indirectly. The "dynamicImports" array lists the chunks
+// loaded using "import()" and the "assets" array lists the files from the
+// "file" loader, both for the entry point and for those chunks.
+func (b *Bundle) generateManifestJSON(options config.Options, results []OutputFile) []byte {
+	relPath := func(absPath string) string {
+		if relPath, ok := b.fs.Rel(options.AbsOutputDir, absPath); ok {
+			absPath = relPath
+		}
+		return strings.ReplaceAll(absPath, "\\", "/")
+	}
+
+	// Chunks are found by their path when collecting assets
+	chunksByRelPath := make(map[string]*OutputFile)
+	var entryPoints []*OutputFile
+	for i := range results {
+		result := &results[i]
+		if result.isSourceMap {
+			continue
+		}
+		chunksByRelPath[relPath(result.AbsPath)] = result
+		if result.entryPointSourceIndex != nil {
+			entryPoints = append(entryPoints, result)
+		}
+	}
+
+	// Sort entry points by path for determinism
+	sort.SliceStable(entryPoints, func(i int, j int) bool {
+		return b.sources[*entryPoints[i].entryPointSourceIndex].PrettyPath < b.sources[*entryPoints[j].entryPointSourceIndex].PrettyPath
+	})
+
+	j := printer.Joiner{}
+	j.AddString("{")
+	addArray := func(name string, values []string) {
+		j.AddString(fmt.Sprintf("\n    %s: [", printer.QuoteForJSON(name)))
+		for i, value := range values {
+			if i > 0 {
+				j.AddString(",")
+			}
+			j.AddString("\n      " + printer.QuoteForJSON(value))
+		}
+		if len(values) > 0 {
+			j.AddString("\n    ")
+		}
+		j.AddString("]")
+	}
+
+	for i, entryPoint := range entryPoints {
+		if i > 0 {
+			j.AddString(",")
+		}
+		j.AddString(fmt.Sprintf("\n  %s: {\n    \"file\": %s,",
+			printer.QuoteForJSON(b.sources[*entryPoint.entryPointSourceIndex].PrettyPath),
+			printer.QuoteForJSON(relPath(entryPoint.AbsPath))))
+
+		var assets []string
+		dynamicImports := append([]string{}, entryPoint.dynamicImportRelPaths...)
+		for _, assetAbsPath := range entryPoint.assetAbsPaths {
+			assets = append(assets, relPath(assetAbsPath))
+		}
+		for _, importRelPath := range entryPoint.transitiveImportRelPaths {
+			if chunk, ok := chunksByRelPath[importRelPath]; ok {
+				dynamicImports = append(dynamicImports, chunk.dynamicImportRelPaths...)
+				for _, assetAbsPath := range chunk.assetAbsPaths {
+					assets = append(assets, relPath(assetAbsPath))
+				}
+			}
+		}
+
+		addArray("imports", entryPoint.transitiveImportRelPaths)
+		j.AddString(",")
+		addArray("dynamicImports", sortedUniqueStrings(dynamicImports))
+		j.AddString(",")
+		addArray("assets", sortedUniqueStrings(assets))
+		j.AddString("\n  }")
+	}
+	if len(entryPoints) > 0 {
+		j.AddString("\n")
+	}
+
+	j.AddString("}\n")
 	return j.Done()
 }
 
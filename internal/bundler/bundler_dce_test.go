@@ -3,7 +3,9 @@ package bundler
 import (
 	"testing"
 
+	"github.com/evanw/esbuild/internal/ast"
 	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/lexer"
 )
 
 func TestPackageJsonSideEffectsFalseKeepNamedImportES6(t *testing.T) {
@@ -872,3 +874,146 @@ console.log(f);
 		},
 	})
 }
+
+func TestRemoveUnusedNoSideEffectsCalls(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import makeDefault, {make, makeAsync, keep} from './lib'
+
+				let a = make(1)
+				let b = makeAsync()
+				let c = makeDefault()
+				let d = local()
+				let e = keep()
+				let f = make(sideEffect())
+				let g = make(2)
+				console.log(g)
+
+				/* @__NO_SIDE_EFFECTS__ */ function local() {}
+			`,
+			"/lib.js": `
+				/* @__NO_SIDE_EFFECTS__ */ export function make(x) { return {x} }
+				export /* @__NO_SIDE_EFFECTS__ */ async function makeAsync() {}
+				/* @__NO_SIDE_EFFECTS__ */ export default function() {}
+				export function keep() {}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `// /lib.js
+/* @__NO_SIDE_EFFECTS__ */ function make(x) {
+  return {x};
+}
+function keep() {
+}
+
+// /entry.js
+let e = keep();
+let f = make(sideEffect());
+let g = make(2);
+console.log(g);
+`,
+		},
+	})
+}
+
+func TestRemoveImportsOnlyUsedInDeadCode(t *testing.T) {
+	defines := config.ProcessDefines(map[string]config.DefineData{
+		"process.env.NODE_ENV": {
+			DefineFunc: func(config.FindSymbol) ast.E { return &ast.EString{Value: lexer.StringToUTF16("production")} },
+		},
+	})
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {devTools} from './dev'
+				import * as devNs from './dev-ns'
+				import {live} from './live'
+				import {shared} from './shared'
+				if (process.env.NODE_ENV !== 'production') {
+					devTools(live)
+					devNs.install()
+					shared()
+				}
+				console.log(live, shared)
+			`,
+			"/dev.js": `
+				export function devTools() {}
+				console.log('dev side effect')
+			`,
+			"/dev-ns.js": `
+				export function install() {}
+				console.log('dev-ns side effect')
+			`,
+			"/live.js": `
+				export let live = 1
+				console.log('live side effect')
+			`,
+			"/shared.js": `
+				export function shared() {}
+				console.log('shared side effect')
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			Defines:       &defines,
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `// /live.js
+let live2 = 1;
+console.log("live side effect");
+
+// /shared.js
+function shared2() {
+}
+console.log("shared side effect");
+
+// /entry.js
+console.log(live2, shared2);
+`,
+		},
+	})
+}
+
+func TestKeepImportsUsedInDeadCodeWithHoistedVar(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {devTools} from './dev'
+				if (false) {
+					var tools = devTools()
+				}
+				console.log(tools)
+			`,
+			"/dev.js": `
+				export function devTools() {}
+				console.log('dev side effect')
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `// /dev.js
+function devTools() {
+}
+console.log("dev side effect");
+
+// /entry.js
+if (false) {
+  var tools = devTools();
+}
+console.log(tools);
+`,
+		},
+	})
+}
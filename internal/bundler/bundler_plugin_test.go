@@ -0,0 +1,211 @@
+package bundler
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/config"
+)
+
+func TestPluginVirtualModule(t *testing.T) {
+	contents := `export let version = "1.0.0"`
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {version} from 'virtual:version'
+				console.log(version)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+			Plugins: []config.Plugin{{
+				Name: "virtual",
+				OnResolve: []config.OnResolveCallback{{
+					Filter: regexp.MustCompile(`^virtual:`),
+					Callback: func(args config.OnResolveArgs) (config.OnResolveResult, error) {
+						return config.OnResolveResult{Path: args.Path[len("virtual:"):], Namespace: "virtual"}, nil
+					},
+				}},
+				OnLoad: []config.OnLoadCallback{{
+					Filter:    regexp.MustCompile(`.*`),
+					Namespace: "virtual",
+					Callback: func(args config.OnLoadArgs) (config.OnLoadResult, error) {
+						return config.OnLoadResult{Contents: &contents}, nil
+					},
+				}},
+			}},
+		},
+		expected: map[string]string{
+			"/out.js": `// virtual:version
+let version = "1.0.0";
+
+// /entry.js
+console.log(version);
+`,
+		},
+	})
+}
+
+func TestPluginResolveEntryPoint(t *testing.T) {
+	contents := `
+		import {fn} from "/lib.js"
+		fn()
+	`
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/lib.js": `export function fn() {}`,
+		},
+		entryPaths: []string{"virtual-entry"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+			Plugins: []config.Plugin{{
+				Name: "virtual",
+				OnResolve: []config.OnResolveCallback{{
+					Filter: regexp.MustCompile(`^virtual-entry$`),
+					Callback: func(args config.OnResolveArgs) (config.OnResolveResult, error) {
+						if args.Importer != "" || args.Namespace != "file" {
+							return config.OnResolveResult{}, errors.New("Unexpected importer")
+						}
+						return config.OnResolveResult{Path: args.Path, Namespace: "virtual"}, nil
+					},
+				}},
+				OnLoad: []config.OnLoadCallback{{
+					Filter:    regexp.MustCompile(`.*`),
+					Namespace: "virtual",
+					Callback: func(args config.OnLoadArgs) (config.OnLoadResult, error) {
+						return config.OnLoadResult{Contents: &contents}, nil
+					},
+				}},
+			}},
+		},
+		expected: map[string]string{
+			"/out.js": `// /lib.js
+function fn() {
+}
+
+// virtual:virtual-entry
+fn();
+`,
+		},
+	})
+}
+
+func TestPluginResolveToFile(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {fn} from 'alias'
+				import 'ignored'
+				fn()
+			`,
+			"/lib/fn.js": `
+				export function fn() {}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+			Plugins: []config.Plugin{{
+				Name: "alias",
+				OnResolve: []config.OnResolveCallback{
+					{
+						Filter: regexp.MustCompile(`^alias$`),
+						Callback: func(args config.OnResolveArgs) (config.OnResolveResult, error) {
+							return config.OnResolveResult{Path: "/lib/fn.js"}, nil
+						},
+					},
+					{
+						Filter: regexp.MustCompile(`^ignored$`),
+						Callback: func(args config.OnResolveArgs) (config.OnResolveResult, error) {
+							return config.OnResolveResult{Path: args.Path, External: true}, nil
+						},
+					},
+				},
+			}},
+		},
+		expected: map[string]string{
+			"/out.js": `// /lib/fn.js
+function fn() {
+}
+
+// /entry.js
+import "ignored";
+fn();
+`,
+		},
+	})
+}
+
+func TestPluginErrors(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import 'fails-to-resolve'
+				import 'virtual:fails-to-load'
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+			Plugins: []config.Plugin{{
+				Name: "errors",
+				OnResolve: []config.OnResolveCallback{
+					{
+						Filter: regexp.MustCompile(`^fails-to-resolve$`),
+						Callback: func(args config.OnResolveArgs) (config.OnResolveResult, error) {
+							return config.OnResolveResult{}, errors.New("Resolve failed")
+						},
+					},
+					{
+						Filter: regexp.MustCompile(`^virtual:`),
+						Callback: func(args config.OnResolveArgs) (config.OnResolveResult, error) {
+							return config.OnResolveResult{Path: args.Path[len("virtual:"):], Namespace: "virtual"}, nil
+						},
+					},
+				},
+				OnLoad: []config.OnLoadCallback{{
+					Filter:    regexp.MustCompile(`^fails-to-load$`),
+					Namespace: "virtual",
+					Callback: func(args config.OnLoadArgs) (config.OnLoadResult, error) {
+						return config.OnLoadResult{}, errors.New("Load failed")
+					},
+				}},
+			}},
+		},
+		expectedScanLog: `/entry.js: error: [errors] Resolve failed
+/entry.js: error: [errors] Load failed
+`,
+	})
+}
+
+func TestPluginNoContents(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import 'virtual:not-loaded'
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+			Plugins: []config.Plugin{{
+				Name: "virtual",
+				OnResolve: []config.OnResolveCallback{{
+					Filter: regexp.MustCompile(`^virtual:`),
+					Callback: func(args config.OnResolveArgs) (config.OnResolveResult, error) {
+						return config.OnResolveResult{Path: args.Path[len("virtual:"):], Namespace: "virtual"}, nil
+					},
+				}},
+			}},
+		},
+		expectedScanLog: `/entry.js: error: Could not load "virtual:not-loaded" because no plugin returned its contents
+`,
+	})
+}
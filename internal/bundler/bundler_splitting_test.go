@@ -1,6 +1,7 @@
 package bundler
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/evanw/esbuild/internal/config"
@@ -286,9 +287,14 @@ func TestSplittingAssignToLocal(t *testing.T) {
 		expected: map[string]string{
 			"/out/a.js": `import {
   foo,
-  setFoo
+  chunk_xL6KqlYO
 } from "./chunk.xL6KqlYO.js";
 
+// /shared.js
+function setFoo(value) {
+  chunk_xL6KqlYO.foo = value;
+}
+
 // /a.js
 setFoo(123);
 console.log(foo);
@@ -300,16 +306,198 @@ console.log(foo);
 // /b.js
 console.log(foo);
 `,
-			"/out/chunk.xL6KqlYO.js": `// /shared.js
+			"/out/chunk.xL6KqlYO.js": `var chunk_xL6KqlYO = {
+  get foo() {
+    return foo;
+  },
+  set foo(value) {
+    foo = value;
+  }
+};
+
+// /shared.js
 let foo;
-function setFoo(value) {
-  foo = value;
-}
 
 export {
   foo,
-  setFoo
+  chunk_xL6KqlYO
+};
+`,
+		},
+	})
+}
+
+func TestSplittingCrossChunkAssignmentsIntoES6(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {count, name, inc} from "./shared.js"
+				inc()
+				console.log(count, name)
+			`,
+			"/b.js": `
+				import {count, name, load} from "./shared.js"
+				load({name: "b"})
+				console.log(count, name)
+			`,
+			"/shared.js": `
+				export let count = 0
+				export let name = ""
+				export function inc() { count++; count += 2 }
+				export function load(obj) {
+					({name} = obj);
+					for (name of [obj.name]) ;
+					[count] = [1]
+				}
+			`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+		},
+		expected: map[string]string{
+			"/out/a.js": `import {
+  count,
+  name,
+  chunk_xL6KqlYO
+} from "./chunk.xL6KqlYO.js";
+
+// /shared.js
+function inc() {
+  chunk_xL6KqlYO.count++;
+  chunk_xL6KqlYO.count += 2;
+}
+
+// /a.js
+inc();
+console.log(count, name);
+`,
+			"/out/b.js": `import {
+  count,
+  name,
+  chunk_xL6KqlYO
+} from "./chunk.xL6KqlYO.js";
+
+// /shared.js
+function load(obj) {
+  ({name: chunk_xL6KqlYO.name} = obj);
+  for (chunk_xL6KqlYO.name of [obj.name])
+    ;
+  [chunk_xL6KqlYO.count] = [1];
+}
+
+// /b.js
+load({name: "b"});
+console.log(count, name);
+`,
+			"/out/chunk.xL6KqlYO.js": `var chunk_xL6KqlYO = {
+  get count() {
+    return count;
+  },
+  set count(value) {
+    count = value;
+  },
+  get name() {
+    return name;
+  },
+  set name(value) {
+    name = value;
+  }
+};
+
+// /shared.js
+let count = 0;
+let name = "";
+
+export {
+  count,
+  name,
+  chunk_xL6KqlYO
+};
+`,
+		},
+	})
+}
+
+func TestSplittingCrossChunkAssignmentsIntoCommonJS(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {count, name, inc} from "./shared.js"
+				inc()
+				console.log(count, name)
+			`,
+			"/b.js": `
+				import {count, name, load} from "./shared.js"
+				load({name: "b"})
+				console.log(count, name)
+			`,
+			"/shared.js": `
+				export let count = 0
+				export let name = ""
+				export function inc() { count++; count += 2 }
+				export function load(obj) {
+					({name} = obj);
+					for (name of [obj.name]) ;
+					[count] = [1]
+				}
+			`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatCommonJS,
+			AbsOutputDir:  "/out",
+		},
+		expected: map[string]string{
+			"/out/a.js": `var chunk_xL6KqlYO = require("./chunk.xL6KqlYO.js");
+
+// /shared.js
+function inc() {
+  chunk_xL6KqlYO.count++;
+  chunk_xL6KqlYO.count += 2;
+}
+
+// /a.js
+inc();
+console.log(chunk_xL6KqlYO.count, chunk_xL6KqlYO.name);
+`,
+			"/out/b.js": `var chunk_xL6KqlYO = require("./chunk.xL6KqlYO.js");
+
+// /shared.js
+function load(obj) {
+  ({name: chunk_xL6KqlYO.name} = obj);
+  for (chunk_xL6KqlYO.name of [obj.name])
+    ;
+  [chunk_xL6KqlYO.count] = [1];
+}
+
+// /b.js
+load({name: "b"});
+console.log(chunk_xL6KqlYO.count, chunk_xL6KqlYO.name);
+`,
+			"/out/chunk.xL6KqlYO.js": `module.exports = {
+  get count() {
+    return count;
+  },
+  set count(value) {
+    count = value;
+  },
+  get name() {
+    return name;
+  },
+  set name(value) {
+    name = value;
+  }
 };
+
+// /shared.js
+let count = 0;
+let name = "";
 `,
 		},
 	})
@@ -413,3 +601,949 @@ export {
 		},
 	})
 }
+
+func TestSplittingSharedES6IntoCommonJS(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo, bar} from "./shared.js"
+				console.log(foo, bar)
+				export let a = 1
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				import "./side-effect.js"
+				console.log(foo)
+			`,
+			"/shared.js": `
+				export let foo = 123
+				export function bar() {}
+			`,
+			"/side-effect.js": `console.log('side effect')`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatCommonJS,
+			AbsOutputDir:  "/out",
+		},
+		expected: map[string]string{
+			"/out/a.js": `var chunk_xL6KqlYO = require("./chunk.xL6KqlYO.js");
+
+// /shared.js
+function bar() {
+}
+
+// /a.js
+__export(exports, {
+  a: () => a
+});
+console.log(chunk_xL6KqlYO.foo, bar);
+let a = 1;
+`,
+			"/out/b.js": `var chunk_xL6KqlYO = require("./chunk.xL6KqlYO.js");
+
+// /side-effect.js
+console.log("side effect");
+
+// /b.js
+console.log(chunk_xL6KqlYO.foo);
+`,
+			"/out/chunk.xL6KqlYO.js": `module.exports = {
+  get foo() {
+    return foo;
+  }
+};
+
+// /shared.js
+let foo = 123;
+`,
+		},
+	})
+}
+
+func TestSplittingEntryPointRequiredByEntryPointIntoCommonJS(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				const b = require("./b.js")
+				console.log(b.x)
+			`,
+			"/b.js": `export let x = 1`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatCommonJS,
+			AbsOutputDir:  "/out",
+		},
+		expected: map[string]string{
+			"/out/a.js": `var chunk_xL6KqlYO = require("./chunk.xL6KqlYO.js");
+
+// /a.js
+const b = chunk_xL6KqlYO.require_b();
+console.log(b.x);
+`,
+			"/out/b.js": `var chunk_xL6KqlYO = require("./chunk.xL6KqlYO.js");
+
+// /b.js
+module.exports = chunk_xL6KqlYO.require_b();
+`,
+			"/out/chunk.xL6KqlYO.js": `module.exports = {
+  get require_b() {
+    return require_b;
+  }
+};
+
+// /b.js
+var require_b = __commonJS((exports) => {
+  __export(exports, {
+    x: () => x
+  });
+  let x = 1;
+});
+`,
+		},
+	})
+}
+
+func TestSplittingSharedES6IntoIIFE(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+				export let a = 1
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+			`,
+			"/shared.js": `export let foo = 123`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatIIFE,
+			ModuleName:    "lib",
+			AbsOutputDir:  "/out",
+		},
+		expected: map[string]string{
+			"/out/a.js": `var lib = ((factory) => {
+  var chunks = self.__esbuildChunks || (self.__esbuildChunks = {});
+  var loaded = self.__esbuildChunkExports || (self.__esbuildChunkExports = {});
+  var loading = self.__esbuildChunkLoads || (self.__esbuildChunkLoads = {});
+  var base = document.currentScript.src;
+  var requireChunk = (key) => key in loaded ? loaded[key] : loaded[key] = chunks[key](requireChunk);
+  var load = ([key, path]) => key in chunks || loading[key] || (loading[key] = new Promise((resolve, reject) => {
+    var script = document.createElement("script");
+    script.src = new URL(path, base);
+    script.onload = resolve;
+    script.onerror = reject;
+    document.head.appendChild(script);
+  }));
+  return Promise.all([["chunk.xL6KqlYO.js", "./chunk.xL6KqlYO.js"]].map(load)).then(() => factory(requireChunk));
+})((__requireChunk) => {
+  var chunk_xL6KqlYO = __requireChunk("chunk.xL6KqlYO.js");
+
+  // /a.js
+  var require_a = __commonJS((exports) => {
+    __export(exports, {
+      a: () => a
+    });
+    console.log(chunk_xL6KqlYO.foo);
+    let a = 1;
+  });
+  return require_a();
+});
+`,
+			"/out/b.js": `var lib = ((factory) => {
+  var chunks = self.__esbuildChunks || (self.__esbuildChunks = {});
+  var loaded = self.__esbuildChunkExports || (self.__esbuildChunkExports = {});
+  var loading = self.__esbuildChunkLoads || (self.__esbuildChunkLoads = {});
+  var base = document.currentScript.src;
+  var requireChunk = (key) => key in loaded ? loaded[key] : loaded[key] = chunks[key](requireChunk);
+  var load = ([key, path]) => key in chunks || loading[key] || (loading[key] = new Promise((resolve, reject) => {
+    var script = document.createElement("script");
+    script.src = new URL(path, base);
+    script.onload = resolve;
+    script.onerror = reject;
+    document.head.appendChild(script);
+  }));
+  return Promise.all([["chunk.xL6KqlYO.js", "./chunk.xL6KqlYO.js"]].map(load)).then(() => factory(requireChunk));
+})((__requireChunk) => {
+  var chunk_xL6KqlYO = __requireChunk("chunk.xL6KqlYO.js");
+
+  // /b.js
+  console.log(chunk_xL6KqlYO.foo);
+});
+`,
+			"/out/chunk.xL6KqlYO.js": `(self.__esbuildChunks || (self.__esbuildChunks = {}))["chunk.xL6KqlYO.js"] = (__requireChunk) => {
+  // /shared.js
+  let foo = 123;
+
+  return {
+    get foo() {
+      return foo;
+    }
+  };
+};
+`,
+		},
+	})
+}
+
+func TestSplittingManualChunks(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo} from "foo"
+				import {bar} from "bar"
+				console.log(foo, bar)
+			`,
+			"/b.js": `
+				import {bar} from "bar"
+				import {shared} from "./shared.js"
+				console.log(bar, shared)
+			`,
+			"/c.js": `
+				import {shared} from "./shared.js"
+				console.log(shared)
+			`,
+			"/shared.js":                 `export let shared = 1`,
+			"/node_modules/foo/index.js": `export let foo = 2`,
+			"/node_modules/bar/index.js": `
+				import {shared} from "../../shared.js"
+				export let bar = shared + 3
+			`,
+		},
+		entryPaths: []string{"/a.js", "/b.js", "/c.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			ManualChunks: []config.ManualChunk{
+				{Name: "vendor", Pattern: regexp.MustCompile("^(?:/node_modules/.*)$")},
+			},
+		},
+		expected: map[string]string{
+			"/out/a.js": `import {
+  bar2,
+  foo2
+} from "./vendor.js";
+
+// /a.js
+console.log(foo2, bar2);
+`,
+			"/out/b.js": `import {
+  bar2,
+  shared2
+} from "./vendor.js";
+
+// /b.js
+console.log(bar2, shared2);
+`,
+			"/out/c.js": `import {
+  shared2
+} from "./vendor.js";
+
+// /c.js
+console.log(shared2);
+`,
+			"/out/vendor.js": `// /shared.js
+let shared2 = 1;
+
+// /node_modules/bar/index.js
+let bar2 = shared2 + 3;
+
+// /node_modules/foo/index.js
+let foo2 = 2;
+
+export {
+  bar2,
+  foo2,
+  shared2
+};
+`,
+		},
+	})
+}
+
+func TestSplittingMinChunkSize(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {ab} from "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(ab, abc)
+			`,
+			"/b.js": `
+				import {ab} from "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(ab, abc)
+			`,
+			"/c.js": `
+				import {abc} from "./abc.js"
+				console.log(abc)
+			`,
+			"/ab.js":  `export let ab = 1`,
+			"/abc.js": `export let abc = 2`,
+		},
+		entryPaths: []string{"/a.js", "/b.js", "/c.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			MinChunkSize:  20,
+		},
+		expected: map[string]string{
+			"/out/a.js": `import {
+  ab2,
+  abc2
+} from "./chunk.Potp7zZH.js";
+
+// /a.js
+console.log(ab2, abc2);
+`,
+			"/out/b.js": `import {
+  ab2,
+  abc2
+} from "./chunk.Potp7zZH.js";
+
+// /b.js
+console.log(ab2, abc2);
+`,
+			"/out/c.js": `import {
+  abc2
+} from "./chunk.Potp7zZH.js";
+
+// /c.js
+console.log(abc2);
+`,
+			"/out/chunk.Potp7zZH.js": `// /ab.js
+let ab2 = 1;
+
+// /abc.js
+let abc2 = 2;
+
+export {
+  ab2,
+  abc2
+};
+`,
+		},
+	})
+}
+
+func TestSplittingMinChunkSizeCountsOnlyPartsInChunk(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {ab, onlyA} from "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(ab, abc, onlyA())
+			`,
+			"/b.js": `
+				import {ab} from "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(ab, abc)
+			`,
+			"/c.js": `
+				import {abc} from "./abc.js"
+				console.log(abc)
+			`,
+			"/ab.js": `
+				export let ab = 1
+				export function onlyA() {
+					return "this function is only used by a.js, so it isn't in the shared chunk"
+				}
+			`,
+			"/abc.js": `export let abc = 2`,
+		},
+		entryPaths: []string{"/a.js", "/b.js", "/c.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			MinChunkSize:  40,
+		},
+		expected: map[string]string{
+			"/out/a.js": `import {
+  ab2,
+  abc2
+} from "./chunk.Potp7zZH.js";
+
+// /ab.js
+function onlyA() {
+  return "this function is only used by a.js, so it isn't in the shared chunk";
+}
+
+// /a.js
+console.log(ab2, abc2, onlyA());
+`,
+			"/out/b.js": `import {
+  ab2,
+  abc2
+} from "./chunk.Potp7zZH.js";
+
+// /b.js
+console.log(ab2, abc2);
+`,
+			"/out/c.js": `import {
+  abc2
+} from "./chunk.Potp7zZH.js";
+
+// /c.js
+console.log(abc2);
+`,
+			"/out/chunk.Potp7zZH.js": `// /ab.js
+let ab2 = 1;
+
+// /abc.js
+let abc2 = 2;
+
+export {
+  ab2,
+  abc2
+};
+`,
+		},
+	})
+}
+
+func TestSplittingMinChunkSizeSideEffects(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(abc)
+			`,
+			"/b.js": `
+				import "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(abc)
+			`,
+			"/c.js": `
+				import {abc} from "./abc.js"
+				console.log(abc)
+			`,
+			"/ab.js":  `console.log("ab")`,
+			"/abc.js": `export let abc = 2`,
+		},
+		entryPaths: []string{"/a.js", "/b.js", "/c.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			MinChunkSize:  20,
+		},
+		expected: map[string]string{
+			"/out/a.js": `import {
+  abc2
+} from "./chunk.Potp7zZH.js";
+import "./chunk.xL6KqlYO.js";
+
+// /a.js
+console.log(abc2);
+`,
+			"/out/b.js": `import {
+  abc2
+} from "./chunk.Potp7zZH.js";
+import "./chunk.xL6KqlYO.js";
+
+// /b.js
+console.log(abc2);
+`,
+			"/out/c.js": `import {
+  abc2
+} from "./chunk.Potp7zZH.js";
+
+// /c.js
+console.log(abc2);
+`,
+			"/out/chunk.xL6KqlYO.js": `// /ab.js
+console.log("ab");
+`,
+			"/out/chunk.Potp7zZH.js": `// /abc.js
+let abc2 = 2;
+
+export {
+  abc2
+};
+`,
+		},
+	})
+}
+
+func TestSplittingPreloadDynamicImports(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {shared} from "./shared.js"
+				import("./c.js").then(c => console.log(shared, c))
+			`,
+			"/b.js": `
+				import {shared} from "./shared.js"
+				import {dep} from "./dep.js"
+				console.log(shared, dep)
+			`,
+			"/c.js": `
+				import {shared} from "./shared.js"
+				import {dep} from "./dep.js"
+				export let c = shared + dep
+			`,
+			"/shared.js": `export let shared = 1`,
+			"/dep.js":    `export let dep = 2`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:            true,
+			CodeSplitting:         true,
+			PreloadDynamicImports: true,
+			OutputFormat:          config.FormatESModule,
+			AbsOutputDir:          "/out",
+			AbsMetadataFile:       "/out/meta.json",
+		},
+		expected: map[string]string{
+			"/out/a.js": `import {
+  shared2
+} from "./chunk.Potp7zZH.js";
+
+// /a.js
+__preload(() => import("./c.js"), ["./chunk.UQ2nPdga.js"], import.meta.url).then((c2) => console.log(shared2, c2));
+`,
+			"/out/b.js": `import {
+  shared2
+} from "./chunk.Potp7zZH.js";
+import {
+  dep2
+} from "./chunk.UQ2nPdga.js";
+
+// /b.js
+console.log(shared2, dep2);
+`,
+			"/out/c.js": `import {
+  shared2
+} from "./chunk.Potp7zZH.js";
+import {
+  dep2
+} from "./chunk.UQ2nPdga.js";
+
+// /c.js
+let c = shared2 + dep2;
+export {
+  c
+};
+`,
+			"/out/chunk.UQ2nPdga.js": `// /dep.js
+let dep2 = 2;
+
+export {
+  dep2
+};
+`,
+			"/out/chunk.Potp7zZH.js": `// /shared.js
+let shared2 = 1;
+
+export {
+  shared2
+};
+`,
+			"/out/meta.json": `{
+  "version": 2,
+  "inputs": {
+    "/a.js": {
+      "bytes": 98,
+      "imports": [
+        {
+          "path": "/shared.js",
+          "kind": "import-statement"
+        },
+        {
+          "path": "/c.js",
+          "kind": "dynamic-import"
+        }
+      ]
+    },
+    "/b.js": {
+      "bytes": 105,
+      "imports": [
+        {
+          "path": "/shared.js",
+          "kind": "import-statement"
+        },
+        {
+          "path": "/dep.js",
+          "kind": "import-statement"
+        }
+      ]
+    },
+    "/c.js": {
+      "bytes": 108,
+      "imports": [
+        {
+          "path": "/shared.js",
+          "kind": "import-statement"
+        },
+        {
+          "path": "/dep.js",
+          "kind": "import-statement"
+        }
+      ]
+    },
+    "/dep.js": {
+      "bytes": 18,
+      "imports": []
+    },
+    "/shared.js": {
+      "bytes": 21,
+      "imports": []
+    }
+  },
+  "outputs": {
+    "/out/a.js": {
+      "imports": [
+        {
+          "path": "/out/chunk.Potp7zZH.js",
+          "kind": "import-statement"
+        },
+        {
+          "path": "/out/c.js",
+          "kind": "dynamic-import"
+        }
+      ],
+      "transitiveImports": [
+        {
+          "path": "/out/chunk.Potp7zZH.js"
+        }
+      ],
+      "exports": [],
+      "entryPoint": "/a.js",
+      "inputs": {
+        "/a.js": {
+          "bytesInOutput": 116
+        }
+      },
+      "bytes": 175
+    },
+    "/out/b.js": {
+      "imports": [
+        {
+          "path": "/out/chunk.Potp7zZH.js",
+          "kind": "import-statement"
+        },
+        {
+          "path": "/out/chunk.UQ2nPdga.js",
+          "kind": "import-statement"
+        }
+      ],
+      "transitiveImports": [
+        {
+          "path": "/out/chunk.Potp7zZH.js"
+        },
+        {
+          "path": "/out/chunk.UQ2nPdga.js"
+        }
+      ],
+      "exports": [],
+      "entryPoint": "/b.js",
+      "inputs": {
+        "/b.js": {
+          "bytesInOutput": 28
+        }
+      },
+      "bytes": 133
+    },
+    "/out/c.js": {
+      "imports": [
+        {
+          "path": "/out/chunk.Potp7zZH.js",
+          "kind": "import-statement"
+        },
+        {
+          "path": "/out/chunk.UQ2nPdga.js",
+          "kind": "import-statement"
+        }
+      ],
+      "transitiveImports": [
+        {
+          "path": "/out/chunk.Potp7zZH.js"
+        },
+        {
+          "path": "/out/chunk.UQ2nPdga.js"
+        }
+      ],
+      "exports": [
+        "c"
+      ],
+      "entryPoint": "/c.js",
+      "inputs": {
+        "/c.js": {
+          "bytesInOutput": 24
+        }
+      },
+      "bytes": 145
+    },
+    "/out/chunk.UQ2nPdga.js": {
+      "imports": [],
+      "transitiveImports": [],
+      "exports": [],
+      "inputs": {
+        "/dep.js": {
+          "bytesInOutput": 14
+        }
+      },
+      "bytes": 45
+    },
+    "/out/chunk.Potp7zZH.js": {
+      "imports": [],
+      "transitiveImports": [],
+      "exports": [],
+      "inputs": {
+        "/shared.js": {
+          "bytesInOutput": 17
+        }
+      },
+      "bytes": 54
+    }
+  },
+  "circularImports": []
+}
+`,
+		},
+	})
+}
+
+func TestSplittingPreloadDynamicImportsAlreadyLoaded(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {shared} from "./shared.js"
+				import {lazy} from "./lazy.js"
+				console.log(shared, lazy)
+			`,
+			"/b.js": `
+				import {shared} from "./shared.js"
+				import {lazy} from "./lazy.js"
+				console.log(shared, lazy)
+			`,
+			"/lazy.js": `
+				export let lazy = () => import("./c.js")
+			`,
+			"/c.js": `
+				import {shared} from "./shared.js"
+				export let c = shared
+			`,
+			"/shared.js": `export let shared = 1`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:            true,
+			CodeSplitting:         true,
+			PreloadDynamicImports: true,
+			OutputFormat:          config.FormatESModule,
+			AbsOutputDir:          "/out",
+		},
+		expected: map[string]string{
+			"/out/a.js": `import {
+  shared2
+} from "./chunk.Potp7zZH.js";
+import {
+  lazy2
+} from "./chunk.xL6KqlYO.js";
+
+// /a.js
+console.log(shared2, lazy2);
+`,
+			"/out/b.js": `import {
+  shared2
+} from "./chunk.Potp7zZH.js";
+import {
+  lazy2
+} from "./chunk.xL6KqlYO.js";
+
+// /b.js
+console.log(shared2, lazy2);
+`,
+			"/out/chunk.xL6KqlYO.js": `// /lazy.js
+let lazy2 = () => import("./c.js");
+
+export {
+  lazy2
+};
+`,
+			"/out/c.js": `import {
+  shared2
+} from "./chunk.Potp7zZH.js";
+
+// /c.js
+let c = shared2;
+export {
+  c
+};
+`,
+			"/out/chunk.Potp7zZH.js": `// /shared.js
+let shared2 = 1;
+
+export {
+  shared2
+};
+`,
+		},
+	})
+}
+func TestSplittingManifest(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {shared, lazy} from './shared'
+				console.log(shared, lazy, import('./c'))
+			`,
+			"/b.js": `
+				import {shared, lazy} from './shared'
+				console.log(shared, lazy)
+			`,
+			"/shared.js": `
+				export let shared = require('./logo.svg')
+				export let lazy = () => import('./d')
+			`,
+			"/c.js":     `export let c = 123`,
+			"/d.js":     `export let d = 456`,
+			"/logo.svg": "<svg></svg>",
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:      true,
+			CodeSplitting:   true,
+			OutputFormat:    config.FormatESModule,
+			AbsOutputDir:    "/out",
+			AbsManifestFile: "/out/manifest.json",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".svg": config.LoaderFile,
+			},
+		},
+		expected: map[string]string{
+			"/out/a.js": `import {
+  lazy,
+  shared2
+} from "./chunk.xL6KqlYO.js";
+
+// /a.js
+console.log(shared2, lazy, import("./c.js"));
+`,
+			"/out/b.js": `import {
+  lazy,
+  shared2
+} from "./chunk.xL6KqlYO.js";
+
+// /b.js
+console.log(shared2, lazy);
+`,
+			"/out/logo.JcTCT2IT.svg": `<svg></svg>`,
+			"/out/chunk.xL6KqlYO.js": `// /logo.svg
+var require_logo = __commonJS((exports, module) => {
+  module.exports = "logo.JcTCT2IT.svg";
+});
+
+// /shared.js
+let shared2 = require_logo();
+let lazy = () => import("./d.js");
+
+export {
+  lazy,
+  shared2
+};
+`,
+			"/out/c.js": `// /c.js
+let c = 123;
+export {
+  c
+};
+`,
+			"/out/d.js": `// /d.js
+let d = 456;
+export {
+  d
+};
+`,
+			"/out/manifest.json": `{
+  "/a.js": {
+    "file": "a.js",
+    "imports": [
+      "chunk.xL6KqlYO.js"
+    ],
+    "dynamicImports": [
+      "c.js",
+      "d.js"
+    ],
+    "assets": [
+      "logo.JcTCT2IT.svg"
+    ]
+  },
+  "/b.js": {
+    "file": "b.js",
+    "imports": [
+      "chunk.xL6KqlYO.js"
+    ],
+    "dynamicImports": [
+      "d.js"
+    ],
+    "assets": [
+      "logo.JcTCT2IT.svg"
+    ]
+  },
+  "/c.js": {
+    "file": "c.js",
+    "imports": [],
+    "dynamicImports": [],
+    "assets": []
+  },
+  "/d.js": {
+    "file": "d.js",
+    "imports": [],
+    "dynamicImports": [],
+    "assets": []
+  }
+}
+`,
+		},
+	})
+}
+
+func TestSplittingSizeLimits(t *testing.T) {
+	// The limit for an entry point includes the shared chunk it imports
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {big} from './big'
+				console.log('a', big)
+			`,
+			"/b.js": `
+				import {big} from './big'
+				console.log('b', big)
+			`,
+			"/big.js": `export let big = 'this is a big string that takes up a lot of space in the output'`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:    true,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			SizeLimits: []config.SizeLimit{
+				{EntryPoint: "/a.js", MaxBytes: 100},
+			},
+		},
+		expectedCompileLog: `error: Output file "/out/a.js" and the chunks it imports are 189 bytes, which is over the limit of 100 bytes (largest inputs: /big.js is 78 bytes, /a.js is 24 bytes)
+`,
+	})
+}
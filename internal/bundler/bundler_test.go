@@ -3,6 +3,7 @@ package bundler
 import (
 	"fmt"
 	"path"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -4638,3 +4639,928 @@ func TestDeduplicateCommentsInBundle(t *testing.T) {
 		},
 	})
 }
+
+func TestMangleProps(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {make, Foo} from './foo'
+				let obj = make()
+				console.log(obj.count_, obj['count_'], obj.name_, obj.keep_, obj.other)
+				let {count_: x, name_} = new Foo()
+				console.log(x, name_)
+			`,
+			"/foo.js": `
+				export function make() {
+					return {count_: 1, 'name_': 2, keep_: 3, other: 4, a: 5}
+				}
+				export class Foo {
+					count_ = 1
+					name_() {}
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+			MangleProps:   regexp.MustCompile("_$"),
+			ReserveProps:  regexp.MustCompile("^keep_$"),
+			MangleCache:   map[string]interface{}{"name_": "c"},
+		},
+		expected: map[string]string{
+			"/out.js": `// /foo.js
+function make() {
+  return {b: 1, c: 2, keep_: 3, other: 4, a: 5};
+}
+class Foo {
+  b = 1;
+  c() {
+  }
+}
+
+// /entry.js
+let obj = make();
+console.log(obj.b, obj["b"], obj.c, obj.keep_, obj.other);
+let {b: x, c: name_} = new Foo();
+console.log(x, name_);
+`,
+		},
+	})
+}
+
+func TestCancelledBuild(t *testing.T) {
+	cancel := &config.CancelFlag{}
+	cancel.Cancel()
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {fn} from './foo'
+				fn()
+			`,
+			"/foo.js": `
+				export function fn() {}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/out.js",
+			Cancel:        cancel,
+		},
+		expected: map[string]string{},
+	})
+}
+
+func TestMetafileImportKindsAndExports(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {a} from './a'
+				import 'ext'
+				export let b = require('./b')
+				export default a
+				import('./c')
+			`,
+			"/a.js": `export let a = 1`,
+			"/b.js": `module.exports = 2`,
+			"/c.js": `export let c = 3`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:      true,
+			OutputFormat:    config.FormatESModule,
+			AbsOutputFile:   "/out.js",
+			AbsMetadataFile: "/meta.json",
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"ext": true,
+				},
+			},
+		},
+		expected: map[string]string{
+			"/out.js": `// /b.js
+var require_b = __commonJS((exports, module) => {
+  module.exports = 2;
+});
+
+// /c.js
+var require_c = __commonJS((exports) => {
+  __export(exports, {
+    c: () => c
+  });
+  let c = 3;
+});
+
+// /a.js
+let a = 1;
+
+// /entry.js
+import "ext";
+let b = require_b();
+var entry_default = a;
+Promise.resolve().then(() => __toModule(require_c()));
+export {
+  b,
+  entry_default as default
+};
+`,
+			"/meta.json": `{
+  "version": 2,
+  "inputs": {
+    "/a.js": {
+      "bytes": 16,
+      "imports": []
+    },
+    "/b.js": {
+      "bytes": 18,
+      "imports": []
+    },
+    "/c.js": {
+      "bytes": 16,
+      "imports": []
+    },
+    "/entry.js": {
+      "bytes": 120,
+      "imports": [
+        {
+          "path": "/a.js",
+          "kind": "import-statement"
+        },
+        {
+          "path": "ext",
+          "kind": "import-statement",
+          "external": true
+        },
+        {
+          "path": "/b.js",
+          "kind": "require-call"
+        },
+        {
+          "path": "/c.js",
+          "kind": "dynamic-import"
+        }
+      ]
+    }
+  },
+  "outputs": {
+    "/out.js": {
+      "imports": [],
+      "exports": [
+        "b",
+        "default"
+      ],
+      "entryPoint": "/entry.js",
+      "inputs": {
+        "/b.js": {
+          "bytesInOutput": 76
+        },
+        "/c.js": {
+          "bytesInOutput": 102
+        },
+        "/a.js": {
+          "bytesInOutput": 11
+        },
+        "/entry.js": {
+          "bytesInOutput": 113
+        }
+      },
+      "bytes": 389
+    }
+  },
+  "circularImports": []
+}
+`,
+		},
+	})
+}
+
+func TestWhy(t *testing.T) {
+	fs := fs.MockFS(map[string]string{
+		"/entry.js": `
+			import './a'
+			import {b} from './b'
+			console.log(b)
+		`,
+		"/a.js": `
+			import 'pkg/side'
+		`,
+		"/b.js": `
+			export let b = 1
+			import 'pkg/side'
+		`,
+		"/node_modules/pkg/side.js": `
+			let unused = 1
+			window.sideEffect = true
+		`,
+	})
+	options := config.Options{
+		IsBundling:     true,
+		AbsOutputFile:  "/out.js",
+		ExtensionOrder: []string{".js"},
+	}
+	log := logging.NewDeferLog()
+	resolver := resolver.NewResolver(fs, log, options)
+	bundle := ScanBundle(log, fs, resolver, []string{"/entry.js"}, options)
+	assertLog(t, log.Done(), "")
+
+	text := ""
+	for _, module := range bundle.Why(logging.NewDeferLog(), options, "pkg") {
+		text += module.PrettyPath + "\n"
+		for _, chain := range module.ImportChains {
+			for _, step := range chain {
+				text += fmt.Sprintf("  import %q at %s:%d:%d\n", step.Path, step.Location.File, step.Location.Line, step.Location.Column)
+			}
+		}
+		for _, loc := range module.SideEffects {
+			text += fmt.Sprintf("  side effect at %s:%d:%d\n", loc.File, loc.Line, loc.Column)
+		}
+	}
+	assertEqual(t, text, `/node_modules/pkg/side.js
+  import "./a" at /entry.js:2:10
+  import "pkg/side" at /a.js:2:10
+  side effect at /node_modules/pkg/side.js:3:3
+`)
+}
+
+func whyText(modules []WhyModule) string {
+	text := ""
+	for _, module := range modules {
+		text += module.PrettyPath + "\n"
+		for _, chain := range module.ImportChains {
+			for _, step := range chain {
+				text += fmt.Sprintf("  import %q at %s:%d:%d\n", step.Path, step.Location.File, step.Location.Line, step.Location.Column)
+			}
+		}
+		for _, loc := range module.SideEffects {
+			text += fmt.Sprintf("  side effect at %s:%d:%d\n", loc.File, loc.Line, loc.Column)
+		}
+	}
+	return text
+}
+
+func TestWhyTreeShaking(t *testing.T) {
+	fs := fs.MockFS(map[string]string{
+		"/entry.js": `
+			import {debug} from './debug'
+			import {unused} from 'pure'
+			import {used} from './used'
+			if (false) debug()
+			console.log(used)
+		`,
+		"/debug.js": `
+			import 'pkg/side'
+			export function debug() {}
+		`,
+		"/used.js": `
+			import 'pkg/side'
+			/* @__NO_SIDE_EFFECTS__ */ function make() { return {} }
+			let made = make()
+			export let used = 1
+		`,
+		"/node_modules/pure/index.js": `
+			import 'pkg/side'
+			export let unused = 1
+		`,
+		"/node_modules/pure/package.json": `{ "sideEffects": false }`,
+		"/node_modules/pkg/side.js": `
+			let made = /* @__PURE__ */ make()
+			window.sideEffect = true
+		`,
+	})
+	options := config.Options{
+		IsBundling:     true,
+		AbsOutputFile:  "/out.js",
+		ExtensionOrder: []string{".js"},
+	}
+	log := logging.NewDeferLog()
+	resolver := resolver.NewResolver(fs, log, options)
+	bundle := ScanBundle(log, fs, resolver, []string{"/entry.js"}, options)
+	assertLog(t, log.Done(), "")
+
+	// Imports only used in dead code and unused imports of modules without side
+	// effects don't explain why a module is in the bundle
+	assertEqual(t, whyText(bundle.Why(logging.NewDeferLog(), options, "pkg")), `/node_modules/pkg/side.js
+  import "./used" at /entry.js:4:22
+  import "pkg/side" at /used.js:2:10
+  side effect at /node_modules/pkg/side.js:3:3
+`)
+	assertEqual(t, whyText(bundle.Why(logging.NewDeferLog(), options, "pure")), "")
+
+	// Calls to functions without side effects aren't reported as side effects
+	assertEqual(t, whyText(bundle.Why(logging.NewDeferLog(), options, "/used.js")), `/used.js
+  import "./used" at /entry.js:4:22
+`)
+}
+
+func scannedModuleText(module ScannedModule) string {
+	kinds := []string{"import", "re-export", "require", "dynamic import"}
+	text := module.PrettyPath
+	if module.IsEntryPoint {
+		text += " (entry point)"
+	}
+	text += "\n"
+	for _, record := range module.Imports {
+		text += fmt.Sprintf("  %s %q at %d:%d", kinds[record.Kind], record.Path, record.Location.Line, record.Location.Column)
+		if record.ResolvedPath != "" {
+			text += " -> " + record.ResolvedPath
+		}
+		text += "\n"
+	}
+	text += fmt.Sprintf("  exports: %s\n", strings.Join(module.Exports, ", "))
+	text += fmt.Sprintf("  commonjs: %v, import.meta: %v\n", module.UsesCommonJS, module.UsesImportMeta)
+	return text
+}
+
+func TestScanFile(t *testing.T) {
+	source := logging.Source{
+		Index:      1,
+		PrettyPath: "<stdin>",
+		Contents: `
+			import {a} from './a'
+			import type {T} from './types'
+			import {U} from './only-used-as-a-type'
+			export * from './b'
+			export {c} from './c'
+			export let x: T | U = require('./d')
+			export default function() { return import('./e') }
+			console.log(a, import.meta.url, import(dynamic), require(dynamic), require('./f', 2), [require])
+		`,
+	}
+	log := logging.NewDeferLog()
+	module := ScanFile(log, source, config.LoaderTS, config.Options{})
+	assertLog(t, log.Done(), "")
+	assertEqual(t, scannedModuleText(module), `<stdin>
+  import "./a" at 2:19
+  re-export "./b" at 5:17
+  re-export "./c" at 6:19
+  require "./d" at 7:33
+  dynamic import "./e" at 8:45
+  exports: c, default, x
+  commonjs: false, import.meta: true
+`)
+}
+
+func TestScanModules(t *testing.T) {
+	fs := fs.MockFS(map[string]string{
+		"/entry.js": `
+			import {a} from './a'
+			export * from 'external'
+			console.log(a, require('./b'))
+		`,
+		"/a.js": `
+			export let a = 1
+			export let unused = 2
+		`,
+		"/b.js": `
+			module.exports = import('./a')
+		`,
+	})
+	options := config.Options{
+		IsBundling:     true,
+		AbsOutputFile:  "/out.js",
+		ExtensionOrder: []string{".js"},
+		ExternalModules: config.ExternalModules{
+			NodeModules: map[string]bool{"external": true},
+		},
+	}
+	log := logging.NewDeferLog()
+	resolver := resolver.NewResolver(fs, log, options)
+	bundle := ScanBundle(log, fs, resolver, []string{"/entry.js"}, options)
+	assertLog(t, log.Done(), "")
+
+	text := ""
+	for _, module := range bundle.ScanModules() {
+		text += scannedModuleText(module)
+	}
+	assertEqual(t, text, `/entry.js (entry point)
+  import "./a" at 2:19 -> /a.js
+  re-export "external" at 3:17
+  require "./b" at 4:26 -> /b.js
+  exports: 
+  commonjs: false, import.meta: false
+/a.js
+  exports: a, unused
+  commonjs: false, import.meta: false
+/b.js
+  dynamic import "./a" at 2:27 -> /a.js
+  exports: 
+  commonjs: true, import.meta: false
+`)
+}
+
+func TestCircularImportWarnings(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './a'
+				import './x'
+				import('./entry')
+			`,
+			"/a.js": `
+				import './b'
+			`,
+			"/b.js": `
+				import './c'
+				import './a'
+			`,
+			"/c.js": `
+				import './b'
+			`,
+			"/x.js": `
+				import './y'
+			`,
+			"/y.js": `
+				import './x'
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:               true,
+			AbsOutputFile:            "/out.js",
+			WarnAboutCircularImports: true,
+			AllowedCircularImports:   [][]string{{"/x.js", "/y.js"}},
+		},
+		expectedScanLog: `/a.js: warning: Circular import: /a.js:2:11 -> /b.js:3:11 -> /a.js (3 files import each other in this group)
+`,
+		expected: map[string]string{
+			"/out.js": `// /entry.js
+var require_entry = __commonJS(() => {
+  Promise.resolve().then(() => __toModule(require_entry()));
+});
+
+// /c.js
+
+// /b.js
+
+// /a.js
+
+// /y.js
+
+// /x.js
+export default require_entry();
+`,
+		},
+	})
+}
+
+func TestMetafileCircularImports(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './a'
+			`,
+			"/a.js": `
+				import './b'
+			`,
+			"/b.js": `
+				import './a'
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:      true,
+			AbsOutputFile:   "/out.js",
+			AbsMetadataFile: "/meta.json",
+		},
+		expected: map[string]string{
+			"/out.js": `// /b.js
+
+// /a.js
+
+// /entry.js
+`,
+			"/meta.json": `{
+  "version": 2,
+  "inputs": {
+    "/a.js": {
+      "bytes": 21,
+      "imports": [
+        {
+          "path": "/b.js",
+          "kind": "import-statement"
+        }
+      ]
+    },
+    "/b.js": {
+      "bytes": 21,
+      "imports": [
+        {
+          "path": "/a.js",
+          "kind": "import-statement"
+        }
+      ]
+    },
+    "/entry.js": {
+      "bytes": 21,
+      "imports": [
+        {
+          "path": "/a.js",
+          "kind": "import-statement"
+        }
+      ]
+    }
+  },
+  "outputs": {
+    "/out.js": {
+      "imports": [],
+      "exports": [],
+      "entryPoint": "/entry.js",
+      "inputs": {
+        "/b.js": {
+          "bytesInOutput": 0
+        },
+        "/a.js": {
+          "bytesInOutput": 0
+        },
+        "/entry.js": {
+          "bytesInOutput": 0
+        }
+      },
+      "bytes": 33
+    }
+  },
+  "circularImports": [
+    [
+      "/a.js",
+      "/b.js"
+    ]
+  ]
+}
+`,
+		},
+	})
+}
+
+// Three copies of "lib": one at the top level and one nested inside each of
+// "pkg-a" and "pkg-b", which both depend on the same older version
+var duplicatePackageFiles = map[string]string{
+	"/Users/user/project/src/entry.js": `
+		import a from 'lib'
+		import b from 'pkg-a'
+		import c from 'pkg-b'
+		console.log(a, b, c)
+	`,
+	"/Users/user/project/src/lib-only.js":                                  `import a from 'lib'; console.log(a)`,
+	"/Users/user/project/src/pkg-a-only.js":                                `import b from 'pkg-a'; console.log(b)`,
+	"/Users/user/project/node_modules/lib/package.json":                    `{ "name": "lib", "version": "2.0.0" }`,
+	"/Users/user/project/node_modules/lib/index.js":                        `export default 'lib 2.0.0'`,
+	"/Users/user/project/node_modules/pkg-a/package.json":                  `{ "name": "pkg-a", "version": "1.0.0" }`,
+	"/Users/user/project/node_modules/pkg-a/index.js":                      `export {default} from 'lib'`,
+	"/Users/user/project/node_modules/pkg-b/package.json":                  `{ "name": "pkg-b", "version": "1.0.0" }`,
+	"/Users/user/project/node_modules/pkg-b/index.js":                      `export {default} from 'lib'`,
+	"/Users/user/project/node_modules/pkg-a/node_modules/lib/package.json": `{ "name": "lib", "version": "1.0.0" }`,
+	"/Users/user/project/node_modules/pkg-a/node_modules/lib/index.js":     `export default 'lib 1.0.0'`,
+	"/Users/user/project/node_modules/pkg-b/node_modules/lib/package.json": `{ "name": "lib", "version": "1.0.0" }`,
+	"/Users/user/project/node_modules/pkg-b/node_modules/lib/index.js":     `export default 'lib 1.0.0'`,
+}
+
+func TestDuplicatePackageWarning(t *testing.T) {
+	expectBundled(t, bundled{
+		files:      duplicatePackageFiles,
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+		expectedCompileLog: "warning: Package \"lib\" is in the bundle 3 times, which adds 63 bytes: " +
+			"2.0.0 at /Users/user/project/node_modules/lib, 1.0.0 at /Users/user/project/node_modules/pkg-a/node_modules/lib, " +
+			"1.0.0 at /Users/user/project/node_modules/pkg-b/node_modules/lib\n",
+		expected: map[string]string{
+			"/Users/user/project/out.js": `// /Users/user/project/node_modules/lib/index.js
+var lib_default = "lib 2.0.0";
+
+// /Users/user/project/node_modules/pkg-a/node_modules/lib/index.js
+var lib_default2 = "lib 1.0.0";
+
+// /Users/user/project/node_modules/pkg-a/index.js
+
+// /Users/user/project/node_modules/pkg-b/node_modules/lib/index.js
+var lib_default3 = "lib 1.0.0";
+
+// /Users/user/project/node_modules/pkg-b/index.js
+
+// /Users/user/project/src/entry.js
+console.log(lib_default, lib_default2, lib_default3);
+`,
+		},
+	})
+}
+
+func TestDedupePackages(t *testing.T) {
+	expectBundled(t, bundled{
+		files:      duplicatePackageFiles,
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			IsBundling:     true,
+			DedupePackages: true,
+			AbsOutputFile:  "/Users/user/project/out.js",
+		},
+		expectedCompileLog: "warning: Package \"lib\" is in the bundle 2 times, which adds 31 bytes: " +
+			"2.0.0 at /Users/user/project/node_modules/lib, 1.0.0 at /Users/user/project/node_modules/pkg-a/node_modules/lib\n",
+		expected: map[string]string{
+			"/Users/user/project/out.js": `// /Users/user/project/node_modules/lib/index.js
+var lib_default = "lib 2.0.0";
+
+// /Users/user/project/node_modules/pkg-a/node_modules/lib/index.js
+var lib_default2 = "lib 1.0.0";
+
+// /Users/user/project/node_modules/pkg-a/index.js
+
+// /Users/user/project/node_modules/pkg-b/index.js
+
+// /Users/user/project/src/entry.js
+console.log(lib_default, lib_default2, lib_default2);
+`,
+		},
+	})
+}
+
+func TestDuplicatePackageWarningSeparateBundles(t *testing.T) {
+	// Without code splitting, copies that are only in different bundles are
+	// not duplicates of each other
+	expectBundled(t, bundled{
+		files: duplicatePackageFiles,
+		entryPaths: []string{
+			"/Users/user/project/src/entry.js",
+			"/Users/user/project/src/lib-only.js",
+			"/Users/user/project/src/pkg-a-only.js",
+		},
+		options: config.Options{
+			IsBundling:   true,
+			AbsOutputDir: "/Users/user/project/out",
+		},
+		expectedCompileLog: "warning: Package \"lib\" is in the bundle 3 times, which adds 63 bytes: " +
+			"2.0.0 at /Users/user/project/node_modules/lib, 1.0.0 at /Users/user/project/node_modules/pkg-a/node_modules/lib, " +
+			"1.0.0 at /Users/user/project/node_modules/pkg-b/node_modules/lib\n",
+		expected: map[string]string{
+			"/Users/user/project/out/entry.js": `// /Users/user/project/node_modules/lib/index.js
+var lib_default = "lib 2.0.0";
+
+// /Users/user/project/node_modules/pkg-a/node_modules/lib/index.js
+var lib_default2 = "lib 1.0.0";
+
+// /Users/user/project/node_modules/pkg-a/index.js
+
+// /Users/user/project/node_modules/pkg-b/node_modules/lib/index.js
+var lib_default3 = "lib 1.0.0";
+
+// /Users/user/project/node_modules/pkg-b/index.js
+
+// /Users/user/project/src/entry.js
+console.log(lib_default, lib_default2, lib_default3);
+`,
+			"/Users/user/project/out/lib-only.js": `// /Users/user/project/node_modules/lib/index.js
+var lib_default = "lib 2.0.0";
+
+// /Users/user/project/src/lib-only.js
+console.log(lib_default);
+`,
+			"/Users/user/project/out/pkg-a-only.js": `// /Users/user/project/node_modules/pkg-a/node_modules/lib/index.js
+var lib_default = "lib 1.0.0";
+
+// /Users/user/project/node_modules/pkg-a/index.js
+
+// /Users/user/project/src/pkg-a-only.js
+console.log(lib_default);
+`,
+		},
+	})
+}
+
+func TestSizeLimits(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {big} from './big'
+				import {small} from './small'
+				console.log(big, small)
+			`,
+			"/b.js":     `console.log('b')`,
+			"/big.js":   `export let big = 'this is a big string that takes up a lot of space in the output'`,
+			"/small.js": `export let small = 'small'`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			IsBundling:   true,
+			AbsOutputDir: "/out",
+			SizeLimits: []config.SizeLimit{
+				{EntryPoint: "/a.js", MaxBytes: 100},
+				{Pattern: regexp.MustCompile(`^b\.js$`), MaxBytes: 1000},
+				{Pattern: regexp.MustCompile(`\.js$`), MaxBytes: 10, Gzip: true, IsWarning: true},
+				{MaxBytes: 150},
+			},
+		},
+		expectedCompileLog: `error: Output file "/out/a.js" is 162 bytes, which is over the limit of 100 bytes (largest inputs: /big.js is 78 bytes, /a.js is 27 bytes, /small.js is 22 bytes)
+warning: Output file "/out/a.js" is 133 bytes after gzip, which is over the limit of 10 bytes (largest inputs: /big.js is 78 bytes, /a.js is 27 bytes, /small.js is 22 bytes)
+warning: Output file "/out/b.js" is 48 bytes after gzip, which is over the limit of 10 bytes (largest inputs: /b.js is 18 bytes)
+error: The output files are 189 bytes, which is over the limit of 150 bytes (largest inputs: /big.js is 78 bytes, /a.js is 27 bytes, /small.js is 22 bytes)
+`,
+	})
+}
+
+func TestConvertFormatESMToCommonJS(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import fs from 'fs'
+				import {join} from 'path'
+				import * as ns from 'os'
+				export let x = fs.readFileSync(join('a', 'b'))
+				export default function foo() { return ns }
+				export {y} from 'util'
+				export {x as z}
+				console.log(this, import.meta.url)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    false,
+			OutputFormat:  config.FormatCommonJS,
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `__export(exports, {
+  default: () => foo,
+  x: () => x,
+  y: () => util.y,
+  z: () => x
+});
+const fs = __toModule(require("fs"));
+const path = __toModule(require("path"));
+const ns = __toModule(require("os"));
+const util = __toModule(require("util"));
+const import_meta = {};
+let x = fs.default.readFileSync(path.join("a", "b"));
+function foo() {
+  return ns;
+}
+console.log(void 0, import_meta.url);
+`,
+		},
+	})
+}
+
+func TestConvertFormatESMToIIFE(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {render} from 'react-dom'
+				export const name = 'app'
+				export function main(root) { render(name, root) }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    false,
+			OutputFormat:  config.FormatIIFE,
+			ModuleName:    "app",
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `var app = (() => {
+  var require_entry = __commonJS((exports) => {
+    __export(exports, {
+      main: () => main,
+      name: () => name
+    });
+    const react_dom = __toModule(require("react-dom"));
+    const name = "app";
+    function main(root) {
+      react_dom.render(name, root);
+    }
+  });
+  return require_entry();
+})();
+`,
+		},
+	})
+}
+
+func TestConvertFormatCommonJSToESM(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				const fs = require('fs')
+				module.exports = function(file) { return fs.readFileSync(file) }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    false,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `var require_entry = __commonJS((exports, module) => {
+  const fs = require("fs");
+  module.exports = function(file) {
+    return fs.readFileSync(file);
+  };
+});
+export default require_entry();
+`,
+		},
+	})
+}
+
+func TestConvertFormatTypeScriptToCommonJS(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.ts": `
+				import {Type} from './types'
+				import {value} from './values'
+				export let x: Type = value
+			`,
+		},
+		entryPaths: []string{"/entry.ts"},
+		options: config.Options{
+			IsBundling:    false,
+			OutputFormat:  config.FormatCommonJS,
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `__export(exports, {
+  x: () => x
+});
+const values = __toModule(require("./values"));
+let x = values.value;
+`,
+		},
+	})
+}
+
+func TestManglePropsSkipsGeneratedExports(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import * as ns from './foo'
+				export let value_ = ns.value_
+				console.log(ns, {value_})
+			`,
+			"/foo.js": `
+				export let value_ = 1
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			IsBundling:    true,
+			OutputFormat:  config.FormatCommonJS,
+			AbsOutputFile: "/out.js",
+			MangleProps:   regexp.MustCompile("_$"),
+		},
+		expected: map[string]string{
+			"/out.js": `// /foo.js
+const foo_exports = {};
+__export(foo_exports, {
+  value_: () => value_2
+});
+let value_2 = 1;
+
+// /entry.js
+__export(exports, {
+  value_: () => value_
+});
+let value_ = value_2;
+console.log(foo_exports, {a: value_});
+`,
+		},
+	})
+}
+
+func TestManglePropsLoweredClassFields(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.ts": `
+				class Foo {
+					count_ = 1
+					static total_ = 2
+					constructor(public name_: string) {}
+				}
+				let foo = new Foo('x')
+				console.log(foo.count_, Foo.total_, foo.name_)
+			`,
+		},
+		entryPaths: []string{"/entry.ts"},
+		options: config.Options{
+			IsBundling:          true,
+			AbsOutputFile:       "/out.js",
+			UnsupportedFeatures: es(2019),
+			Strict:              config.StrictOptions{ClassFields: true},
+			MangleProps:         regexp.MustCompile("_$"),
+		},
+		expected: map[string]string{
+			"/out.js": `// /entry.ts
+class Foo {
+  constructor(name_) {
+    this.b = name_;
+    __publicField(this, "a", 1);
+  }
+}
+__publicField(Foo, "c", 2);
+let foo = new Foo("x");
+console.log(foo.a, Foo.c, foo.b);
+`,
+		},
+	})
+}
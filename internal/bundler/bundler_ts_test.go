@@ -1007,3 +1007,116 @@ console.log(all_default, all_computed_default, a, b, c, d, e_default2, f_default
 		},
 	})
 }
+
+func TestTSInlineConstantsAndEnumsAcrossFiles(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.ts": `
+				import {DEBUG, NAME, LONG, Color, Kind, Mixed} from './flags'
+				if (DEBUG) console.log(NAME)
+				if (!DEBUG) console.log('release'); else { var keep = 1 }
+				if (Color.Green) console.log('green'); else console.log('red')
+				console.log(DEBUG ? 'debug' : NAME, DEBUG || Kind.B, NAME ?? 'none')
+				console.log(LONG, {NAME}, Color.Red, Color.Blue, Kind.A, Kind, Mixed.X)
+			`,
+			"/flags.ts": `
+				export const DEBUG = false
+				export const NAME = 'app'
+				export const LONG = 'this string is too long to inline'
+				export enum Color { Red, Green, Blue = -1 }
+				export enum Kind { A, B }
+				export enum Mixed { X = 1, Y = foo() }
+			`,
+		},
+		entryPaths: []string{"/entry.ts"},
+		options: config.Options{
+			IsBundling:    true,
+			MangleSyntax:  true,
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `// /flags.ts
+const LONG = "this string is too long to inline";
+var Kind;
+(function(Kind2) {
+  Kind2[Kind2.A = 0] = "A", Kind2[Kind2.B = 1] = "B";
+})(Kind || (Kind = {}));
+var Mixed;
+(function(Mixed2) {
+  Mixed2[Mixed2.X = 1] = "X", Mixed2[Mixed2.Y = foo()] = "Y";
+})(Mixed || (Mixed = {}));
+
+// /entry.ts
+if (true)
+  console.log("release");
+else
+  var keep = 1;
+console.log("green");
+console.log("app", Kind.B, "app");
+console.log(LONG, {NAME: "app"}, 0, -1, Kind.A, Kind, Mixed.X);
+`,
+		},
+	})
+}
+
+func TestTSInlineConstantsRemoveImportsOnlyUsedInDeadBranches(t *testing.T) {
+	expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.ts": `
+				import {DEBUG, Mode} from './flags'
+				import {devTools} from './dev'
+				import {live, shared} from './live'
+				if (DEBUG) {
+					devTools(shared)
+				}
+				let lazy = DEBUG && require('./lazy')
+				console.log(Mode.Prod ? 'prod' : devTools)
+				export function check() {
+					if (!DEBUG) return live
+					return shared
+				}
+			`,
+			"/flags.ts": `
+				export const DEBUG = false
+				export const enum Mode { Dev, Prod }
+				console.log('flags side effect')
+			`,
+			"/dev.ts": `
+				export function devTools() {}
+				console.log('dev side effect')
+			`,
+			"/live.ts": `
+				export let live = 1
+				export let shared = 2
+			`,
+			"/lazy.ts": `
+				console.log('lazy side effect')
+			`,
+		},
+		entryPaths: []string{"/entry.ts"},
+		options: config.Options{
+			IsBundling:    true,
+			MangleSyntax:  true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputFile: "/out.js",
+		},
+		expected: map[string]string{
+			"/out.js": `// /flags.ts
+console.log("flags side effect");
+
+// /live.ts
+let live2 = 1;
+
+// /entry.ts
+let lazy = false;
+console.log("prod");
+function check() {
+  return live2;
+}
+export {
+  check
+};
+`,
+		},
+	})
+}
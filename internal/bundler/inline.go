@@ -0,0 +1,719 @@
+package bundler
+
+import (
+	"fmt"
+
+	"github.com/evanw/esbuild/internal/ast"
+)
+
+// This replaces uses of inlined imports in a file with their values and then
+// removes the code that can never run as a result, such as the body of
+// "if (DEBUG) {...}" when "DEBUG" is imported as "false". This runs before
+// tree shaking so that the symbols and import records that were only used in
+// the removed code don't keep other parts and files in the bundle.
+func (c *linkerContext) inlineConstantsInFile(sourceIndex uint32, inlinedRefs map[ast.Ref]bool) {
+	file := &c.files[sourceIndex]
+	partsToFold := make(map[uint32]bool)
+	for ref := range inlinedRefs {
+		for _, partIndex := range file.ast.NamedImports[ref].LocalPartsWithUses {
+			partsToFold[partIndex] = true
+		}
+	}
+
+	removedRefs := make(map[ast.Ref]bool)
+	removedRecords := make(map[uint32]bool)
+	for partIndex := range partsToFold {
+		part := &file.ast.Parts[partIndex]
+		f := constantFolder{
+			c:           c,
+			liveRefs:    make(map[ast.Ref]bool),
+			deadRefs:    make(map[ast.Ref]uint32),
+			liveRecords: make(map[uint32]bool),
+			deadRecords: make(map[uint32]bool),
+		}
+
+		// Parts are shared with other linking operations, so the statements are
+		// copied instead of being modified in place
+		part.Stmts = f.visitStmts(part.Stmts)
+		if len(part.Stmts) == 0 {
+			part.CanBeRemovedIfUnused = true
+		}
+
+		// Forget about symbols that are no longer used in this part
+		dependencies := make(map[uint32]bool, len(part.LocalDependencies))
+		for ref, count := range f.deadRefs {
+			if f.liveRefs[ref] {
+				if use, ok := part.SymbolUses[ref]; ok && use.CountEstimate > count {
+					use.CountEstimate -= count
+					part.SymbolUses[ref] = use
+				}
+				continue
+			}
+			if _, ok := part.SymbolUses[ref]; !ok {
+				continue
+			}
+			delete(part.SymbolUses, ref)
+			removedRefs[ref] = true
+
+			if namedImport, ok := file.ast.NamedImports[ref]; ok {
+				partsWithUses := []uint32{}
+				for _, otherPartIndex := range namedImport.LocalPartsWithUses {
+					if otherPartIndex != partIndex {
+						partsWithUses = append(partsWithUses, otherPartIndex)
+					}
+				}
+				namedImport.LocalPartsWithUses = partsWithUses
+				file.ast.NamedImports[ref] = namedImport
+			}
+		}
+		for ref := range part.SymbolUses {
+			for _, otherPartIndex := range file.ast.TopLevelSymbolToParts[ref] {
+				dependencies[otherPartIndex] = true
+			}
+		}
+		for otherPartIndex := range part.LocalDependencies {
+			if !isDependencyOnRemovedRef(file, otherPartIndex, removedRefs) {
+				dependencies[otherPartIndex] = true
+			}
+		}
+		part.LocalDependencies = dependencies
+
+		// Forget about "require()" and "import()" calls that were removed
+		recordIndices := []uint32{}
+		for _, recordIndex := range part.ImportRecordIndices {
+			if f.deadRecords[recordIndex] && !f.liveRecords[recordIndex] {
+				removedRecords[recordIndex] = true
+				continue
+			}
+			recordIndices = append(recordIndices, recordIndex)
+		}
+		part.ImportRecordIndices = recordIndices
+	}
+
+	// An import statement whose imported names were only used in the removed
+	// code doesn't need to be included in the bundle anymore
+	liveRefs := make(map[ast.Ref]bool)
+	for _, part := range file.ast.Parts {
+		for ref := range part.SymbolUses {
+			liveRefs[ref] = true
+		}
+	}
+	isLive := make(map[uint32]bool)
+	isCandidate := make(map[uint32]bool)
+	for ref, namedImport := range file.ast.NamedImports {
+		index := namedImport.ImportRecordIndex
+		if len(namedImport.LocalPartsWithUses) > 0 || namedImport.IsExported || liveRefs[namedImport.NamespaceRef] {
+			isLive[index] = true
+		} else if removedRefs[ref] && !inlinedRefs[ref] {
+			isCandidate[index] = true
+		}
+	}
+	for index := range isCandidate {
+		if record := &file.ast.ImportRecords[index]; !isLive[index] && record.Kind == ast.ImportStmt {
+			record.IsOnlyUsedInDeadCode = true
+		}
+	}
+	for index := range removedRecords {
+		file.ast.ImportRecords[index].IsOnlyUsedInDeadCode = true
+	}
+}
+
+// A local dependency is only dropped if every symbol that part declares and
+// that this part used to reference has been removed
+func isDependencyOnRemovedRef(file *file, partIndex uint32, removedRefs map[ast.Ref]bool) bool {
+	for _, declared := range file.ast.Parts[partIndex].DeclaredSymbols {
+		if declared.IsTopLevel && removedRefs[declared.Ref] {
+			return true
+		}
+	}
+	return false
+}
+
+type constantFolder struct {
+	c *linkerContext
+
+	// Code that was removed is still visited to find the symbols and import
+	// records it references, but it's recorded separately from the code that
+	// was kept
+	isDead      bool
+	liveRefs    map[ast.Ref]bool
+	deadRefs    map[ast.Ref]uint32
+	liveRecords map[uint32]bool
+	deadRecords map[uint32]bool
+}
+
+func (f *constantFolder) recordRef(ref ast.Ref) {
+	if f.isDead {
+		f.deadRefs[ref]++
+	} else {
+		f.liveRefs[ref] = true
+	}
+}
+
+func (f *constantFolder) recordImportRecord(index uint32) {
+	if f.isDead {
+		f.deadRecords[index] = true
+	} else {
+		f.liveRecords[index] = true
+	}
+}
+
+func (f *constantFolder) removeStmt(stmt ast.Stmt) {
+	oldIsDead := f.isDead
+	f.isDead = true
+	f.visitStmt(stmt)
+	f.isDead = oldIsDead
+}
+
+func (f *constantFolder) removeExpr(expr ast.Expr) {
+	oldIsDead := f.isDead
+	f.isDead = true
+	f.visitExpr(expr)
+	f.isDead = oldIsDead
+}
+
+func isPrimitiveLiteral(data ast.E) bool {
+	switch data.(type) {
+	case *ast.ENull, *ast.EUndefined, *ast.EBoolean, *ast.ENumber, *ast.EBigInt, *ast.EString:
+		return true
+	}
+	return false
+}
+
+func (f *constantFolder) visitStmts(stmts []ast.Stmt) []ast.Stmt {
+	result := make([]ast.Stmt, 0, len(stmts))
+	for _, stmt := range stmts {
+		if visited, ok := f.visitStmt(stmt); ok {
+			result = append(result, visited)
+		}
+	}
+	return result
+}
+
+func (f *constantFolder) visitSingleStmt(stmt ast.Stmt) ast.Stmt {
+	if visited, ok := f.visitStmt(stmt); ok {
+		return visited
+	}
+	return ast.Stmt{Loc: stmt.Loc, Data: &ast.SEmpty{}}
+}
+
+// This returns false if the statement was removed
+func (f *constantFolder) visitStmt(stmt ast.Stmt) (ast.Stmt, bool) {
+	switch s := stmt.Data.(type) {
+	case *ast.SEmpty, *ast.STypeScript, *ast.SComment, *ast.SDebugger, *ast.SDirective:
+		return stmt, true
+
+	case *ast.SBreak:
+		if s.Name != nil {
+			f.recordRef(s.Name.Ref)
+		}
+		return stmt, true
+
+	case *ast.SContinue:
+		if s.Name != nil {
+			f.recordRef(s.Name.Ref)
+		}
+		return stmt, true
+
+	case *ast.SImport:
+		f.recordImportRecord(s.ImportRecordIndex)
+		f.recordRef(s.NamespaceRef)
+		if s.DefaultName != nil {
+			f.recordRef(s.DefaultName.Ref)
+		}
+		if s.Items != nil {
+			for _, item := range *s.Items {
+				f.recordRef(item.Name.Ref)
+			}
+		}
+		return stmt, true
+
+	case *ast.SExportClause:
+		for _, item := range s.Items {
+			f.recordRef(item.Name.Ref)
+		}
+		return stmt, true
+
+	case *ast.SExportFrom:
+		f.recordImportRecord(s.ImportRecordIndex)
+		f.recordRef(s.NamespaceRef)
+		for _, item := range s.Items {
+			f.recordRef(item.Name.Ref)
+		}
+		return stmt, true
+
+	case *ast.SExportStar:
+		f.recordImportRecord(s.ImportRecordIndex)
+		f.recordRef(s.NamespaceRef)
+		return stmt, true
+
+	case *ast.SExportDefault:
+		clone := *s
+		f.recordRef(s.DefaultName.Ref)
+		if s.Value.Expr != nil {
+			value := f.visitExpr(*s.Value.Expr)
+			clone.Value.Expr = &value
+		} else {
+			value := f.visitSingleStmt(*s.Value.Stmt)
+			clone.Value.Stmt = &value
+		}
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SExportEquals:
+		return ast.Stmt{Loc: stmt.Loc, Data: &ast.SExportEquals{Value: f.visitExpr(s.Value)}}, true
+
+	case *ast.SLazyExport:
+		return ast.Stmt{Loc: stmt.Loc, Data: &ast.SLazyExport{Value: f.visitExpr(s.Value)}}, true
+
+	case *ast.SExpr:
+		clone := *s
+		clone.Value = f.visitExpr(s.Value)
+
+		// Expressions that became constants don't do anything
+		if !f.isDead && clone.Value.Data != s.Value.Data && isPrimitiveLiteral(clone.Value.Data) {
+			return stmt, false
+		}
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SFunction:
+		clone := *s
+		clone.Fn = f.visitFn(s.Fn)
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SClass:
+		clone := *s
+		clone.Class = f.visitClass(s.Class)
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SLabel:
+		f.recordRef(s.Name.Ref)
+		return ast.Stmt{Loc: stmt.Loc, Data: &ast.SLabel{Name: s.Name, Stmt: f.visitSingleStmt(s.Stmt)}}, true
+
+	case *ast.SBlock:
+		return ast.Stmt{Loc: stmt.Loc, Data: &ast.SBlock{Stmts: f.visitStmts(s.Stmts)}}, true
+
+	case *ast.SIf:
+		test := f.visitExpr(s.Test)
+
+		// Remove the branch that is never taken if it's safe to do so
+		if boolean, ok := ast.ToBooleanWithoutSideEffects(test.Data); ok && !f.isDead && test.Data != s.Test.Data {
+			live, dead := &s.Yes, s.No
+			if !boolean {
+				live, dead = s.No, &s.Yes
+			}
+			if dead == nil || ast.CanRemoveDeadStmt(*dead) {
+				if dead != nil {
+					f.removeStmt(*dead)
+				}
+				if live == nil {
+					return stmt, false
+				}
+				result, ok := f.visitStmt(*live)
+				if ok && ast.StatementCaresAboutScope(result) {
+					result = ast.Stmt{Loc: result.Loc, Data: &ast.SBlock{Stmts: []ast.Stmt{result}}}
+				}
+				return result, ok
+			}
+		}
+
+		clone := ast.SIf{Test: test, Yes: f.visitSingleStmt(s.Yes)}
+		if s.No != nil {
+			no := f.visitSingleStmt(*s.No)
+			clone.No = &no
+		}
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SFor:
+		clone := *s
+		if s.Init != nil {
+			init := f.visitForLoopInit(*s.Init)
+			clone.Init = &init
+		}
+		if s.Test != nil {
+			test := f.visitExpr(*s.Test)
+			clone.Test = &test
+		}
+		if s.Update != nil {
+			update := f.visitExpr(*s.Update)
+			clone.Update = &update
+		}
+		clone.Body = f.visitSingleStmt(s.Body)
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SForIn:
+		clone := *s
+		clone.Init = f.visitForLoopInit(s.Init)
+		clone.Value = f.visitExpr(s.Value)
+		clone.Body = f.visitSingleStmt(s.Body)
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SForOf:
+		clone := *s
+		clone.Init = f.visitForLoopInit(s.Init)
+		clone.Value = f.visitExpr(s.Value)
+		clone.Body = f.visitSingleStmt(s.Body)
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SDoWhile:
+		return ast.Stmt{Loc: stmt.Loc, Data: &ast.SDoWhile{Body: f.visitSingleStmt(s.Body), Test: f.visitExpr(s.Test)}}, true
+
+	case *ast.SWhile:
+		return ast.Stmt{Loc: stmt.Loc, Data: &ast.SWhile{Test: f.visitExpr(s.Test), Body: f.visitSingleStmt(s.Body)}}, true
+
+	case *ast.SWith:
+		clone := *s
+		clone.Value = f.visitExpr(s.Value)
+		clone.Body = f.visitSingleStmt(s.Body)
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.STry:
+		clone := ast.STry{Body: f.visitStmts(s.Body)}
+		if s.Catch != nil {
+			catch := *s.Catch
+			if s.Catch.Binding != nil {
+				binding := f.visitBinding(*s.Catch.Binding)
+				catch.Binding = &binding
+			}
+			catch.Body = f.visitStmts(s.Catch.Body)
+			clone.Catch = &catch
+		}
+		if s.Finally != nil {
+			clone.Finally = &ast.Finally{Loc: s.Finally.Loc, Stmts: f.visitStmts(s.Finally.Stmts)}
+		}
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SSwitch:
+		clone := *s
+		clone.Test = f.visitExpr(s.Test)
+		clone.Cases = make([]ast.Case, len(s.Cases))
+		for i, c := range s.Cases {
+			if c.Value != nil {
+				value := f.visitExpr(*c.Value)
+				clone.Cases[i].Value = &value
+			}
+			clone.Cases[i].Body = f.visitStmts(c.Body)
+		}
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	case *ast.SReturn:
+		if s.Value == nil {
+			return stmt, true
+		}
+		value := f.visitExpr(*s.Value)
+		return ast.Stmt{Loc: stmt.Loc, Data: &ast.SReturn{Value: &value}}, true
+
+	case *ast.SThrow:
+		return ast.Stmt{Loc: stmt.Loc, Data: &ast.SThrow{Value: f.visitExpr(s.Value)}}, true
+
+	case *ast.SLocal:
+		clone := *s
+		clone.Decls = make([]ast.Decl, len(s.Decls))
+		for i, decl := range s.Decls {
+			clone.Decls[i].Binding = f.visitBinding(decl.Binding)
+			if decl.Value != nil {
+				value := f.visitExpr(*decl.Value)
+				clone.Decls[i].Value = &value
+			}
+		}
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}, true
+
+	default:
+		panic(fmt.Sprintf("Unexpected statement of type %T", stmt.Data))
+	}
+}
+
+// The initializer of a loop is never removed even if it becomes a constant
+func (f *constantFolder) visitForLoopInit(stmt ast.Stmt) ast.Stmt {
+	if s, ok := stmt.Data.(*ast.SExpr); ok {
+		clone := *s
+		clone.Value = f.visitExpr(s.Value)
+		return ast.Stmt{Loc: stmt.Loc, Data: &clone}
+	}
+	return f.visitSingleStmt(stmt)
+}
+
+func (f *constantFolder) visitBinding(binding ast.Binding) ast.Binding {
+	switch b := binding.Data.(type) {
+	case *ast.BMissing:
+		return binding
+
+	case *ast.BIdentifier:
+		f.recordRef(b.Ref)
+		return binding
+
+	case *ast.BArray:
+		clone := *b
+		clone.Items = make([]ast.ArrayBinding, len(b.Items))
+		for i, item := range b.Items {
+			clone.Items[i].Binding = f.visitBinding(item.Binding)
+			if item.DefaultValue != nil {
+				value := f.visitExpr(*item.DefaultValue)
+				clone.Items[i].DefaultValue = &value
+			}
+		}
+		return ast.Binding{Loc: binding.Loc, Data: &clone}
+
+	case *ast.BObject:
+		clone := *b
+		clone.Properties = make([]ast.PropertyBinding, len(b.Properties))
+		for i, property := range b.Properties {
+			property.Key = f.visitExpr(property.Key)
+			property.Value = f.visitBinding(property.Value)
+			if property.DefaultValue != nil {
+				value := f.visitExpr(*property.DefaultValue)
+				property.DefaultValue = &value
+			}
+			clone.Properties[i] = property
+		}
+		return ast.Binding{Loc: binding.Loc, Data: &clone}
+
+	default:
+		panic(fmt.Sprintf("Unexpected binding of type %T", binding.Data))
+	}
+}
+
+func (f *constantFolder) visitExprs(exprs []ast.Expr) []ast.Expr {
+	result := make([]ast.Expr, len(exprs))
+	for i, expr := range exprs {
+		result[i] = f.visitExpr(expr)
+	}
+	return result
+}
+
+func (f *constantFolder) visitFn(fn ast.Fn) ast.Fn {
+	if fn.Name != nil {
+		f.recordRef(fn.Name.Ref)
+	}
+	fn.Args = f.visitArgs(fn.Args)
+	fn.Body.Stmts = f.visitStmts(fn.Body.Stmts)
+	return fn
+}
+
+func (f *constantFolder) visitArgs(args []ast.Arg) []ast.Arg {
+	result := make([]ast.Arg, len(args))
+	for i, arg := range args {
+		arg.TSDecorators = f.visitExprs(arg.TSDecorators)
+		arg.Binding = f.visitBinding(arg.Binding)
+		if arg.Default != nil {
+			value := f.visitExpr(*arg.Default)
+			arg.Default = &value
+		}
+		result[i] = arg
+	}
+	return result
+}
+
+func (f *constantFolder) visitClass(class ast.Class) ast.Class {
+	class.TSDecorators = f.visitExprs(class.TSDecorators)
+	if class.Name != nil {
+		f.recordRef(class.Name.Ref)
+	}
+	if class.Extends != nil {
+		extends := f.visitExpr(*class.Extends)
+		class.Extends = &extends
+	}
+	class.Properties = f.visitProperties(class.Properties)
+	return class
+}
+
+func (f *constantFolder) visitProperties(properties []ast.Property) []ast.Property {
+	result := make([]ast.Property, len(properties))
+	for i, property := range properties {
+		property.TSDecorators = f.visitExprs(property.TSDecorators)
+		property.Key = f.visitExpr(property.Key)
+		if property.Value != nil {
+			value := f.visitExpr(*property.Value)
+			property.Value = &value
+		}
+		if property.Initializer != nil {
+			initializer := f.visitExpr(*property.Initializer)
+			property.Initializer = &initializer
+		}
+		result[i] = property
+	}
+	return result
+}
+
+func (f *constantFolder) visitExpr(expr ast.Expr) ast.Expr {
+	switch e := expr.Data.(type) {
+	case *ast.EBoolean, *ast.ESuper, *ast.ENull, *ast.EUndefined, *ast.EThis, *ast.ENewTarget,
+		*ast.EImportMeta, *ast.EMissing, *ast.ENumber, *ast.EBigInt, *ast.EString, *ast.ERegExp:
+		return expr
+
+	case *ast.EIdentifier:
+		f.recordRef(e.Ref)
+		return expr
+
+	case *ast.EPrivateIdentifier:
+		f.recordRef(e.Ref)
+		return expr
+
+	case *ast.EImportIdentifier:
+		// Substitute the value of an inlined constant
+		if value, ok := f.c.inlinedConstants[e.Ref]; ok {
+			f.deadRefs[e.Ref]++
+			return ast.Expr{Loc: expr.Loc, Data: value.Data}
+		}
+		f.recordRef(e.Ref)
+		return expr
+
+	case *ast.ERequire:
+		f.recordImportRecord(e.ImportRecordIndex)
+		return expr
+
+	case *ast.EImport:
+		if e.ImportRecordIndex != nil {
+			f.recordImportRecord(*e.ImportRecordIndex)
+		}
+		clone := *e
+		clone.Expr = f.visitExpr(e.Expr)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.EArray:
+		clone := *e
+		clone.Items = f.visitExprs(e.Items)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.EUnary:
+		value := f.visitExpr(e.Value)
+		if e.Op == ast.UnOpNot && value.Data != e.Value.Data {
+			if boolean, ok := ast.ToBooleanWithoutSideEffects(value.Data); ok {
+				return ast.Expr{Loc: expr.Loc, Data: &ast.EBoolean{Value: !boolean}}
+			}
+		}
+		return ast.Expr{Loc: expr.Loc, Data: &ast.EUnary{Op: e.Op, Value: value}}
+
+	case *ast.EBinary:
+		left := f.visitExpr(e.Left)
+
+		// Remove the side that is never evaluated
+		if !f.isDead && left.Data != e.Left.Data {
+			switch e.Op {
+			case ast.BinOpLogicalAnd, ast.BinOpLogicalOr:
+				if boolean, ok := ast.ToBooleanWithoutSideEffects(left.Data); ok {
+					if boolean == (e.Op == ast.BinOpLogicalOr) {
+						f.removeExpr(e.Right)
+						return left
+					}
+					return f.visitExpr(e.Right)
+				}
+
+			case ast.BinOpNullishCoalescing:
+				switch left.Data.(type) {
+				case *ast.ENull, *ast.EUndefined:
+					return f.visitExpr(e.Right)
+				}
+				if isPrimitiveLiteral(left.Data) {
+					f.removeExpr(e.Right)
+					return left
+				}
+			}
+		}
+
+		return ast.Expr{Loc: expr.Loc, Data: &ast.EBinary{Op: e.Op, Left: left, Right: f.visitExpr(e.Right)}}
+
+	case *ast.EIf:
+		test := f.visitExpr(e.Test)
+
+		// Remove the branch that is never evaluated
+		if !f.isDead && test.Data != e.Test.Data {
+			if boolean, ok := ast.ToBooleanWithoutSideEffects(test.Data); ok {
+				if boolean {
+					f.removeExpr(e.No)
+					return f.visitExpr(e.Yes)
+				}
+				f.removeExpr(e.Yes)
+				return f.visitExpr(e.No)
+			}
+		}
+
+		return ast.Expr{Loc: expr.Loc, Data: &ast.EIf{Test: test, Yes: f.visitExpr(e.Yes), No: f.visitExpr(e.No)}}
+
+	case *ast.ENew:
+		clone := *e
+		clone.Target = f.visitExpr(e.Target)
+		clone.Args = f.visitExprs(e.Args)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.ECall:
+		clone := *e
+		clone.Target = f.visitExpr(e.Target)
+		clone.Args = f.visitExprs(e.Args)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.EDot:
+		// Substitute the value of an inlined enum member
+		if id, ok := e.Target.Data.(*ast.EImportIdentifier); ok {
+			if value, ok := f.c.inlinedEnums[id.Ref][e.Name]; ok {
+				f.deadRefs[id.Ref]++
+				return ast.Expr{Loc: expr.Loc, Data: &ast.ENumber{Value: value}}
+			}
+		}
+		clone := *e
+		clone.Target = f.visitExpr(e.Target)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.EIndex:
+		clone := *e
+		clone.Target = f.visitExpr(e.Target)
+		clone.Index = f.visitExpr(e.Index)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.EArrow:
+		clone := *e
+		clone.Args = f.visitArgs(e.Args)
+		clone.Body.Stmts = f.visitStmts(e.Body.Stmts)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.EFunction:
+		return ast.Expr{Loc: expr.Loc, Data: &ast.EFunction{Fn: f.visitFn(e.Fn)}}
+
+	case *ast.EClass:
+		return ast.Expr{Loc: expr.Loc, Data: &ast.EClass{Class: f.visitClass(e.Class)}}
+
+	case *ast.EJSXElement:
+		clone := *e
+		if e.Tag != nil {
+			tag := f.visitExpr(*e.Tag)
+			clone.Tag = &tag
+		}
+		clone.Properties = f.visitProperties(e.Properties)
+		clone.Children = f.visitExprs(e.Children)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.EObject:
+		clone := *e
+		clone.Properties = f.visitProperties(e.Properties)
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.ESpread:
+		return ast.Expr{Loc: expr.Loc, Data: &ast.ESpread{Value: f.visitExpr(e.Value)}}
+
+	case *ast.ETemplate:
+		clone := *e
+		if e.Tag != nil {
+			tag := f.visitExpr(*e.Tag)
+			clone.Tag = &tag
+		}
+		clone.Parts = make([]ast.TemplatePart, len(e.Parts))
+		for i, part := range e.Parts {
+			part.Value = f.visitExpr(part.Value)
+			clone.Parts[i] = part
+		}
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	case *ast.EAwait:
+		return ast.Expr{Loc: expr.Loc, Data: &ast.EAwait{Value: f.visitExpr(e.Value)}}
+
+	case *ast.EYield:
+		clone := *e
+		if e.Value != nil {
+			value := f.visitExpr(*e.Value)
+			clone.Value = &value
+		}
+		return ast.Expr{Loc: expr.Loc, Data: &clone}
+
+	default:
+		panic(fmt.Sprintf("Unexpected expression of type %T", expr.Data))
+	}
+}
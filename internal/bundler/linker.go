@@ -74,6 +74,24 @@ type linkerContext struct {
 
 	// We may need to refer to the CommonJS "module" symbol for exports
 	unboundModuleRef ast.Ref
+
+	// Code splitting may need to refer to the chunk loader's require function
+	// when generating IIFE chunks, and to the argument of the setters that
+	// other chunks use to assign to exported symbols
+	requireChunkRef ast.Ref
+	setterArgRef    ast.Ref
+
+	// This maps property names to their mangled names, if any
+	mangledProps map[string]string
+
+	// Imports of constants and enums from other files that will be inlined.
+	// These are keyed by the import symbol in the importing file.
+	inlinedConstants map[ast.Ref]ast.Expr
+	inlinedEnums     map[ast.Ref]map[string]float64
+
+	// Dynamic imports that load chunks which aren't already loaded by the
+	// chunk containing the "import()" expression. Only these use "__preload".
+	preloadRecords map[*ast.ImportRecord]bool
 }
 
 type entryPointStatus uint8
@@ -256,6 +274,27 @@ type chunkMeta struct {
 	crossChunkImportRecords []ast.ImportRecord
 	crossChunkPrefixStmts   []ast.Stmt
 	crossChunkSuffixStmts   []ast.Stmt
+
+	// The keys of the chunks this chunk must load before it can run. This is
+	// only used by the chunk loader for the IIFE format.
+	crossChunkImportKeys []string
+
+	// Other chunks refer to this chunk's exports using this symbol when they
+	// can't use an ES6 import, or when they need to assign to an export
+	namespaceRef ast.Ref
+
+	// Uses of symbols from other chunks that must be printed as a property
+	// access off of the other chunk's namespace symbol
+	crossChunkImportAliases map[ast.Ref]ast.NamespaceAlias
+
+	// The paths of all chunks that are loaded when this chunk is loaded, both
+	// directly and indirectly, relative to the output directory
+	transitiveImportRelPaths []string
+
+	// The paths of the entry point chunks loaded by this chunk using "import()",
+	// relative to the output directory. This is only used for the metadata and
+	// manifest files.
+	dynamicImportRelPaths []string
 }
 
 func newLinkerContext(
@@ -379,6 +418,23 @@ func newLinkerContext(
 		})
 	}
 
+	// Allocate the symbols used for cross-chunk imports and exports
+	if options.CodeSplitting {
+		runtimeSymbols := &c.symbols.Outer[runtime.SourceIndex]
+		c.requireChunkRef = ast.Ref{OuterIndex: runtime.SourceIndex, InnerIndex: uint32(len(*runtimeSymbols))}
+		*runtimeSymbols = append(*runtimeSymbols, ast.Symbol{
+			Kind: ast.SymbolUnbound,
+			Name: "__requireChunk",
+			Link: ast.InvalidRef,
+		})
+		c.setterArgRef = ast.Ref{OuterIndex: runtime.SourceIndex, InnerIndex: uint32(len(*runtimeSymbols))}
+		*runtimeSymbols = append(*runtimeSymbols, ast.Symbol{
+			Kind: ast.SymbolHoisted,
+			Name: "value",
+			Link: ast.InvalidRef,
+		})
+	}
+
 	return c
 }
 
@@ -446,14 +502,14 @@ func (c *linkerContext) addRangeError(source logging.Source, r ast.Range, text s
 func (c *linkerContext) link() []OutputFile {
 	c.scanImportsAndExports()
 
-	// Stop now if there were errors
-	if c.hasErrors {
+	// Stop now if there were errors or if the build was cancelled
+	if c.hasErrors || c.options.Cancel.DidCancel() {
 		return []OutputFile{}
 	}
 
 	c.markPartsReachableFromEntryPoints()
 
-	if !c.options.IsBundling {
+	if !c.options.IsBundling && !c.options.IsConvertingFormat() {
 		for _, entryPoint := range c.entryPoints {
 			c.markExportsAsUnbound(entryPoint)
 		}
@@ -463,11 +519,17 @@ func (c *linkerContext) link() []OutputFile {
 	// won't hit concurrent map mutation hazards
 	ast.FollowAllSymbols(c.symbols)
 
-	c.renameOrMinifyAllSymbols()
-
+	// Chunks are computed before renaming since each chunk has a symbol for
+	// its exports that must not collide with any other symbol
 	chunks := c.computeChunks()
+	c.renameOrMinifyAllSymbols(chunks)
 	c.computeCrossChunkDependencies(chunks)
 
+	// Generating code is the most expensive phase, so check before starting it
+	if c.options.Cancel.DidCancel() {
+		return []OutputFile{}
+	}
+
 	// Generate chunks in parallel
 	results := make([][]OutputFile, len(chunks))
 	waitGroup := sync.WaitGroup{}
@@ -515,18 +577,27 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkMeta) {
 	}
 
 	type chunkMeta struct {
-		imports map[ast.Ref]bool
-		exports map[ast.Ref]bool
+		imports  map[ast.Ref]bool
+		exports  map[ast.Ref]bool
+		assigned map[ast.Ref]bool
+	}
+
+	type dynamicImport struct {
+		chunkIndex        uint32
+		targetSourceIndex uint32
+		record            *ast.ImportRecord
 	}
 
 	topLevelDeclaredSymbolToChunk := make(map[ast.Ref]uint32)
 	chunkMetas := make([]chunkMeta, len(chunks))
+	var dynamicImports []dynamicImport
 
 	// For each chunk, see what symbols it uses from other chunks
 	for chunkIndex, chunk := range chunks {
 		chunkKey := string(chunk.entryBits.entries)
 		imports := make(map[ast.Ref]bool)
-		chunkMetas[chunkIndex] = chunkMeta{imports: imports, exports: make(map[ast.Ref]bool)}
+		assigned := make(map[ast.Ref]bool)
+		chunkMetas[chunkIndex] = chunkMeta{imports: imports, exports: make(map[ast.Ref]bool), assigned: assigned}
 
 		// Go over each file in this chunk
 		for sourceIndex := range chunk.filesWithPartsInChunk {
@@ -544,8 +615,19 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkMeta) {
 				for _, importRecordIndex := range part.ImportRecordIndices {
 					record := &file.ast.ImportRecords[importRecordIndex]
 					if record.SourceIndex != nil && c.isExternalDynamicImport(record) {
-						record.Path.Text = c.relativePathBetweenChunks(&chunk, c.fileMeta[*record.SourceIndex].entryPointRelPath)
+						if c.preloadRecords[record] {
+							dynamicImports = append(dynamicImports, dynamicImport{
+								chunkIndex:        uint32(chunkIndex),
+								targetSourceIndex: *record.SourceIndex,
+								record:            record,
+							})
+						}
+						entryPointRelPath := c.fileMeta[*record.SourceIndex].entryPointRelPath
+						record.Path.Text = c.relativePathBetweenChunks(&chunk, entryPointRelPath)
 						record.SourceIndex = nil
+						if c.options.AbsMetadataFile != "" || c.options.AbsManifestFile != "" {
+							chunks[chunkIndex].dynamicImportRelPaths = append(chunks[chunkIndex].dynamicImportRelPaths, entryPointRelPath)
+						}
 					}
 				}
 
@@ -563,7 +645,7 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkMeta) {
 				// Record each symbol used in this part. This will later be matched up
 				// with our map of which chunk a given symbol is declared in to
 				// determine if the symbol needs to be imported from another chunk.
-				for ref := range part.SymbolUses {
+				for ref, use := range part.SymbolUses {
 					symbol := c.symbols.Get(ref)
 
 					// Ignore unbound symbols, which don't have declarations
@@ -589,18 +671,41 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkMeta) {
 						ref = symbol.NamespaceAlias.NamespaceRef
 					}
 
+					// Symbols in a file wrapped in a CommonJS closure are declared inside
+					// the closure, so other chunks can only reference the wrapper itself
+					if otherFile := &c.files[ref.OuterIndex]; c.fileMeta[ref.OuterIndex].cjsWrap && ref != otherFile.ast.WrapperRef {
+						continue
+					}
+
 					// We must record this relationship even for symbols that are not
 					// imports. Due to code splitting, the definition of a symbol may
 					// be moved to a separate chunk than the use of a symbol even if
 					// the definition and use of that symbol are originally from the
 					// same source file.
 					imports[ref] = true
+					if use.IsAssigned {
+						assigned[ref] = true
+					}
 				}
 			}
 		}
 	}
 
+	// Code splitting may cause an assignment to a symbol to end up in a
+	// separate chunk from the symbol's declaration. Imported symbols can't be
+	// assigned to, so these assignments go through a setter that's exported by
+	// the chunk containing the declaration instead.
+	assignedFromOtherChunk := make(map[ast.Ref]bool)
+	for chunkIndex, chunkMeta := range chunkMetas {
+		for ref := range chunkMeta.assigned {
+			if topLevelDeclaredSymbolToChunk[ref] != uint32(chunkIndex) {
+				assignedFromOtherChunk[ref] = true
+			}
+		}
+	}
+
 	// Generate cross-chunk imports
+	directImports := make([][]uint32, len(chunks))
 	for chunkIndex := range chunks {
 		chunk := &chunks[chunkIndex]
 
@@ -631,18 +736,36 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkMeta) {
 
 		var crossChunkImportRecords []ast.ImportRecord
 		var crossChunkPrefixStmts []ast.Stmt
+		var crossChunkImportKeys []string
+		crossChunkImportAliases := make(map[ast.Ref]ast.NamespaceAlias)
 
 		for _, crossChunkImport := range c.sortedCrossChunkImports(chunks, importsFromOtherChunks) {
+			directImports[chunkIndex] = append(directImports[chunkIndex], crossChunkImport.chunkIndex)
+			otherChunk := &chunks[crossChunkImport.chunkIndex]
+
 			switch c.options.OutputFormat {
 			case config.FormatESModule:
 				var items []ast.ClauseItem
+				needsNamespace := false
 				for _, alias := range crossChunkImport.sortedImportAliases {
 					items = append(items, ast.ClauseItem{Name: ast.LocRef{Ref: alias.ref}, Alias: alias.name})
+					if chunkMetas[chunkIndex].assigned[alias.ref] {
+						crossChunkImportAliases[ast.FollowSymbols(c.symbols, alias.ref)] = ast.NamespaceAlias{
+							NamespaceRef: otherChunk.namespaceRef,
+							Alias:        alias.name,
+						}
+						needsNamespace = true
+					}
+				}
+				if needsNamespace {
+					// Also import the object with the setters for assignments
+					namespaceName := c.symbols.Get(otherChunk.namespaceRef).Name
+					items = append(items, ast.ClauseItem{Name: ast.LocRef{Ref: otherChunk.namespaceRef}, Alias: namespaceName})
 				}
 				importRecordIndex := uint32(len(crossChunkImportRecords))
 				crossChunkImportRecords = append(crossChunkImportRecords, ast.ImportRecord{
 					Kind: ast.ImportStmt,
-					Path: ast.Path{Text: c.relativePathBetweenChunks(chunk, chunks[crossChunkImport.chunkIndex].relPath)},
+					Path: ast.Path{Text: c.relativePathBetweenChunks(chunk, otherChunk.relPath)},
 				})
 				if len(items) > 0 {
 					// "import {a, b} from './chunk.js'"
@@ -657,6 +780,30 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkMeta) {
 					}})
 				}
 
+			case config.FormatCommonJS:
+				importRecordIndex := uint32(len(crossChunkImportRecords))
+				crossChunkImportRecords = append(crossChunkImportRecords, ast.ImportRecord{
+					Kind: ast.ImportRequire,
+					Path: ast.Path{Text: c.relativePathBetweenChunks(chunk, otherChunk.relPath)},
+				})
+				value := ast.Expr{Data: &ast.ERequire{ImportRecordIndex: importRecordIndex}}
+				crossChunkPrefixStmts = append(crossChunkPrefixStmts, c.crossChunkImportStmt(
+					otherChunk.namespaceRef, crossChunkImport.sortedImportAliases, value, crossChunkImportAliases))
+
+			case config.FormatIIFE:
+				key := otherChunk.relPath
+				crossChunkImportRecords = append(crossChunkImportRecords, ast.ImportRecord{
+					Kind: ast.ImportRequire,
+					Path: ast.Path{Text: c.relativePathBetweenChunks(chunk, key)},
+				})
+				crossChunkImportKeys = append(crossChunkImportKeys, key)
+				value := ast.Expr{Data: &ast.ECall{
+					Target: ast.Expr{Data: &ast.EIdentifier{Ref: c.requireChunkRef}},
+					Args:   []ast.Expr{{Data: &ast.EString{Value: lexer.StringToUTF16(key)}}},
+				}}
+				crossChunkPrefixStmts = append(crossChunkPrefixStmts, c.crossChunkImportStmt(
+					otherChunk.namespaceRef, crossChunkImport.sortedImportAliases, value, crossChunkImportAliases))
+
 			default:
 				panic("Internal error")
 			}
@@ -664,21 +811,103 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkMeta) {
 
 		chunk.crossChunkImportRecords = crossChunkImportRecords
 		chunk.crossChunkPrefixStmts = crossChunkPrefixStmts
+		chunk.crossChunkImportKeys = crossChunkImportKeys
+		chunk.crossChunkImportAliases = crossChunkImportAliases
+	}
+
+	// Find all chunks that each chunk loads, directly or indirectly
+	transitiveImports := make([]map[uint32]bool, len(chunks))
+	entryPointChunks := make(map[uint32]uint32)
+	for chunkIndex := range chunks {
+		chunk := &chunks[chunkIndex]
+		visited := make(map[uint32]bool)
+		var visit func(uint32)
+		visit = func(otherChunkIndex uint32) {
+			for _, importIndex := range directImports[otherChunkIndex] {
+				if !visited[importIndex] {
+					visited[importIndex] = true
+					visit(importIndex)
+				}
+			}
+		}
+		visit(uint32(chunkIndex))
+		delete(visited, uint32(chunkIndex))
+		transitiveImports[chunkIndex] = visited
+		chunk.transitiveImportRelPaths = sortedChunkRelPaths(chunks, visited)
+		if chunk.isEntryPoint {
+			entryPointChunks[chunk.sourceIndex] = uint32(chunkIndex)
+		}
+	}
+
+	// Preload the chunks a dynamically-imported chunk needs that aren't already
+	// loaded by the time the "import()" expression runs. Whatever entry point
+	// loaded the chunk containing the "import()" expression also loaded every
+	// chunk whose entry bits are a superset of that chunk's entry bits.
+	for _, dynamicImport := range dynamicImports {
+		targetChunkIndex := entryPointChunks[dynamicImport.targetSourceIndex]
+		chunk := &chunks[dynamicImport.chunkIndex]
+		preload := make(map[uint32]bool)
+		for otherChunkIndex := range transitiveImports[targetChunkIndex] {
+			if !isEntryBitSuperset(chunks[otherChunkIndex].entryBits, chunk.entryBits, uint(len(c.entryPoints))) {
+				preload[otherChunkIndex] = true
+			}
+		}
+		var preloadPaths []string
+		for _, relPath := range sortedChunkRelPaths(chunks, preload) {
+			preloadPaths = append(preloadPaths, c.relativePathBetweenChunks(chunk, relPath))
+		}
+		dynamicImport.record.PreloadPaths = preloadPaths
 	}
 
 	// Generate cross-chunk exports
 	for chunkIndex := range chunks {
+		chunk := &chunks[chunkIndex]
+		aliases := c.sortedCrossChunkExportRefs(chunkMetas[chunkIndex].exports)
+		if len(aliases) == 0 {
+			continue
+		}
 		switch c.options.OutputFormat {
 		case config.FormatESModule:
 			var items []ast.ClauseItem
-			for _, alias := range c.sortedCrossChunkExportRefs(chunkMetas[chunkIndex].exports) {
+			var setterAliases crossChunkAliasArray
+			for _, alias := range aliases {
 				items = append(items, ast.ClauseItem{Name: ast.LocRef{Ref: alias.ref}, Alias: alias.name})
+				if assignedFromOtherChunk[alias.ref] {
+					setterAliases = append(setterAliases, alias)
+				}
 			}
-			if len(items) > 0 {
-				chunks[chunkIndex].crossChunkSuffixStmts = []ast.Stmt{{Data: &ast.SExportClause{
-					Items: items,
-				}}}
+			if len(setterAliases) > 0 {
+				// "var chunk_x = {get a() { return a; }, set a(value) { a = value; }};"
+				value := c.crossChunkExportsObject(setterAliases, assignedFromOtherChunk)
+				chunk.crossChunkPrefixStmts = append(chunk.crossChunkPrefixStmts, ast.Stmt{Data: &ast.SLocal{Decls: []ast.Decl{{
+					Binding: ast.Binding{Data: &ast.BIdentifier{Ref: chunk.namespaceRef}},
+					Value:   &value,
+				}}}})
+				namespaceName := c.symbols.Get(chunk.namespaceRef).Name
+				items = append(items, ast.ClauseItem{Name: ast.LocRef{Ref: chunk.namespaceRef}, Alias: namespaceName})
 			}
+			chunk.crossChunkSuffixStmts = []ast.Stmt{{Data: &ast.SExportClause{
+				Items: items,
+			}}}
+
+		case config.FormatCommonJS:
+			// "module.exports = {get a() { return a; }};"
+			//
+			// This comes before the imports of this chunk so that it has already
+			// happened if one of those chunks imports this chunk back.
+			stmt := ast.AssignStmt(
+				ast.Expr{Data: &ast.EDot{
+					Target: ast.Expr{Data: &ast.EIdentifier{Ref: c.unboundModuleRef}},
+					Name:   "exports",
+				}},
+				c.crossChunkExportsObject(aliases, assignedFromOtherChunk),
+			)
+			chunk.crossChunkPrefixStmts = append([]ast.Stmt{stmt}, chunk.crossChunkPrefixStmts...)
+
+		case config.FormatIIFE:
+			// "return {get a() { return a; }};"
+			value := c.crossChunkExportsObject(aliases, assignedFromOtherChunk)
+			chunk.crossChunkSuffixStmts = []ast.Stmt{{Data: &ast.SReturn{Value: &value}}}
 
 		default:
 			panic("Internal error")
@@ -686,6 +915,125 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkMeta) {
 	}
 }
 
+func sortedChunkRelPaths(chunks []chunkMeta, chunkIndices map[uint32]bool) []string {
+	relPaths := make([]string, 0, len(chunkIndices))
+	for chunkIndex := range chunkIndices {
+		relPaths = append(relPaths, chunks[chunkIndex].relPath)
+	}
+	sort.Strings(relPaths)
+	return relPaths
+}
+
+// This generates a cross-chunk import for formats without ES6 import syntax.
+// The value evaluates to the exports of the other chunk. Each use of one of
+// the imported symbols becomes a property access off of the exports so that
+// it's a live binding.
+func (c *linkerContext) crossChunkImportStmt(
+	namespaceRef ast.Ref, aliases crossChunkAliasArray, value ast.Expr, crossChunkImportAliases map[ast.Ref]ast.NamespaceAlias,
+) ast.Stmt {
+	if len(aliases) == 0 {
+		// "require('./chunk.js');"
+		return ast.Stmt{Data: &ast.SExpr{Value: value}}
+	}
+
+	// "var chunk_x = require('./chunk.js');"
+	for _, alias := range aliases {
+		crossChunkImportAliases[ast.FollowSymbols(c.symbols, alias.ref)] = ast.NamespaceAlias{
+			NamespaceRef: namespaceRef,
+			Alias:        alias.name,
+		}
+	}
+	return ast.Stmt{Data: &ast.SLocal{Decls: []ast.Decl{{
+		Binding: ast.Binding{Data: &ast.BIdentifier{Ref: namespaceRef}},
+		Value:   &value,
+	}}}}
+}
+
+// This generates the object that other chunks use to access the exports of
+// a chunk. Each export has a getter so that it's a live binding, and exports
+// that are assigned to from other chunks also have a setter.
+func (c *linkerContext) crossChunkExportsObject(aliases crossChunkAliasArray, hasSetter map[ast.Ref]bool) ast.Expr {
+	properties := make([]ast.Property, 0, len(aliases))
+	for _, alias := range aliases {
+		key := ast.Expr{Data: &ast.EString{Value: lexer.StringToUTF16(alias.name)}}
+
+		// "get a() { return a; }"
+		getter := ast.Expr{Data: &ast.EFunction{Fn: ast.Fn{Body: ast.FnBody{Stmts: []ast.Stmt{{Data: &ast.SReturn{
+			Value: &ast.Expr{Data: &ast.EIdentifier{Ref: alias.ref}},
+		}}}}}}}
+		properties = append(properties, ast.Property{Kind: ast.PropertyGet, IsMethod: true, Key: key, Value: &getter})
+
+		// "set a(value) { a = value; }"
+		if hasSetter[alias.ref] {
+			setter := ast.Expr{Data: &ast.EFunction{Fn: ast.Fn{
+				Args: []ast.Arg{{Binding: ast.Binding{Data: &ast.BIdentifier{Ref: c.setterArgRef}}}},
+				Body: ast.FnBody{Stmts: []ast.Stmt{ast.AssignStmt(
+					ast.Expr{Data: &ast.EIdentifier{Ref: alias.ref}},
+					ast.Expr{Data: &ast.EIdentifier{Ref: c.setterArgRef}},
+				)}},
+			}}}
+			properties = append(properties, ast.Property{Kind: ast.PropertySet, IsMethod: true, Key: key, Value: &setter})
+		}
+	}
+	return ast.Expr{Data: &ast.EObject{Properties: properties}}
+}
+
+// With code splitting, each IIFE entry chunk starts with this small loader.
+// It injects a script tag for each chunk that hasn't been loaded yet and then
+// calls the entry chunk's code once all of them have registered themselves.
+// Chunks that another entry chunk is already loading reuse that script tag.
+// The exports of each chunk are cached so chunks shared between entry points
+// only run once. The global name, if any, becomes a promise for the exports.
+var iifeChunkLoaderLines = []string{
+	"((factory)~=>~{",
+	"\tvar chunks~=~self.__esbuildChunks~||~(self.__esbuildChunks~=~{});",
+	"\tvar loaded~=~self.__esbuildChunkExports~||~(self.__esbuildChunkExports~=~{});",
+	"\tvar loading~=~self.__esbuildChunkLoads~||~(self.__esbuildChunkLoads~=~{});",
+	"\tvar base~=~document.currentScript.src;",
+	"\tvar requireChunk~=~(key)~=>~key in loaded~?~loaded[key]~:~loaded[key]~=~chunks[key](requireChunk);",
+	"\tvar load~=~([key,~path])~=>~key in chunks~||~loading[key]~||~(loading[key]~=~new Promise((resolve,~reject)~=>~{",
+	"\t\tvar script~=~document.createElement(\"script\");",
+	"\t\tscript.src~=~new URL(path,~base);",
+	"\t\tscript.onload~=~resolve;",
+	"\t\tscript.onerror~=~reject;",
+	"\t\tdocument.head.appendChild(script);",
+	"\t}));",
+	"\treturn Promise.all([%s].map(load)).then(()~=>~factory(requireChunk));",
+	"})",
+}
+
+func iifeChunkLoader(chunk chunkMeta, removeWhitespace bool) string {
+	space := " "
+	if removeWhitespace {
+		space = ""
+	}
+
+	// Each chunk is registered using its path relative to the output directory
+	// but is loaded using its path relative to this chunk
+	quotedChunks := make([]string, len(chunk.crossChunkImportKeys))
+	for i, key := range chunk.crossChunkImportKeys {
+		quotedChunks[i] = "[" + printer.Quote(key) + "," + space + printer.Quote(chunk.crossChunkImportRecords[i].Path.Text) + "]"
+	}
+
+	sb := strings.Builder{}
+	for _, line := range iifeChunkLoaderLines {
+		if removeWhitespace {
+			line = strings.ReplaceAll(line, "\t", "")
+		} else {
+			line = strings.ReplaceAll(line, "\t", "  ")
+		}
+		line = strings.ReplaceAll(line, "~", space)
+		if strings.Contains(line, "%s") {
+			line = fmt.Sprintf(line, strings.Join(quotedChunks, ","+space))
+		}
+		sb.WriteString(line)
+		if !removeWhitespace && line != "})" {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
 type crossChunkImport struct {
 	chunkIndex          uint32
 	sortingKey          string
@@ -985,10 +1333,32 @@ func (c *linkerContext) scanImportsAndExports() {
 		file := &c.files[sourceIndex]
 		fileMeta := &c.fileMeta[sourceIndex]
 
+		// Inlining happens before binding because removing code that can never
+		// run may also remove the only uses of other imports
+		var inlinedRefs map[ast.Ref]bool
+		if c.options.MangleSyntax {
+			for importRef, importToBind := range fileMeta.importsToBind {
+				if c.maybeInlineImport(file, importRef, &c.files[importToBind.sourceIndex], importToBind.ref) {
+					if inlinedRefs == nil {
+						inlinedRefs = make(map[ast.Ref]bool)
+					}
+					inlinedRefs[importRef] = true
+				}
+			}
+			if inlinedRefs != nil {
+				c.inlineConstantsInFile(sourceIndex, inlinedRefs)
+			}
+		}
+
 		for importRef, importToBind := range fileMeta.importsToBind {
 			resolvedFile := &c.files[importToBind.sourceIndex]
 			partsDeclaringSymbol := resolvedFile.ast.TopLevelSymbolToParts[importToBind.ref]
 
+			// Don't depend on the declaration if every use was inlined
+			if inlinedRefs[importRef] {
+				partsDeclaringSymbol = nil
+			}
+
 			for _, partIndex := range file.ast.NamedImports[importRef].LocalPartsWithUses {
 				partMeta := &fileMeta.partMeta[partIndex]
 
@@ -1006,6 +1376,46 @@ func (c *linkerContext) scanImportsAndExports() {
 	}
 }
 
+// Primitive constants and TypeScript enum members are inlined into the files
+// that import them. This returns true if all uses of the import will be
+// replaced, in which case the importing file no longer needs the declaration.
+func (c *linkerContext) maybeInlineImport(file *file, importRef ast.Ref, resolvedFile *file, resolvedRef ast.Ref) bool {
+	if value, ok := resolvedFile.ast.ConstValues[resolvedRef]; ok {
+		if c.inlinedConstants == nil {
+			c.inlinedConstants = make(map[ast.Ref]ast.Expr)
+		}
+		c.inlinedConstants[importRef] = value
+		return true
+	}
+
+	if values, ok := resolvedFile.ast.EnumValues[resolvedRef]; ok {
+		// Only inline an enum if all uses are reads of known members. Otherwise
+		// the enum object itself is still needed.
+		namedImport := file.ast.NamedImports[importRef]
+		uses := uint32(0)
+		for _, partIndex := range namedImport.LocalPartsWithUses {
+			uses += file.ast.Parts[partIndex].SymbolUses[importRef].CountEstimate
+		}
+		reads := uint32(0)
+		for name, count := range namedImport.PropertyReads {
+			if _, ok := values[name]; !ok {
+				return false
+			}
+			reads += count
+		}
+		if reads == 0 || reads != uses {
+			return false
+		}
+		if c.inlinedEnums == nil {
+			c.inlinedEnums = make(map[ast.Ref]map[string]float64)
+		}
+		c.inlinedEnums[importRef] = values
+		return true
+	}
+
+	return false
+}
+
 func (c *linkerContext) generateCodeForLazyExport(sourceIndex uint32, file *file, fileMeta *fileMeta) {
 	// Grab the lazy expression
 	if len(file.ast.Parts) < 1 {
@@ -1769,72 +2179,91 @@ func (c *linkerContext) markPartsReachableFromEntryPoints() {
 		c.includeFile(entryPoint, uint(i), 0)
 	}
 
-	c.handleCrossChunkAssignments()
+	if c.options.PreloadDynamicImports && c.options.CodeSplitting {
+		c.includePreloadHelper()
+	}
 }
 
-// Code splitting may cause an assignment to a local variable to end up in a
-// separate chunk from the variable. This is bad because that will generate
-// an assignment to an import, which will fail. Make sure these parts end up
-// in the same chunk in these cases.
-func (c *linkerContext) handleCrossChunkAssignments() {
-	if len(c.entryPoints) < 2 {
-		// No need to do this if there cannot be cross-chunk assignments
-		return
+// Whether a dynamic import needs to preload anything depends on the chunks,
+// which depend on which parts are included. Entry bits at this point are what
+// chunks are made from, so this decides which dynamic imports will have
+// something to preload and only then includes the "__preload" helper for them.
+func (c *linkerContext) includePreloadHelper() {
+	entryPointCount := uint(len(c.entryPoints))
+	entryPointBits := make(map[uint32]uint)
+	for i, entryPoint := range c.entryPoints {
+		entryPointBits[entryPoint] = uint(i)
 	}
-	neverReachedEntryBits := newBitSet(uint(len(c.entryPoints)))
 
+	// Each distinct set of entry bits will become a chunk
+	chunkBits := make(map[string]bitSet)
 	for _, sourceIndex := range c.reachableFiles {
-		file := &c.files[sourceIndex]
-		partMeta := c.fileMeta[sourceIndex].partMeta
-
-		// Initialize a union-find data structure to merge parts together
-		unionFind := make([]uint32, len(file.ast.Parts))
-		for partIndex := range file.ast.Parts {
-			unionFind[partIndex] = uint32(partIndex)
+		for _, partMeta := range c.fileMeta[sourceIndex].partMeta {
+			chunkBits[string(partMeta.entryBits.entries)] = partMeta.entryBits
 		}
+	}
 
-		// Look up a merged label
-		var find func(uint32) uint32
-		find = func(label uint32) uint32 {
-			next := unionFind[label]
-			if next != label {
-				next = find(next)
-				unionFind[label] = next
+	c.preloadRecords = make(map[*ast.ImportRecord]bool)
+	for _, sourceIndex := range c.reachableFiles {
+		file := &c.files[sourceIndex]
+		fileMeta := &c.fileMeta[sourceIndex]
+		for partIndex, partMeta := range fileMeta.partMeta {
+			part := &file.ast.Parts[partIndex]
+			preloadUses := uint32(0)
+			for _, importRecordIndex := range part.ImportRecordIndices {
+				record := &file.ast.ImportRecords[importRecordIndex]
+				if record.SourceIndex == nil || !c.isExternalDynamicImport(record) {
+					continue
+				}
+				targetBit := entryPointBits[*record.SourceIndex]
+				for _, bits := range chunkBits {
+					if bits.hasBit(targetBit) && !isOnlyEntryBit(bits, targetBit) &&
+						!isEntryBitSuperset(bits, partMeta.entryBits, entryPointCount) {
+						c.preloadRecords[record] = true
+						preloadUses++
+						break
+					}
+				}
 			}
-			return next
-		}
-
-		for partIndex, part := range file.ast.Parts {
-			// Ignore this part if it's dead code
-			if partMeta[partIndex].entryBits.equals(neverReachedEntryBits) {
+			if preloadUses == 0 {
 				continue
 			}
-
-			// If this part assigns to a local variable, make sure the parts for the
-			// variable's declaration are in the same chunk as this part
-			for ref, use := range part.SymbolUses {
-				if use.IsAssigned {
-					if otherParts, ok := file.ast.TopLevelSymbolToParts[ref]; ok {
-						for _, otherPartIndex := range otherParts {
-							// Union the two labels together
-							a := find(uint32(partIndex))
-							b := find(otherPartIndex)
-							unionFind[a] = b
-							partMeta[b].entryBits.bitwiseOrWith(partMeta[a].entryBits)
-						}
+			runtimeFile := &c.files[runtime.SourceIndex]
+			preloadRef := runtimeFile.ast.NamedExports["__preload"]
+			c.generateUseOfSymbolForInclude(part, fileMeta, preloadUses, preloadRef, runtime.SourceIndex)
+			for bit := uint(0); bit < entryPointCount; bit++ {
+				if partMeta.entryBits.hasBit(bit) {
+					for _, runtimePartIndex := range runtimeFile.ast.TopLevelSymbolToParts[preloadRef] {
+						c.includePart(runtime.SourceIndex, runtimePartIndex, bit, fileMeta.distanceFromEntryPoint)
 					}
 				}
 			}
 		}
+	}
+}
 
-		// Update the entry bits of parts that were merged
-		for partIndex := range file.ast.Parts {
-			label := find(uint32(partIndex))
-			if label != uint32(partIndex) {
-				partMeta[partIndex].entryBits.copyFrom(partMeta[label].entryBits)
-			}
+func isOnlyEntryBit(bits bitSet, bit uint) bool {
+	for i, entry := range bits.entries {
+		expected := byte(0)
+		if uint(i) == bit/8 {
+			expected = 1 << (bit & 7)
+		}
+		if entry != expected {
+			return false
 		}
 	}
+	return true
+}
+
+// Any entry point that loads a chunk with the entry bits "b" also loads every
+// chunk with entry bits "a" if "a" is a superset of "b"
+func isEntryBitSuperset(a bitSet, b bitSet, entryPointCount uint) bool {
+	for bit := uint(0); bit < entryPointCount; bit++ {
+		if b.hasBit(bit) && !a.hasBit(bit) {
+			return false
+		}
+	}
+	return true
 }
 
 func (c *linkerContext) accumulateSymbolCount(ref ast.Ref, count uint32) {
@@ -1842,6 +2271,22 @@ func (c *linkerContext) accumulateSymbolCount(ref ast.Ref, count uint32) {
 	c.symbols.Get(ref).UseCountEstimate += count
 }
 
+func (c *linkerContext) canBeRemovedIfUnused(part *ast.Part) bool {
+	// Calls to imported functions marked "@__NO_SIDE_EFFECTS__" don't stop a
+	// part from being removed. Imports have already been bound to exports by
+	// now, so following the symbol gives us the function declaration.
+	if len(part.CanBeRemovedIfImportedCallsAreUnused) > 0 {
+		for _, ref := range part.CanBeRemovedIfImportedCallsAreUnused {
+			if !c.symbols.Get(ast.FollowSymbols(c.symbols, ref)).CallCanBeUnwrappedIfUnused {
+				return false
+			}
+		}
+		return true
+	}
+
+	return part.CanBeRemovedIfUnused
+}
+
 func (c *linkerContext) includeFile(sourceIndex uint32, entryPointBit uint, distanceFromEntryPoint uint32) {
 	fileMeta := &c.fileMeta[sourceIndex]
 
@@ -1867,7 +2312,7 @@ func (c *linkerContext) includeFile(sourceIndex uint32, entryPointBit uint, dist
 	}
 
 	for partIndex, part := range file.ast.Parts {
-		canBeRemovedIfUnused := part.CanBeRemovedIfUnused
+		canBeRemovedIfUnused := c.canBeRemovedIfUnused(&part)
 
 		// Don't include the entry point part if we're not the entry point
 		if fileMeta.entryPointExportPartIndex != nil && uint32(partIndex) == *fileMeta.entryPointExportPartIndex &&
@@ -1891,6 +2336,12 @@ func (c *linkerContext) includeFile(sourceIndex uint32, entryPointBit uint, dist
 					continue
 				}
 
+				// Also don't include it if the only uses of its imports were in
+				// code that has been eliminated as dead code
+				if record.IsOnlyUsedInDeadCode {
+					continue
+				}
+
 				// Otherwise, include this module for its side effects
 				c.includeFile(otherSourceIndex, entryPointBit, distanceFromEntryPoint)
 			}
@@ -2058,6 +2509,16 @@ func (c *linkerContext) includePart(sourceIndex uint32, partIndex uint32, entryP
 	}
 }
 
+// Chunks that aren't entry points use the same extension as entry points
+func (c *linkerContext) outputExtension() string {
+	if c.options.AbsOutputFile != "" {
+		if ext := c.fs.Ext(c.options.AbsOutputFile); ext != "" {
+			return ext
+		}
+	}
+	return ".js"
+}
+
 func (c *linkerContext) computeChunks() []chunkMeta {
 	chunks := make(map[string]chunkMeta)
 	neverReachedKey := string(newBitSet(uint(len(c.entryPoints))).entries)
@@ -2077,10 +2538,9 @@ func (c *linkerContext) computeChunks() []chunkMeta {
 				chunkRelPath = c.fs.Base(source.KeyPath.Text)
 			}
 
-			// Swap the extension for ".js"
-			ext := c.fs.Ext(chunkRelPath)
-			if ext != ".js" {
-				chunkRelPath = chunkRelPath[:len(chunkRelPath)-len(ext)] + ".js"
+			// Swap the extension for the output extension
+			if ext, outExt := c.fs.Ext(chunkRelPath), c.outputExtension(); ext != outExt {
+				chunkRelPath = chunkRelPath[:len(chunkRelPath)-len(ext)] + outExt
 			}
 		}
 
@@ -2101,6 +2561,12 @@ func (c *linkerContext) computeChunks() []chunkMeta {
 		}
 	}
 
+	// Force files into manual chunks before grouping the remaining parts by
+	// which entry points reach them
+	if len(c.options.ManualChunks) > 0 {
+		c.assignManualChunks(chunks, neverReachedKey)
+	}
+
 	// Figure out which files are in which chunk
 	for _, sourceIndex := range c.reachableFiles {
 		for _, partMeta := range c.fileMeta[sourceIndex].partMeta {
@@ -2130,7 +2596,7 @@ func (c *linkerContext) computeChunks() []chunkMeta {
 					bytes := []byte(lowerCaseAbsPathForWindows(chunk.relPath))
 					hashBytes := sha1.Sum(bytes)
 					hash := base64.URLEncoding.EncodeToString(hashBytes[:])[:8]
-					chunk.relPath = "chunk." + hash + ".js"
+					chunk.relPath = "chunk." + hash + c.outputExtension()
 				}
 
 				chunk.entryBits = partMeta.entryBits
@@ -2141,6 +2607,11 @@ func (c *linkerContext) computeChunks() []chunkMeta {
 		}
 	}
 
+	// Avoid lots of tiny chunks
+	if c.options.MinChunkSize > 0 {
+		c.mergeSmallChunks(chunks)
+	}
+
 	// Sort the chunks for determinism. This mostly doesn't matter because each
 	// chunk is a separate file, but it matters for error messages in tests since
 	// tests stop on the first output mismatch.
@@ -2153,9 +2624,283 @@ func (c *linkerContext) computeChunks() []chunkMeta {
 	for i, key := range sortedKeys {
 		sortedChunks[i] = chunks[key]
 	}
+
+	// Allocate a symbol for the exports of each chunk
+	if c.options.CodeSplitting {
+		runtimeSymbols := &c.symbols.Outer[runtime.SourceIndex]
+		for i := range sortedChunks {
+			chunk := &sortedChunks[i]
+			name := ast.GenerateNonUniqueNameFromPath(chunk.relPath)
+			if !strings.HasPrefix(name, "chunk_") {
+				name = "chunk_" + name
+			}
+			chunk.namespaceRef = ast.Ref{OuterIndex: runtime.SourceIndex, InnerIndex: uint32(len(*runtimeSymbols))}
+			*runtimeSymbols = append(*runtimeSymbols, ast.Symbol{
+				Kind: ast.SymbolOther,
+				Name: name,
+				Link: ast.InvalidRef,
+			})
+		}
+	}
 	return sortedChunks
 }
 
+// Manual chunks are identified by an extra bit after the entry point bits.
+// Each one also has the bits of all entry points that reach any of its parts
+// so that those entry points load it. Parts that the manual chunk depends on
+// are moved into it as well. Otherwise the manual chunk could end up importing
+// from a chunk that imports it back, such as an entry point chunk.
+func (c *linkerContext) assignManualChunks(chunks map[string]chunkMeta, neverReachedKey string) {
+	entryPointCount := uint(len(c.entryPoints))
+	bitCount := entryPointCount + uint(len(c.options.ManualChunks))
+	manualBits := make([]bitSet, len(c.options.ManualChunks))
+	isManual := make(map[partRef]bool)
+	var worklists [][]partRef
+
+	for i := range c.options.ManualChunks {
+		manualBits[i] = newBitSet(bitCount)
+		manualBits[i].setBit(entryPointCount + uint(i))
+		worklists = append(worklists, nil)
+	}
+
+	// Assign each matching file to the first manual chunk that matches it.
+	// Entry points are never moved since they must stay in their own chunk.
+	for _, sourceIndex := range c.reachableFiles {
+		fileMeta := &c.fileMeta[sourceIndex]
+		if sourceIndex == runtime.SourceIndex || fileMeta.entryPointStatus != entryPointNone {
+			continue
+		}
+		for i, manualChunk := range c.options.ManualChunks {
+			if manualChunk.Pattern.MatchString(c.sources[sourceIndex].PrettyPath) {
+				for partIndex, partMeta := range fileMeta.partMeta {
+					ref := partRef{sourceIndex: sourceIndex, partIndex: uint32(partIndex)}
+					if string(partMeta.entryBits.entries) != neverReachedKey && !isManual[ref] {
+						isManual[ref] = true
+						worklists[i] = append(worklists[i], ref)
+					}
+				}
+				break
+			}
+		}
+	}
+
+	for i, manualChunk := range c.options.ManualChunks {
+		worklist := worklists[i]
+		if len(worklist) == 0 {
+			continue
+		}
+		bits := manualBits[i]
+		chunk := chunkMeta{
+			entryBits:             bits,
+			relPath:               manualChunk.Name + c.outputExtension(),
+			filesWithPartsInChunk: make(map[uint32]bool),
+		}
+
+		for len(worklist) > 0 {
+			ref := worklist[len(worklist)-1]
+			worklist = worklist[:len(worklist)-1]
+			fileMeta := &c.fileMeta[ref.sourceIndex]
+			partMeta := &fileMeta.partMeta[ref.partIndex]
+
+			// Move this part into the manual chunk
+			for bit := uint(0); bit < entryPointCount; bit++ {
+				if partMeta.entryBits.hasBit(bit) {
+					bits.setBit(bit)
+				}
+			}
+			partMeta.entryBits = bits
+			fileMeta.entryBits = bits
+			chunk.filesWithPartsInChunk[ref.sourceIndex] = true
+
+			// Pull in the parts that declare the symbols this part uses
+			for _, dep := range c.manualChunkDependencies(ref) {
+				if !isManual[dep] && string(c.fileMeta[dep.sourceIndex].partMeta[dep.partIndex].entryBits.entries) != neverReachedKey {
+					isManual[dep] = true
+					worklist = append(worklist, dep)
+				}
+			}
+		}
+
+		for _, entryChunk := range chunks {
+			if entryChunk.relPath == chunk.relPath {
+				c.log.AddError(nil, ast.Loc{}, fmt.Sprintf(
+					"The manual chunk %q has the same output path as an entry point", manualChunk.Name))
+			}
+		}
+		chunks[string(bits.entries)] = chunk
+	}
+}
+
+// This returns the parts that must be in the same chunk as a manual chunk
+// part. CommonJS wrappers can't be split up, so their files move as a whole.
+func (c *linkerContext) manualChunkDependencies(ref partRef) (deps []partRef) {
+	file := &c.files[ref.sourceIndex]
+	fileMeta := &c.fileMeta[ref.sourceIndex]
+	part := &file.ast.Parts[ref.partIndex]
+
+	if fileMeta.cjsWrap {
+		for partIndex := range file.ast.Parts {
+			deps = append(deps, partRef{sourceIndex: ref.sourceIndex, partIndex: uint32(partIndex)})
+		}
+	}
+
+	for symbolRef := range part.SymbolUses {
+		if c.symbols.Get(symbolRef).Kind == ast.SymbolUnbound {
+			continue
+		}
+		if importToBind, ok := fileMeta.importsToBind[symbolRef]; ok {
+			symbolRef = importToBind.ref
+		}
+		if namespaceAlias := c.symbols.Get(symbolRef).NamespaceAlias; namespaceAlias != nil {
+			symbolRef = namespaceAlias.NamespaceRef
+		}
+		otherSourceIndex := symbolRef.OuterIndex
+		for _, partIndex := range c.files[otherSourceIndex].ast.TopLevelSymbolToParts[symbolRef] {
+			deps = append(deps, partRef{sourceIndex: otherSourceIndex, partIndex: partIndex})
+		}
+	}
+
+	return
+}
+
+// Chunks that are too small are merged into the smallest other chunk that is
+// loaded by all of the same entry points. Larger sets of entry points are
+// handled first so that a chain of small chunks ends up in a single chunk.
+// Chunks with side effects are never merged since that would cause entry
+// points that load the target chunk to run code they never imported.
+func (c *linkerContext) mergeSmallChunks(chunks map[string]chunkMeta) {
+	entryPointCount := uint(len(c.entryPoints))
+	popCount := func(bits bitSet) (count int) {
+		for bit := uint(0); bit < entryPointCount; bit++ {
+			if bits.hasBit(bit) {
+				count++
+			}
+		}
+		return
+	}
+	isManualChunk := func(bits bitSet) bool {
+		for bit := entryPointCount; bit < uint(len(bits.entries))*8; bit++ {
+			if bits.hasBit(bit) {
+				return true
+			}
+		}
+		return false
+	}
+	isSubset := func(a bitSet, b bitSet) bool {
+		for bit := uint(0); bit < entryPointCount; bit++ {
+			if a.hasBit(bit) && !b.hasBit(bit) {
+				return false
+			}
+		}
+		return true
+	}
+	sizes := make(map[string]int)
+	hasSideEffects := make(map[string]bool)
+	partSizes := make(map[uint32][]int)
+	for key, chunk := range chunks {
+		for sourceIndex := range chunk.filesWithPartsInChunk {
+			file := &c.files[sourceIndex]
+			if partSizes[sourceIndex] == nil {
+				partSizes[sourceIndex] = c.estimatePartSizes(sourceIndex)
+			}
+			for partIndex, partMeta := range c.fileMeta[sourceIndex].partMeta {
+				if string(partMeta.entryBits.entries) == key {
+					sizes[key] += partSizes[sourceIndex][partIndex]
+					if !file.ast.Parts[partIndex].CanBeRemovedIfUnused {
+						hasSideEffects[key] = true
+					}
+				}
+			}
+		}
+	}
+
+	// Only automatically-generated chunks are candidates for merging
+	var candidates []string
+	for key, chunk := range chunks {
+		if !chunk.isEntryPoint && !isManualChunk(chunk.entryBits) {
+			candidates = append(candidates, key)
+		}
+	}
+	sort.Slice(candidates, func(i int, j int) bool {
+		a, b := popCount(chunks[candidates[i]].entryBits), popCount(chunks[candidates[j]].entryBits)
+		return a > b || (a == b && chunks[candidates[i]].relPath < chunks[candidates[j]].relPath)
+	})
+
+	for _, key := range candidates {
+		chunk := chunks[key]
+		if sizes[key] >= c.options.MinChunkSize || hasSideEffects[key] {
+			continue
+		}
+
+		// Find the chunk to merge into
+		targetKey := ""
+		targetCount := 0
+		for otherKey, other := range chunks {
+			if otherKey == key || !isSubset(chunk.entryBits, other.entryBits) {
+				continue
+			}
+			count := popCount(other.entryBits)
+			if targetKey == "" || count < targetCount || (count == targetCount && other.relPath < chunks[targetKey].relPath) {
+				targetKey = otherKey
+				targetCount = count
+			}
+		}
+		if targetKey == "" {
+			continue
+		}
+
+		// Move all parts in this chunk over to the other chunk
+		target := chunks[targetKey]
+		for sourceIndex := range chunk.filesWithPartsInChunk {
+			fileMeta := &c.fileMeta[sourceIndex]
+			for partIndex, partMeta := range fileMeta.partMeta {
+				if string(partMeta.entryBits.entries) == key {
+					fileMeta.partMeta[partIndex].entryBits = target.entryBits
+				}
+			}
+			target.filesWithPartsInChunk[sourceIndex] = true
+		}
+		sizes[targetKey] += sizes[key]
+		delete(chunks, key)
+	}
+}
+
+// Chunk sizes are compared before any code is generated, so this estimates
+// the size of each part using the source text of its statements. Each
+// statement extends to the start of the next statement in the file.
+func (c *linkerContext) estimatePartSizes(sourceIndex uint32) []int {
+	parts := c.files[sourceIndex].ast.Parts
+	var starts []int
+	for _, part := range parts {
+		for _, stmt := range part.Stmts {
+			starts = append(starts, int(stmt.Loc.Start))
+		}
+	}
+	sort.Ints(starts)
+
+	// Statements generated by the compiler may share a location with another
+	// statement, so only count the text after each location once
+	sizes := make([]int, len(parts))
+	counted := make(map[int]bool)
+	end := len(c.sources[sourceIndex].Contents)
+	for partIndex, part := range parts {
+		for _, stmt := range part.Stmts {
+			start := int(stmt.Loc.Start)
+			if counted[start] {
+				continue
+			}
+			counted[start] = true
+			next := sort.SearchInts(starts, start+1)
+			if next < len(starts) {
+				sizes[partIndex] += starts[next] - start
+			} else if end > start {
+				sizes[partIndex] += end - start
+			}
+		}
+	}
+	return sizes
+}
+
 type chunkOrder struct {
 	sourceIndex uint32
 	distance    uint32
@@ -2288,7 +3033,7 @@ func (c *linkerContext) shouldRemoveImportExportStmt(
 }
 
 func (c *linkerContext) convertStmtsForChunk(sourceIndex uint32, stmtList *stmtList, partStmts []ast.Stmt) {
-	shouldStripExports := c.options.IsBundling || sourceIndex == runtime.SourceIndex
+	shouldStripExports := c.options.IsBundling || c.options.IsConvertingFormat() || sourceIndex == runtime.SourceIndex
 	shouldExtractES6StmtsForCJSWrap := c.fileMeta[sourceIndex].cjsWrap
 
 	for _, stmt := range partStmts {
@@ -2548,6 +3293,8 @@ func (c *linkerContext) generateCodeForFileInChunk(
 	entryBits bitSet,
 	commonJSRef ast.Ref,
 	toModuleRef ast.Ref,
+	preloadRef ast.Ref,
+	crossChunkImports map[ast.Ref]ast.NamespaceAlias,
 	result *compileResult,
 ) {
 	file := &c.files[sourceIndex]
@@ -2650,10 +3397,16 @@ func (c *linkerContext) generateCodeForFileInChunk(
 		OutputFormat:        c.options.OutputFormat,
 		RemoveWhitespace:    c.options.RemoveWhitespace,
 		ToModuleRef:         toModuleRef,
+		PreloadRef:          preloadRef,
+		CrossChunkImports:   crossChunkImports,
 		SourceMapContents:   sourceMapContents,
 		ExtractComments:     c.options.IsBundling && c.options.RemoveWhitespace,
 		UnsupportedFeatures: c.options.UnsupportedFeatures,
 	}
+	if sourceIndex != runtime.SourceIndex {
+		// The runtime was parsed without property mangling
+		printOptions.MangledProps = c.mangledProps
+	}
 	tree := file.ast
 	tree.Parts = []ast.Part{{Stmts: stmts}}
 	*result = compileResult{
@@ -2682,9 +3435,11 @@ func (c *linkerContext) generateCodeForFileInChunk(
 func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 	filesInChunkInOrder := c.chunkFileOrder(chunk)
 	compileResults := make([]compileResult, 0, len(filesInChunkInOrder))
+	var assetAbsPaths []string
 	runtimeMembers := c.files[runtime.SourceIndex].ast.ModuleScope.Members
 	commonJSRef := ast.FollowSymbols(c.symbols, runtimeMembers["__commonJS"])
 	toModuleRef := ast.FollowSymbols(c.symbols, runtimeMembers["__toModule"])
+	preloadRef := ast.FollowSymbols(c.symbols, runtimeMembers["__preload"])
 
 	// Generate JavaScript for each file in parallel
 	waitGroup := sync.WaitGroup{}
@@ -2698,6 +3453,7 @@ func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 		// output directory. This is used by the "file" loader.
 		if additionalFile := c.files[sourceIndex].additionalFile; additionalFile != nil {
 			results = append(results, *additionalFile)
+			assetAbsPaths = append(assetAbsPaths, additionalFile.AbsPath)
 		}
 
 		// Create a goroutine for this file
@@ -2710,6 +3466,8 @@ func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 			chunk.entryBits,
 			commonJSRef,
 			toModuleRef,
+			preloadRef,
+			chunk.crossChunkImportAliases,
 			compileResult,
 		)
 	}
@@ -2777,7 +3535,18 @@ func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 	if c.options.OutputFormat == config.FormatIIFE {
 		indent = "  "
 		text := "(()" + space + "=>" + space + "{" + newline
-		if c.options.ModuleName != "" {
+		if c.options.CodeSplitting {
+			if chunk.isEntryPoint {
+				// Entry chunks load the chunks they depend on before running
+				text = iifeChunkLoader(chunk, c.options.RemoveWhitespace) +
+					"((__requireChunk)" + space + "=>" + space + "{" + newline
+			} else {
+				// Other chunks register themselves so entry chunks can run them
+				text = "(self.__esbuildChunks" + space + "||" + space + "(self.__esbuildChunks" + space + "=" + space + "{}))[" +
+					printer.Quote(chunk.relPath) + "]" + space + "=" + space + "(__requireChunk)" + space + "=>" + space + "{" + newline
+			}
+		}
+		if c.options.ModuleName != "" && (!c.options.CodeSplitting || chunk.isEntryPoint) {
 			text = "var " + c.options.ModuleName + space + "=" + space + text
 		}
 		prevOffset.advance(text)
@@ -2796,23 +3565,72 @@ func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 	if c.options.AbsMetadataFile != "" {
 		isFirstMeta := true
 		jMeta.AddString("{\n      \"imports\": [")
-		for _, record := range chunk.crossChunkImportRecords {
+		addImport := func(importAbsPath string, kind ast.ImportKind) {
 			if isFirstMeta {
 				isFirstMeta = false
 			} else {
 				jMeta.AddString(",")
 			}
+			jMeta.AddString(fmt.Sprintf("\n        {\n          \"path\": %s,\n          \"kind\": %s\n        }",
+				printer.QuoteForJSON(c.res.PrettyPath(importAbsPath)), printer.QuoteForJSON(metadataImportKind(kind))))
+		}
+		for _, record := range chunk.crossChunkImportRecords {
 			chunkAbsPath := c.fs.Join(c.options.AbsOutputDir, chunk.relPath)
-			importAbsPath := c.fs.Join(c.fs.Dir(chunkAbsPath), record.Path.Text)
-			jMeta.AddString(fmt.Sprintf("\n        {\n          \"path\": %s\n        }",
-				printer.QuoteForJSON(c.res.PrettyPath(importAbsPath))))
+			addImport(c.fs.Join(c.fs.Dir(chunkAbsPath), record.Path.Text), record.Kind)
+		}
+		for _, relPath := range sortedUniqueStrings(chunk.dynamicImportRelPaths) {
+			addImport(c.fs.Join(c.options.AbsOutputDir, relPath), ast.ImportDynamic)
 		}
 		if !isFirstMeta {
 			jMeta.AddString("\n      ")
 		}
-		jMeta.AddString("],\n      \"inputs\": {")
+		jMeta.AddString("],")
+
+		// Also list every chunk loaded by this chunk so servers can preload them
+		if c.options.CodeSplitting {
+			jMeta.AddString("\n      \"transitiveImports\": [")
+			for i, relPath := range chunk.transitiveImportRelPaths {
+				if i > 0 {
+					jMeta.AddString(",")
+				}
+				importAbsPath := c.fs.Join(c.options.AbsOutputDir, relPath)
+				jMeta.AddString(fmt.Sprintf("\n        {\n          \"path\": %s\n        }",
+					printer.QuoteForJSON(c.res.PrettyPath(importAbsPath))))
+			}
+			if len(chunk.transitiveImportRelPaths) > 0 {
+				jMeta.AddString("\n      ")
+			}
+			jMeta.AddString("],")
+		}
+
+		// List the names this chunk exports as an entry point. Exports that are
+		// only there so other chunks can import them are internal details.
+		var exports []string
+		if chunk.isEntryPoint {
+			exports = c.fileMeta[chunk.sourceIndex].sortedAndFilteredExportAliases
+		}
+		jMeta.AddString("\n      \"exports\": [")
+		for i, alias := range exports {
+			if i > 0 {
+				jMeta.AddString(",")
+			}
+			jMeta.AddString("\n        " + printer.QuoteForJSON(alias))
+		}
+		if len(exports) > 0 {
+			jMeta.AddString("\n      ")
+		}
+		jMeta.AddString("],")
+
+		// Outputs that aren't entry points don't have this field
+		if chunk.isEntryPoint {
+			jMeta.AddString(fmt.Sprintf("\n      \"entryPoint\": %s,",
+				printer.QuoteForJSON(c.sources[chunk.sourceIndex].PrettyPath)))
+		}
+
+		jMeta.AddString("\n      \"inputs\": {")
 	}
 	isFirstMeta := true
+	bytesInOutput := make(map[uint32]int)
 
 	// Concatenate the generated JavaScript chunks together
 	var compileResultsForSourceMap []compileResult
@@ -2860,6 +3678,7 @@ func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 			compileResult.generatedOffset = prevOffset
 			j.AddBytes(compileResult.JS)
 			prevOffset = lineColumnOffset{}
+			bytesInOutput[compileResult.sourceIndex] += len(compileResult.JS)
 
 			// Include this file in the source map
 			if c.options.SourceMap != config.SourceMapNone {
@@ -2897,7 +3716,13 @@ func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 
 	// Optionally wrap with an IIFE
 	if c.options.OutputFormat == config.FormatIIFE {
-		j.AddString("})();" + newline)
+		if !c.options.CodeSplitting {
+			j.AddString("})();" + newline)
+		} else if chunk.isEntryPoint {
+			j.AddString("});" + newline)
+		} else {
+			j.AddString("};" + newline)
+		}
 	}
 
 	// Make sure the file ends with a newline
@@ -2932,13 +3757,14 @@ func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 			var jsonMetadataChunk []byte
 			if c.options.AbsMetadataFile != "" {
 				jsonMetadataChunk = []byte(fmt.Sprintf(
-					"{\n      \"imports\": [],\n      \"inputs\": {},\n      \"bytes\": %d\n    }", len(sourceMap)))
+					"{\n      \"imports\": [],\n      \"exports\": [],\n      \"inputs\": {},\n      \"bytes\": %d\n    }", len(sourceMap)))
 			}
 
 			results = append(results, OutputFile{
 				AbsPath:           jsAbsPath + ".map",
 				Contents:          sourceMap,
 				jsonMetadataChunk: jsonMetadataChunk,
+				isSourceMap:       true,
 			})
 
 			// Add a comment linking the source to its map
@@ -2962,11 +3788,22 @@ func (c *linkerContext) generateChunk(chunk chunkMeta) (results []OutputFile) {
 		jsonMetadataChunk = jMeta.Done()
 	}
 
-	results = append(results, OutputFile{
+	outputFile := OutputFile{
 		AbsPath:           jsAbsPath,
 		Contents:          jsContents,
 		jsonMetadataChunk: jsonMetadataChunk,
-	})
+		bytesInOutput:     bytesInOutput,
+	}
+	if chunk.isEntryPoint {
+		sourceIndex := chunk.sourceIndex
+		outputFile.entryPointSourceIndex = &sourceIndex
+		outputFile.transitiveImportRelPaths = chunk.transitiveImportRelPaths
+	}
+	if c.options.AbsManifestFile != "" {
+		outputFile.dynamicImportRelPaths = sortedUniqueStrings(append([]string{}, chunk.dynamicImportRelPaths...))
+		outputFile.assetAbsPaths = assetAbsPaths
+	}
+	results = append(results, outputFile)
 	return
 }
 
@@ -3069,7 +3906,7 @@ func (c *linkerContext) markExportsAsUnbound(sourceIndex uint32) {
 	}
 }
 
-func (c *linkerContext) renameOrMinifyAllSymbols() {
+func (c *linkerContext) renameOrMinifyAllSymbols(chunks []chunkMeta) {
 	topLevelScopes := make([]*ast.Scope, 0, len(c.files))
 	moduleScopes := make([]*ast.Scope, 0, len(c.files))
 
@@ -3122,13 +3959,39 @@ func (c *linkerContext) renameOrMinifyAllSymbols() {
 		}
 	}
 
+	// The symbols for cross-chunk imports and exports don't belong to any file.
+	// Pretend they are declared in a top-level scope of their own, with the
+	// setter argument in a nested scope.
+	if c.options.CodeSplitting {
+		namespaceRefs := make([]ast.Ref, len(chunks))
+		for i, chunk := range chunks {
+			namespaceRefs[i] = chunk.namespaceRef
+		}
+		topLevelScopes = append(topLevelScopes, &ast.Scope{
+			Members:   make(map[string]ast.Ref),
+			Generated: namespaceRefs,
+			Children: []*ast.Scope{{
+				Members:   make(map[string]ast.Ref),
+				Generated: []ast.Ref{c.setterArgRef},
+			}},
+		})
+	}
+
 	// Avoid collisions with any unbound symbols in this module group
 	reservedNames := computeReservedNames(moduleScopes, c.symbols)
-	if c.options.IsBundling {
+	if c.options.IsBundling || c.options.IsConvertingFormat() {
 		// These are used to implement bundling, and need to be free for use
 		reservedNames["require"] = true
 		reservedNames["Promise"] = true
 	}
+	if c.options.CodeSplitting && c.options.OutputFormat == config.FormatIIFE {
+		// This is the parameter of the function wrapping each chunk
+		reservedNames["__requireChunk"] = true
+	}
+	if c.options.CodeSplitting && c.options.OutputFormat == config.FormatCommonJS {
+		// This is used for the exports of each chunk
+		reservedNames["module"] = true
+	}
 
 	if c.options.MinifyIdentifiers {
 		minifyAllSymbols(reservedNames, topLevelScopes, c.symbols)
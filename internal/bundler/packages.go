@@ -0,0 +1,168 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/logging"
+)
+
+type packageInfo struct {
+	name    string
+	version string
+	absDir  string // The directory containing the "package.json" file
+}
+
+// Several copies of a package with the same name and version can be installed
+// in different "node_modules" directories. This redirects imports of a file in
+// one of these copies to the same file in the copy closest to the root of the
+// file system, as long as that file is also part of the bundle. The files in
+// the other copies are then left out of the bundle if nothing else uses them.
+func dedupePackages(fs fs.FS, sources []logging.Source, files []file, visited map[string]uint32) {
+	// Pick one directory for each name and version
+	canonicalDirs := make(map[string]string)
+	for _, f := range files {
+		if f.pkg.name == "" || f.pkg.version == "" {
+			continue
+		}
+		key := f.pkg.name + "@" + f.pkg.version
+		if dir, ok := canonicalDirs[key]; !ok || isBetterCanonicalDir(f.pkg.absDir, dir) {
+			canonicalDirs[key] = f.pkg.absDir
+		}
+	}
+
+	for i := range files {
+		for j := range files[i].ast.ImportRecords {
+			record := &files[i].ast.ImportRecords[j]
+			if record.SourceIndex == nil {
+				continue
+			}
+			pkg := files[*record.SourceIndex].pkg
+			if pkg.name == "" || pkg.version == "" {
+				continue
+			}
+			canonicalDir := canonicalDirs[pkg.name+"@"+pkg.version]
+			if canonicalDir == pkg.absDir {
+				continue
+			}
+
+			// Only redirect the import if the other copy has this exact file too
+			relPath, ok := fs.Rel(pkg.absDir, sources[*record.SourceIndex].KeyPath.Text)
+			if !ok {
+				continue
+			}
+			otherIndex, ok := visited[lowerCaseAbsPathForWindows(fs.Join(canonicalDir, relPath))]
+			if ok && files[otherIndex].pkg == (packageInfo{name: pkg.name, version: pkg.version, absDir: canonicalDir}) {
+				record.SourceIndex = &otherIndex
+			}
+		}
+	}
+}
+
+// Prefer the shallowest directory, and then the first one in sorted order
+func isBetterCanonicalDir(a string, b string) bool {
+	depthA := strings.Count(strings.ReplaceAll(a, "\\", "/"), "/")
+	depthB := strings.Count(strings.ReplaceAll(b, "\\", "/"), "/")
+	if depthA != depthB {
+		return depthA < depthB
+	}
+	return a < b
+}
+
+// This warns about each package that has more than one copy in the same
+// bundle. Copies may have different versions or may be the same version
+// installed in more than one place. Only files that actually contributed code
+// to an output file count, so copies that were completely removed by tree
+// shaking don't cause a warning. Each bundle is the group of output files from
+// one linking operation, so separate entry points without code splitting don't
+// count as duplicates of each other.
+func (b *Bundle) warnAboutDuplicatePackages(log logging.Log, bundles [][]OutputFile) {
+	type packageCopy struct {
+		version string
+		absDir  string
+	}
+	type duplicatePackage struct {
+		copies      map[string]packageCopy
+		wastedBytes int
+	}
+	duplicatesByName := make(map[string]*duplicatePackage)
+	versionsByDir := make(map[string]string)
+
+	for _, outputFiles := range bundles {
+		// Count the bytes for each copy of each package in this bundle
+		bytesByName := make(map[string]map[string]int)
+		for _, outputFile := range outputFiles {
+			for sourceIndex, bytes := range outputFile.bytesInOutput {
+				pkg := b.files[sourceIndex].pkg
+				if pkg.name == "" {
+					continue
+				}
+				bytesByDir := bytesByName[pkg.name]
+				if bytesByDir == nil {
+					bytesByDir = make(map[string]int)
+					bytesByName[pkg.name] = bytesByDir
+				}
+				bytesByDir[pkg.absDir] += bytes
+				versionsByDir[pkg.absDir] = pkg.version
+			}
+		}
+
+		for name, bytesByDir := range bytesByName {
+			if len(bytesByDir) < 2 {
+				continue
+			}
+			duplicate := duplicatesByName[name]
+			if duplicate == nil {
+				duplicate = &duplicatePackage{copies: make(map[string]packageCopy)}
+				duplicatesByName[name] = duplicate
+			}
+
+			// Everything except the largest copy in this bundle is considered to
+			// be wasted
+			totalBytes := 0
+			largestBytes := 0
+			for absDir, bytes := range bytesByDir {
+				totalBytes += bytes
+				if bytes > largestBytes {
+					largestBytes = bytes
+				}
+				duplicate.copies[absDir] = packageCopy{version: versionsByDir[absDir], absDir: absDir}
+			}
+			duplicate.wastedBytes += totalBytes - largestBytes
+		}
+	}
+
+	// Sort for determinism
+	names := make([]string, 0, len(duplicatesByName))
+	for name := range duplicatesByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		duplicate := duplicatesByName[name]
+		copies := make([]packageCopy, 0, len(duplicate.copies))
+		for _, c := range duplicate.copies {
+			copies = append(copies, c)
+		}
+		sort.Slice(copies, func(i int, j int) bool {
+			return copies[i].absDir < copies[j].absDir
+		})
+
+		descriptions := make([]string, len(copies))
+		for i, c := range copies {
+			version := c.version
+			if version == "" {
+				version = "(no version)"
+			}
+			descriptions[i] = fmt.Sprintf("%s at %s", version, b.res.PrettyPath(c.absDir))
+		}
+
+		log.AddRangeWarningWithID("duplicate-package", nil, ast.Range{}, fmt.Sprintf(
+			"Package %q is in the bundle %d times, which adds %d bytes: %s",
+			name, len(copies), duplicate.wastedBytes, strings.Join(descriptions, ", ")))
+	}
+}
@@ -417,3 +417,81 @@ func (a slotAndCountArray) Less(i int, j int) bool {
 	ai, aj := a[i], a[j]
 	return ai.count > aj.count || (ai.count == aj.count && ai.slot < aj.slot)
 }
+
+////////////////////////////////////////////////////////////////////////////////
+// mangleProps() implementation
+
+// Property names are renamed consistently across all files in the bundle so
+// that a property that is written in one file and read in another still
+// matches up. The most frequently-used names get the shortest replacements.
+// Names from the cache are reused as-is so that output stays stable across
+// builds, and the returned cache contains every name that was assigned.
+func mangleProps(files []file, cache map[string]interface{}) (map[string]string, map[string]interface{}) {
+	counts := make(map[string]uint32)
+	reserved := make(map[string]bool)
+
+	// All keywords are reserved names
+	for k := range lexer.Keywords() {
+		reserved[k] = true
+	}
+
+	for _, f := range files {
+		for name, count := range f.ast.MangledProps {
+			counts[name] += count
+		}
+		for name := range f.ast.ReservedProps {
+			reserved[name] = true
+		}
+	}
+
+	mangled := make(map[string]string)
+	outputCache := make(map[string]interface{}, len(cache))
+	for name, value := range cache {
+		outputCache[name] = value
+		switch v := value.(type) {
+		case string:
+			reserved[v] = true
+		case bool:
+			// A "false" in the cache means the property must not be mangled
+			if !v {
+				reserved[name] = true
+			}
+		}
+	}
+
+	// Sort for determinism
+	sorted := make([]string, 0, len(counts))
+	for name := range counts {
+		if reserved[name] {
+			continue
+		}
+		if value, ok := cache[name]; ok {
+			if v, ok := value.(string); ok {
+				mangled[name] = v
+			}
+			continue
+		}
+		sorted = append(sorted, name)
+	}
+	sort.Slice(sorted, func(i int, j int) bool {
+		ci, cj := counts[sorted[i]], counts[sorted[j]]
+		return ci > cj || (ci == cj && sorted[i] < sorted[j])
+	})
+
+	// Avoid generating a name that is also an original property name, since
+	// that name may be left alone or may be mangled into something else
+	next := 0
+	for _, name := range sorted {
+		for {
+			candidate := lexer.NumberToMinifiedName(next)
+			next++
+			if _, ok := counts[candidate]; !ok && !reserved[candidate] {
+				mangled[name] = candidate
+				outputCache[name] = candidate
+				break
+			}
+		}
+	}
+
+	return mangled, outputCache
+}
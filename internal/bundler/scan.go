@@ -0,0 +1,156 @@
+package bundler
+
+import (
+	"sort"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/logging"
+	"github.com/evanw/esbuild/internal/parser"
+	"github.com/evanw/esbuild/internal/runtime"
+)
+
+type ScannedImportKind uint8
+
+const (
+	ScannedImportStmt ScannedImportKind = iota
+	ScannedReExport
+	ScannedRequire
+	ScannedDynamicImport
+)
+
+type ScannedImport struct {
+	Kind     ScannedImportKind
+	Location *logging.MsgLocation // The location of the import path
+	Path     string               // The import path as it was written
+
+	// The pretty path of the module this import resolved to. This is only set
+	// for modules in a bundle and is empty for external imports.
+	ResolvedPath string
+}
+
+type ScannedModule struct {
+	PrettyPath   string
+	IsEntryPoint bool
+	Imports      []ScannedImport
+
+	// The names of the ES6 exports in sorted order. This doesn't include names
+	// that are only re-exported using "export * from 'path'".
+	Exports []string
+
+	// A module uses CommonJS if it uses "exports" or "module" or if it has a
+	// top-level return statement. Calls to "require()" are listed as imports.
+	UsesCommonJS   bool
+	UsesImportMeta bool
+}
+
+// This parses a single file and reports its imports and exports without
+// resolving, transforming, or printing anything
+func ScanFile(log logging.Log, source logging.Source, loader config.Loader, options config.Options) ScannedModule {
+	// Parse as if bundling so that calls to "require()" become import records
+	options.IsBundling = true
+	options.IsScanningImports = true
+
+	switch loader {
+	case config.LoaderJSX:
+		options.JSX.Parse = true
+	case config.LoaderTS:
+		options.TS.Parse = true
+	case config.LoaderTSX:
+		options.TS.Parse = true
+		options.JSX.Parse = true
+	}
+
+	tree, ok := parser.Parse(log, source, options)
+	if !ok {
+		return ScannedModule{PrettyPath: source.PrettyPath}
+	}
+	return scanModule(&source, &tree, nil)
+}
+
+// This reports the imports and exports of every module in the bundle along
+// with the module that each import resolved to. The bundle isn't linked, so
+// this includes modules that tree shaking would have removed.
+func (b *Bundle) ScanModules() []ScannedModule {
+	isEntryPoint := make(map[uint32]bool)
+	for _, entryPoint := range b.entryPoints {
+		isEntryPoint[entryPoint] = true
+	}
+
+	modules := []ScannedModule{}
+	for sourceIndex := range b.sources {
+		if uint32(sourceIndex) == runtime.SourceIndex {
+			continue
+		}
+		module := scanModule(&b.sources[sourceIndex], &b.files[sourceIndex].ast, b.sources)
+		module.IsEntryPoint = isEntryPoint[uint32(sourceIndex)]
+		modules = append(modules, module)
+	}
+	return modules
+}
+
+func scanModule(source *logging.Source, tree *ast.AST, sources []logging.Source) ScannedModule {
+	module := ScannedModule{
+		PrettyPath:     source.PrettyPath,
+		Exports:        make([]string, 0, len(tree.NamedExports)),
+		UsesCommonJS:   tree.HasCommonJSFeatures(),
+		UsesImportMeta: tree.UsesImportMeta,
+	}
+
+	// Only report the import records that are still used by some part. For
+	// example, TypeScript imports that are only used as types are removed.
+	isUsed := make(map[uint32]bool)
+
+	// Re-exports are import statements as far as the linker is concerned, so
+	// they have to be found by looking at the statements themselves
+	isReExport := make(map[uint32]bool)
+	for _, part := range tree.Parts {
+		for _, importRecordIndex := range part.ImportRecordIndices {
+			isUsed[importRecordIndex] = true
+		}
+		for _, stmt := range part.Stmts {
+			switch s := stmt.Data.(type) {
+			case *ast.SExportFrom:
+				isReExport[s.ImportRecordIndex] = true
+			case *ast.SExportStar:
+				isReExport[s.ImportRecordIndex] = true
+			}
+		}
+	}
+
+	for i, record := range tree.ImportRecords {
+		// Skip imports that were removed and imports of the runtime that were
+		// generated by the parser
+		if !isUsed[uint32(i)] || (record.SourceIndex != nil && *record.SourceIndex == runtime.SourceIndex) {
+			continue
+		}
+
+		var kind ScannedImportKind
+		switch {
+		case record.Kind == ast.ImportRequire:
+			kind = ScannedRequire
+		case record.Kind == ast.ImportDynamic:
+			kind = ScannedDynamicImport
+		case isReExport[uint32(i)]:
+			kind = ScannedReExport
+		default:
+			kind = ScannedImportStmt
+		}
+
+		scanned := ScannedImport{
+			Kind:     kind,
+			Location: logging.LocationOrNil(source, source.RangeOfString(record.Loc)),
+			Path:     record.Path.Text,
+		}
+		if record.SourceIndex != nil && sources != nil {
+			scanned.ResolvedPath = sources[*record.SourceIndex].PrettyPath
+		}
+		module.Imports = append(module.Imports, scanned)
+	}
+
+	for alias := range tree.NamedExports {
+		module.Exports = append(module.Exports, alias)
+	}
+	sort.Strings(module.Exports)
+	return module
+}
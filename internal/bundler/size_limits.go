@@ -0,0 +1,157 @@
+package bundler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/logging"
+)
+
+// This is how many inputs are listed when an output is over its size limit
+const sizeLimitInputCount = 3
+
+func (b *Bundle) checkSizeLimits(log logging.Log, options config.Options, outputFiles []OutputFile) {
+	// Source maps and the metadata file don't count toward any limit
+	var measured []*OutputFile
+	for i := range outputFiles {
+		if !outputFiles[i].isSourceMap {
+			measured = append(measured, &outputFiles[i])
+		}
+	}
+
+	// Chunks are found by their path when collecting the chunks for an entry point
+	chunksByRelPath := make(map[string]*OutputFile)
+	for _, outputFile := range measured {
+		if relPath, ok := b.fs.Rel(options.AbsOutputDir, outputFile.AbsPath); ok {
+			chunksByRelPath[strings.ReplaceAll(relPath, "\\", "/")] = outputFile
+		}
+	}
+
+	for _, limit := range options.SizeLimits {
+		switch {
+		case limit.EntryPoint != "":
+			found := false
+			for _, outputFile := range measured {
+				if outputFile.entryPointSourceIndex != nil {
+					keyPath := b.sources[*outputFile.entryPointSourceIndex].KeyPath
+					if keyPath.IsAbsolute && keyPath.Text == limit.EntryPoint {
+						// With code splitting, the entry point also needs every chunk that
+						// it imports to run, so those count toward its limit too
+						files := []*OutputFile{outputFile}
+						for _, importRelPath := range outputFile.transitiveImportRelPaths {
+							if chunk, ok := chunksByRelPath[importRelPath]; ok {
+								files = append(files, chunk)
+							}
+						}
+						subject := fmt.Sprintf("Output file %q is", b.prettyOutputPath(outputFile.AbsPath))
+						if len(files) > 1 {
+							subject = fmt.Sprintf("Output file %q and the chunks it imports are", b.prettyOutputPath(outputFile.AbsPath))
+						}
+						b.checkSizeLimit(log, limit, subject, files...)
+						found = true
+					}
+				}
+			}
+			if !found {
+				log.AddError(nil, ast.Loc{}, fmt.Sprintf("The size limit for %q doesn't match an entry point", b.res.PrettyPath(limit.EntryPoint)))
+			}
+
+		case limit.Pattern != nil:
+			for _, outputFile := range measured {
+				relPath, ok := b.fs.Rel(options.AbsOutputDir, outputFile.AbsPath)
+				if !ok {
+					relPath = b.fs.Base(outputFile.AbsPath)
+				}
+				if limit.Pattern.MatchString(strings.ReplaceAll(relPath, "\\", "/")) {
+					b.checkSizeLimit(log, limit, fmt.Sprintf("Output file %q is", b.prettyOutputPath(outputFile.AbsPath)), outputFile)
+				}
+			}
+
+		default:
+			b.checkSizeLimit(log, limit, "The output files are", measured...)
+		}
+	}
+}
+
+func (b *Bundle) checkSizeLimit(log logging.Log, limit config.SizeLimit, subject string, outputFiles ...*OutputFile) {
+	size := 0
+	for _, outputFile := range outputFiles {
+		if limit.Gzip {
+			size += gzipSize(outputFile.Contents)
+		} else {
+			size += len(outputFile.Contents)
+		}
+	}
+	if size <= limit.MaxBytes {
+		return
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(subject)
+	if limit.Gzip {
+		sb.WriteString(fmt.Sprintf(" %d bytes after gzip, which is over the limit of %d bytes", size, limit.MaxBytes))
+	} else {
+		sb.WriteString(fmt.Sprintf(" %d bytes, which is over the limit of %d bytes", size, limit.MaxBytes))
+	}
+
+	// Name the inputs that contributed the most code to help explain the size
+	type input struct {
+		prettyPath string
+		bytes      int
+	}
+	bytesBySource := make(map[uint32]int)
+	for _, outputFile := range outputFiles {
+		for sourceIndex, count := range outputFile.bytesInOutput {
+			bytesBySource[sourceIndex] += count
+		}
+	}
+	inputs := make([]input, 0, len(bytesBySource))
+	for sourceIndex, count := range bytesBySource {
+		inputs = append(inputs, input{prettyPath: b.sources[sourceIndex].PrettyPath, bytes: count})
+	}
+	sort.Slice(inputs, func(i int, j int) bool {
+		if inputs[i].bytes != inputs[j].bytes {
+			return inputs[i].bytes > inputs[j].bytes
+		}
+		return inputs[i].prettyPath < inputs[j].prettyPath
+	})
+	if len(inputs) > sizeLimitInputCount {
+		inputs = inputs[:sizeLimitInputCount]
+	}
+	if len(inputs) > 0 {
+		sb.WriteString(" (largest inputs: ")
+		for i, input := range inputs {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(fmt.Sprintf("%s is %d bytes", input.prettyPath, input.bytes))
+		}
+		sb.WriteString(")")
+	}
+
+	if limit.IsWarning {
+		log.AddRangeWarningWithID("size-limit", nil, ast.Range{}, sb.String())
+	} else {
+		log.AddError(nil, ast.Loc{}, sb.String())
+	}
+}
+
+func (b *Bundle) prettyOutputPath(absPath string) string {
+	if relPath, ok := b.fs.Rel(b.fs.Cwd(), absPath); ok {
+		return strings.ReplaceAll(relPath, "\\", "/")
+	}
+	return absPath
+}
+
+func gzipSize(contents []byte) int {
+	buffer := bytes.Buffer{}
+	writer, _ := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	writer.Write(contents)
+	writer.Close()
+	return buffer.Len()
+}
@@ -0,0 +1,298 @@
+package bundler
+
+import (
+	"strings"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/logging"
+	"github.com/evanw/esbuild/internal/runtime"
+)
+
+type WhyImport struct {
+	Location *logging.MsgLocation // The location of the import path
+	Path     string               // The import path as it was written
+}
+
+type WhyModule struct {
+	PrettyPath string
+
+	// The shortest chain of imports from each entry point that reaches this
+	// module. The chain is empty if the module is that entry point.
+	ImportChains [][]WhyImport
+
+	// Top-level statements with side effects. These keep the module in the
+	// bundle once it's imported even if none of its exports are used.
+	SideEffects []*logging.MsgLocation
+
+	// Other reasons this module can't be removed by tree shaking
+	Notes []string
+}
+
+// This explains why each module matching "target" is part of the bundle. The
+// target can be the path of a module or the name of a package, in which case
+// every module in that package matches. The bundle is linked the same way as
+// in "Compile" so modules and imports removed by tree shaking are ignored.
+func (b *Bundle) Why(log logging.Log, options config.Options, target string) []WhyModule {
+	absTarget, _ := b.fs.Abs(target)
+	matches := []uint32{}
+	for sourceIndex, source := range b.sources {
+		if uint32(sourceIndex) == runtime.SourceIndex {
+			continue
+		}
+		if source.PrettyPath == target || (source.KeyPath.IsAbsolute && source.KeyPath.Text == absTarget) ||
+			(source.KeyPath.IsAbsolute && packageNameForPath(source.KeyPath.Text) == target) {
+			matches = append(matches, uint32(sourceIndex))
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	// Link each group of entry points the same way "Compile" does, but stop
+	// after tree shaking
+	applyLinkerDefaults(&options)
+	var linkers []*linkerContext
+	if options.CodeSplitting {
+		c := newLinkerContext(&options, log, b.fs, b.res, b.sources, b.files, b.entryPoints, "")
+		linkers = append(linkers, &c)
+	} else {
+		for _, entryPoint := range b.entryPoints {
+			c := newLinkerContext(&options, log, b.fs, b.res, b.sources, b.files, []uint32{entryPoint}, "")
+			linkers = append(linkers, &c)
+		}
+	}
+	for _, c := range linkers {
+		c.scanImportsAndExports()
+		if c.hasErrors {
+			return nil
+		}
+		c.markPartsReachableFromEntryPoints()
+	}
+
+	modules := make([]WhyModule, len(matches))
+	for i, sourceIndex := range matches {
+		modules[i] = b.whyModule(linkers, sourceIndex)
+	}
+
+	// Find the shortest chain of imports from each entry point to each match
+	for _, c := range linkers {
+		for _, entryPoint := range b.entryPoints {
+			if bit, ok := c.entryPointBit(entryPoint); ok {
+				c.whyImportChains(entryPoint, bit, matches, modules)
+			}
+		}
+	}
+
+	// Only report modules that are actually in the bundle
+	var result []WhyModule
+	for _, module := range modules {
+		if len(module.ImportChains) > 0 {
+			result = append(result, module)
+		}
+	}
+	return result
+}
+
+func (c *linkerContext) entryPointBit(sourceIndex uint32) (uint, bool) {
+	for bit, entryPoint := range c.entryPoints {
+		if entryPoint == sourceIndex {
+			return uint(bit), true
+		}
+	}
+	return 0, false
+}
+
+func (c *linkerContext) isPartLive(sourceIndex uint32, partIndex uint32, bit uint) bool {
+	return c.fileMeta[sourceIndex].partMeta[partIndex].entryBits.hasBit(bit)
+}
+
+// This is a breadth-first search from the entry point that only follows
+// imports that cause the imported module to be in the bundle. Dynamic imports
+// with code splitting lead to another entry point, so the search continues
+// using the parts included for that entry point instead.
+func (c *linkerContext) whyImportChains(entryPoint uint32, entryBit uint, matches []uint32, modules []WhyModule) {
+	type step struct {
+		importer          uint32
+		importRecordIndex uint32
+	}
+	steps := map[uint32]step{}
+	visited := map[uint32]bool{entryPoint: true}
+	bits := map[uint32]uint{entryPoint: entryBit}
+	queue := []uint32{entryPoint}
+	for len(queue) > 0 {
+		sourceIndex := queue[0]
+		queue = queue[1:]
+		bit := bits[sourceIndex]
+		file := &c.files[sourceIndex]
+
+		for partIndex, part := range file.ast.Parts {
+			for _, importRecordIndex := range part.ImportRecordIndices {
+				record := &file.ast.ImportRecords[importRecordIndex]
+				if record.SourceIndex == nil || visited[*record.SourceIndex] || record.IsOnlyUsedInDeadCode {
+					continue
+				}
+				other := *record.SourceIndex
+				otherBit := bit
+				if record.Kind == ast.ImportDynamic && c.options.CodeSplitting {
+					otherBit, _ = c.entryPointBit(other)
+				}
+				if !c.fileMeta[other].entryBits.hasBit(otherBit) {
+					continue
+				}
+
+				// The import must be in code that's in the bundle. Import statements
+				// are removed when bundling, but one still counts if the module is
+				// included for its side effects or something it imports is used.
+				if !c.isPartLive(sourceIndex, uint32(partIndex), bit) &&
+					(record.Kind != ast.ImportStmt || !c.isImportUsed(sourceIndex, importRecordIndex, bit)) {
+					continue
+				}
+
+				visited[other] = true
+				bits[other] = otherBit
+				steps[other] = step{importer: sourceIndex, importRecordIndex: importRecordIndex}
+				queue = append(queue, other)
+			}
+		}
+	}
+
+	for i, sourceIndex := range matches {
+		if !visited[sourceIndex] {
+			continue
+		}
+		chain := []WhyImport{}
+		for sourceIndex != entryPoint {
+			s := steps[sourceIndex]
+			importer := &c.sources[s.importer]
+			record := &c.files[s.importer].ast.ImportRecords[s.importRecordIndex]
+			chain = append(chain, WhyImport{
+				Location: logging.LocationOrNil(importer, importer.RangeOfString(record.Loc)),
+				Path:     record.Path.Text,
+			})
+			sourceIndex = s.importer
+		}
+
+		// The chain was built backward starting from the module
+		for a, b := 0, len(chain)-1; a < b; a, b = a+1, b-1 {
+			chain[a], chain[b] = chain[b], chain[a]
+		}
+		modules[i].ImportChains = append(modules[i].ImportChains, chain)
+	}
+}
+
+// This returns true if a module has side effects that cause it to be included
+// by an import statement, or if any of the imported names are used
+func (c *linkerContext) isImportUsed(sourceIndex uint32, importRecordIndex uint32, bit uint) bool {
+	file := &c.files[sourceIndex]
+	record := &file.ast.ImportRecords[importRecordIndex]
+	if !c.files[*record.SourceIndex].ignoreIfUnused {
+		return true
+	}
+	for _, namedImport := range file.ast.NamedImports {
+		if namedImport.ImportRecordIndex != importRecordIndex {
+			continue
+		}
+		if namedImport.IsExported {
+			return true
+		}
+		for _, partIndex := range namedImport.LocalPartsWithUses {
+			if c.isPartLive(sourceIndex, partIndex, bit) {
+				return true
+			}
+		}
+		for partIndex, part := range file.ast.Parts {
+			if _, ok := part.SymbolUses[namedImport.NamespaceRef]; ok && c.isPartLive(sourceIndex, uint32(partIndex), bit) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b *Bundle) whyModule(linkers []*linkerContext, sourceIndex uint32) WhyModule {
+	source := &b.sources[sourceIndex]
+	file := &b.files[sourceIndex]
+	module := WhyModule{PrettyPath: source.PrettyPath}
+
+	for _, entryPoint := range b.entryPoints {
+		if entryPoint == sourceIndex {
+			module.Notes = append(module.Notes, "This module is an entry point")
+			break
+		}
+	}
+
+	if file.ast.UsesExportsRef || file.ast.UsesModuleRef || file.ast.HasTopLevelReturn {
+		module.Notes = append(module.Notes, "This module uses CommonJS features, so it's included in its entirety")
+	}
+
+	// Files imported using "require()" are wrapped in a closure and can't be
+	// tree shaken. Dynamic imports are converted to "require()" when bundling
+	// without code splitting. Only imports in code that's included count.
+	isRequired := false
+	for _, c := range linkers {
+		for _, otherSourceIndex := range c.reachableFiles {
+			other := &c.files[otherSourceIndex]
+			for partIndex, part := range other.ast.Parts {
+				if c.fileMeta[otherSourceIndex].partMeta[partIndex].entryBits.equals(newBitSet(uint(len(c.entryPoints)))) {
+					continue
+				}
+				for _, importRecordIndex := range part.ImportRecordIndices {
+					record := &other.ast.ImportRecords[importRecordIndex]
+					if record.SourceIndex != nil && *record.SourceIndex == sourceIndex && !record.IsOnlyUsedInDeadCode &&
+						(record.Kind == ast.ImportRequire || (record.Kind == ast.ImportDynamic && !c.options.CodeSplitting)) {
+						isRequired = true
+					}
+				}
+			}
+		}
+	}
+	if isRequired {
+		module.Notes = append(module.Notes, "This module is imported using \"require()\" or \"import()\", so it's included in its entirety")
+	}
+
+	if file.ignoreIfUnused {
+		module.Notes = append(module.Notes, "The \"sideEffects\" field in \"package.json\" says this module has no side effects, "+
+			"so it's only included if one of its exports is used")
+	}
+
+	// Only report statements that are kept in the bundle because of their
+	// side effects, not ones that tree shaking removes
+	for partIndex, part := range file.ast.Parts {
+		if len(part.Stmts) == 0 {
+			continue
+		}
+		for _, c := range linkers {
+			meta := &c.fileMeta[sourceIndex]
+			if partIndex < len(meta.partMeta) && !meta.partMeta[partIndex].entryBits.equals(newBitSet(uint(len(c.entryPoints)))) &&
+				!c.canBeRemovedIfUnused(&c.files[sourceIndex].ast.Parts[partIndex]) {
+				module.SideEffects = append(module.SideEffects, logging.LocationOrNil(source, ast.Range{Loc: part.Stmts[0].Loc}))
+				break
+			}
+		}
+	}
+
+	return module
+}
+
+// This returns the name of the innermost package containing the path, or an
+// empty string if it's not inside a "node_modules" directory. Scoped packages
+// such as "@scope/pkg" include the scope in the name.
+func packageNameForPath(path string) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	i := strings.LastIndex(path, "/node_modules/")
+	if i == -1 {
+		return ""
+	}
+	parts := strings.SplitN(path[i+len("/node_modules/"):], "/", 3)
+	if strings.HasPrefix(parts[0], "@") {
+		if len(parts) < 3 {
+			return ""
+		}
+		return parts[0] + "/" + parts[1]
+	}
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0]
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"regexp"
+	"sync/atomic"
+
 	"github.com/evanw/esbuild/internal/compat"
 )
 
@@ -119,6 +122,76 @@ type StdinInfo struct {
 	AbsResolveDir string
 }
 
+// Files whose path relative to the current directory matches "Pattern" are
+// forced into a chunk called "Name" when code splitting. The path uses forward
+// slashes, like the paths in the comments in the output files.
+type ManualChunk struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Plugins run callbacks during the build. Each callback only runs for paths
+// that match its filter, and for paths in its namespace if one is given.
+// Callbacks run in the order they were added, and the first one to return a
+// result wins.
+type Plugin struct {
+	Name      string
+	OnResolve []OnResolveCallback
+	OnLoad    []OnLoadCallback
+}
+
+type OnResolveCallback struct {
+	Filter    *regexp.Regexp
+	Namespace string
+	Callback  func(OnResolveArgs) (OnResolveResult, error)
+}
+
+type OnResolveArgs struct {
+	Path       string
+	Importer   string
+	Namespace  string // The namespace of the importer
+	ResolveDir string
+}
+
+// An empty "Path" means this callback didn't handle the import. Paths in the
+// "file" namespace (the default) must be absolute file system paths.
+type OnResolveResult struct {
+	Path      string
+	Namespace string
+	External  bool
+}
+
+type OnLoadCallback struct {
+	Filter    *regexp.Regexp
+	Namespace string
+	Callback  func(OnLoadArgs) (OnLoadResult, error)
+}
+
+type OnLoadArgs struct {
+	Path      string
+	Namespace string
+}
+
+// A nil "Contents" means this callback didn't handle the file. Imports in
+// the file are resolved relative to "ResolveDir" if it's present.
+type OnLoadResult struct {
+	Contents   *string
+	ResolveDir string
+	Loader     Loader
+}
+
+// An output size budget. It applies to the output file for "EntryPoint" if
+// that's present, to each output file with a path matching "Pattern" if that's
+// present, and otherwise to the total size of all output files. Patterns are
+// matched against paths relative to the output directory.
+type SizeLimit struct {
+	EntryPoint string // An absolute path
+	Pattern    *regexp.Regexp
+	MaxBytes   int
+	Gzip       bool // Measure the size after gzip compression
+	IsWarning  bool // Report a warning instead of an error
+}
+
 type ExternalModules struct {
 	NodeModules map[string]bool
 	AbsPaths    map[string]bool
@@ -129,9 +202,15 @@ type Options struct {
 	// false: imports are left alone and the file is passed through as-is
 	IsBundling bool
 
+	// If true, the file is only parsed to list its imports. It's parsed as if
+	// bundling, but there are no warnings about code that can't be bundled.
+	IsScanningImports bool
+
 	RemoveWhitespace  bool
 	MinifyIdentifiers bool
 	MangleSyntax      bool
+	DropConsole       bool
+	DropDebugger      bool
 	CodeSplitting     bool
 
 	// If true, make sure to generate a single file that can be written to stdout
@@ -139,6 +218,12 @@ type Options struct {
 
 	OmitRuntimeForTests bool
 
+	// If true, the parser keeps syntax that it would otherwise compile away
+	// (JSX elements, TypeScript enums and namespaces, and directives) and
+	// records the location of every token, comment, and node end. This is used
+	// when the AST itself is the output instead of code generated from it.
+	PreserveSyntax bool
+
 	Strict   StrictOptions
 	Defines  *ProcessedDefines
 	TS       TSOptions
@@ -160,6 +245,67 @@ type Options struct {
 	// If present, metadata about the bundle is written as JSON here
 	AbsMetadataFile string
 
+	// If present, a JSON file mapping each entry point to the output files it
+	// needs is written here
+	AbsManifestFile string
+
+	// If true, warn about each group of files that import each other in a
+	// cycle unless all files in the group are in one of the allowed groups.
+	// The allowed groups contain absolute paths.
+	WarnAboutCircularImports bool
+	AllowedCircularImports   [][]string
+
+	// If true, imports of a file in one copy of a package are redirected to the
+	// same file in another copy of that package with the same name and version
+	DedupePackages bool
+
+	// These customize how code splitting groups files into chunks. Chunks with
+	// fewer than "MinChunkSize" bytes of input are merged into another chunk
+	// that is loaded by the same entry points.
+	ManualChunks []ManualChunk
+	MinChunkSize int
+
+	// If true, "import()" expressions of other chunks preload the chunks that
+	// the imported chunk depends on
+	PreloadDynamicImports bool
+
+	// Property names matching "MangleProps" are renamed to short names across
+	// the whole bundle unless they also match "ReserveProps". The cache maps
+	// original property names to either the mangled name (a string) or false
+	// if that property must not be mangled. It's used to keep names stable
+	// between builds.
+	MangleProps  *regexp.Regexp
+	ReserveProps *regexp.Regexp
+	MangleCache  map[string]interface{}
+
+	// Output files over these limits cause an error or a warning
+	SizeLimits []SizeLimit
+
 	SourceMap SourceMap
 	Stdin     *StdinInfo
+	Plugins   []Plugin
+
+	// This is checked between the phases of a build. It may be nil.
+	Cancel *CancelFlag
+}
+
+// A single file can be converted to another output format without bundling.
+// Its imports are left alone but its import and export syntax is rewritten by
+// the linker the same way it would be for an entry point of a bundle.
+func (options *Options) IsConvertingFormat() bool {
+	return !options.IsBundling && options.OutputFormat != FormatPreserve
+}
+
+// A build stops early once this has been set. It's safe to set this from
+// another goroutine while the build is running.
+type CancelFlag struct {
+	flag int32
+}
+
+func (c *CancelFlag) Cancel() {
+	atomic.StoreInt32(&c.flag, 1)
+}
+
+func (c *CancelFlag) DidCancel() bool {
+	return c != nil && atomic.LoadInt32(&c.flag) != 0
 }
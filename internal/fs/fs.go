@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -314,3 +315,63 @@ func readdir(dirname string) ([]string, error) {
 	defer f.Close()
 	return f.Readdirnames(-1)
 }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// This wraps another file system and records every file and directory that
+// was read through it. Build systems can use these paths to know when the
+// build needs to run again. Paths that don't exist aren't recorded since the
+// directory listing of their parent directory covers them.
+type ReadRecorder struct {
+	FS
+	mutex sync.Mutex
+	paths map[string]bool
+}
+
+func RecordReads(fs FS) *ReadRecorder {
+	return &ReadRecorder{FS: fs, paths: make(map[string]bool)}
+}
+
+func (fs *ReadRecorder) ReadDirectory(path string) map[string]Entry {
+	entries := fs.FS.ReadDirectory(path)
+
+	// Some file systems return an empty map for a missing directory, so check
+	// that the directory exists using the listing of its parent directory
+	exists := entries != nil
+	if exists {
+		if dir := fs.FS.Dir(path); dir != path {
+			exists = fs.FS.ReadDirectory(dir)[fs.FS.Base(path)].Kind == DirEntry
+		}
+	}
+
+	if exists {
+		fs.record(path)
+	}
+	return entries
+}
+
+func (fs *ReadRecorder) ReadFile(path string) (string, bool) {
+	contents, ok := fs.FS.ReadFile(path)
+	if ok {
+		fs.record(path)
+	}
+	return contents, ok
+}
+
+func (fs *ReadRecorder) record(path string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.paths[path] = true
+}
+
+// This returns the paths of everything that was read in sorted order
+func (fs *ReadRecorder) Paths() []string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	paths := make([]string, 0, len(fs.paths))
+	for path := range fs.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -87,3 +87,23 @@ func TestRel(t *testing.T) {
 	expect("/a/b/c", "/a/b/x/y", "../x/y")
 	expect("/a/b/c/d", "/a/b/x/y", "../../x/y")
 }
+
+func TestRecordReads(t *testing.T) {
+	fs := RecordReads(MockFS(map[string]string{
+		"/package.json": "// package.json",
+		"/src/index.js": "// src/index.js",
+		"/src/util.js":  "// src/util.js",
+	}))
+
+	fs.ReadDirectory("/src")
+	fs.ReadDirectory("/missing")
+	fs.ReadFile("/src/index.js")
+	fs.ReadFile("/src/index.js")
+	fs.ReadFile("/src/missing.js")
+	fs.ReadFile("/package.json")
+
+	paths := fmt.Sprintf("%v", fs.Paths())
+	if paths != "[/package.json /src /src/index.js]" {
+		t.Fatalf("Incorrect paths: %s", paths)
+	}
+}
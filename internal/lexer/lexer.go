@@ -243,6 +243,7 @@ type Lexer struct {
 	Token                           T
 	HasNewlineBefore                bool
 	HasPureCommentBefore            bool
+	HasNoSideEffectsCommentBefore   bool
 	CommentsToPreserveBefore        []Comment
 	codePoint                       rune
 	StringLiteral                   []uint16
@@ -253,6 +254,13 @@ type Lexer struct {
 
 	// The log is disabled during speculative scans that may backtrack
 	IsLogDisabled bool
+
+	// These are only filled in when "RecordSyntax" is true. Each token is
+	// recorded when it's consumed, so the list reflects any rescanning done by
+	// the parser. Comment ranges include the comment delimiters.
+	RecordSyntax bool
+	Tokens       []ast.Range
+	Comments     []ast.Range
 }
 
 type LexerPanic struct{}
@@ -267,6 +275,17 @@ func NewLexer(log logging.Log, source logging.Source) Lexer {
 	return lexer
 }
 
+func NewLexerRecordingSyntax(log logging.Log, source logging.Source) Lexer {
+	lexer := Lexer{
+		log:          log,
+		source:       source,
+		RecordSyntax: true,
+	}
+	lexer.step()
+	lexer.Next()
+	return lexer
+}
+
 func NewLexerJSON(log logging.Log, source logging.Source, allowComments bool) Lexer {
 	lexer := Lexer{
 		log:    log,
@@ -489,10 +508,11 @@ func IsIdentifierUTF16(text []uint16) bool {
 	}
 	for i := 0; i < n; i++ {
 		r1 := rune(text[i])
-		if utf16.IsSurrogate(r1) && i+1 < n {
-			r2 := rune(text[i+1])
-			r1 = (r1-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
-			i++
+		if r1 >= 0xD800 && r1 <= 0xDBFF && i+1 < n {
+			if r2 := rune(text[i+1]); r2 >= 0xDC00 && r2 <= 0xDFFF {
+				r1 = (r1-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
+				i++
+			}
 		}
 		if i == 0 {
 			if !IsIdentifierStart(r1) {
@@ -616,6 +636,7 @@ func (lexer *Lexer) ExpectJSXElementChild(token T) {
 }
 
 func (lexer *Lexer) NextJSXElementChild() {
+	lexer.recordToken()
 	lexer.HasNewlineBefore = false
 	originalStart := lexer.end
 
@@ -698,6 +719,7 @@ func (lexer *Lexer) ExpectInsideJSXElement(token T) {
 }
 
 func (lexer *Lexer) NextInsideJSXElement() {
+	lexer.recordToken()
 	lexer.HasNewlineBefore = false
 
 	for {
@@ -757,6 +779,7 @@ func (lexer *Lexer) NextInsideJSXElement() {
 						break singleLineComment
 					}
 				}
+				lexer.recordComment()
 				continue
 
 			case '*':
@@ -785,6 +808,7 @@ func (lexer *Lexer) NextInsideJSXElement() {
 						lexer.step()
 					}
 				}
+				lexer.recordComment()
 				continue
 
 			default:
@@ -824,7 +848,7 @@ func (lexer *Lexer) NextInsideJSXElement() {
 
 			if needsDecode {
 				// Slow path
-				lexer.StringLiteral = decodeJSXEntities([]uint16{}, text)
+				lexer.StringLiteral = DecodeJSXEntities([]uint16{}, text)
 			} else {
 				// Fast path
 				n := len(text)
@@ -861,8 +885,10 @@ func (lexer *Lexer) NextInsideJSXElement() {
 }
 
 func (lexer *Lexer) Next() {
+	lexer.recordToken()
 	lexer.HasNewlineBefore = lexer.end == 0
 	lexer.HasPureCommentBefore = false
+	lexer.HasNoSideEffectsCommentBefore = false
 	lexer.CommentsToPreserveBefore = nil
 
 	for {
@@ -1869,7 +1895,7 @@ func (lexer *Lexer) ScanRegExp() {
 	}
 }
 
-func decodeJSXEntities(decoded []uint16, text string) []uint16 {
+func DecodeJSXEntities(decoded []uint16, text string) []uint16 {
 	i := 0
 
 	for i < len(text) {
@@ -1930,7 +1956,7 @@ func fixWhitespaceAndDecodeJSXEntities(text string) []uint16 {
 				}
 
 				// Trim whitespace off the start and end of lines in the middle
-				decoded = decodeJSXEntities(decoded, text[firstNonWhitespace:afterLastNonWhitespace])
+				decoded = DecodeJSXEntities(decoded, text[firstNonWhitespace:afterLastNonWhitespace])
 			}
 
 			// Reset for the next line
@@ -1958,7 +1984,7 @@ func fixWhitespaceAndDecodeJSXEntities(text string) []uint16 {
 		}
 
 		// Trim whitespace off the start of the last line
-		decoded = decodeJSXEntities(decoded, text[firstNonWhitespace:])
+		decoded = DecodeJSXEntities(decoded, text[firstNonWhitespace:])
 	}
 
 	return decoded
@@ -2218,7 +2244,22 @@ func (lexer *Lexer) addRangeError(r ast.Range, text string) {
 	}
 }
 
+func (lexer *Lexer) recordToken() {
+	// Don't record the "}" token that's about to be rescanned as part of a
+	// template literal, and don't record the empty token before the first one
+	if lexer.RecordSyntax && !lexer.rescanCloseBraceAsTemplateToken && lexer.end > lexer.start {
+		lexer.Tokens = append(lexer.Tokens, lexer.Range())
+	}
+}
+
+func (lexer *Lexer) recordComment() {
+	if lexer.RecordSyntax {
+		lexer.Comments = append(lexer.Comments, lexer.Range())
+	}
+}
+
 func (lexer *Lexer) scanCommentText() {
+	lexer.recordComment()
 	text := lexer.source.Contents[lexer.start:lexer.end]
 	hasPreserveAnnotation := len(text) > 2 && text[2] == '!'
 
@@ -2228,12 +2269,16 @@ func (lexer *Lexer) scanCommentText() {
 			rest := text[i+1:]
 			if strings.HasPrefix(rest, "__PURE__") {
 				lexer.HasPureCommentBefore = true
+			} else if strings.HasPrefix(rest, "__NO_SIDE_EFFECTS__") {
+				lexer.HasNoSideEffectsCommentBefore = true
 			}
 
 		case '@':
 			rest := text[i+1:]
 			if strings.HasPrefix(rest, "__PURE__") {
 				lexer.HasPureCommentBefore = true
+			} else if strings.HasPrefix(rest, "__NO_SIDE_EFFECTS__") {
+				lexer.HasNoSideEffectsCommentBefore = true
 			} else if strings.HasPrefix(rest, "preserve") || strings.HasPrefix(rest, "license") {
 				hasPreserveAnnotation = true
 			}
@@ -2333,10 +2378,11 @@ func UTF16ToString(text []uint16) string {
 	n := len(text)
 	for i := 0; i < n; i++ {
 		r1 := rune(text[i])
-		if utf16.IsSurrogate(r1) && i+1 < n {
-			r2 := rune(text[i+1])
-			r1 = (r1-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
-			i++
+		if r1 >= 0xD800 && r1 <= 0xDBFF && i+1 < n {
+			if r2 := rune(text[i+1]); r2 >= 0xDC00 && r2 <= 0xDFFF {
+				r1 = (r1-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
+				i++
+			}
 		}
 		width := encodeWTF8Rune(temp, r1)
 		b.Write(temp[:width])
@@ -2355,10 +2401,11 @@ func UTF16EqualsString(text []uint16, str string) bool {
 	j := 0
 	for i := 0; i < n; i++ {
 		r1 := rune(text[i])
-		if utf16.IsSurrogate(r1) && i+1 < n {
-			r2 := rune(text[i+1])
-			r1 = (r1-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
-			i++
+		if r1 >= 0xD800 && r1 <= 0xDBFF && i+1 < n {
+			if r2 := rune(text[i+1]); r2 >= 0xDC00 && r2 <= 0xDFFF {
+				r1 = (r1-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
+				i++
+			}
 		}
 		width := encodeWTF8Rune(temp, r1)
 		if j+width > len(str) {
@@ -2380,10 +2427,11 @@ func AppendUTF16ToBytes(bytes []byte, text []uint16) []byte {
 	n := len(text)
 	for i := 0; i < n; i++ {
 		r1 := rune(text[i])
-		if utf16.IsSurrogate(r1) && i+1 < n {
-			r2 := rune(text[i+1])
-			r1 = (r1-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
-			i++
+		if r1 >= 0xD800 && r1 <= 0xDBFF && i+1 < n {
+			if r2 := rune(text[i+1]); r2 >= 0xDC00 && r2 <= 0xDFFF {
+				r1 = (r1-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
+				i++
+			}
 		}
 		width := encodeWTF8Rune(temp, r1)
 		bytes = append(bytes, temp[:width]...)
@@ -433,6 +433,8 @@ func TestStringLiteral(t *testing.T) {
 	expectString(t, "'\\ucafe\\uCAFE\\u7FFF'", "\ucafe\uCAFE\u7FFF")
 	expectString(t, "'\\uD800'", "\xED\xA0\x80")
 	expectString(t, "'\\uDC00'", "\xED\xB0\x80")
+	expectString(t, "'\\uD800\\u2028'", "\xED\xA0\x80\u2028")
+	expectString(t, "'\\uDC00\\uD800'", "\xED\xB0\x80\xED\xA0\x80")
 	expectString(t, "'\\U0000'", "U0000")
 
 	expectString(t, "'\\u{100000}'", "\U00100000")
@@ -476,6 +478,26 @@ func TestStringLiteral(t *testing.T) {
 	expectLexerError(t, "'\\u000'", "<stdin>: error: Syntax error \"'\"\n")
 }
 
+func TestUnpairedSurrogates(t *testing.T) {
+	// Only a high surrogate followed by a low surrogate is a pair. Anything
+	// else is encoded by itself using WTF-8.
+	expectUTF16 := func(text []uint16, expected string) {
+		t.Helper()
+		assertEqualStrings(t, UTF16ToString(text), expected)
+		assertEqualStrings(t, string(AppendUTF16ToBytes(nil, text)), expected)
+		test.AssertEqual(t, UTF16EqualsString(text, expected), true)
+	}
+	expectUTF16([]uint16{0xD800, 0xDC00}, "\U00010000")
+	expectUTF16([]uint16{0xD800, 'a'}, "\xED\xA0\x80a")
+	expectUTF16([]uint16{0xDC00, 0xD800}, "\xED\xB0\x80\xED\xA0\x80")
+	expectUTF16([]uint16{0xDC00, 0xDC00}, "\xED\xB0\x80\xED\xB0\x80")
+	expectUTF16([]uint16{'a', 0xD800}, "a\xED\xA0\x80")
+
+	test.AssertEqual(t, IsIdentifierUTF16([]uint16{'a', 0xD800, 0xDC00}), true)
+	test.AssertEqual(t, IsIdentifierUTF16([]uint16{'a', 0xD802, ' '}), false)
+	test.AssertEqual(t, IsIdentifierUTF16([]uint16{'a', 0xDC00, 0xDC00}), false)
+}
+
 func TestTokens(t *testing.T) {
 	expected := []struct {
 		contents string
@@ -40,6 +40,10 @@ type Msg struct {
 	Kind     MsgKind
 	Text     string
 	Location *MsgLocation
+
+	// Optional warnings have an ID so that tools can recognize them without
+	// matching on the text
+	ID string
 }
 
 type MsgLocation struct {
@@ -374,6 +378,11 @@ loop:
 	return
 }
 
+// This is for reporting locations in source files outside of log messages
+func LocationOrNil(source *Source, r ast.Range) *MsgLocation {
+	return locationOrNil(source, r.Loc.Start, r.Len)
+}
+
 func locationOrNil(source *Source, start int32, length int32) *MsgLocation {
 	if source == nil {
 		return nil
@@ -562,3 +571,12 @@ func (log Log) AddRangeWarning(source *Source, r ast.Range, text string) {
 		Location: locationOrNil(source, r.Loc.Start, r.Len),
 	})
 }
+
+func (log Log) AddRangeWarningWithID(id string, source *Source, r ast.Range, text string) {
+	log.addMsg(Msg{
+		Kind:     Warning,
+		Text:     text,
+		Location: locationOrNil(source, r.Loc.Start, r.Len),
+		ID:       id,
+	})
+}
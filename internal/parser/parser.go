@@ -188,6 +188,27 @@ type parser struct {
 	// Temporary variables used for lowering
 	tempRefsToDeclare []ast.Ref
 	tempRefCount      int
+
+	// This is only non-nil while checking if a part can be removed if unused.
+	// Calls to imported functions are collected here since those calls may be
+	// removable if the imported function is marked "@__NO_SIDE_EFFECTS__".
+	importedCallRefs *[]ast.Ref
+
+	// Imports that are used inside dead code, which may mean the import is
+	// unnecessary if there are no other uses
+	importItemsUsedInDeadCode map[ast.Ref]bool
+
+	// These are for cross-module inlining of constants and enums
+	constValues         map[ast.Ref]ast.Expr
+	enumValues          map[ast.Ref]map[string]float64
+	importPropertyReads map[ast.Ref]map[string]uint32
+
+	// These are for property mangling
+	mangledProps  map[string]uint32
+	reservedProps map[string]bool
+
+	// This is only non-nil when syntax is being preserved
+	nodeEnds map[interface{}]int32
 }
 
 const (
@@ -222,30 +243,6 @@ func isJumpStatement(data ast.S) bool {
 	return false
 }
 
-func toBooleanWithoutSideEffects(data ast.E) (bool, bool) {
-	switch e := data.(type) {
-	case *ast.ENull, *ast.EUndefined:
-		return false, true
-
-	case *ast.EBoolean:
-		return e.Value, true
-
-	case *ast.ENumber:
-		return e.Value != 0 && !math.IsNaN(e.Value), true
-
-	case *ast.EBigInt:
-		return e.Value != "0", true
-
-	case *ast.EString:
-		return len(e.Value) > 0, true
-
-	case *ast.EFunction, *ast.EArrow:
-		return true, true
-	}
-
-	return false, false
-}
-
 func toNumberWithoutSideEffects(data ast.E) (float64, bool) {
 	switch e := data.(type) {
 	case *ast.ENull:
@@ -690,6 +687,8 @@ func (p *parser) recordUsage(ref ast.Ref) {
 		use := p.symbolUses[ref]
 		use.CountEstimate++
 		p.symbolUses[ref] = use
+	} else if p.isImportItem[ref] {
+		p.importItemsUsedInDeadCode[ref] = true
 	}
 
 	// The correctness of TypeScript-to-JavaScript conversion relies on accurate
@@ -1795,7 +1794,7 @@ func (p *parser) parsePrefix(level ast.L, errors *deferredErrors, flags exprFlag
 		value := p.lexer.Identifier
 		p.markSyntaxFeature(compat.BigInt, p.lexer.Range())
 		p.lexer.Next()
-		return ast.Expr{Loc: p.lexer.Loc(), Data: &ast.EBigInt{Value: value}}
+		return ast.Expr{Loc: loc, Data: &ast.EBigInt{Value: value}}
 
 	case lexer.TSlash, lexer.TSlashEquals:
 		p.lexer.ScanRegExp()
@@ -1885,7 +1884,7 @@ func (p *parser) parsePrefix(level ast.L, errors *deferredErrors, flags exprFlag
 		if p.lexer.Token == lexer.TIdentifier {
 			p.pushScopeForParsePass(ast.ScopeClassName, loc)
 			nameLoc := p.lexer.Loc()
-			name = &ast.LocRef{Loc: loc, Ref: p.declareSymbol(ast.SymbolOther, nameLoc, p.lexer.Identifier)}
+			name = &ast.LocRef{Loc: nameLoc, Ref: p.declareSymbol(ast.SymbolOther, nameLoc, p.lexer.Identifier)}
 			p.lexer.Next()
 		}
 
@@ -2254,6 +2253,17 @@ func (p *parser) parseExprCommon(level ast.L, errors *deferredErrors, flags expr
 	return p.parseSuffix(expr, level, errors, flags)
 }
 
+// When syntax is being preserved, this records the end of the most recently
+// consumed token as the end of a node. The first call for a node wins since
+// later calls happen after the parser has already moved past that node.
+func (p *parser) recordEnd(data interface{}) {
+	if p.nodeEnds != nil && data != nil && len(p.lexer.Tokens) > 0 {
+		if _, ok := p.nodeEnds[data]; !ok {
+			p.nodeEnds[data] = p.lexer.Tokens[len(p.lexer.Tokens)-1].End()
+		}
+	}
+}
+
 func (p *parser) parseSuffix(left ast.Expr, level ast.L, errors *deferredErrors, flags exprFlag) ast.Expr {
 	// ArrowFunction is a special case in the grammar. Although it appears to be
 	// a PrimaryExpression, it's actually an AssigmentExpression. This means if
@@ -2273,6 +2283,7 @@ func (p *parser) parseSuffix(left ast.Expr, level ast.L, errors *deferredErrors,
 	//
 	if level < ast.LAssign {
 		if arrow, ok := left.Data.(*ast.EArrow); ok && !arrow.IsParenthesized {
+			p.recordEnd(left.Data)
 			for {
 				switch p.lexer.Token {
 				case lexer.TComma:
@@ -2292,6 +2303,8 @@ func (p *parser) parseSuffix(left ast.Expr, level ast.L, errors *deferredErrors,
 	optionalChain := ast.OptionalChainNone
 
 	for {
+		p.recordEnd(left.Data)
+
 		// Reset the optional chain flag by default. That way we won't accidentally
 		// treat "c.d" as OptionalChainContinue in "a?.b + c.d".
 		oldOptionalChain := optionalChain
@@ -3777,7 +3790,11 @@ func (p *parser) parseFnStmt(loc ast.Loc, opts parseStmtOpts, isAsync bool, asyn
 		if opts.isExport {
 			p.recordExport(name.Loc, nameText, name.Ref)
 		}
+		if opts.hasNoSideEffectsComment {
+			p.symbols[name.Ref.InnerIndex].CallCanBeUnwrappedIfUnused = true
+		}
 	}
+	fn.HasNoSideEffectsComment = opts.hasNoSideEffectsComment
 
 	return ast.Stmt{Loc: loc, Data: &ast.SFunction{Fn: fn, IsExport: opts.isExport}}
 }
@@ -3798,10 +3815,20 @@ type parseStmtOpts struct {
 	isExport            bool
 	isNameOptional      bool // For "export default" pseudo-statements
 	isTypeScriptDeclare bool
+
+	// For "/* @__NO_SIDE_EFFECTS__ */ export function foo() {}"
+	hasNoSideEffectsComment bool
 }
 
-func (p *parser) parseStmt(opts parseStmtOpts) ast.Stmt {
+func (p *parser) parseStmt(opts parseStmtOpts) (result ast.Stmt) {
+	if p.nodeEnds != nil {
+		defer func() { p.recordEnd(result.Data) }()
+	}
+
 	loc := p.lexer.Loc()
+	if p.lexer.HasNoSideEffectsCommentBefore {
+		opts.hasNoSideEffectsComment = true
+	}
 
 	switch p.lexer.Token {
 	case lexer.TSemicolon:
@@ -3862,6 +3889,9 @@ func (p *parser) parseStmt(opts parseStmtOpts) ast.Stmt {
 			if p.lexer.IsContextualKeyword("async") {
 				// "export async function foo() {}"
 				asyncRange := p.lexer.Range()
+				if p.lexer.HasNoSideEffectsCommentBefore {
+					opts.hasNoSideEffectsComment = true
+				}
 				p.lexer.Next()
 				p.lexer.Expect(lexer.TFunction)
 				opts.isExport = true
@@ -3919,13 +3949,15 @@ func (p *parser) parseStmt(opts parseStmtOpts) ast.Stmt {
 
 			if p.lexer.IsContextualKeyword("async") {
 				asyncRange := p.lexer.Range()
+				hasNoSideEffectsComment := opts.hasNoSideEffectsComment || p.lexer.HasNoSideEffectsCommentBefore
 				p.lexer.Next()
 
 				if p.lexer.Token == lexer.TFunction {
 					p.lexer.Expect(lexer.TFunction)
 					stmt := p.parseFnStmt(loc, parseStmtOpts{
-						isNameOptional:   true,
-						allowLexicalDecl: true,
+						isNameOptional:          true,
+						allowLexicalDecl:        true,
+						hasNoSideEffectsComment: hasNoSideEffectsComment,
 					}, true /* isAsync */, asyncRange)
 					if _, ok := stmt.Data.(*ast.STypeScript); ok {
 						return stmt // This was just a type annotation
@@ -3937,6 +3969,9 @@ func (p *parser) parseStmt(opts parseStmtOpts) ast.Stmt {
 						defaultName = ast.LocRef{Loc: defaultLoc, Ref: s.Fn.Name.Ref}
 					} else {
 						defaultName = createDefaultName()
+						if hasNoSideEffectsComment {
+							p.symbols[defaultName.Ref.InnerIndex].CallCanBeUnwrappedIfUnused = true
+						}
 					}
 
 					p.recordExport(defaultLoc, "default", defaultName.Ref)
@@ -3952,9 +3987,10 @@ func (p *parser) parseStmt(opts parseStmtOpts) ast.Stmt {
 
 			if p.lexer.Token == lexer.TFunction || p.lexer.Token == lexer.TClass || p.lexer.Token == lexer.TInterface {
 				stmt := p.parseStmt(parseStmtOpts{
-					tsDecorators:     opts.tsDecorators,
-					isNameOptional:   true,
-					allowLexicalDecl: true,
+					tsDecorators:            opts.tsDecorators,
+					isNameOptional:          true,
+					allowLexicalDecl:        true,
+					hasNoSideEffectsComment: opts.hasNoSideEffectsComment,
 				})
 				if _, ok := stmt.Data.(*ast.STypeScript); ok {
 					return stmt // This was just a type annotation
@@ -3968,6 +4004,9 @@ func (p *parser) parseStmt(opts parseStmtOpts) ast.Stmt {
 						defaultName = ast.LocRef{Loc: defaultLoc, Ref: s.Fn.Name.Ref}
 					} else {
 						defaultName = createDefaultName()
+						if s.Fn.HasNoSideEffectsComment {
+							p.symbols[defaultName.Ref.InnerIndex].CallCanBeUnwrappedIfUnused = true
+						}
 					}
 				case *ast.SClass:
 					if s.Class.Name != nil {
@@ -5193,7 +5232,7 @@ func (p *parser) visitStmts(stmts []ast.Stmt) []ast.Stmt {
 	visited := make([]ast.Stmt, 0, len(stmts))
 	var after []ast.Stmt
 	for _, stmt := range stmts {
-		if _, ok := stmt.Data.(*ast.SExportEquals); ok {
+		if _, ok := stmt.Data.(*ast.SExportEquals); ok && !p.PreserveSyntax {
 			// TypeScript "export = value;" becomes "module.exports = value;". This
 			// must happen at the end after everything is parsed because TypeScript
 			// moves this statement to the end when it generates code.
@@ -5533,6 +5572,9 @@ func (p *parser) visitBinding(binding ast.Binding) {
 		for i, property := range b.Properties {
 			if !property.IsSpread {
 				property.Key = p.visitExpr(property.Key)
+				if !property.IsComputed {
+					p.recordPropertyKey(property.Key)
+				}
 			}
 			p.visitBinding(property.Value)
 			if property.DefaultValue != nil {
@@ -5546,20 +5588,37 @@ func (p *parser) visitBinding(binding ast.Binding) {
 	}
 }
 
-func statementCaresAboutScope(stmt ast.Stmt) bool {
-	switch s := stmt.Data.(type) {
-	case *ast.SBlock, *ast.SEmpty, *ast.SDebugger, *ast.SExpr, *ast.SIf,
-		*ast.SFor, *ast.SForIn, *ast.SForOf, *ast.SDoWhile, *ast.SWhile,
-		*ast.SWith, *ast.STry, *ast.SSwitch, *ast.SReturn, *ast.SThrow,
-		*ast.SBreak, *ast.SContinue, *ast.SDirective:
-		return false
-
-	case *ast.SLocal:
-		return s.Kind != ast.LocalVar
+// Imports that are only used in a branch that is never taken are left out of
+// the bundle, so the branch must be removed too. Otherwise it would reference
+// symbols that no longer exist. This returns true if the branch uses imports
+// and must be removed. A branch with hoisted declarations can't be removed, so
+// its uses of imports are recorded as live instead.
+func (p *parser) visitDeadBranch(stmt ast.Stmt) (ast.Stmt, bool) {
+	oldIsControlFlowDead := p.isControlFlowDead
+	oldImportItemsUsedInDeadCode := p.importItemsUsedInDeadCode
+	p.isControlFlowDead = true
+	p.importItemsUsedInDeadCode = make(map[ast.Ref]bool)
+	stmt = p.visitSingleStmt(stmt)
 
-	default:
-		return true
+	mustRemove := false
+	if len(p.importItemsUsedInDeadCode) > 0 && (p.IsBundling || p.IsConvertingFormat()) {
+		if ast.CanRemoveDeadStmt(stmt) {
+			for ref := range p.importItemsUsedInDeadCode {
+				oldImportItemsUsedInDeadCode[ref] = true
+			}
+			mustRemove = true
+		} else {
+			for ref := range p.importItemsUsedInDeadCode {
+				use := p.symbolUses[ref]
+				use.CountEstimate++
+				p.symbolUses[ref] = use
+			}
+		}
 	}
+
+	p.isControlFlowDead = oldIsControlFlowDead
+	p.importItemsUsedInDeadCode = oldImportItemsUsedInDeadCode
+	return stmt, mustRemove
 }
 
 func (p *parser) mangleIf(loc ast.Loc, s *ast.SIf, isTestBooleanConstant bool, testBooleanValue bool) ast.Stmt {
@@ -5569,7 +5628,7 @@ func (p *parser) mangleIf(loc ast.Loc, s *ast.SIf, isTestBooleanConstant bool, t
 			// The test is true
 			if s.No == nil || !shouldKeepStmtInDeadControlFlow(*s.No) {
 				// We can drop the "no" branch
-				if statementCaresAboutScope(s.Yes) {
+				if ast.StatementCaresAboutScope(s.Yes) {
 					return ast.Stmt{Loc: s.Yes.Loc, Data: &ast.SBlock{Stmts: []ast.Stmt{s.Yes}}}
 				} else {
 					return s.Yes
@@ -5583,7 +5642,7 @@ func (p *parser) mangleIf(loc ast.Loc, s *ast.SIf, isTestBooleanConstant bool, t
 				// We can drop the "yes" branch
 				if s.No == nil {
 					return ast.Stmt{Loc: loc, Data: &ast.SEmpty{}}
-				} else if statementCaresAboutScope(*s.No) {
+				} else if ast.StatementCaresAboutScope(*s.No) {
 					return ast.Stmt{Loc: s.No.Loc, Data: &ast.SBlock{Stmts: []ast.Stmt{*s.No}}}
 				} else {
 					return *s.No
@@ -5687,7 +5746,12 @@ func (p *parser) mangleIf(loc ast.Loc, s *ast.SIf, isTestBooleanConstant bool, t
 
 func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt {
 	switch s := stmt.Data.(type) {
-	case *ast.SDebugger, *ast.SEmpty, *ast.SDirective, *ast.SComment:
+	case *ast.SDebugger:
+		if p.DropDebugger {
+			return stmts
+		}
+
+	case *ast.SEmpty, *ast.SDirective, *ast.SComment:
 		// These don't contain anything to traverse
 
 	case *ast.STypeScript:
@@ -5772,6 +5836,11 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 		}
 
 	case *ast.SExportEquals:
+		if p.PreserveSyntax {
+			s.Value = p.visitExpr(s.Value)
+			break
+		}
+
 		// "module.exports = value"
 		stmts = append(stmts, ast.AssignStmt(
 			ast.Expr{Loc: stmt.Loc, Data: &ast.EDot{
@@ -5834,6 +5903,15 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 			}
 		}
 
+		// Remember the values of top-level constants so they can be inlined
+		if p.MangleSyntax && s.Kind == ast.LocalConst && p.currentScope == p.moduleScope {
+			for _, d := range s.Decls {
+				if id, ok := d.Binding.Data.(*ast.BIdentifier); ok && d.Value != nil && isInlinableConstant(*d.Value) {
+					p.constValues[id.Ref] = *d.Value
+				}
+			}
+		}
+
 		// Handle being exported inside a namespace
 		if s.IsExport && p.enclosingNamespaceRef != nil {
 			wrapIdentifier := func(loc ast.Loc, ref ast.Ref) ast.Expr {
@@ -5860,7 +5938,13 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 		s.Decls = p.lowerObjectRestInDecls(s.Decls)
 
 	case *ast.SExpr:
-		s.Value = p.visitExpr(s.Value)
+		var out exprOut
+		s.Value, out = p.visitExprInOut(s.Value, exprIn{})
+
+		// Remove the statement entirely if it was a dropped "console" call
+		if out.isDroppedConsoleCall {
+			return stmts
+		}
 
 		// Trim expressions without side effects
 		if p.MangleSyntax {
@@ -5891,7 +5975,7 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 		p.popScope()
 
 		if p.MangleSyntax {
-			if len(s.Stmts) == 1 && !statementCaresAboutScope(s.Stmts[0]) {
+			if len(s.Stmts) == 1 && !ast.StatementCaresAboutScope(s.Stmts[0]) {
 				// Unwrap blocks containing a single statement
 				stmt = s.Stmts[0]
 			} else if len(s.Stmts) == 0 {
@@ -5913,7 +5997,7 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 		if p.MangleSyntax {
 			// "while (a) {}" => "for (;a;) {}"
 			test := &s.Test
-			if boolean, ok := toBooleanWithoutSideEffects(s.Test.Data); ok && boolean {
+			if boolean, ok := ast.ToBooleanWithoutSideEffects(s.Test.Data); ok && boolean {
 				test = nil
 			}
 			stmt = ast.Stmt{Loc: stmt.Loc, Data: &ast.SFor{Test: test, Body: s.Body}}
@@ -5927,14 +6011,12 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 		s.Test = p.visitBooleanExpr(s.Test)
 
 		// Fold constants
-		boolean, ok := toBooleanWithoutSideEffects(s.Test.Data)
+		boolean, ok := ast.ToBooleanWithoutSideEffects(s.Test.Data)
 
 		// Mark the control flow as dead if the branch is never taken
+		mustRemoveDeadBranch := false
 		if ok && !boolean {
-			old := p.isControlFlowDead
-			p.isControlFlowDead = true
-			s.Yes = p.visitSingleStmt(s.Yes)
-			p.isControlFlowDead = old
+			s.Yes, mustRemoveDeadBranch = p.visitDeadBranch(s.Yes)
 		} else {
 			s.Yes = p.visitSingleStmt(s.Yes)
 		}
@@ -5943,10 +6025,7 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 		if s.No != nil {
 			// Mark the control flow as dead if the branch is never taken
 			if ok && boolean {
-				old := p.isControlFlowDead
-				p.isControlFlowDead = true
-				*s.No = p.visitSingleStmt(*s.No)
-				p.isControlFlowDead = old
+				*s.No, mustRemoveDeadBranch = p.visitDeadBranch(*s.No)
 			} else {
 				*s.No = p.visitSingleStmt(*s.No)
 			}
@@ -5959,6 +6038,17 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 			}
 		}
 
+		if mustRemoveDeadBranch {
+			if boolean {
+				s.No = nil
+			} else if s.No == nil {
+				return stmts
+			} else {
+				s.Test = ast.Expr{Loc: s.Test.Loc, Data: &ast.EBoolean{Value: true}}
+				s.Yes, s.No = *s.No, nil
+			}
+		}
+
 		if p.MangleSyntax {
 			stmt = p.mangleIf(stmt.Loc, s, ok, boolean)
 		}
@@ -5974,7 +6064,7 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 
 			// A true value is implied
 			if p.MangleSyntax {
-				if boolean, ok := toBooleanWithoutSideEffects(s.Test.Data); ok && boolean {
+				if boolean, ok := ast.ToBooleanWithoutSideEffects(s.Test.Data); ok && boolean {
 					s.Test = nil
 				}
 			}
@@ -6088,9 +6178,20 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 
 	case *ast.SEnum:
 		p.recordDeclaredSymbol(s.Name.Ref)
+		isTopLevel := p.currentScope == p.moduleScope
 		p.pushScopeForVisitPass(ast.ScopeEntry, stmt.Loc)
 		defer p.popScope()
 
+		// Keep the enum instead of compiling it to a closure
+		if p.PreserveSyntax {
+			for _, value := range s.Values {
+				if value.Value != nil {
+					*value.Value = p.visitExpr(*value.Value)
+				}
+			}
+			break
+		}
+
 		// Scan ahead for any variables inside this namespace. This must be done
 		// ahead of time before visiting any statements inside the namespace
 		// because we may end up visiting the uses before the declarations.
@@ -6187,6 +6288,21 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 
 		p.shouldFoldNumericConstants = oldShouldFoldNumericConstants
 
+		// Enums without side effects can be removed by tree shaking, and their
+		// members can be inlined into other files
+		hasSideEffects := false
+		if p.MangleSyntax && isTopLevel {
+			for _, value := range s.Values {
+				if value.Value != nil && !p.exprCanBeRemovedIfUnused(*value.Value) {
+					hasSideEffects = true
+					break
+				}
+			}
+			if !hasSideEffects {
+				p.enumValues[s.Name.Ref] = valuesSoFar
+			}
+		}
+
 		// Generate statements from expressions
 		valueStmts := []ast.Stmt{}
 		if len(valueExprs) > 0 {
@@ -6204,11 +6320,24 @@ func (p *parser) visitAndAppendStmt(stmts []ast.Stmt, stmt ast.Stmt) []ast.Stmt
 		// Wrap this enum definition in a closure
 		stmts = p.generateClosureForTypeScriptNamespaceOrEnum(
 			stmts, stmt.Loc, s.IsExport, s.Name.Loc, s.Name.Ref, s.Arg, valueStmts)
+		if p.MangleSyntax && isTopLevel && !hasSideEffects {
+			stmts[len(stmts)-1].Data.(*ast.SExpr).DoesNotAffectTreeShaking = true
+		}
 		return stmts
 
 	case *ast.SNamespace:
 		p.recordDeclaredSymbol(s.Name.Ref)
 
+		// Keep the namespace instead of compiling it to a closure. Exported
+		// declarations are left alone since they don't need to become property
+		// accesses on the namespace object.
+		if p.PreserveSyntax {
+			p.pushScopeForVisitPass(ast.ScopeEntry, stmt.Loc)
+			s.Stmts = p.visitStmtsAndPrependTempRefs(s.Stmts)
+			p.popScope()
+			break
+		}
+
 		// Scan ahead for any variables inside this namespace. This must be done
 		// ahead of time before visiting any statements inside the namespace
 		// because we may end up visiting the uses before the declarations.
@@ -6429,6 +6558,9 @@ func (p *parser) visitClass(class *ast.Class) {
 		// Special-case EPrivateIdentifier to allow it here
 		if _, ok := property.Key.Data.(*ast.EPrivateIdentifier); !ok {
 			class.Properties[i].Key = p.visitExpr(property.Key)
+			if !property.IsComputed {
+				p.recordPropertyKey(class.Properties[i].Key)
+			}
 		}
 		if property.Value != nil {
 			*property.Value = p.visitExpr(*property.Value)
@@ -6557,7 +6689,7 @@ func (p *parser) maybeRewriteDot(loc ast.Loc, assignTarget ast.AssignTarget, dot
 				p.isImportItem[item.Ref] = true
 
 				symbol := &p.symbols[item.Ref.InnerIndex]
-				if !p.IsBundling {
+				if !p.IsBundling && !p.IsConvertingFormat() {
 					// Make sure the printer prints this as a property access
 					symbol.NamespaceAlias = &ast.NamespaceAlias{
 						NamespaceRef: id.Ref,
@@ -6579,7 +6711,7 @@ func (p *parser) maybeRewriteDot(loc ast.Loc, assignTarget ast.AssignTarget, dot
 			// imported module end up in the same module group and the namespace
 			// symbol has never been captured, then we don't need to generate
 			// any code for the namespace at all.
-			if p.IsBundling {
+			if p.IsBundling || p.IsConvertingFormat() {
 				p.ignoreUsage(id.Ref)
 			}
 
@@ -6762,6 +6894,9 @@ type exprOut struct {
 	// True if the child node is an optional chain node (EDot, EIndex, or ECall
 	// with an IsOptionalChain value of true)
 	childContainsOptionalChain bool
+
+	// True if this was a call to a "console" method that was removed
+	isDroppedConsoleCall bool
 }
 
 func (p *parser) visitExpr(expr ast.Expr) ast.Expr {
@@ -6770,7 +6905,7 @@ func (p *parser) visitExpr(expr ast.Expr) ast.Expr {
 }
 
 func (p *parser) valueForThis(loc ast.Loc) (ast.Expr, bool) {
-	if p.IsBundling && !p.isThisCaptured {
+	if (p.IsBundling || p.IsConvertingFormat()) && !p.isThisCaptured {
 		if p.hasES6ImportSyntax || p.hasES6ExportSyntax {
 			// In an ES6 module, "this" is supposed to be undefined. Instead of
 			// doing this at runtime using "fn.call(undefined)", we do it at
@@ -6788,7 +6923,15 @@ func (p *parser) valueForThis(loc ast.Loc) (ast.Expr, bool) {
 	return ast.Expr{}, false
 }
 
-func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
+func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (result ast.Expr, out exprOut) {
+	// When syntax is being preserved, this pass is only used to bind identifiers
+	// to symbols. Expressions that would otherwise be replaced (by constant
+	// folding, for example) are kept as-is. Their children are still visited
+	// because that happens in place.
+	if p.PreserveSyntax {
+		defer func() { result = expr }()
+	}
+
 	switch e := expr.Data.(type) {
 	case *ast.EMissing, *ast.ENull, *ast.ESuper, *ast.EString,
 		*ast.EBoolean, *ast.ENumber, *ast.EBigInt,
@@ -6845,13 +6988,8 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 		panic("Internal error")
 
 	case *ast.EJSXElement:
-		// A missing tag is a fragment
-		tag := e.Tag
-		if tag == nil {
-			value := p.jsxStringsToMemberExpression(expr.Loc, p.JSX.Fragment, in.assignTarget)
-			tag = &value
-		} else {
-			*tag = p.visitExpr(*tag)
+		if e.Tag != nil {
+			*e.Tag = p.visitExpr(*e.Tag)
 		}
 
 		// Visit properties
@@ -6868,6 +7006,21 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 			e.Properties[i] = property
 		}
 
+		// Keep the element instead of converting it to a call
+		if p.PreserveSyntax {
+			for i, child := range e.Children {
+				e.Children[i] = p.visitExpr(child)
+			}
+			return expr, exprOut{}
+		}
+
+		// A missing tag is a fragment
+		tag := e.Tag
+		if tag == nil {
+			value := p.jsxStringsToMemberExpression(expr.Loc, p.JSX.Fragment, in.assignTarget)
+			tag = &value
+		}
+
 		// Arguments to createElement()
 		args := []ast.Expr{*tag}
 		if len(e.Properties) > 0 {
@@ -6995,7 +7148,7 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 			}
 
 		case ast.BinOpLogicalOr:
-			if boolean, ok := toBooleanWithoutSideEffects(e.Left.Data); ok {
+			if boolean, ok := ast.ToBooleanWithoutSideEffects(e.Left.Data); ok {
 				if boolean {
 					return e.Left, exprOut{}
 				} else {
@@ -7004,7 +7157,7 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 			}
 
 		case ast.BinOpLogicalAnd:
-			if boolean, ok := toBooleanWithoutSideEffects(e.Left.Data); ok {
+			if boolean, ok := ast.ToBooleanWithoutSideEffects(e.Left.Data); ok {
 				if boolean {
 					return e.Right, exprOut{}
 				} else {
@@ -7260,6 +7413,7 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 			}
 		} else {
 			e.Index = p.visitExpr(e.Index)
+			p.recordPropertyKey(e.Index)
 		}
 
 		// Create an error for assigning to an import namespace
@@ -7321,7 +7475,7 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 		// Post-process the binary expression
 		switch e.Op {
 		case ast.UnOpNot:
-			if boolean, ok := toBooleanWithoutSideEffects(e.Value.Data); ok {
+			if boolean, ok := ast.ToBooleanWithoutSideEffects(e.Value.Data); ok {
 				return ast.Expr{Loc: expr.Loc, Data: &ast.EBoolean{Value: !boolean}}, exprOut{}
 			}
 
@@ -7403,6 +7557,18 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 			hasChainParent: e.OptionalChain == ast.OptionalChainContinue,
 		})
 		e.Target = target
+		e.MangleProp = p.recordPropertyName(e.Name)
+
+		// Count reads of properties off of imports in case they are enums
+		if id, ok := e.Target.Data.(*ast.EImportIdentifier); ok && p.MangleSyntax && !p.isControlFlowDead &&
+			in.assignTarget == ast.AssignTargetNone && e.OptionalChain == ast.OptionalChainNone {
+			reads := p.importPropertyReads[id.Ref]
+			if reads == nil {
+				reads = make(map[string]uint32)
+				p.importPropertyReads[id.Ref] = reads
+			}
+			reads[e.Name]++
+		}
 
 		// Lower optional chaining if we're the top of the chain
 		containsOptionalChain := e.OptionalChain != ast.OptionalChainNone
@@ -7420,7 +7586,7 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 		e.No = p.visitExpr(e.No)
 
 		// Fold constants
-		if boolean, ok := toBooleanWithoutSideEffects(e.Test.Data); ok {
+		if boolean, ok := ast.ToBooleanWithoutSideEffects(e.Test.Data); ok {
 			if boolean {
 				return e.Yes, exprOut{}
 			} else {
@@ -7464,6 +7630,9 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 		for i, property := range e.Properties {
 			if property.Kind != ast.PropertySpread {
 				e.Properties[i].Key = p.visitExpr(property.Key)
+				if !property.IsComputed {
+					p.recordPropertyKey(e.Properties[i].Key)
+				}
 			}
 			if property.Value != nil {
 				*property.Value, _ = p.visitExprInOut(*property.Value, exprIn{assignTarget: in.assignTarget})
@@ -7500,7 +7669,7 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 			p.importRecordsForCurrentPart = append(p.importRecordsForCurrentPart, importRecordIndex)
 
 			e.ImportRecordIndex = &importRecordIndex
-		} else if p.IsBundling {
+		} else if p.IsBundling && !p.IsScanningImports {
 			r := lexer.RangeOfIdentifier(p.source, expr.Loc)
 			p.log.AddRangeWarning(&p.source, r,
 				"This dynamic import will not be bundled because the argument is not a string literal")
@@ -7526,6 +7695,20 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 			storeThisArgForParentOptionalChain: storeThisArg,
 		})
 		e.Target = target
+
+		// Calls to "console" methods are removed along with their arguments. The
+		// arguments must still be visited to keep the scope order in sync, but
+		// they are visited as dead code so their symbol uses aren't counted.
+		if p.DropConsole && p.isConsoleMethod(e.Target) {
+			oldIsControlFlowDead := p.isControlFlowDead
+			p.isControlFlowDead = true
+			for i, arg := range e.Args {
+				e.Args[i] = p.visitExpr(arg)
+			}
+			p.isControlFlowDead = oldIsControlFlowDead
+			return ast.Expr{Loc: expr.Loc, Data: &ast.EUndefined{}}, exprOut{isDroppedConsoleCall: true}
+		}
+
 		for i, arg := range e.Args {
 			e.Args[i] = p.visitExpr(arg)
 		}
@@ -7550,7 +7733,7 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 		// Copy the call side effect flag over if this is a known target
 		switch t := target.Data.(type) {
 		case *ast.EIdentifier:
-			if t.CallCanBeUnwrappedIfUnused {
+			if t.CallCanBeUnwrappedIfUnused || p.symbols[t.Ref.InnerIndex].CallCanBeUnwrappedIfUnused {
 				e.CanBeUnwrappedIfUnused = true
 			}
 		case *ast.EDot:
@@ -7591,9 +7774,11 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 		if id, ok := e.Target.Data.(*ast.EIdentifier); ok && id.Ref == p.requireRef && p.IsBundling {
 			// There must be one argument
 			if len(e.Args) != 1 {
-				r := lexer.RangeOfIdentifier(p.source, e.Target.Loc)
-				p.log.AddRangeWarning(&p.source, r, fmt.Sprintf(
-					"This call to \"require\" will not be bundled because it has %d arguments", len(e.Args)))
+				if !p.IsScanningImports {
+					r := lexer.RangeOfIdentifier(p.source, e.Target.Loc)
+					p.log.AddRangeWarning(&p.source, r, fmt.Sprintf(
+						"This call to \"require\" will not be bundled because it has %d arguments", len(e.Args)))
+				}
 			} else {
 				arg := e.Args[0]
 
@@ -7620,9 +7805,11 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 					return ast.Expr{Loc: expr.Loc, Data: &ast.ERequire{ImportRecordIndex: importRecordIndex}}, exprOut{}
 				}
 
-				r := lexer.RangeOfIdentifier(p.source, e.Target.Loc)
-				p.log.AddRangeWarning(&p.source, r,
-					"This call to \"require\" will not be bundled because the argument is not a string literal")
+				if !p.IsScanningImports {
+					r := lexer.RangeOfIdentifier(p.source, e.Target.Loc)
+					p.log.AddRangeWarning(&p.source, r,
+						"This call to \"require\" will not be bundled because the argument is not a string literal")
+				}
 			}
 		}
 
@@ -7684,6 +7871,62 @@ func (p *parser) visitExprInOut(expr ast.Expr, in exprIn) (ast.Expr, exprOut) {
 	return expr, exprOut{}
 }
 
+// Only inline small primitive values since the value is duplicated at every
+// use. Long strings could make the output bigger instead of smaller.
+func isInlinableConstant(expr ast.Expr) bool {
+	switch e := expr.Data.(type) {
+	case *ast.ENull, *ast.EUndefined, *ast.EBoolean, *ast.ENumber:
+		return true
+	case *ast.EString:
+		return len(e.Value) <= 16
+	}
+	return false
+}
+
+// Returns true for a property access on the global "console" object, such as
+// "console.log" or "console.log.bind". A local variable named "console" that
+// shadows the global doesn't count.
+func (p *parser) isConsoleMethod(target ast.Expr) bool {
+	hasProperty := false
+	for {
+		switch e := target.Data.(type) {
+		case *ast.EDot:
+			target = e.Target
+		case *ast.EIndex:
+			target = e.Target
+		case *ast.EIdentifier:
+			symbol := p.symbols[e.Ref.InnerIndex]
+			return hasProperty && symbol.Kind == ast.SymbolUnbound && symbol.Name == "console"
+		default:
+			return false
+		}
+		hasProperty = true
+	}
+}
+
+// Property mangling is done by the linker so that names are consistent across
+// all files in the bundle. Here we just count how often each candidate name is
+// used so the most common names can get the shortest replacements, and also
+// remember every other property name so the replacements never collide.
+// Returns true if the name will be mangled.
+func (p *parser) recordPropertyName(name string) bool {
+	if p.MangleProps == nil {
+		return false
+	}
+	if p.MangleProps.MatchString(name) && (p.ReserveProps == nil || !p.ReserveProps.MatchString(name)) {
+		p.mangledProps[name]++
+		return true
+	}
+	p.reservedProps[name] = true
+	return false
+}
+
+func (p *parser) recordPropertyKey(key ast.Expr) {
+	if str, ok := key.Data.(*ast.EString); ok {
+		str.MangleProp = p.recordPropertyName(lexer.UTF16ToString(str.Value))
+	}
+}
+
 func (p *parser) valueForDefine(loc ast.Loc, assignTarget ast.AssignTarget, defineFunc config.DefineFunc) ast.Expr {
 	expr := ast.Expr{Loc: loc, Data: defineFunc(p.findSymbolHelper)}
 	if id, ok := expr.Data.(*ast.EIdentifier); ok {
@@ -7735,7 +7978,7 @@ func (p *parser) handleIdentifier(loc ast.Loc, assignTarget ast.AssignTarget, e
 	}
 
 	// Warn about uses of "require" other than a direct call
-	if ref == p.requireRef && e != p.callTarget && e != p.typeofTarget && p.tryBodyCount == 0 {
+	if ref == p.requireRef && e != p.callTarget && e != p.typeofTarget && p.tryBodyCount == 0 && !p.IsScanningImports {
 		// "typeof require == 'function' && require"
 		if e == p.typeofRequireEqualsFnTarget {
 			// Become "false" in the browser and "require" in node
@@ -7789,8 +8032,9 @@ func (p *parser) scanForImportsAndExports(stmts []ast.Stmt, isBundling bool) []a
 		case *ast.SImport:
 			// TypeScript always trims unused imports. This is important for
 			// correctness since some imports might be fake (only in the type
-			// system and used for type-only imports).
-			if p.MangleSyntax || p.TS.Parse {
+			// system and used for type-only imports). Imports are kept when
+			// syntax is being preserved since they are part of the source.
+			if (p.MangleSyntax || p.TS.Parse) && !p.PreserveSyntax {
 				foundImports := false
 				isUnusedInTypeScript := true
 
@@ -8033,7 +8277,15 @@ func (p *parser) appendPart(parts []ast.Part, stmts []ast.Stmt) []ast.Part {
 		SymbolUses: p.symbolUses,
 	}
 	if len(part.Stmts) > 0 {
-		part.CanBeRemovedIfUnused = p.stmtsCanBeRemovedIfUnused(part.Stmts)
+		var importedCallRefs []ast.Ref
+		p.importedCallRefs = &importedCallRefs
+		canBeRemovedIfUnused := p.stmtsCanBeRemovedIfUnused(part.Stmts)
+		p.importedCallRefs = nil
+		if canBeRemovedIfUnused && len(importedCallRefs) > 0 {
+			part.CanBeRemovedIfImportedCallsAreUnused = importedCallRefs
+		} else {
+			part.CanBeRemovedIfUnused = canBeRemovedIfUnused
+		}
 		part.DeclaredSymbols = p.declaredSymbols
 		part.ImportRecordIndices = p.importRecordsForCurrentPart
 		parts = append(parts, part)
@@ -8059,7 +8311,7 @@ func (p *parser) stmtsCanBeRemovedIfUnused(stmts []ast.Stmt) bool {
 			}
 
 		case *ast.SExpr:
-			if !p.exprCanBeRemovedIfUnused(s.Value) {
+			if !s.DoesNotAffectTreeShaking && !p.exprCanBeRemovedIfUnused(s.Value) {
 				return false
 			}
 
@@ -8209,6 +8461,18 @@ func (p *parser) exprCanBeRemovedIfUnused(expr ast.Expr) bool {
 			return true
 		}
 
+		// A call to an imported function may be removable, but we won't know
+		// until the linker has bound the import to the function declaration
+		if id, ok := e.Target.Data.(*ast.EImportIdentifier); ok && p.importedCallRefs != nil {
+			for _, arg := range e.Args {
+				if !p.exprCanBeRemovedIfUnused(arg) {
+					return false
+				}
+			}
+			*p.importedCallRefs = append(*p.importedCallRefs, id.Ref)
+			return true
+		}
+
 	case *ast.ENew:
 		// A constructor call that has been marked "__PURE__" can be removed if all
 		// arguments can be removed. The annotation causes us to ignore the target.
@@ -8470,6 +8734,23 @@ func newParser(log logging.Log, source logging.Source, lexer lexer.Lexer, option
 		isImportItem:            make(map[ast.Ref]bool),
 		namedImports:            make(map[ast.Ref]ast.NamedImport),
 		namedExports:            make(map[string]ast.Ref),
+
+		importItemsUsedInDeadCode: make(map[ast.Ref]bool),
+	}
+
+	if options.PreserveSyntax {
+		p.nodeEnds = make(map[interface{}]int32)
+	}
+
+	if options.MangleSyntax {
+		p.constValues = make(map[ast.Ref]ast.Expr)
+		p.enumValues = make(map[ast.Ref]map[string]float64)
+		p.importPropertyReads = make(map[ast.Ref]map[string]uint32)
+	}
+
+	if options.MangleProps != nil {
+		p.mangledProps = make(map[string]uint32)
+		p.reservedProps = make(map[string]bool)
 	}
 
 	p.findSymbolHelper = func(name string) ast.Ref { return p.findSymbol(name).ref }
@@ -8497,7 +8778,12 @@ func Parse(log logging.Log, source logging.Source, options config.Options) (resu
 		options.JSX.Fragment = []string{"React", "Fragment"}
 	}
 
-	p := newParser(log, source, lexer.NewLexer(log, source), &options)
+	var p *parser
+	if options.PreserveSyntax {
+		p = newParser(log, source, lexer.NewLexerRecordingSyntax(log, source), &options)
+	} else {
+		p = newParser(log, source, lexer.NewLexer(log, source), &options)
+	}
 
 	// Consume a leading hashbang comment
 	hashbang := ""
@@ -8512,7 +8798,7 @@ func Parse(log logging.Log, source logging.Source, options config.Options) (resu
 
 	// Strip off a leading "use strict" directive when not bundling
 	directive := ""
-	if !options.IsBundling && len(stmts) > 0 {
+	if !options.IsBundling && !options.PreserveSyntax && len(stmts) > 0 {
 		if s, ok := stmts[0].Data.(*ast.SDirective); ok {
 			directive = lexer.UTF16ToString(s.Value)
 			stmts = stmts[1:]
@@ -8539,7 +8825,7 @@ func Parse(log logging.Log, source logging.Source, options config.Options) (resu
 	// correctly while handling arrow functions because of the grammar
 	// ambiguities.
 	parts := []ast.Part{}
-	if !p.IsBundling {
+	if !p.IsBundling && !p.IsConvertingFormat() {
 		// When not bundling, everything comes in a single part
 		parts = p.appendPart(parts, stmts)
 	} else {
@@ -8606,7 +8892,7 @@ func (p *parser) prepareForVisitPass(options *config.Options) {
 	p.pushScopeForVisitPass(ast.ScopeEntry, ast.Loc{Start: locModuleScope})
 	p.moduleScope = p.currentScope
 
-	if options.IsBundling {
+	if options.IsBundling || options.IsConvertingFormat() {
 		p.exportsRef = p.declareCommonJSSymbol(ast.SymbolHoisted, "exports")
 		p.requireRef = p.declareCommonJSSymbol(ast.SymbolUnbound, "require")
 		p.moduleRef = p.declareCommonJSSymbol(ast.SymbolHoisted, "module")
@@ -8617,7 +8903,7 @@ func (p *parser) prepareForVisitPass(options *config.Options) {
 	}
 
 	// Convert "import.meta" to a variable if it's not supported in the output format
-	if p.hasImportMeta && (p.UnsupportedFeatures.Has(compat.ImportMeta) || (options.IsBundling && !p.OutputFormat.KeepES6ImportExportSyntax())) {
+	if p.hasImportMeta && (p.UnsupportedFeatures.Has(compat.ImportMeta) || ((options.IsBundling || options.IsConvertingFormat()) && !p.OutputFormat.KeepES6ImportExportSyntax())) {
 		p.importMetaRef = p.newSymbol(ast.SymbolOther, "import_meta")
 		p.moduleScope.Generated = append(p.moduleScope.Generated, p.importMetaRef)
 	} else {
@@ -8670,6 +8956,35 @@ func (p *parser) declareCommonJSSymbol(kind ast.SymbolKind, name string) ast.Ref
 	return ref
 }
 
+// An import statement whose imported names are only ever referenced in dead
+// code doesn't need to be included in the bundle. Any use outside of dead code,
+// or a re-export of an imported name, means the import must be kept.
+func (p *parser) markImportsOnlyUsedInDeadCode(parts []ast.Part) {
+	liveRefs := make(map[ast.Ref]bool)
+	for _, part := range parts {
+		for ref := range part.SymbolUses {
+			liveRefs[ref] = true
+		}
+	}
+
+	isLive := make(map[uint32]bool)
+	isCandidate := make(map[uint32]bool)
+	for ref, namedImport := range p.namedImports {
+		index := namedImport.ImportRecordIndex
+		if len(namedImport.LocalPartsWithUses) > 0 || namedImport.IsExported || liveRefs[namedImport.NamespaceRef] {
+			isLive[index] = true
+		} else if p.importItemsUsedInDeadCode[ref] {
+			isCandidate[index] = true
+		}
+	}
+
+	for index := range isCandidate {
+		if record := &p.importRecords[index]; !isLive[index] && record.Kind == ast.ImportStmt {
+			record.IsOnlyUsedInDeadCode = true
+		}
+	}
+}
+
 func (p *parser) toAST(source logging.Source, parts []ast.Part, hashbang string, directive string) ast.AST {
 	// Insert an import statement for any runtime imports we generated
 	if len(p.runtimeImports) > 0 {
@@ -8720,7 +9035,7 @@ func (p *parser) toAST(source logging.Source, parts []ast.Part, hashbang string,
 	for _, part := range parts {
 		p.importRecordsForCurrentPart = nil
 		p.declaredSymbols = nil
-		part.Stmts = p.scanForImportsAndExports(part.Stmts, p.IsBundling)
+		part.Stmts = p.scanForImportsAndExports(part.Stmts, p.IsBundling || p.IsConvertingFormat())
 		part.ImportRecordIndices = append(part.ImportRecordIndices, p.importRecordsForCurrentPart...)
 		part.DeclaredSymbols = append(part.DeclaredSymbols, p.declaredSymbols...)
 		if len(part.Stmts) > 0 {
@@ -8754,11 +9069,16 @@ func (p *parser) toAST(source logging.Source, parts []ast.Part, hashbang string,
 				// Also map from imports to parts that use them
 				if namedImport, ok := p.namedImports[ref]; ok {
 					namedImport.LocalPartsWithUses = append(namedImport.LocalPartsWithUses, uint32(partIndex))
+					namedImport.PropertyReads = p.importPropertyReads[ref]
 					p.namedImports[ref] = namedImport
 				}
 			}
 			parts[partIndex].LocalDependencies = localDependencies
 		}
+
+		if (p.IsBundling || p.IsConvertingFormat()) && len(p.importItemsUsedInDeadCode) > 0 {
+			p.markImportsOnlyUsedInDeadCode(parts)
+		}
 	}
 
 	// Make a wrapper symbol in case we need to be wrapped in a closure
@@ -8789,7 +9109,21 @@ func (p *parser) toAST(source logging.Source, parts []ast.Part, hashbang string,
 		UsesModuleRef:     p.symbols[p.moduleRef.InnerIndex].UseCountEstimate > 0,
 
 		// ES6 features
-		HasES6Imports: p.hasES6ImportSyntax,
-		HasES6Exports: p.hasES6ExportSyntax,
+		HasES6Imports:  p.hasES6ImportSyntax,
+		HasES6Exports:  p.hasES6ExportSyntax,
+		UsesImportMeta: p.hasImportMeta,
+
+		// Cross-module inlining
+		ConstValues: p.constValues,
+		EnumValues:  p.enumValues,
+
+		// Property mangling
+		MangledProps:  p.mangledProps,
+		ReservedProps: p.reservedProps,
+
+		// Preserved syntax
+		Tokens:   p.lexer.Tokens,
+		Comments: p.lexer.Comments,
+		NodeEnds: p.nodeEnds,
 	}
 }
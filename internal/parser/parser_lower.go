@@ -291,9 +291,10 @@ flatten:
 			case *ast.EDot:
 				targetFunc, wrapFunc := p.captureValueWithPossibleSideEffects(loc, 2, e.Target)
 				expr = ast.Expr{Loc: loc, Data: &ast.EDot{
-					Target:  targetFunc(),
-					Name:    e.Name,
-					NameLoc: e.NameLoc,
+					Target:     targetFunc(),
+					Name:       e.Name,
+					NameLoc:    e.NameLoc,
+					MangleProp: e.MangleProp,
 				}}
 				thisArg = targetFunc()
 				targetWrapFunc = wrapFunc
@@ -342,9 +343,10 @@ flatten:
 		switch e := chain[i].Data.(type) {
 		case *ast.EDot:
 			result = ast.Expr{Loc: loc, Data: &ast.EDot{
-				Target:  result,
-				Name:    e.Name,
-				NameLoc: e.NameLoc,
+				Target:     result,
+				Name:       e.Name,
+				NameLoc:    e.NameLoc,
+				MangleProp: e.MangleProp,
 			}}
 
 		case *ast.EIndex:
@@ -450,14 +452,16 @@ func (p *parser) lowerAssignmentOperator(value ast.Expr, callback func(ast.Expr,
 			referenceFunc, wrapFunc := p.captureValueWithPossibleSideEffects(value.Loc, 2, left.Target)
 			return wrapFunc(callback(
 				ast.Expr{Loc: value.Loc, Data: &ast.EDot{
-					Target:  referenceFunc(),
-					Name:    left.Name,
-					NameLoc: left.NameLoc,
+					Target:     referenceFunc(),
+					Name:       left.Name,
+					NameLoc:    left.NameLoc,
+					MangleProp: left.MangleProp,
 				}},
 				ast.Expr{Loc: value.Loc, Data: &ast.EDot{
-					Target:  referenceFunc(),
-					Name:    left.Name,
-					NameLoc: left.NameLoc,
+					Target:     referenceFunc(),
+					Name:       left.Name,
+					NameLoc:    left.NameLoc,
+					MangleProp: left.MangleProp,
 				}},
 			))
 		}
@@ -1295,11 +1299,12 @@ func (p *parser) lowerClass(stmt ast.Stmt, expr ast.Expr) ([]ast.Stmt, ast.Expr)
 	// We always lower class fields when parsing TypeScript since class fields in
 	// TypeScript don't follow the JavaScript spec. We also need to always lower
 	// TypeScript-style decorators since they don't have a JavaScript equivalent.
+	// None of this applies when syntax is being preserved.
 	classFeatures := compat.ClassField | compat.ClassStaticField |
 		compat.ClassPrivateField | compat.ClassPrivateStaticField |
 		compat.ClassPrivateMethod | compat.ClassPrivateStaticMethod |
 		compat.ClassPrivateAccessor | compat.ClassPrivateStaticAccessor
-	if !p.TS.Parse && !p.UnsupportedFeatures.Has(classFeatures) {
+	if p.PreserveSyntax || (!p.TS.Parse && !p.UnsupportedFeatures.Has(classFeatures)) {
 		if kind == classKindExpr {
 			return nil, expr
 		} else {
@@ -1552,9 +1557,10 @@ func (p *parser) lowerClass(stmt ast.Stmt, expr ast.Expr) ([]ast.Stmt, ast.Expr)
 				} else {
 					if key, ok := prop.Key.Data.(*ast.EString); ok && !prop.IsComputed {
 						target = ast.Expr{Loc: loc, Data: &ast.EDot{
-							Target:  target,
-							Name:    lexer.UTF16ToString(key.Value),
-							NameLoc: loc,
+							Target:     target,
+							Name:       lexer.UTF16ToString(key.Value),
+							NameLoc:    loc,
+							MangleProp: key.MangleProp,
 						}}
 					} else {
 						target = ast.Expr{Loc: loc, Data: &ast.EIndex{
@@ -1655,11 +1661,13 @@ func (p *parser) lowerClass(stmt ast.Stmt, expr ast.Expr) ([]ast.Stmt, ast.Expr)
 						for _, arg := range ctor.Fn.Args {
 							if arg.IsTypeScriptCtorField {
 								if id, ok := arg.Binding.Data.(*ast.BIdentifier); ok {
+									name := p.symbols[id.Ref.InnerIndex].Name
 									parameterFields = append(parameterFields, ast.AssignStmt(
 										ast.Expr{Loc: arg.Binding.Loc, Data: &ast.EDot{
-											Target:  ast.Expr{Loc: arg.Binding.Loc, Data: &ast.EThis{}},
-											Name:    p.symbols[id.Ref.InnerIndex].Name,
-											NameLoc: arg.Binding.Loc,
+											Target:     ast.Expr{Loc: arg.Binding.Loc, Data: &ast.EThis{}},
+											Name:       name,
+											NameLoc:    arg.Binding.Loc,
+											MangleProp: p.recordPropertyName(name),
 										}},
 										ast.Expr{Loc: arg.Binding.Loc, Data: &ast.EIdentifier{Ref: id.Ref}},
 									))
@@ -83,6 +83,27 @@ func expectPrintedMangle(t *testing.T, contents string, expected string) {
 	})
 }
 
+func expectPrintedDrop(t *testing.T, contents string, expected string) {
+	t.Run(contents, func(t *testing.T) {
+		log := logging.NewDeferLog()
+		ast, ok := Parse(log, test.SourceForTest(contents), config.Options{
+			DropConsole:  true,
+			DropDebugger: true,
+		})
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logging.StderrOptions{}, logging.TerminalInfo{})
+		}
+		test.AssertEqual(t, text, "")
+		if !ok {
+			t.Fatal("Parse error")
+		}
+		js := printer.Print(ast, printer.PrintOptions{}).JS
+		test.AssertEqual(t, string(js), expected)
+	})
+}
+
 func expectPrintedTarget(t *testing.T, esVersion int, contents string, expected string) {
 	t.Run(contents, func(t *testing.T) {
 		log := logging.NewDeferLog()
@@ -1553,6 +1574,20 @@ func TestReplacementCharacter(t *testing.T) {
 	expectPrintedJSX(t, "<a>\uFFFD</a>", "/* @__PURE__ */ React.createElement(\"a\", null, \"\uFFFD\");\n")
 }
 
+func TestDrop(t *testing.T) {
+	expectPrintedDrop(t, "debugger", "")
+	expectPrintedDrop(t, "if (a) debugger; else b()", "if (a)\n  ;\nelse\n  b();\n")
+	expectPrintedDrop(t, "console.log(a(), b())", "")
+	expectPrintedDrop(t, "console['warn'](a)", "")
+	expectPrintedDrop(t, "console.log.call(console, a)", "")
+	expectPrintedDrop(t, "x = console.log(() => a())", "x = void 0;\n")
+	expectPrintedDrop(t, "console(a)", "console(a);\n")
+	expectPrintedDrop(t, "x = console.log", "x = console.log;\n")
+	expectPrintedDrop(t, "let console; console.log(a)", "let console;\nconsole.log(a);\n")
+	expectPrintedDrop(t, "undefined; void 0", "void 0;\nvoid 0;\n")
+	expectPrintedDrop(t, "(console.log(a))", "")
+}
+
 func TestNewTarget(t *testing.T) {
 	expectPrinted(t, "new.target", "new.target;\n")
 	expectPrinted(t, "(new.target)", "new.target;\n")
@@ -0,0 +1,1992 @@
+package printer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/lexer"
+	"github.com/evanw/esbuild/internal/logging"
+)
+
+// This converts an AST to JSON in the ESTree format used by JavaScript tools
+// such as ESLint. The AST must have been parsed with "PreserveSyntax" enabled
+// so the locations of tokens, comments, and node ends are available.
+//
+// Every node has "start" and "end" offsets, a "range" array with the same
+// offsets, and a "loc" object with 1-based lines and 0-based columns. Offsets
+// and columns count UTF-16 code units to match JavaScript string indices.
+//
+// Identifiers that reference a symbol have a "binding" field with the range
+// of the identifier that declares that symbol, or null if the symbol isn't
+// declared in this file.
+//
+// This is not TS-ESTree. Type annotations and other type-only syntax are not
+// present because the parser doesn't keep them in the AST. Only TypeScript
+// enums, namespaces, and "export =" statements add nodes, which use the same
+// node types as TS-ESTree.
+func PrintESTree(tree ast.AST, source logging.Source) []byte {
+	p := &estreePrinter{
+		source:        source,
+		symbols:       tree.Symbols,
+		importRecords: tree.ImportRecords,
+		tokens:        tree.Tokens,
+		nodeEnds:      tree.NodeEnds,
+		starts:        make(map[ast.E]int32),
+		declarations:  make(map[ast.Ref][]interface{}),
+	}
+	p.computeOffsets()
+	p.matchBrackets()
+
+	stmts := []ast.Stmt{}
+	for _, part := range tree.Parts {
+		stmts = append(stmts, part.Stmts...)
+	}
+
+	sourceType := "script"
+	if tree.HasES6Syntax() {
+		sourceType = "module"
+	}
+
+	program := p.node("Program", 0, int32(len(source.Contents)))
+	program.set("body", p.stmts(stmts))
+	program.set("sourceType", sourceType)
+	program.set("comments", p.comments(tree.Comments))
+
+	// References are resolved at the end since a declaration may come after
+	// the identifiers that reference it
+	for _, reference := range p.references {
+		var binding interface{}
+		if r, ok := p.declarations[ast.FollowSymbols(p.symbols, reference.ref)]; ok {
+			binding = r
+		}
+		reference.node.set("binding", binding)
+	}
+
+	return appendJSONValue(nil, program)
+}
+
+type estreePrinter struct {
+	source        logging.Source
+	symbols       ast.SymbolMap
+	importRecords []ast.ImportRecord
+	tokens        []ast.Range
+	nodeEnds      map[interface{}]int32
+
+	// For each token, this is the index of the matching bracket token or -1
+	matches []int
+
+	// These convert byte offsets into UTF-16 offsets and lines
+	utf16Offsets []int32
+	lineStarts   []int32
+
+	// This caches expression starts, which otherwise take linear time to find
+	// for left-recursive expressions such as "a + b + c"
+	starts map[ast.E]int32
+
+	// The range of the identifier that declares each symbol
+	declarations map[ast.Ref][]interface{}
+	references   []estreeReference
+}
+
+type estreeReference struct {
+	node *estreeNode
+	ref  ast.Ref
+}
+
+// Fields are stored in order so the output looks like what other ESTree
+// parsers produce. The start and end are byte offsets that aren't printed.
+type estreeNode struct {
+	fields []estreeField
+	start  int32
+	end    int32
+}
+
+type estreeField struct {
+	key   string
+	value interface{}
+}
+
+func (n *estreeNode) set(key string, value interface{}) {
+	// Avoid storing typed nil pointers, which wouldn't be printed as "null"
+	if node, ok := value.(*estreeNode); ok && node == nil {
+		value = nil
+	}
+
+	for i := range n.fields {
+		if n.fields[i].key == key {
+			n.fields[i].value = value
+			return
+		}
+	}
+	n.fields = append(n.fields, estreeField{key, value})
+}
+
+func appendJSONValue(js []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(js, "null"...)
+
+	case bool:
+		return strconv.AppendBool(js, v)
+
+	case int32:
+		return strconv.AppendInt(js, int64(v), 10)
+
+	case int:
+		return strconv.AppendInt(js, int64(v), 10)
+
+	case float64:
+		// JSON doesn't have "NaN" or "Infinity"
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return append(js, "null"...)
+		}
+		return strconv.AppendFloat(js, v, 'g', -1, 64)
+
+	case string:
+		return append(js, QuoteForJSON(v)...)
+
+	case []interface{}:
+		js = append(js, '[')
+		for i, item := range v {
+			if i > 0 {
+				js = append(js, ',')
+			}
+			js = appendJSONValue(js, item)
+		}
+		return append(js, ']')
+
+	case *estreeNode:
+		js = append(js, '{')
+		for i, field := range v.fields {
+			if i > 0 {
+				js = append(js, ',')
+			}
+			js = append(js, QuoteForJSON(field.key)...)
+			js = append(js, ':')
+			js = appendJSONValue(js, field.value)
+		}
+		return append(js, '}')
+
+	default:
+		panic(fmt.Sprintf("Unexpected JSON value of type %T", value))
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Locations
+
+func (p *estreePrinter) computeOffsets() {
+	contents := p.source.Contents
+	p.utf16Offsets = make([]int32, len(contents)+1)
+	p.lineStarts = []int32{0}
+	offset := int32(0)
+
+	for i := 0; i < len(contents); {
+		c, width := utf8.DecodeRuneInString(contents[i:])
+		for j := 0; j < width; j++ {
+			p.utf16Offsets[i+j] = offset
+		}
+		if c >= 0x10000 {
+			offset += 2
+		} else {
+			offset++
+		}
+		i += width
+
+		switch c {
+		case '\r':
+			if i < len(contents) && contents[i] == '\n' {
+				continue
+			}
+			p.lineStarts = append(p.lineStarts, int32(i))
+
+		case '\n', ' ', ' ':
+			p.lineStarts = append(p.lineStarts, int32(i))
+		}
+	}
+
+	p.utf16Offsets[len(contents)] = offset
+}
+
+func (p *estreePrinter) offset(byteOffset int32) int32 {
+	return p.utf16Offsets[byteOffset]
+}
+
+func (p *estreePrinter) position(byteOffset int32) *estreeNode {
+	line := sort.Search(len(p.lineStarts), func(i int) bool {
+		return p.lineStarts[i] > byteOffset
+	}) - 1
+	column := p.utf16Offsets[byteOffset] - p.utf16Offsets[p.lineStarts[line]]
+	return &estreeNode{fields: []estreeField{
+		{"line", line + 1},
+		{"column", column},
+	}}
+}
+
+func (p *estreePrinter) node(kind string, start int32, end int32) *estreeNode {
+	if end < start {
+		end = start
+	}
+	return &estreeNode{start: start, end: end, fields: []estreeField{
+		{"type", kind},
+		{"start", p.offset(start)},
+		{"end", p.offset(end)},
+		{"range", p.rangeValue(start, end)},
+		{"loc", &estreeNode{fields: []estreeField{
+			{"start", p.position(start)},
+			{"end", p.position(end)},
+		}}},
+	}}
+}
+
+func (p *estreePrinter) rangeValue(start int32, end int32) []interface{} {
+	return []interface{}{p.offset(start), p.offset(end)}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Tokens
+
+func (p *estreePrinter) matchBrackets() {
+	p.matches = make([]int, len(p.tokens))
+	stack := []int{}
+
+	// Template literal tokens close and open a substitution at the same time
+	for i := range p.tokens {
+		p.matches[i] = -1
+		text := p.tokenText(i)
+		closes := byte(0)
+		opens := byte(0)
+
+		switch text {
+		case "(", "[", "{":
+			opens = text[0]
+		case ")":
+			closes = '('
+		case "]":
+			closes = '['
+		case "}":
+			closes = '{'
+		default:
+			if len(text) >= 2 && (text[0] == '`' || text[0] == '}') {
+				if text[0] == '}' {
+					closes = '`'
+				}
+				if strings.HasSuffix(text, "${") {
+					opens = '`'
+				}
+			}
+		}
+
+		if closes != 0 {
+			// Tolerate mismatched brackets instead of giving up completely
+			for j := len(stack) - 1; j >= 0; j-- {
+				if p.tokenText(stack[j])[0] == closes || (closes == '`' && p.tokenText(stack[j])[0] == '}') {
+					p.matches[stack[j]] = i
+					p.matches[i] = stack[j]
+					stack = stack[:j]
+					break
+				}
+			}
+		}
+		if opens != 0 {
+			stack = append(stack, i)
+		}
+	}
+}
+
+func (p *estreePrinter) tokenText(i int) string {
+	if i < 0 || i >= len(p.tokens) {
+		return ""
+	}
+	r := p.tokens[i]
+	return p.source.Contents[r.Loc.Start:r.End()]
+}
+
+func (p *estreePrinter) tokenStart(i int) int32 {
+	if i < 0 {
+		return 0
+	}
+	if i >= len(p.tokens) {
+		return int32(len(p.source.Contents))
+	}
+	return p.tokens[i].Loc.Start
+}
+
+func (p *estreePrinter) tokenEnd(i int) int32 {
+	if i < 0 {
+		return 0
+	}
+	if i >= len(p.tokens) {
+		return int32(len(p.source.Contents))
+	}
+	return p.tokens[i].End()
+}
+
+// This returns the index of the first token that starts at or after "offset"
+func (p *estreePrinter) tokenAfter(offset int32) int {
+	return sort.Search(len(p.tokens), func(i int) bool {
+		return p.tokens[i].Loc.Start >= offset
+	})
+}
+
+func (p *estreePrinter) endOfTokenAt(loc ast.Loc) int32 {
+	if i := p.tokenAfter(loc.Start); i < len(p.tokens) && p.tokens[i].Loc == loc {
+		return p.tokens[i].End()
+	}
+	return loc.Start
+}
+
+// This returns the end of the bracket that matches the bracket at "loc"
+func (p *estreePrinter) matchingEnd(loc ast.Loc) int32 {
+	if i := p.tokenAfter(loc.Start); i < len(p.tokens) && p.tokens[i].Loc == loc && p.matches[i] != -1 {
+		return p.tokens[p.matches[i]].End()
+	}
+	return p.endOfTokenAt(loc)
+}
+
+// Include a semicolon that immediately follows the end of a node
+func (p *estreePrinter) endWithSemicolon(end int32) int32 {
+	if i := p.tokenAfter(end); p.tokenText(i) == ";" {
+		return p.tokenEnd(i)
+	}
+	return end
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Expression ranges
+
+func (p *estreePrinter) exprStart(expr ast.Expr) int32 {
+	if start, ok := p.starts[expr.Data]; ok {
+		return start
+	}
+
+	// Nodes that begin with a child expression start where that child starts,
+	// including any parentheses around the child
+	start := expr.Loc.Start
+	switch e := expr.Data.(type) {
+	case *ast.EBinary:
+		start, _ = p.outerRange(e.Left)
+	case *ast.ECall:
+		start, _ = p.outerRange(e.Target)
+	case *ast.EDot:
+		start, _ = p.outerRange(e.Target)
+	case *ast.EIndex:
+		start, _ = p.outerRange(e.Target)
+	case *ast.EIf:
+		start, _ = p.outerRange(e.Test)
+	case *ast.ETemplate:
+		if e.Tag != nil {
+			start, _ = p.outerRange(*e.Tag)
+		}
+	case *ast.EUnary:
+		if !e.Op.IsPrefix() {
+			start, _ = p.outerRange(e.Value)
+		}
+	}
+
+	if expr.Data != nil {
+		p.starts[expr.Data] = start
+	}
+	return start
+}
+
+func (p *estreePrinter) exprEnd(expr ast.Expr) int32 {
+	switch e := expr.Data.(type) {
+	case *ast.EMissing:
+		return expr.Loc.Start
+
+	case *ast.ESuper, *ast.ENull, *ast.EUndefined, *ast.EThis:
+		// Pointers to these empty nodes aren't distinct, so skip the end table
+		return p.endOfTokenAt(expr.Loc)
+
+	case *ast.ENewTarget, *ast.EImportMeta:
+		// "new.target" and "import.meta" are three tokens
+		return p.tokenEnd(p.tokenAfter(expr.Loc.Start) + 2)
+
+	case *ast.EArray:
+		return p.matchingEnd(expr.Loc)
+
+	case *ast.EObject:
+		return p.matchingEnd(expr.Loc)
+
+	case *ast.EFunction:
+		return p.matchingEnd(e.Fn.Body.Loc)
+
+	case *ast.EClass:
+		return p.matchingEnd(e.Class.BodyLoc)
+
+	case *ast.EJSXElement:
+		return p.jsxElement(expr.Loc, e).end
+
+	case *ast.EBinary:
+		// Comma operators inside parentheses are only created after the closing
+		// parenthesis is parsed, so the end table includes that parenthesis
+		_, end := p.outerRange(e.Right)
+		return end
+	}
+
+	if end, ok := p.nodeEnds[expr.Data]; ok {
+		return end
+	}
+
+	// Fall back to computing the end from the children
+	switch e := expr.Data.(type) {
+	case *ast.EUnary:
+		if e.Op.IsPrefix() {
+			_, end := p.outerRange(e.Value)
+			return end
+		}
+		_, end := p.outerRange(e.Value)
+		return p.tokenEnd(p.tokenAfter(end))
+
+	case *ast.EDot:
+		return p.endOfTokenAt(e.NameLoc)
+
+	case *ast.EIndex:
+		_, end := p.outerRange(e.Index)
+		return p.tokenEnd(p.tokenAfter(end))
+
+	case *ast.EArrow:
+		if e.PreferExpr && len(e.Body.Stmts) == 1 {
+			if s, ok := e.Body.Stmts[0].Data.(*ast.SReturn); ok && s.Value != nil {
+				_, end := p.outerRange(*s.Value)
+				return end
+			}
+		}
+		return p.matchingEnd(e.Body.Loc)
+
+	case *ast.ESpread:
+		_, end := p.outerRange(e.Value)
+		return end
+
+	case *ast.EAwait:
+		_, end := p.outerRange(e.Value)
+		return end
+
+	case *ast.EYield:
+		if e.Value != nil {
+			_, end := p.outerRange(*e.Value)
+			return end
+		}
+
+	case *ast.EIf:
+		_, end := p.outerRange(e.No)
+		return end
+
+	case *ast.ETemplate:
+		if len(e.Parts) > 0 {
+			_, end := p.outerRange(e.Parts[len(e.Parts)-1].Value)
+			return p.tokenEnd(p.tokenAfter(end))
+		}
+		if e.Tag != nil {
+			_, end := p.outerRange(*e.Tag)
+			return p.tokenEnd(p.tokenAfter(end))
+		}
+	}
+
+	return p.endOfTokenAt(expr.Loc)
+}
+
+// Parentheses around an expression aren't part of that expression in ESTree,
+// but they are part of any node that contains it. This returns the range of
+// an expression including any parentheses around it.
+func (p *estreePrinter) outerRange(expr ast.Expr) (int32, int32) {
+	start, end := p.exprStart(expr), p.exprEnd(expr)
+	for {
+		before := p.tokenAfter(start) - 1
+		after := p.tokenAfter(end)
+		if before < 0 || after >= len(p.tokens) || p.tokenText(before) != "(" || p.matches[before] != after {
+			return start, end
+		}
+		start, end = p.tokens[before].Loc.Start, p.tokens[after].End()
+	}
+}
+
+func (p *estreePrinter) stmtEnd(stmt ast.Stmt) int32 {
+	switch stmt.Data.(type) {
+	case *ast.SEmpty:
+		return p.endOfTokenAt(stmt.Loc)
+
+	case *ast.SDebugger:
+		return p.endWithSemicolon(p.endOfTokenAt(stmt.Loc))
+	}
+
+	if end, ok := p.nodeEnds[stmt.Data]; ok {
+		return end
+	}
+	return p.endOfTokenAt(stmt.Loc)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Identifiers
+
+func (p *estreePrinter) identifier(loc ast.Loc, name string) *estreeNode {
+	node := p.node("Identifier", loc.Start, p.endOfTokenAt(loc))
+	node.set("name", name)
+	return node
+}
+
+// This is an identifier that declares a symbol
+func (p *estreePrinter) bindingIdentifier(loc ast.Loc, refs ...ast.Ref) *estreeNode {
+	node := p.identifier(loc, p.symbols.Get(refs[0]).Name)
+	for _, ref := range refs {
+		if ref == ast.InvalidRef {
+			continue
+		}
+		ref = ast.FollowSymbols(p.symbols, ref)
+		if _, ok := p.declarations[ref]; !ok {
+			p.declarations[ref] = p.rangeValue(node.start, node.end)
+		}
+	}
+	return node
+}
+
+// This is an identifier that references a symbol
+func (p *estreePrinter) identifierReference(loc ast.Loc, ref ast.Ref) *estreeNode {
+	node := p.identifier(loc, p.symbols.Get(ref).Name)
+	node.set("binding", nil)
+	p.references = append(p.references, estreeReference{node, ref})
+	return node
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Statements
+
+func (p *estreePrinter) stmts(stmts []ast.Stmt) []interface{} {
+	nodes := []interface{}{}
+	for _, stmt := range stmts {
+		if node := p.stmt(stmt); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func (p *estreePrinter) block(loc ast.Loc, stmts []ast.Stmt) *estreeNode {
+	node := p.node("BlockStatement", loc.Start, p.matchingEnd(loc))
+	node.set("body", p.stmts(stmts))
+	return node
+}
+
+// Declarations with the "export" keyword are represented in the AST as the
+// declaration itself. The statement location is either the location of the
+// "export" keyword or the location of the declaration.
+func (p *estreePrinter) exportStarts(loc ast.Loc, isExport bool) (int32, int32) {
+	if !isExport {
+		return loc.Start, loc.Start
+	}
+	i := p.tokenAfter(loc.Start)
+	if p.tokenText(i) == "export" {
+		return loc.Start, p.tokenStart(i + 1)
+	}
+	return p.tokenStart(i - 1), loc.Start
+}
+
+func (p *estreePrinter) maybeExport(start int32, end int32, isExport bool, decl *estreeNode) *estreeNode {
+	if !isExport {
+		return decl
+	}
+	node := p.node("ExportNamedDeclaration", start, end)
+	node.set("declaration", decl)
+	node.set("specifiers", []interface{}{})
+	node.set("source", nil)
+	return node
+}
+
+func (p *estreePrinter) stmt(stmt ast.Stmt) *estreeNode {
+	start := stmt.Loc.Start
+	end := p.stmtEnd(stmt)
+
+	switch s := stmt.Data.(type) {
+	case *ast.STypeScript, *ast.SComment:
+		// Type-only syntax isn't represented and comments are printed separately
+		return nil
+
+	case *ast.SEmpty:
+		return p.node("EmptyStatement", start, end)
+
+	case *ast.SDebugger:
+		return p.node("DebuggerStatement", start, end)
+
+	case *ast.SDirective:
+		node := p.node("ExpressionStatement", start, end)
+		raw := p.tokenText(p.tokenAfter(start))
+		literal := p.node("Literal", start, p.endOfTokenAt(stmt.Loc))
+		literal.set("value", lexer.UTF16ToString(s.Value))
+		literal.set("raw", raw)
+		node.set("expression", literal)
+		if len(raw) >= 2 {
+			node.set("directive", raw[1:len(raw)-1])
+		}
+		return node
+
+	case *ast.SExpr:
+		node := p.node("ExpressionStatement", start, end)
+		node.set("expression", p.expr(s.Value))
+		return node
+
+	case *ast.SBlock:
+		node := p.node("BlockStatement", start, end)
+		node.set("body", p.stmts(s.Stmts))
+		return node
+
+	case *ast.SLocal:
+		start, declStart := p.exportStarts(stmt.Loc, s.IsExport)
+		decl := p.variableDeclaration(declStart, end, s)
+		return p.maybeExport(start, end, s.IsExport, decl)
+
+	case *ast.SFunction:
+		start, declStart := p.exportStarts(stmt.Loc, s.IsExport)
+		decl := p.function("FunctionDeclaration", declStart, s.Fn)
+		return p.maybeExport(start, end, s.IsExport, decl)
+
+	case *ast.SClass:
+		start, declStart := p.exportStarts(stmt.Loc, s.IsExport)
+		decl := p.class("ClassDeclaration", declStart, s.Class)
+		return p.maybeExport(start, end, s.IsExport, decl)
+
+	case *ast.SEnum:
+		start, declStart := p.exportStarts(stmt.Loc, s.IsExport)
+		decl := p.node("TSEnumDeclaration", declStart, end)
+		decl.set("id", p.bindingIdentifier(s.Name.Loc, s.Name.Ref, s.Arg))
+		members := []interface{}{}
+		for _, value := range s.Values {
+			var id *estreeNode
+			if text := p.tokenText(p.tokenAfter(value.Loc.Start)); strings.HasPrefix(text, "'") || strings.HasPrefix(text, "\"") {
+				id = p.node("Literal", value.Loc.Start, p.endOfTokenAt(value.Loc))
+				id.set("value", lexer.UTF16ToString(value.Name))
+				id.set("raw", text)
+			} else {
+				id = p.bindingIdentifier(value.Loc, value.Ref)
+			}
+			member := p.node("TSEnumMember", id.start, id.end)
+			member.set("id", id)
+			if value.Value != nil {
+				_, member.end = p.outerRange(*value.Value)
+				member = p.withRange(member, "TSEnumMember")
+				member.set("initializer", p.expr(*value.Value))
+			}
+			members = append(members, member)
+		}
+		decl.set("members", members)
+		return p.maybeExport(start, end, s.IsExport, decl)
+
+	case *ast.SNamespace:
+		start, declStart := p.exportStarts(stmt.Loc, s.IsExport)
+		decl := p.namespace(declStart, s)
+		return p.maybeExport(start, decl.end, s.IsExport, decl)
+
+	case *ast.SImport:
+		node := p.node("ImportDeclaration", start, end)
+		specifiers := []interface{}{}
+		if s.DefaultName != nil {
+			local := p.bindingIdentifier(s.DefaultName.Loc, s.DefaultName.Ref)
+			specifier := p.node("ImportDefaultSpecifier", local.start, local.end)
+			specifier.set("local", local)
+			specifiers = append(specifiers, specifier)
+		}
+		if s.StarNameLoc != nil {
+			// "* as ns"
+			local := p.bindingIdentifier(*s.StarNameLoc, s.NamespaceRef)
+			specifier := p.node("ImportNamespaceSpecifier", p.tokenStart(p.tokenAfter(local.start)-2), local.end)
+			specifier.set("local", local)
+			specifiers = append(specifiers, specifier)
+		}
+		if s.Items != nil {
+			for _, item := range *s.Items {
+				imported := p.identifier(item.AliasLoc, item.Alias)
+				local := p.bindingIdentifier(item.Name.Loc, item.Name.Ref)
+				specifier := p.node("ImportSpecifier", imported.start, local.end)
+				specifier.set("imported", imported)
+				specifier.set("local", local)
+				specifiers = append(specifiers, specifier)
+			}
+		}
+		node.set("specifiers", specifiers)
+		node.set("source", p.importSource(s.ImportRecordIndex))
+		return node
+
+	case *ast.SExportClause:
+		node := p.node("ExportNamedDeclaration", start, end)
+		specifiers := []interface{}{}
+		for _, item := range s.Items {
+			local := p.identifierReference(item.Name.Loc, item.Name.Ref)
+			exported := p.identifier(item.AliasLoc, item.Alias)
+			specifier := p.node("ExportSpecifier", local.start, exported.end)
+			specifier.set("local", local)
+			specifier.set("exported", exported)
+			specifiers = append(specifiers, specifier)
+		}
+		node.set("declaration", nil)
+		node.set("specifiers", specifiers)
+		node.set("source", nil)
+		return node
+
+	case *ast.SExportFrom:
+		node := p.node("ExportNamedDeclaration", start, end)
+		specifiers := []interface{}{}
+		for _, item := range s.Items {
+			local := p.identifier(item.Name.Loc, item.OriginalName)
+			exported := p.identifier(item.AliasLoc, item.Alias)
+			specifier := p.node("ExportSpecifier", local.start, exported.end)
+			specifier.set("local", local)
+			specifier.set("exported", exported)
+			specifiers = append(specifiers, specifier)
+		}
+		node.set("declaration", nil)
+		node.set("specifiers", specifiers)
+		node.set("source", p.importSource(s.ImportRecordIndex))
+		return node
+
+	case *ast.SExportStar:
+		node := p.node("ExportAllDeclaration", start, end)
+		var exported *estreeNode
+		if s.Alias != nil {
+			exported = p.identifier(s.Alias.Loc, s.Alias.Name)
+		}
+		node.set("exported", exported)
+		node.set("source", p.importSource(s.ImportRecordIndex))
+		return node
+
+	case *ast.SExportDefault:
+		node := p.node("ExportDefaultDeclaration", start, end)
+
+		// The declaration starts after the "export default" keywords
+		declStart := p.tokenStart(p.tokenAfter(start) + 2)
+		switch {
+		case s.Value.Expr != nil:
+			node.set("declaration", p.expr(*s.Value.Expr))
+
+		case s.Value.Stmt != nil:
+			switch s2 := s.Value.Stmt.Data.(type) {
+			case *ast.SFunction:
+				node.set("declaration", p.function("FunctionDeclaration", declStart, s2.Fn))
+
+			case *ast.SClass:
+				node.set("declaration", p.class("ClassDeclaration", declStart, s2.Class))
+
+			default:
+				panic("Internal error")
+			}
+		}
+		return node
+
+	case *ast.SExportEquals:
+		node := p.node("TSExportAssignment", start, end)
+		node.set("expression", p.expr(s.Value))
+		return node
+
+	case *ast.SIf:
+		node := p.node("IfStatement", start, end)
+		node.set("test", p.expr(s.Test))
+		node.set("consequent", p.stmt(s.Yes))
+		var alternate *estreeNode
+		if s.No != nil {
+			alternate = p.stmt(*s.No)
+		}
+		node.set("alternate", alternate)
+		return node
+
+	case *ast.SFor:
+		node := p.node("ForStatement", start, end)
+		var init, test, update *estreeNode
+		if s.Init != nil {
+			init = p.forInit(*s.Init)
+		}
+		if s.Test != nil {
+			test = p.expr(*s.Test)
+		}
+		if s.Update != nil {
+			update = p.expr(*s.Update)
+		}
+		node.set("init", init)
+		node.set("test", test)
+		node.set("update", update)
+		node.set("body", p.stmt(s.Body))
+		return node
+
+	case *ast.SForIn:
+		node := p.node("ForInStatement", start, end)
+		node.set("left", p.forInit(s.Init))
+		node.set("right", p.expr(s.Value))
+		node.set("body", p.stmt(s.Body))
+		return node
+
+	case *ast.SForOf:
+		node := p.node("ForOfStatement", start, end)
+		node.set("await", s.IsAwait)
+		node.set("left", p.forInit(s.Init))
+		node.set("right", p.expr(s.Value))
+		node.set("body", p.stmt(s.Body))
+		return node
+
+	case *ast.SWhile:
+		node := p.node("WhileStatement", start, end)
+		node.set("test", p.expr(s.Test))
+		node.set("body", p.stmt(s.Body))
+		return node
+
+	case *ast.SDoWhile:
+		node := p.node("DoWhileStatement", start, end)
+		node.set("body", p.stmt(s.Body))
+		node.set("test", p.expr(s.Test))
+		return node
+
+	case *ast.SWith:
+		node := p.node("WithStatement", start, end)
+		node.set("object", p.expr(s.Value))
+		node.set("body", p.stmt(s.Body))
+		return node
+
+	case *ast.SLabel:
+		node := p.node("LabeledStatement", start, end)
+		node.set("label", p.bindingIdentifier(s.Name.Loc, s.Name.Ref))
+		node.set("body", p.stmt(s.Stmt))
+		return node
+
+	case *ast.SBreak:
+		node := p.node("BreakStatement", start, end)
+		var label *estreeNode
+		if s.Name != nil {
+			label = p.identifierReference(s.Name.Loc, s.Name.Ref)
+		}
+		node.set("label", label)
+		return node
+
+	case *ast.SContinue:
+		node := p.node("ContinueStatement", start, end)
+		var label *estreeNode
+		if s.Name != nil {
+			label = p.identifierReference(s.Name.Loc, s.Name.Ref)
+		}
+		node.set("label", label)
+		return node
+
+	case *ast.SReturn:
+		node := p.node("ReturnStatement", start, end)
+		var argument *estreeNode
+		if s.Value != nil {
+			argument = p.expr(*s.Value)
+		}
+		node.set("argument", argument)
+		return node
+
+	case *ast.SThrow:
+		node := p.node("ThrowStatement", start, end)
+		node.set("argument", p.expr(s.Value))
+		return node
+
+	case *ast.STry:
+		node := p.node("TryStatement", start, end)
+		node.set("block", p.block(ast.Loc{Start: p.tokenStart(p.tokenAfter(start) + 1)}, s.Body))
+
+		var handler *estreeNode
+		if s.Catch != nil {
+			// "catch {" or "catch (binding) {"
+			i := p.tokenAfter(s.Catch.Loc.Start) + 1
+			var param *estreeNode
+			if s.Catch.Binding != nil {
+				param = p.binding(*s.Catch.Binding)
+				if p.tokenText(i) == "(" && p.matches[i] != -1 {
+					i = p.matches[i] + 1
+				}
+			}
+			body := p.block(ast.Loc{Start: p.tokenStart(i)}, s.Catch.Body)
+			handler = p.node("CatchClause", s.Catch.Loc.Start, body.end)
+			handler.set("param", param)
+			handler.set("body", body)
+		}
+		node.set("handler", handler)
+
+		var finalizer *estreeNode
+		if s.Finally != nil {
+			finalizer = p.block(ast.Loc{Start: p.tokenStart(p.tokenAfter(s.Finally.Loc.Start) + 1)}, s.Finally.Stmts)
+		}
+		node.set("finalizer", finalizer)
+		return node
+
+	case *ast.SSwitch:
+		node := p.node("SwitchStatement", start, end)
+		node.set("discriminant", p.expr(s.Test))
+		cases := []interface{}{}
+		prevEnd := p.endOfTokenAt(s.BodyLoc)
+		for _, c := range s.Cases {
+			// "case value:" or "default:"
+			i := p.tokenAfter(prevEnd)
+			colon := i + 1
+			var test *estreeNode
+			if c.Value != nil {
+				test = p.expr(*c.Value)
+				_, valueEnd := p.outerRange(*c.Value)
+				colon = p.tokenAfter(valueEnd)
+			}
+			caseEnd := p.tokenEnd(colon)
+			if len(c.Body) > 0 {
+				caseEnd = p.stmtEnd(c.Body[len(c.Body)-1])
+			}
+			node := p.node("SwitchCase", p.tokenStart(i), caseEnd)
+			node.set("test", test)
+			node.set("consequent", p.stmts(c.Body))
+			cases = append(cases, node)
+			prevEnd = caseEnd
+		}
+		node.set("cases", cases)
+		return node
+
+	default:
+		panic(fmt.Sprintf("Unexpected statement of type %T", stmt.Data))
+	}
+}
+
+// ESTree nodes don't have a way to change the range after creating them, so
+// this creates a copy of a node with the range stored in "start" and "end"
+func (p *estreePrinter) withRange(node *estreeNode, kind string) *estreeNode {
+	result := p.node(kind, node.start, node.end)
+	for _, field := range node.fields[len(result.fields):] {
+		result.set(field.key, field.value)
+	}
+	return result
+}
+
+func (p *estreePrinter) variableDeclaration(start int32, end int32, s *ast.SLocal) *estreeNode {
+	kind := "var"
+	switch s.Kind {
+	case ast.LocalLet:
+		kind = "let"
+	case ast.LocalConst:
+		kind = "const"
+	}
+
+	decls := []interface{}{}
+	for _, d := range s.Decls {
+		id := p.binding(d.Binding)
+		declEnd := id.end
+		var init *estreeNode
+		if d.Value != nil {
+			init = p.expr(*d.Value)
+			_, declEnd = p.outerRange(*d.Value)
+		}
+		decl := p.node("VariableDeclarator", id.start, declEnd)
+		decl.set("id", id)
+		decl.set("init", init)
+		decls = append(decls, decl)
+	}
+
+	node := p.node("VariableDeclaration", start, end)
+	node.set("declarations", decls)
+	node.set("kind", kind)
+	return node
+}
+
+// The initializer of a "for" loop is either a declaration without the
+// trailing semicolon, or an expression (which is a pattern for "for-in" and
+// "for-of" loops)
+func (p *estreePrinter) forInit(stmt ast.Stmt) *estreeNode {
+	switch s := stmt.Data.(type) {
+	case *ast.SLocal:
+		end := stmt.Loc.Start
+		if len(s.Decls) > 0 {
+			d := s.Decls[len(s.Decls)-1]
+			if d.Value != nil {
+				_, end = p.outerRange(*d.Value)
+			} else {
+				end = p.bindingEnd(d.Binding)
+			}
+		}
+		return p.variableDeclaration(stmt.Loc.Start, end, s)
+
+	case *ast.SExpr:
+		return p.pattern(s.Value)
+
+	default:
+		panic("Internal error")
+	}
+}
+
+func (p *estreePrinter) namespace(start int32, s *ast.SNamespace) *estreeNode {
+	id := p.bindingIdentifier(s.Name.Loc, s.Name.Ref, s.Arg)
+
+	// "namespace a.b {}" is a namespace inside a namespace
+	var body *estreeNode
+	i := p.tokenAfter(id.end)
+	if p.tokenText(i) == "." && len(s.Stmts) == 1 {
+		if inner, ok := s.Stmts[0].Data.(*ast.SNamespace); ok {
+			body = p.namespace(p.tokenStart(i+1), inner)
+		}
+	}
+	if body == nil {
+		loc := ast.Loc{Start: p.tokenStart(i)}
+		body = p.node("TSModuleBlock", loc.Start, p.matchingEnd(loc))
+		body.set("body", p.stmts(s.Stmts))
+	}
+
+	node := p.node("TSModuleDeclaration", start, body.end)
+	node.set("id", id)
+	node.set("body", body)
+	return node
+}
+
+func (p *estreePrinter) importSource(importRecordIndex uint32) *estreeNode {
+	record := p.importRecords[importRecordIndex]
+	node := p.node("Literal", record.Loc.Start, p.endOfTokenAt(record.Loc))
+	node.set("value", record.Path.Text)
+	node.set("raw", p.source.Contents[node.start:node.end])
+	return node
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Functions and classes
+
+func (p *estreePrinter) function(kind string, start int32, fn ast.Fn) *estreeNode {
+	node := p.node(kind, start, p.matchingEnd(fn.Body.Loc))
+	var id *estreeNode
+	if fn.Name != nil {
+		id = p.bindingIdentifier(fn.Name.Loc, fn.Name.Ref)
+	}
+	node.set("id", id)
+	node.set("expression", false)
+	node.set("generator", fn.IsGenerator)
+	node.set("async", fn.IsAsync)
+	node.set("params", p.args(fn.Args, fn.HasRestArg))
+	node.set("body", p.block(fn.Body.Loc, fn.Body.Stmts))
+	return node
+}
+
+// The function for a method starts at the parameter list
+func (p *estreePrinter) method(fn ast.Fn, keyEnd int32) *estreeNode {
+	i := p.tokenAfter(keyEnd)
+	for i < len(p.tokens) && p.tokenText(i) != "(" {
+		i++
+	}
+	return p.function("FunctionExpression", p.tokenStart(i), fn)
+}
+
+func (p *estreePrinter) args(args []ast.Arg, hasRestArg bool) []interface{} {
+	params := []interface{}{}
+	for i, arg := range args {
+		param := p.binding(arg.Binding)
+		if arg.Default != nil {
+			param = p.assignmentPattern(param, *arg.Default)
+		}
+		if hasRestArg && i+1 == len(args) {
+			param = p.restElement(param)
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+func (p *estreePrinter) class(kind string, start int32, class ast.Class) *estreeNode {
+	end := p.matchingEnd(class.BodyLoc)
+	node := p.node(kind, start, end)
+	var id, superClass *estreeNode
+	if class.Name != nil {
+		id = p.bindingIdentifier(class.Name.Loc, class.Name.Ref)
+	}
+	if class.Extends != nil {
+		superClass = p.expr(*class.Extends)
+	}
+
+	members := []interface{}{}
+	prevEnd := p.endOfTokenAt(class.BodyLoc)
+	for _, prop := range class.Properties {
+		member := p.classMember(prop, prevEnd)
+		members = append(members, member)
+		prevEnd = member.end
+	}
+	body := p.node("ClassBody", class.BodyLoc.Start, end)
+	body.set("body", members)
+
+	node.set("id", id)
+	node.set("superClass", superClass)
+	node.set("body", body)
+	return node
+}
+
+func (p *estreePrinter) classMember(prop ast.Property, prevEnd int32) *estreeNode {
+	start := p.propertyStart(prop.Key, prop.IsComputed, prevEnd)
+	key, keyEnd := p.propertyKey(prop.Key, prop.IsComputed)
+
+	if prop.IsMethod {
+		value := p.method(prop.Value.Data.(*ast.EFunction).Fn, keyEnd)
+		kind := "method"
+		switch {
+		case prop.Kind == ast.PropertyGet:
+			kind = "get"
+		case prop.Kind == ast.PropertySet:
+			kind = "set"
+		case !prop.IsStatic && !prop.IsComputed && p.source.Contents[key.start:key.end] == "constructor":
+			kind = "constructor"
+		}
+		node := p.node("MethodDefinition", start, value.end)
+		node.set("static", prop.IsStatic)
+		node.set("computed", prop.IsComputed)
+		node.set("key", key)
+		node.set("kind", kind)
+		node.set("value", value)
+		return node
+	}
+
+	end := p.skipTypeAnnotation(keyEnd)
+	var value *estreeNode
+	if prop.Initializer != nil {
+		value = p.expr(*prop.Initializer)
+		_, end = p.outerRange(*prop.Initializer)
+	}
+	node := p.node("PropertyDefinition", start, p.endWithSemicolon(end))
+	node.set("static", prop.IsStatic)
+	node.set("computed", prop.IsComputed)
+	node.set("key", key)
+	node.set("value", value)
+	return node
+}
+
+// Type annotations aren't in the AST, so the end of a class field without an
+// initializer is found by skipping tokens until the end of the line or a
+// semicolon. The type annotation for a field with an initializer comes before
+// the initializer and doesn't need to be skipped.
+func (p *estreePrinter) skipTypeAnnotation(end int32) int32 {
+	i := p.tokenAfter(end)
+	for i < len(p.tokens) {
+		text := p.tokenText(i)
+		if text == ";" || text == "}" || text == "=" || strings.ContainsAny(p.source.Contents[end:p.tokens[i].Loc.Start], "\r\n\u2028\u2029") {
+			break
+		}
+		if p.matches[i] > i {
+			i = p.matches[i]
+		}
+		end = p.tokens[i].End()
+		i++
+	}
+	return end
+}
+
+// Modifiers such as "static" and "get" come before the key of a property
+var estreePropertyModifiers = map[string]bool{
+	"*":         true,
+	"abstract":  true,
+	"async":     true,
+	"declare":   true,
+	"get":       true,
+	"override":  true,
+	"private":   true,
+	"protected": true,
+	"public":    true,
+	"readonly":  true,
+	"set":       true,
+	"static":    true,
+}
+
+func (p *estreePrinter) propertyStart(key ast.Expr, isComputed bool, prevEnd int32) int32 {
+	start, _ := p.outerRange(key)
+	i := p.tokenAfter(start) - 1
+	if isComputed && p.tokenText(i) == "[" {
+		start = p.tokenStart(i)
+		i--
+	}
+	for i >= 0 && p.tokens[i].Loc.Start >= prevEnd && estreePropertyModifiers[p.tokenText(i)] {
+		start = p.tokenStart(i)
+		i--
+	}
+	return start
+}
+
+// This returns the key and the end of the key, which includes the "]" for
+// computed keys
+func (p *estreePrinter) propertyKey(key ast.Expr, isComputed bool) (*estreeNode, int32) {
+	if isComputed {
+		_, end := p.outerRange(key)
+		return p.expr(key), p.tokenEnd(p.tokenAfter(end))
+	}
+
+	if str, ok := key.Data.(*ast.EString); ok {
+		if text := p.tokenText(p.tokenAfter(key.Loc.Start)); !strings.HasPrefix(text, "'") && !strings.HasPrefix(text, "\"") {
+			node := p.identifier(key.Loc, lexer.UTF16ToString(str.Value))
+			return node, node.end
+		}
+	}
+
+	node := p.expr(key)
+	return node, node.end
+}
+
+func (p *estreePrinter) objectProperty(prop ast.Property, prevEnd int32) *estreeNode {
+	if prop.Kind == ast.PropertySpread {
+		return p.spreadElement("SpreadElement", p.expr(*prop.Value), *prop.Value)
+	}
+
+	start := p.propertyStart(prop.Key, prop.IsComputed, prevEnd)
+	key, keyEnd := p.propertyKey(prop.Key, prop.IsComputed)
+	kind := "init"
+	switch prop.Kind {
+	case ast.PropertyGet:
+		kind = "get"
+	case ast.PropertySet:
+		kind = "set"
+	}
+
+	var value *estreeNode
+	var end int32
+	shorthand := false
+	if fn, ok := prop.Value.Data.(*ast.EFunction); ok && (prop.IsMethod || prop.Kind != ast.PropertyNormal) {
+		value = p.method(fn.Fn, keyEnd)
+		end = value.end
+	} else {
+		shorthand = !prop.IsComputed && prop.Value.Loc == prop.Key.Loc
+		value = p.expr(*prop.Value)
+		_, end = p.outerRange(*prop.Value)
+	}
+
+	node := p.node("Property", start, end)
+	node.set("method", prop.IsMethod && prop.Kind == ast.PropertyNormal)
+	node.set("shorthand", shorthand)
+	node.set("computed", prop.IsComputed)
+	node.set("key", key)
+	node.set("value", value)
+	node.set("kind", kind)
+	return node
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Patterns
+
+func (p *estreePrinter) bindingEnd(binding ast.Binding) int32 {
+	switch binding.Data.(type) {
+	case *ast.BArray, *ast.BObject:
+		return p.matchingEnd(binding.Loc)
+	}
+	return p.endOfTokenAt(binding.Loc)
+}
+
+func (p *estreePrinter) binding(binding ast.Binding) *estreeNode {
+	switch b := binding.Data.(type) {
+	case *ast.BMissing:
+		return nil
+
+	case *ast.BIdentifier:
+		return p.bindingIdentifier(binding.Loc, b.Ref)
+
+	case *ast.BArray:
+		elements := []interface{}{}
+		for i, item := range b.Items {
+			element := p.binding(item.Binding)
+			if element == nil {
+				elements = append(elements, nil)
+				continue
+			}
+			if item.DefaultValue != nil {
+				element = p.assignmentPattern(element, *item.DefaultValue)
+			}
+			if b.HasSpread && i+1 == len(b.Items) {
+				element = p.restElement(element)
+			}
+			elements = append(elements, element)
+		}
+		node := p.node("ArrayPattern", binding.Loc.Start, p.bindingEnd(binding))
+		node.set("elements", elements)
+		return node
+
+	case *ast.BObject:
+		properties := []interface{}{}
+		for _, property := range b.Properties {
+			value := p.binding(property.Value)
+			if property.IsSpread {
+				properties = append(properties, p.restElement(value))
+				continue
+			}
+			if property.DefaultValue != nil {
+				value = p.assignmentPattern(value, *property.DefaultValue)
+			}
+			start := p.propertyStart(property.Key, property.IsComputed, binding.Loc.Start)
+			key, _ := p.propertyKey(property.Key, property.IsComputed)
+			node := p.node("Property", start, value.end)
+			node.set("method", false)
+			node.set("shorthand", !property.IsComputed && property.Value.Loc == property.Key.Loc)
+			node.set("computed", property.IsComputed)
+			node.set("key", key)
+			node.set("value", value)
+			node.set("kind", "init")
+			properties = append(properties, node)
+		}
+		node := p.node("ObjectPattern", binding.Loc.Start, p.bindingEnd(binding))
+		node.set("properties", properties)
+		return node
+
+	default:
+		panic(fmt.Sprintf("Unexpected binding of type %T", binding.Data))
+	}
+}
+
+func (p *estreePrinter) assignmentPattern(left *estreeNode, defaultValue ast.Expr) *estreeNode {
+	_, end := p.outerRange(defaultValue)
+	node := p.node("AssignmentPattern", left.start, end)
+	node.set("left", left)
+	node.set("right", p.expr(defaultValue))
+	return node
+}
+
+// The "..." token comes right before the argument
+func (p *estreePrinter) restElement(argument *estreeNode) *estreeNode {
+	node := p.node("RestElement", p.tokenStart(p.tokenAfter(argument.start)-1), argument.end)
+	node.set("argument", argument)
+	return node
+}
+
+// Assignment targets are parsed as expressions but ESTree represents them as
+// patterns
+func (p *estreePrinter) pattern(expr ast.Expr) *estreeNode {
+	switch e := expr.Data.(type) {
+	case *ast.EArray:
+		elements := []interface{}{}
+		for _, item := range e.Items {
+			switch item2 := item.Data.(type) {
+			case *ast.EMissing:
+				elements = append(elements, nil)
+			case *ast.ESpread:
+				elements = append(elements, p.restElement(p.pattern(item2.Value)))
+			default:
+				elements = append(elements, p.pattern(item))
+			}
+		}
+		node := p.node("ArrayPattern", p.exprStart(expr), p.exprEnd(expr))
+		node.set("elements", elements)
+		return node
+
+	case *ast.EObject:
+		properties := []interface{}{}
+		prevEnd := p.endOfTokenAt(expr.Loc)
+		for _, prop := range e.Properties {
+			if prop.Kind == ast.PropertySpread {
+				properties = append(properties, p.restElement(p.pattern(*prop.Value)))
+				continue
+			}
+			start := p.propertyStart(prop.Key, prop.IsComputed, prevEnd)
+			key, _ := p.propertyKey(prop.Key, prop.IsComputed)
+			value := p.pattern(*prop.Value)
+			if prop.Initializer != nil {
+				value = p.assignmentPattern(value, *prop.Initializer)
+			}
+			node := p.node("Property", start, value.end)
+			node.set("method", false)
+			node.set("shorthand", !prop.IsComputed && prop.Value.Loc == prop.Key.Loc)
+			node.set("computed", prop.IsComputed)
+			node.set("key", key)
+			node.set("value", value)
+			node.set("kind", "init")
+			properties = append(properties, node)
+			prevEnd = node.end
+		}
+		node := p.node("ObjectPattern", p.exprStart(expr), p.exprEnd(expr))
+		node.set("properties", properties)
+		return node
+
+	case *ast.EBinary:
+		if e.Op == ast.BinOpAssign {
+			return p.assignmentPattern(p.pattern(e.Left), e.Right)
+		}
+	}
+
+	return p.expr(expr)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Expressions
+
+func (p *estreePrinter) expr(expr ast.Expr) *estreeNode {
+	return p.exprInChain(expr, false)
+}
+
+func optionalChainOf(data ast.E) ast.OptionalChain {
+	switch e := data.(type) {
+	case *ast.ECall:
+		return e.OptionalChain
+	case *ast.EDot:
+		return e.OptionalChain
+	case *ast.EIndex:
+		return e.OptionalChain
+	}
+	return ast.OptionalChainNone
+}
+
+// The outermost node of an optional chain is wrapped in a "ChainExpression"
+// node in ESTree. The target of any node that is part of a chain is inside the
+// same chain, so "a?.b?.c" is a single "ChainExpression".
+func (p *estreePrinter) exprInChain(expr ast.Expr, isInsideChain bool) *estreeNode {
+	optionalChain := optionalChainOf(expr.Data)
+	if optionalChain != ast.OptionalChainNone && !isInsideChain {
+		inner := p.exprInChain(expr, true)
+		node := p.node("ChainExpression", inner.start, inner.end)
+		node.set("expression", inner)
+		return node
+	}
+	isTargetInsideChain := optionalChain != ast.OptionalChainNone
+
+	start := p.exprStart(expr)
+	end := p.exprEnd(expr)
+
+	switch e := expr.Data.(type) {
+	case *ast.EMissing:
+		return nil
+
+	case *ast.ENull:
+		node := p.node("Literal", start, end)
+		node.set("value", nil)
+		node.set("raw", "null")
+		return node
+
+	case *ast.EBoolean:
+		node := p.node("Literal", start, end)
+		node.set("value", e.Value)
+		node.set("raw", p.source.Contents[start:end])
+		return node
+
+	case *ast.ENumber:
+		node := p.node("Literal", start, end)
+		node.set("value", e.Value)
+		node.set("raw", p.source.Contents[start:end])
+		return node
+
+	case *ast.EBigInt:
+		node := p.node("Literal", start, end)
+		node.set("value", nil)
+		node.set("raw", p.source.Contents[start:end])
+		node.set("bigint", e.Value)
+		return node
+
+	case *ast.ERegExp:
+		slash := strings.LastIndexByte(e.Value, '/')
+		node := p.node("Literal", start, end)
+		node.set("value", nil)
+		node.set("raw", e.Value)
+		node.set("regex", &estreeNode{fields: []estreeField{
+			{"pattern", e.Value[1:slash]},
+			{"flags", e.Value[slash+1:]},
+		}})
+		return node
+
+	case *ast.EString:
+		raw := p.source.Contents[start:end]
+
+		// A template literal without substitutions may have been turned into a string
+		if strings.HasPrefix(raw, "`") {
+			return p.templateLiteral(p.tokenAfter(start), e.Value, nil)
+		}
+
+		node := p.node("Literal", start, end)
+		node.set("value", lexer.UTF16ToString(e.Value))
+		node.set("raw", raw)
+		return node
+
+	case *ast.ETemplate:
+		if e.Tag == nil {
+			return p.templateLiteral(p.tokenAfter(start), e.Head, e.Parts)
+		}
+		_, tagEnd := p.outerRange(*e.Tag)
+		quasi := p.templateLiteral(p.tokenAfter(tagEnd), e.Head, e.Parts)
+		node := p.node("TaggedTemplateExpression", start, quasi.end)
+		node.set("tag", p.expr(*e.Tag))
+		node.set("quasi", quasi)
+		return node
+
+	case *ast.EThis:
+		return p.node("ThisExpression", start, end)
+
+	case *ast.ESuper:
+		return p.node("Super", start, end)
+
+	case *ast.EUndefined:
+		node := p.identifier(expr.Loc, "undefined")
+		node.set("binding", nil)
+		return node
+
+	case *ast.ENewTarget, *ast.EImportMeta:
+		i := p.tokenAfter(start)
+		meta := p.identifier(ast.Loc{Start: p.tokenStart(i)}, p.tokenText(i))
+		property := p.identifier(ast.Loc{Start: p.tokenStart(i + 2)}, p.tokenText(i+2))
+		node := p.node("MetaProperty", start, end)
+		node.set("meta", meta)
+		node.set("property", property)
+		return node
+
+	case *ast.EIdentifier:
+		return p.identifierReference(expr.Loc, e.Ref)
+
+	case *ast.EImportIdentifier:
+		return p.identifierReference(expr.Loc, e.Ref)
+
+	case *ast.EPrivateIdentifier:
+		node := p.node("PrivateIdentifier", start, end)
+		node.set("name", strings.TrimPrefix(p.symbols.Get(e.Ref).Name, "#"))
+		return node
+
+	case *ast.EArray:
+		elements := []interface{}{}
+		for _, item := range e.Items {
+			if element := p.expr(item); element != nil {
+				elements = append(elements, element)
+			} else {
+				elements = append(elements, nil)
+			}
+		}
+		node := p.node("ArrayExpression", start, end)
+		node.set("elements", elements)
+		return node
+
+	case *ast.EObject:
+		properties := []interface{}{}
+		prevEnd := p.endOfTokenAt(expr.Loc)
+		for _, prop := range e.Properties {
+			property := p.objectProperty(prop, prevEnd)
+			properties = append(properties, property)
+			prevEnd = property.end
+		}
+		node := p.node("ObjectExpression", start, end)
+		node.set("properties", properties)
+		return node
+
+	case *ast.ESpread:
+		return p.spreadElement("SpreadElement", p.expr(e.Value), e.Value)
+
+	case *ast.EFunction:
+		return p.function("FunctionExpression", start, e.Fn)
+
+	case *ast.EClass:
+		return p.class("ClassExpression", start, e.Class)
+
+	case *ast.EArrow:
+		node := p.node("ArrowFunctionExpression", start, end)
+		node.set("id", nil)
+		var body *estreeNode
+		isExpression := false
+		if e.PreferExpr && len(e.Body.Stmts) == 1 {
+			if s, ok := e.Body.Stmts[0].Data.(*ast.SReturn); ok && s.Value != nil {
+				body = p.expr(*s.Value)
+				isExpression = true
+			}
+		}
+		if body == nil {
+			body = p.block(e.Body.Loc, e.Body.Stmts)
+		}
+		node.set("expression", isExpression)
+		node.set("generator", false)
+		node.set("async", e.IsAsync)
+		node.set("params", p.args(e.Args, e.HasRestArg))
+		node.set("body", body)
+		return node
+
+	case *ast.EUnary:
+		op := ast.OpTable[e.Op].Text
+		switch e.Op {
+		case ast.UnOpPreInc, ast.UnOpPreDec, ast.UnOpPostInc, ast.UnOpPostDec:
+			node := p.node("UpdateExpression", start, end)
+			node.set("operator", op)
+			node.set("prefix", e.Op.IsPrefix())
+			node.set("argument", p.pattern(e.Value))
+			return node
+		}
+		node := p.node("UnaryExpression", start, end)
+		node.set("operator", op)
+		node.set("prefix", true)
+		node.set("argument", p.expr(e.Value))
+		return node
+
+	case *ast.EBinary:
+		op := ast.OpTable[e.Op].Text
+		switch {
+		case e.Op == ast.BinOpComma:
+			node := p.node("SequenceExpression", start, end)
+			node.set("expressions", p.sequence(expr))
+			return node
+
+		case e.Op >= ast.BinOpAssign:
+			node := p.node("AssignmentExpression", start, end)
+			node.set("operator", op)
+			node.set("left", p.pattern(e.Left))
+			node.set("right", p.expr(e.Right))
+			return node
+
+		case e.Op == ast.BinOpLogicalOr || e.Op == ast.BinOpLogicalAnd || e.Op == ast.BinOpNullishCoalescing:
+			node := p.node("LogicalExpression", start, end)
+			node.set("left", p.expr(e.Left))
+			node.set("operator", op)
+			node.set("right", p.expr(e.Right))
+			return node
+
+		default:
+			node := p.node("BinaryExpression", start, end)
+			node.set("left", p.expr(e.Left))
+			node.set("operator", op)
+			node.set("right", p.expr(e.Right))
+			return node
+		}
+
+	case *ast.EIf:
+		node := p.node("ConditionalExpression", start, end)
+		node.set("test", p.expr(e.Test))
+		node.set("consequent", p.expr(e.Yes))
+		node.set("alternate", p.expr(e.No))
+		return node
+
+	case *ast.EAwait:
+		node := p.node("AwaitExpression", start, end)
+		node.set("argument", p.expr(e.Value))
+		return node
+
+	case *ast.EYield:
+		node := p.node("YieldExpression", start, end)
+		node.set("delegate", e.IsStar)
+		var argument *estreeNode
+		if e.Value != nil {
+			argument = p.expr(*e.Value)
+		}
+		node.set("argument", argument)
+		return node
+
+	case *ast.EDot:
+		node := p.node("MemberExpression", start, end)
+		node.set("object", p.exprInChain(e.Target, isTargetInsideChain))
+		node.set("property", p.identifier(e.NameLoc, e.Name))
+		node.set("computed", false)
+		node.set("optional", e.OptionalChain == ast.OptionalChainStart)
+		return node
+
+	case *ast.EIndex:
+		// "a.#b" is represented as an index expression
+		_, isPrivate := e.Index.Data.(*ast.EPrivateIdentifier)
+		node := p.node("MemberExpression", start, end)
+		node.set("object", p.exprInChain(e.Target, isTargetInsideChain))
+		node.set("property", p.expr(e.Index))
+		node.set("computed", !isPrivate)
+		node.set("optional", e.OptionalChain == ast.OptionalChainStart)
+		return node
+
+	case *ast.ECall:
+		node := p.node("CallExpression", start, end)
+		node.set("callee", p.exprInChain(e.Target, isTargetInsideChain))
+		node.set("arguments", p.exprs(e.Args))
+		node.set("optional", e.OptionalChain == ast.OptionalChainStart)
+		return node
+
+	case *ast.ENew:
+		node := p.node("NewExpression", start, end)
+		node.set("callee", p.expr(e.Target))
+		node.set("arguments", p.exprs(e.Args))
+		return node
+
+	case *ast.EImport:
+		node := p.node("ImportExpression", start, end)
+		node.set("source", p.expr(e.Expr))
+		return node
+
+	case *ast.EJSXElement:
+		return p.jsxElement(expr.Loc, e)
+
+	default:
+		panic(fmt.Sprintf("Unexpected expression of type %T", expr.Data))
+	}
+}
+
+func (p *estreePrinter) exprs(exprs []ast.Expr) []interface{} {
+	nodes := []interface{}{}
+	for _, expr := range exprs {
+		nodes = append(nodes, p.expr(expr))
+	}
+	return nodes
+}
+
+// Comma operators are flattened into a single list, except for ones that are
+// inside parentheses
+func (p *estreePrinter) sequence(expr ast.Expr) []interface{} {
+	if e, ok := expr.Data.(*ast.EBinary); ok && e.Op == ast.BinOpComma {
+		if start, _ := p.outerRange(e.Left); start == p.exprStart(e.Left) {
+			return append(p.sequence(e.Left), p.expr(e.Right))
+		}
+		return []interface{}{p.expr(e.Left), p.expr(e.Right)}
+	}
+	return []interface{}{p.expr(expr)}
+}
+
+// The "..." token comes right before the argument
+func (p *estreePrinter) spreadElement(kind string, argument *estreeNode, value ast.Expr) *estreeNode {
+	start, end := p.outerRange(value)
+	node := p.node(kind, p.tokenStart(p.tokenAfter(start)-1), end)
+	node.set("argument", argument)
+	return node
+}
+
+// Template literals are made of a token for each part of the template text.
+// The text of each part doesn't include the "`", "${", or "}" around it.
+func (p *estreePrinter) templateLiteral(i int, head []uint16, parts []ast.TemplatePart) *estreeNode {
+	start := p.tokenStart(i)
+	quasis := []interface{}{p.templateElement(i, head, len(parts) == 0)}
+	expressions := []interface{}{}
+	for j, part := range parts {
+		expressions = append(expressions, p.expr(part.Value))
+		_, end := p.outerRange(part.Value)
+		i = p.tokenAfter(end)
+		quasis = append(quasis, p.templateElement(i, part.Tail, j+1 == len(parts)))
+	}
+	node := p.node("TemplateLiteral", start, p.tokenEnd(i))
+	node.set("expressions", expressions)
+	node.set("quasis", quasis)
+	return node
+}
+
+func (p *estreePrinter) templateElement(i int, cooked []uint16, isTail bool) *estreeNode {
+	start := p.tokenStart(i) + 1
+	end := p.tokenEnd(i) - 1
+	if !isTail {
+		end--
+	}
+	if end < start {
+		end = start
+	}
+	// Line terminators in the raw text are normalized to "\n"
+	raw := strings.ReplaceAll(p.source.Contents[start:end], "\r\n", "\n")
+	raw = strings.ReplaceAll(raw, "\r", "\n")
+	node := p.node("TemplateElement", start, end)
+	node.set("value", &estreeNode{fields: []estreeField{
+		{"raw", raw},
+		{"cooked", lexer.UTF16ToString(cooked)},
+	}})
+	node.set("tail", isTail)
+	return node
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// JSX
+
+// JSX children are found using the tokens between the opening and closing
+// elements. This includes whitespace between tokens, which isn't in the AST
+// because it's not significant for code generation.
+func (p *estreePrinter) jsxElement(loc ast.Loc, e *ast.EJSXElement) *estreeNode {
+	i := p.tokenAfter(loc.Start)
+	var opening *estreeNode
+	var rootRef *ast.Ref
+	isSelfClosing := false
+
+	if e.Tag == nil {
+		// "<>"
+		opening = p.node("JSXOpeningFragment", loc.Start, p.tokenEnd(i+1))
+		i += 2
+	} else {
+		// Intrinsic elements such as "<div>" don't reference anything
+		tag := *e.Tag
+		if _, ok := tag.Data.(*ast.EString); !ok {
+			for {
+				if dot, ok := tag.Data.(*ast.EDot); ok {
+					tag = dot.Target
+					continue
+				}
+				break
+			}
+			switch t := tag.Data.(type) {
+			case *ast.EIdentifier:
+				rootRef = &t.Ref
+			case *ast.EImportIdentifier:
+				rootRef = &t.Ref
+			}
+		}
+
+		name, last := p.jsxName(i+1, rootRef)
+		attributes := []interface{}{}
+		prevEnd := name.end
+		for _, prop := range e.Properties {
+			attribute := p.jsxAttribute(prop)
+			attributes = append(attributes, attribute)
+			prevEnd = attribute.end
+		}
+		if len(e.Properties) == 0 {
+			prevEnd = p.tokenEnd(last)
+		}
+
+		// "/>" or ">"
+		i = p.tokenAfter(prevEnd)
+		if p.tokenText(i) == "/" {
+			isSelfClosing = true
+			i++
+		}
+		opening = p.node("JSXOpeningElement", loc.Start, p.tokenEnd(i))
+		opening.set("attributes", attributes)
+		opening.set("name", name)
+		opening.set("selfClosing", isSelfClosing)
+		i++
+	}
+
+	if isSelfClosing {
+		node := p.node("JSXElement", loc.Start, opening.end)
+		node.set("openingElement", opening)
+		node.set("closingElement", nil)
+		node.set("children", []interface{}{})
+		return node
+	}
+
+	children := []interface{}{}
+	pos := opening.end
+	next := 0
+	for i < len(p.tokens) {
+		tokenStart := p.tokens[i].Loc.Start
+		if tokenStart > pos {
+			children = append(children, p.jsxText(pos, tokenStart))
+		}
+		text := p.tokenText(i)
+
+		if text == "<" && p.tokenText(i+1) == "/" {
+			// "</name>" or "</>"
+			var closing *estreeNode
+			if e.Tag == nil {
+				closing = p.node("JSXClosingFragment", tokenStart, p.tokenEnd(i+2))
+			} else {
+				name, last := p.jsxName(i+2, rootRef)
+				closing = p.node("JSXClosingElement", tokenStart, p.tokenEnd(last+1))
+				closing.set("name", name)
+			}
+
+			kind := "JSXElement"
+			openingKey, closingKey := "openingElement", "closingElement"
+			if e.Tag == nil {
+				kind = "JSXFragment"
+				openingKey, closingKey = "openingFragment", "closingFragment"
+			}
+			node := p.node(kind, loc.Start, closing.end)
+			node.set(openingKey, opening)
+			node.set(closingKey, closing)
+			node.set("children", children)
+			return node
+		}
+
+		switch {
+		case text == "<" && next < len(e.Children):
+			child := e.Children[next]
+			next++
+			node := p.expr(child)
+			children = append(children, node)
+			pos = node.end
+			i = p.tokenAfter(pos)
+
+		case text == "{" && p.matches[i] != -1:
+			// The expression inside the braces is optional
+			close := p.matches[i]
+			var expression *estreeNode
+			if next < len(e.Children) && e.Children[next].Loc.Start < p.tokens[close].Loc.Start {
+				expression = p.expr(e.Children[next])
+				next++
+			} else {
+				expression = p.node("JSXEmptyExpression", p.tokenEnd(i), p.tokenStart(close))
+			}
+			node := p.node("JSXExpressionContainer", tokenStart, p.tokenEnd(close))
+			node.set("expression", expression)
+			children = append(children, node)
+			pos = node.end
+			i = close + 1
+
+		default:
+			if next < len(e.Children) && e.Children[next].Loc.Start == tokenStart {
+				next++
+			}
+			children = append(children, p.jsxText(tokenStart, p.tokenEnd(i)))
+			pos = p.tokenEnd(i)
+			i++
+		}
+	}
+
+	// The closing element is missing, which only happens for invalid code
+	node := p.node("JSXElement", loc.Start, pos)
+	node.set("openingElement", opening)
+	node.set("closingElement", nil)
+	node.set("children", children)
+	return node
+}
+
+// This returns the name starting at token "i" and the index of its last token
+func (p *estreePrinter) jsxName(i int, rootRef *ast.Ref) (*estreeNode, int) {
+	node := p.node("JSXIdentifier", p.tokenStart(i), p.tokenEnd(i))
+	node.set("name", p.tokenText(i))
+	if rootRef != nil {
+		node.set("binding", nil)
+		p.references = append(p.references, estreeReference{node, *rootRef})
+	}
+
+	for p.tokenText(i+1) == "." {
+		property := p.node("JSXIdentifier", p.tokenStart(i+2), p.tokenEnd(i+2))
+		property.set("name", p.tokenText(i+2))
+		object := node
+		node = p.node("JSXMemberExpression", object.start, property.end)
+		node.set("object", object)
+		node.set("property", property)
+		i += 2
+	}
+
+	return node, i
+}
+
+func (p *estreePrinter) jsxAttribute(prop ast.Property) *estreeNode {
+	if prop.Kind == ast.PropertySpread {
+		// "{...value}"
+		start, end := p.outerRange(*prop.Value)
+		node := p.node("JSXSpreadAttribute", p.tokenStart(p.tokenAfter(start)-2), p.tokenEnd(p.tokenAfter(end)))
+		node.set("argument", p.expr(*prop.Value))
+		return node
+	}
+
+	i := p.tokenAfter(prop.Key.Loc.Start)
+	name := p.node("JSXIdentifier", p.tokenStart(i), p.tokenEnd(i))
+	name.set("name", p.tokenText(i))
+
+	// The value is optional
+	var value *estreeNode
+	if p.tokenText(i+1) == "=" {
+		if i += 2; p.tokenText(i) == "{" && p.matches[i] != -1 {
+			value = p.node("JSXExpressionContainer", p.tokenStart(i), p.tokenEnd(p.matches[i]))
+			value.set("expression", p.expr(*prop.Value))
+		} else {
+			value = p.node("Literal", p.tokenStart(i), p.tokenEnd(i))
+			if str, ok := prop.Value.Data.(*ast.EString); ok {
+				value.set("value", lexer.UTF16ToString(str.Value))
+			}
+			value.set("raw", p.tokenText(i))
+		}
+	}
+
+	end := name.end
+	if value != nil {
+		end = value.end
+	}
+	node := p.node("JSXAttribute", name.start, end)
+	node.set("name", name)
+	node.set("value", value)
+	return node
+}
+
+func (p *estreePrinter) jsxText(start int32, end int32) *estreeNode {
+	raw := p.source.Contents[start:end]
+	node := p.node("JSXText", start, end)
+	node.set("value", lexer.UTF16ToString(lexer.DecodeJSXEntities([]uint16{}, raw)))
+	node.set("raw", raw)
+	return node
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Comments
+
+func (p *estreePrinter) comments(comments []ast.Range) []interface{} {
+	nodes := []interface{}{}
+	for _, r := range comments {
+		text := p.source.Contents[r.Loc.Start:r.End()]
+		var node *estreeNode
+		if strings.HasPrefix(text, "//") {
+			node = p.node("Line", r.Loc.Start, r.End())
+			node.set("value", text[2:])
+		} else {
+			node = p.node("Block", r.Loc.Start, r.End())
+			node.set("value", strings.TrimSuffix(text[2:], "*/"))
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
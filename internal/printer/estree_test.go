@@ -0,0 +1,470 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/logging"
+	"github.com/evanw/esbuild/internal/parser"
+	"github.com/evanw/esbuild/internal/test"
+)
+
+// The JSON is summarized as one line per node with the source text of that
+// node, which is much easier to read than the JSON itself
+func summarizeESTree(contents string, value interface{}, key string, indent string, sb *strings.Builder) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			summarizeESTree(contents, item, key, indent, sb)
+		}
+
+	case map[string]interface{}:
+		next := indent
+		if kind, ok := v["type"].(string); ok {
+			text := utf16.Encode([]rune(contents))
+			start, end := int(v["start"].(float64)), int(v["end"].(float64))
+			sb.WriteString(fmt.Sprintf("%s%s: %s %q", indent, key, kind, string(utf16.Decode(text[start:end]))))
+			if binding, ok := v["binding"].([]interface{}); ok {
+				sb.WriteString(fmt.Sprintf(" -> %q", string(utf16.Decode(text[int(binding[0].(float64)):int(binding[1].(float64))]))))
+			}
+			sb.WriteByte('\n')
+			next += "  "
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if k != "loc" && k != "range" {
+				summarizeESTree(contents, v[k], k, next, sb)
+			}
+		}
+	}
+}
+
+func expectESTreeCommon(t *testing.T, contents string, expected string, options config.Options) {
+	t.Run(contents, func(t *testing.T) {
+		log := logging.NewDeferLog()
+		options.PreserveSyntax = true
+		tree, ok := parser.Parse(log, test.SourceForTest(contents), options)
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logging.StderrOptions{}, logging.TerminalInfo{})
+		}
+		assertEqual(t, text, "")
+		if !ok {
+			t.Fatal("Parse error")
+		}
+		var value interface{}
+		if err := json.Unmarshal(PrintESTree(tree, test.SourceForTest(contents)), &value); err != nil {
+			t.Fatal(err)
+		}
+		sb := strings.Builder{}
+		summarizeESTree(contents, value, "program", "", &sb)
+		assertEqual(t, sb.String(), expected)
+	})
+}
+
+func expectESTree(t *testing.T, contents string, expected string) {
+	expectESTreeCommon(t, contents, expected, config.Options{})
+}
+
+func expectESTreeTS(t *testing.T, contents string, expected string) {
+	expectESTreeCommon(t, contents, expected, config.Options{
+		TS: config.TSOptions{Parse: true},
+	})
+}
+
+func expectESTreeJSX(t *testing.T, contents string, expected string) {
+	expectESTreeCommon(t, contents, expected, config.Options{
+		JSX: config.JSXOptions{Parse: true},
+	})
+}
+
+func expectESTreeJSON(t *testing.T, contents string, expected string) {
+	t.Run(contents, func(t *testing.T) {
+		log := logging.NewDeferLog()
+		tree, ok := parser.Parse(log, test.SourceForTest(contents), config.Options{PreserveSyntax: true})
+		if !ok {
+			t.Fatal("Parse error")
+		}
+		assertEqual(t, string(PrintESTree(tree, test.SourceForTest(contents))), expected)
+	})
+}
+
+func TestESTreeJSON(t *testing.T) {
+	expectESTreeJSON(t, "", `{"type":"Program","start":0,"end":0,"range":[0,0],`+
+		`"loc":{"start":{"line":1,"column":0},"end":{"line":1,"column":0}},"body":[],"sourceType":"script","comments":[]}`)
+	expectESTreeJSON(t, "x\n// y", `{"type":"Program","start":0,"end":6,"range":[0,6],`+
+		`"loc":{"start":{"line":1,"column":0},"end":{"line":2,"column":4}},"body":[`+
+		`{"type":"ExpressionStatement","start":0,"end":1,"range":[0,1],`+
+		`"loc":{"start":{"line":1,"column":0},"end":{"line":1,"column":1}},"expression":`+
+		`{"type":"Identifier","start":0,"end":1,"range":[0,1],`+
+		`"loc":{"start":{"line":1,"column":0},"end":{"line":1,"column":1}},"name":"x","binding":null}}],`+
+		`"sourceType":"script","comments":[{"type":"Line","start":2,"end":6,"range":[2,6],`+
+		`"loc":{"start":{"line":2,"column":0},"end":{"line":2,"column":4}},"value":" y"}]}`)
+
+	// Offsets and columns are in UTF-16 code units
+	expectESTreeJSON(t, "'😀'\r\nx", `{"type":"Program","start":0,"end":7,"range":[0,7],`+
+		`"loc":{"start":{"line":1,"column":0},"end":{"line":2,"column":1}},"body":[`+
+		`{"type":"ExpressionStatement","start":0,"end":4,"range":[0,4],`+
+		`"loc":{"start":{"line":1,"column":0},"end":{"line":1,"column":4}},"expression":`+
+		`{"type":"Literal","start":0,"end":4,"range":[0,4],`+
+		`"loc":{"start":{"line":1,"column":0},"end":{"line":1,"column":4}},"value":"\uD83D\uDE00","raw":"'\uD83D\uDE00'"}},`+
+		`{"type":"ExpressionStatement","start":6,"end":7,"range":[6,7],`+
+		`"loc":{"start":{"line":2,"column":0},"end":{"line":2,"column":1}},"expression":`+
+		`{"type":"Identifier","start":6,"end":7,"range":[6,7],`+
+		`"loc":{"start":{"line":2,"column":0},"end":{"line":2,"column":1}},"name":"x","binding":null}}],`+
+		`"sourceType":"script","comments":[]}`)
+}
+
+func TestESTreeStatements(t *testing.T) {
+	expectESTree(t, "'use strict'; debugger; ;",
+		`program: Program "'use strict'; debugger; ;"
+  body: ExpressionStatement "'use strict';"
+    expression: Literal "'use strict'"
+  body: DebuggerStatement "debugger;"
+  body: EmptyStatement ";"
+`)
+	expectESTree(t, "let [a, , ...b] = c, { d = 1, e: [f] } = g",
+		`program: Program "let [a, , ...b] = c, { d = 1, e: [f] } = g"
+  body: VariableDeclaration "let [a, , ...b] = c, { d = 1, e: [f] } = g"
+    declarations: VariableDeclarator "[a, , ...b] = c"
+      id: ArrayPattern "[a, , ...b]"
+        elements: Identifier "a"
+        elements: RestElement "...b"
+          argument: Identifier "b"
+      init: Identifier "c"
+    declarations: VariableDeclarator "{ d = 1, e: [f] } = g"
+      id: ObjectPattern "{ d = 1, e: [f] }"
+        properties: Property "d = 1"
+          key: Identifier "d"
+          value: AssignmentPattern "d = 1"
+            left: Identifier "d"
+            right: Literal "1"
+        properties: Property "e: [f]"
+          key: Identifier "e"
+          value: ArrayPattern "[f]"
+            elements: Identifier "f"
+      init: Identifier "g"
+`)
+	expectESTree(t, "try { a } catch (e) { e } finally {}",
+		`program: Program "try { a } catch (e) { e } finally {}"
+  body: TryStatement "try { a } catch (e) { e } finally {}"
+    block: BlockStatement "{ a }"
+      body: ExpressionStatement "a"
+        expression: Identifier "a"
+    finalizer: BlockStatement "{}"
+    handler: CatchClause "catch (e) { e }"
+      body: BlockStatement "{ e }"
+        body: ExpressionStatement "e"
+          expression: Identifier "e" -> "e"
+      param: Identifier "e"
+`)
+	expectESTree(t, "switch (a) { case 1: b; default: }",
+		`program: Program "switch (a) { case 1: b; default: }"
+  body: SwitchStatement "switch (a) { case 1: b; default: }"
+    cases: SwitchCase "case 1: b;"
+      consequent: ExpressionStatement "b;"
+        expression: Identifier "b"
+      test: Literal "1"
+    cases: SwitchCase "default:"
+    discriminant: Identifier "a"
+`)
+	expectESTree(t, "x: for (var i in a) { continue x }",
+		`program: Program "x: for (var i in a) { continue x }"
+  body: LabeledStatement "x: for (var i in a) { continue x }"
+    body: ForInStatement "for (var i in a) { continue x }"
+      body: BlockStatement "{ continue x }"
+        body: ContinueStatement "continue x"
+          label: Identifier "x" -> "x"
+      left: VariableDeclaration "var i"
+        declarations: VariableDeclarator "i"
+          id: Identifier "i"
+      right: Identifier "a"
+    label: Identifier "x"
+`)
+}
+
+func TestESTreeModules(t *testing.T) {
+	expectESTree(t, "import a, { b as c } from 'x'; export { c as d }",
+		`program: Program "import a, { b as c } from 'x'; export { c as d }"
+  body: ImportDeclaration "import a, { b as c } from 'x';"
+    source: Literal "'x'"
+    specifiers: ImportDefaultSpecifier "a"
+      local: Identifier "a"
+    specifiers: ImportSpecifier "b as c"
+      imported: Identifier "b"
+      local: Identifier "c"
+  body: ExportNamedDeclaration "export { c as d }"
+    specifiers: ExportSpecifier "c as d"
+      exported: Identifier "d"
+      local: Identifier "c" -> "c"
+`)
+	expectESTree(t, "import * as ns from 'x'; export * as y from 'y'",
+		`program: Program "import * as ns from 'x'; export * as y from 'y'"
+  body: ImportDeclaration "import * as ns from 'x';"
+    source: Literal "'x'"
+    specifiers: ImportNamespaceSpecifier "* as ns"
+      local: Identifier "ns"
+  body: ExportAllDeclaration "export * as y from 'y'"
+    exported: Identifier "y"
+    source: Literal "'y'"
+`)
+	expectESTree(t, "export const a = 1; export default function () {}",
+		`program: Program "export const a = 1; export default function () {}"
+  body: ExportNamedDeclaration "export const a = 1;"
+    declaration: VariableDeclaration "const a = 1;"
+      declarations: VariableDeclarator "a = 1"
+        id: Identifier "a"
+        init: Literal "1"
+  body: ExportDefaultDeclaration "export default function () {}"
+    declaration: FunctionDeclaration "function () {}"
+      body: BlockStatement "{}"
+`)
+}
+
+func TestESTreeExpressions(t *testing.T) {
+	// Parentheses are part of the parent node but not the child node
+	expectESTree(t, "(a + b) * (c, d)",
+		`program: Program "(a + b) * (c, d)"
+  body: ExpressionStatement "(a + b) * (c, d)"
+    expression: BinaryExpression "(a + b) * (c, d)"
+      left: BinaryExpression "a + b"
+        left: Identifier "a"
+        right: Identifier "b"
+      right: SequenceExpression "c, d"
+        expressions: Identifier "c"
+        expressions: Identifier "d"
+`)
+	expectESTree(t, "a?.b.c(); a?.(b)?.[c]",
+		`program: Program "a?.b.c(); a?.(b)?.[c]"
+  body: ExpressionStatement "a?.b.c();"
+    expression: ChainExpression "a?.b.c()"
+      expression: CallExpression "a?.b.c()"
+        callee: MemberExpression "a?.b.c"
+          object: MemberExpression "a?.b"
+            object: Identifier "a"
+            property: Identifier "b"
+          property: Identifier "c"
+  body: ExpressionStatement "a?.(b)?.[c]"
+    expression: ChainExpression "a?.(b)?.[c]"
+      expression: MemberExpression "a?.(b)?.[c]"
+        object: CallExpression "a?.(b)"
+          arguments: Identifier "b"
+          callee: Identifier "a"
+        property: Identifier "c"
+`)
+	expectESTree(t, "a?.b?.c; (a?.b).c",
+		`program: Program "a?.b?.c; (a?.b).c"
+  body: ExpressionStatement "a?.b?.c;"
+    expression: ChainExpression "a?.b?.c"
+      expression: MemberExpression "a?.b?.c"
+        object: MemberExpression "a?.b"
+          object: Identifier "a"
+          property: Identifier "b"
+        property: Identifier "c"
+  body: ExpressionStatement "(a?.b).c"
+    expression: MemberExpression "(a?.b).c"
+      object: ChainExpression "a?.b"
+        expression: MemberExpression "a?.b"
+          object: Identifier "a"
+          property: Identifier "b"
+      property: Identifier "c"
+`)
+	expectESTree(t, "x = `a${b}c`, tag`d`, /e/g, 1n, new.target",
+		`program: Program "x = `+"`a${b}c`, tag`d`"+`, /e/g, 1n, new.target"
+  body: ExpressionStatement "x = `+"`a${b}c`, tag`d`"+`, /e/g, 1n, new.target"
+    expression: SequenceExpression "x = `+"`a${b}c`, tag`d`"+`, /e/g, 1n, new.target"
+      expressions: AssignmentExpression "x = `+"`a${b}c`"+`"
+        left: Identifier "x"
+        right: TemplateLiteral "`+"`a${b}c`"+`"
+          expressions: Identifier "b"
+          quasis: TemplateElement "a"
+          quasis: TemplateElement "c"
+      expressions: TaggedTemplateExpression "tag`+"`d`"+`"
+        quasi: TemplateLiteral "`+"`d`"+`"
+          quasis: TemplateElement "d"
+        tag: Identifier "tag"
+      expressions: Literal "/e/g"
+      expressions: Literal "1n"
+      expressions: MetaProperty "new.target"
+        meta: Identifier "new"
+        property: Identifier "target"
+`)
+	expectESTree(t, "({ a, [b]: c, get d() {}, ...e })",
+		`program: Program "({ a, [b]: c, get d() {}, ...e })"
+  body: ExpressionStatement "({ a, [b]: c, get d() {}, ...e })"
+    expression: ObjectExpression "{ a, [b]: c, get d() {}, ...e }"
+      properties: Property "a"
+        key: Identifier "a"
+        value: Identifier "a"
+      properties: Property "[b]: c"
+        key: Identifier "b"
+        value: Identifier "c"
+      properties: Property "get d() {}"
+        key: Identifier "d"
+        value: FunctionExpression "() {}"
+          body: BlockStatement "{}"
+      properties: SpreadElement "...e"
+        argument: Identifier "e"
+`)
+	expectESTree(t, "async (a, ...b) => a",
+		`program: Program "async (a, ...b) => a"
+  body: ExpressionStatement "async (a, ...b) => a"
+    expression: ArrowFunctionExpression "async (a, ...b) => a"
+      body: Identifier "a" -> "a"
+      params: Identifier "a"
+      params: RestElement "...b"
+        argument: Identifier "b"
+`)
+}
+
+func TestESTreeClass(t *testing.T) {
+	expectESTree(t, "class A extends B { static #x = 1; constructor() { super() } get y() { return this.#x } }",
+		`program: Program "class A extends B { static #x = 1; constructor() { super() } get y() { return this.#x } }"
+  body: ClassDeclaration "class A extends B { static #x = 1; constructor() { super() } get y() { return this.#x } }"
+    body: ClassBody "{ static #x = 1; constructor() { super() } get y() { return this.#x } }"
+      body: PropertyDefinition "static #x = 1;"
+        key: PrivateIdentifier "#x"
+        value: Literal "1"
+      body: MethodDefinition "constructor() { super() }"
+        key: Identifier "constructor"
+        value: FunctionExpression "() { super() }"
+          body: BlockStatement "{ super() }"
+            body: ExpressionStatement "super()"
+              expression: CallExpression "super()"
+                callee: Super "super"
+      body: MethodDefinition "get y() { return this.#x }"
+        key: Identifier "y"
+        value: FunctionExpression "() { return this.#x }"
+          body: BlockStatement "{ return this.#x }"
+            body: ReturnStatement "return this.#x"
+              argument: MemberExpression "this.#x"
+                object: ThisExpression "this"
+                property: PrivateIdentifier "#x"
+    id: Identifier "A"
+    superClass: Identifier "B"
+`)
+	expectESTree(t, "x = class C { [C] }",
+		`program: Program "x = class C { [C] }"
+  body: ExpressionStatement "x = class C { [C] }"
+    expression: AssignmentExpression "x = class C { [C] }"
+      left: Identifier "x"
+      right: ClassExpression "class C { [C] }"
+        body: ClassBody "{ [C] }"
+          body: PropertyDefinition "[C]"
+            key: Identifier "C" -> "C"
+        id: Identifier "C"
+`)
+}
+
+func TestESTreeBindings(t *testing.T) {
+	// References are resolved to the identifier that declares the symbol,
+	// even when the declaration comes later
+	expectESTree(t, "f(x); function f(x) { return x } var x",
+		`program: Program "f(x); function f(x) { return x } var x"
+  body: ExpressionStatement "f(x);"
+    expression: CallExpression "f(x)"
+      arguments: Identifier "x" -> "x"
+      callee: Identifier "f" -> "f"
+  body: FunctionDeclaration "function f(x) { return x }"
+    body: BlockStatement "{ return x }"
+      body: ReturnStatement "return x"
+        argument: Identifier "x" -> "x"
+    id: Identifier "f"
+    params: Identifier "x"
+  body: VariableDeclaration "var x"
+    declarations: VariableDeclarator "x"
+      id: Identifier "x"
+`)
+}
+
+func TestESTreeComments(t *testing.T) {
+	expectESTree(t, "/* a */ x // b",
+		`program: Program "/* a */ x // b"
+  body: ExpressionStatement "x"
+    expression: Identifier "x"
+  comments: Block "/* a */"
+  comments: Line "// b"
+`)
+}
+
+func TestESTreeTypeScript(t *testing.T) {
+	expectESTreeTS(t, "enum E { A = 1, B = A } namespace N.M { export let x: E = E.B }",
+		`program: Program "enum E { A = 1, B = A } namespace N.M { export let x: E = E.B }"
+  body: TSEnumDeclaration "enum E { A = 1, B = A }"
+    id: Identifier "E"
+    members: TSEnumMember "A = 1"
+      id: Identifier "A"
+      initializer: Literal "1"
+    members: TSEnumMember "B = A"
+      id: Identifier "B"
+      initializer: Identifier "A" -> "A"
+  body: TSModuleDeclaration "namespace N.M { export let x: E = E.B }"
+    body: TSModuleDeclaration "M { export let x: E = E.B }"
+      body: TSModuleBlock "{ export let x: E = E.B }"
+        body: ExportNamedDeclaration "export let x: E = E.B"
+          declaration: VariableDeclaration "let x: E = E.B"
+            declarations: VariableDeclarator "x: E = E.B"
+              id: Identifier "x"
+              init: MemberExpression "E.B"
+                object: Identifier "E" -> "E"
+                property: Identifier "B"
+      id: Identifier "M"
+    id: Identifier "N"
+`)
+	expectESTreeTS(t, "class A { x?: number; y = 1 } export = A",
+		`program: Program "class A { x?: number; y = 1 } export = A"
+  body: ClassDeclaration "class A { x?: number; y = 1 }"
+    body: ClassBody "{ x?: number; y = 1 }"
+      body: PropertyDefinition "x?: number;"
+        key: Identifier "x"
+      body: PropertyDefinition "y = 1"
+        key: Identifier "y"
+        value: Literal "1"
+    id: Identifier "A"
+  body: TSExportAssignment "export = A"
+    expression: Identifier "A" -> "A"
+`)
+}
+
+func TestESTreeJSX(t *testing.T) {
+	expectESTreeJSX(t, "<a.b c='d' {...e} f>g &amp; {h}{/* i */}<></></a.b>",
+		`program: Program "<a.b c='d' {...e} f>g &amp; {h}{/* i */}<></></a.b>"
+  body: ExpressionStatement "<a.b c='d' {...e} f>g &amp; {h}{/* i */}<></></a.b>"
+    expression: JSXElement "<a.b c='d' {...e} f>g &amp; {h}{/* i */}<></></a.b>"
+      children: JSXText "g &amp; "
+      children: JSXExpressionContainer "{h}"
+        expression: Identifier "h"
+      children: JSXExpressionContainer "{/* i */}"
+        expression: JSXEmptyExpression "/* i */"
+      children: JSXFragment "<></>"
+        closingFragment: JSXClosingFragment "</>"
+        openingFragment: JSXOpeningFragment "<>"
+      closingElement: JSXClosingElement "</a.b>"
+        name: JSXMemberExpression "a.b"
+          object: JSXIdentifier "a"
+          property: JSXIdentifier "b"
+      openingElement: JSXOpeningElement "<a.b c='d' {...e} f>"
+        attributes: JSXAttribute "c='d'"
+          name: JSXIdentifier "c"
+          value: Literal "'d'"
+        attributes: JSXSpreadAttribute "{...e}"
+          argument: Identifier "e"
+        attributes: JSXAttribute "f"
+          name: JSXIdentifier "f"
+        name: JSXMemberExpression "a.b"
+          object: JSXIdentifier "a"
+          property: JSXIdentifier "b"
+  comments: Block "/* i */"
+`)
+}
@@ -281,7 +281,12 @@ func quoteImpl(text string, forJSON bool) string {
 			i++
 
 		case '\v':
-			b.WriteString("\\v")
+			// JSON doesn't have the "\v" escape sequence
+			if forJSON {
+				b.WriteString("\\u000B")
+			} else {
+				b.WriteString("\\v")
+			}
 			i++
 
 		case '\\':
@@ -460,6 +465,9 @@ type printer struct {
 	prevRegExpEnd      int
 	intToBytesBuffer   [64]byte
 
+	// This is true while printing the target of an assignment
+	isAssignTarget bool
+
 	// For source maps
 	sourceMap     []byte
 	prevLoc       ast.Loc
@@ -622,11 +630,28 @@ func (p *printer) symbolName(ref ast.Ref) string {
 
 func (p *printer) printSymbol(ref ast.Ref) {
 	ref = ast.FollowSymbols(p.symbols, ref)
+	if alias, ok := p.crossChunkImport(ref); ok {
+		p.printSymbol(alias.NamespaceRef)
+		p.print(".")
+		p.print(alias.Alias)
+		return
+	}
 	symbol := p.symbols.Get(ref)
 	p.printSpaceBeforeIdentifier()
 	p.print(symbol.Name)
 }
 
+// Symbols imported from another chunk may have to be accessed as a property
+// of that chunk's exports. ES6 imports are already live bindings, so that's
+// only necessary in ES6 output when the symbol is being assigned to.
+func (p *printer) crossChunkImport(ref ast.Ref) (ast.NamespaceAlias, bool) {
+	alias, ok := p.options.CrossChunkImports[ref]
+	if ok && p.options.OutputFormat == config.FormatESModule && !p.isAssignTarget {
+		return ast.NamespaceAlias{}, false
+	}
+	return alias, ok
+}
+
 func (p *printer) printBinding(binding ast.Binding) {
 	p.addSourceMapping(binding.Loc)
 
@@ -718,6 +743,7 @@ func (p *printer) printBinding(binding ast.Binding) {
 						continue
 					}
 
+					property.Key = p.mangledPropertyKey(property.Key)
 					if str, ok := property.Key.Data.(*ast.EString); ok {
 						if lexer.IsIdentifierUTF16(str.Value) {
 							p.addSourceMapping(property.Key.Loc)
@@ -896,6 +922,21 @@ func (p *printer) printClass(class ast.Class) {
 	p.print("}")
 }
 
+// Substitutes the renamed version of a property name if the property name is
+// being mangled. Returns the original key otherwise. Only names written in the
+// source code are mangled, not names generated by esbuild such as the keys of
+// the object passed to "__export()".
+func (p *printer) mangledPropertyKey(key ast.Expr) ast.Expr {
+	if p.options.MangledProps != nil {
+		if str, ok := key.Data.(*ast.EString); ok && str.MangleProp {
+			if mangled, ok := p.options.MangledProps[lexer.UTF16ToString(str.Value)]; ok {
+				return ast.Expr{Loc: key.Loc, Data: &ast.EString{Value: lexer.StringToUTF16(mangled)}}
+			}
+		}
+	}
+	return key
+}
+
 func (p *printer) printProperty(item ast.Property) {
 	if item.Kind == ast.PropertySpread {
 		p.print("...")
@@ -958,6 +999,7 @@ func (p *printer) printProperty(item ast.Property) {
 		return
 	}
 
+	item.Key = p.mangledPropertyKey(item.Key)
 	switch key := item.Key.Data.(type) {
 	case *ast.EPrivateIdentifier:
 		p.printSymbol(key.Ref)
@@ -972,7 +1014,8 @@ func (p *printer) printProperty(item ast.Property) {
 			if !p.options.UnsupportedFeatures.Has(compat.ObjectExtensions) && item.Value != nil {
 				switch e := item.Value.Data.(type) {
 				case *ast.EIdentifier:
-					if lexer.UTF16EqualsString(key.Value, p.symbolName(e.Ref)) {
+					_, isCrossChunkImport := p.crossChunkImport(ast.FollowSymbols(p.symbols, e.Ref))
+					if !isCrossChunkImport && lexer.UTF16EqualsString(key.Value, p.symbolName(e.Ref)) {
 						if item.Initializer != nil {
 							p.printSpace()
 							p.print("=")
@@ -986,7 +1029,8 @@ func (p *printer) printProperty(item ast.Property) {
 					// Make sure we're not using a property access instead of an identifier
 					ref := ast.FollowSymbols(p.symbols, e.Ref)
 					symbol := p.symbols.Get(ref)
-					if symbol.NamespaceAlias == nil && lexer.UTF16EqualsString(key.Value, symbol.Name) {
+					_, isCrossChunkImport := p.crossChunkImport(ref)
+					if symbol.NamespaceAlias == nil && !isCrossChunkImport && lexer.UTF16EqualsString(key.Value, symbol.Name) {
 						if item.Initializer != nil {
 							p.printSpace()
 							p.print("=")
@@ -1094,9 +1138,24 @@ func (p *printer) printRequireOrImportExpr(importRecordIndex uint32) {
 
 	// Preserve "import()" expressions that don't point inside the bundle
 	if record.SourceIndex == nil && record.Kind == ast.ImportDynamic && p.options.OutputFormat.KeepES6ImportExportSyntax() {
+		// "__preload(() => import('./chunk.js'), ['./dep.js'], import.meta.url)"
+		if len(record.PreloadPaths) > 0 {
+			p.printSymbol(p.options.PreloadRef)
+			p.print("(()" + space + "=>" + space)
+		}
 		p.print("import(")
 		p.print(Quote(record.Path.Text))
 		p.print(")")
+		if len(record.PreloadPaths) > 0 {
+			p.print("," + space + "[")
+			for i, path := range record.PreloadPaths {
+				if i > 0 {
+					p.print("," + space)
+				}
+				p.print(Quote(path))
+			}
+			p.print("]," + space + "import.meta.url)")
+		}
 		return
 	}
 
@@ -1317,7 +1376,11 @@ func (p *printer) printExpr(expr ast.Expr, level ast.L, flags int) {
 		}
 		p.print(".")
 		p.addSourceMapping(e.NameLoc)
-		p.print(e.Name)
+		if mangled, ok := p.options.MangledProps[e.Name]; ok && e.MangleProp {
+			p.print(mangled)
+		} else {
+			p.print(e.Name)
+		}
 		if wrap {
 			p.print(")")
 		}
@@ -1344,7 +1407,7 @@ func (p *printer) printExpr(expr ast.Expr, level ast.L, flags int) {
 			p.printSymbol(private.Ref)
 		} else {
 			p.print("[")
-			p.printExpr(e.Index, ast.LLowest, 0)
+			p.printExpr(p.mangledPropertyKey(e.Index), ast.LLowest, 0)
 			p.print("]")
 		}
 		if wrap {
@@ -1548,6 +1611,10 @@ func (p *printer) printExpr(expr ast.Expr, level ast.L, flags int) {
 		}
 
 	case *ast.EString:
+		// Lowered class fields pass the property name as a string argument
+		if e.MangleProp {
+			e = p.mangledPropertyKey(expr).Data.(*ast.EString)
+		}
 		c := p.bestQuoteCharForString(e.Value, true /* allowBacktick */)
 		p.print(c)
 		p.printQuotedUTF16(e.Value, rune(c[0]))
@@ -1660,8 +1727,7 @@ func (p *printer) printExpr(expr ast.Expr, level ast.L, flags int) {
 			p.print(".")
 			p.print(symbol.NamespaceAlias.Alias)
 		} else {
-			p.printSpaceBeforeIdentifier()
-			p.print(symbol.Name)
+			p.printSymbol(ref)
 		}
 
 	case *ast.EAwait:
@@ -1710,6 +1776,11 @@ func (p *printer) printExpr(expr ast.Expr, level ast.L, flags int) {
 			p.print("(")
 		}
 
+		wasAssignTarget := p.isAssignTarget
+		if e.Op.UnaryAssignTarget() != ast.AssignTargetNone {
+			p.isAssignTarget = true
+		}
+
 		if !e.Op.IsPrefix() {
 			p.printExpr(e.Value, ast.LPostfix-1, 0)
 		}
@@ -1729,6 +1800,8 @@ func (p *printer) printExpr(expr ast.Expr, level ast.L, flags int) {
 			p.printExpr(e.Value, ast.LPrefix-1, 0)
 		}
 
+		p.isAssignTarget = wasAssignTarget
+
 		if wrap {
 			p.print(")")
 		}
@@ -1784,7 +1857,12 @@ func (p *printer) printExpr(expr ast.Expr, level ast.L, flags int) {
 			}
 		}
 
+		wasAssignTarget := p.isAssignTarget
+		if e.Op.BinaryAssignTarget() != ast.AssignTargetNone {
+			p.isAssignTarget = true
+		}
 		p.printExpr(e.Left, leftLevel, flags&forbidIn)
+		p.isAssignTarget = wasAssignTarget
 
 		if e.Op != ast.BinOpComma {
 			p.printSpace()
@@ -2196,6 +2274,9 @@ func (p *printer) printStmt(stmt ast.Stmt) {
 	case *ast.SFunction:
 		p.printIndent()
 		p.printSpaceBeforeIdentifier()
+		if s.Fn.HasNoSideEffectsComment && !p.options.RemoveWhitespace {
+			p.print("/* @__NO_SIDE_EFFECTS__ */ ")
+		}
 		if s.IsExport {
 			p.print("export ")
 		}
@@ -2245,6 +2326,9 @@ func (p *printer) printStmt(stmt ast.Stmt) {
 		switch s2 := s.Value.Stmt.Data.(type) {
 		case *ast.SFunction:
 			p.printSpaceBeforeIdentifier()
+			if s2.Fn.HasNoSideEffectsComment && !p.options.RemoveWhitespace {
+				p.print("/* @__NO_SIDE_EFFECTS__ */ ")
+			}
 			if s2.Fn.IsAsync {
 				p.print("async ")
 			}
@@ -2418,7 +2502,10 @@ func (p *printer) printStmt(stmt ast.Stmt) {
 		p.print("for")
 		p.printSpace()
 		p.print("(")
+		wasAssignTarget := p.isAssignTarget
+		p.isAssignTarget = true
 		p.printForLoopInit(s.Init)
+		p.isAssignTarget = wasAssignTarget
 		p.printSpace()
 		p.printSpaceBeforeIdentifier()
 		p.print("in")
@@ -2436,7 +2523,10 @@ func (p *printer) printStmt(stmt ast.Stmt) {
 		}
 		p.printSpace()
 		p.print("(")
+		wasAssignTarget := p.isAssignTarget
+		p.isAssignTarget = true
 		p.printForLoopInit(s.Init)
+		p.isAssignTarget = wasAssignTarget
 		p.printSpace()
 		p.printSpaceBeforeIdentifier()
 		p.print("of")
@@ -2724,7 +2814,15 @@ type PrintOptions struct {
 	SourceMapContents   *string
 	Indent              int
 	ToModuleRef         ast.Ref
+	PreloadRef          ast.Ref
 	UnsupportedFeatures compat.Feature
+
+	// This maps property names to their replacement when mangling properties
+	MangledProps map[string]string
+
+	// Uses of these symbols are printed as property accesses off of the exports
+	// of another chunk. Each key is the symbol after following links.
+	CrossChunkImports map[ast.Ref]ast.NamespaceAlias
 }
 
 type SourceMapChunk struct {
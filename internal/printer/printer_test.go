@@ -1,6 +1,7 @@
 package printer
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/evanw/esbuild/internal/compat"
@@ -609,3 +610,26 @@ func TestES5(t *testing.T) {
 	expectPrintedTarget(t, 5, "x => x", "(function(x) {\n  return x;\n});\n")
 	expectPrintedTarget(t, 2015, "x => x", "(x) => x;\n")
 }
+
+func TestQuoteForJSON(t *testing.T) {
+	expectQuoted := func(text string, expected string) {
+		t.Helper()
+		quoted := QuoteForJSON(text)
+		assertEqual(t, quoted, expected)
+
+		// The result must also be valid JSON for the same string
+		var decoded string
+		if err := json.Unmarshal([]byte(quoted), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, decoded, text)
+	}
+	expectQuoted("abc", `"abc"`)
+	expectQuoted("\b\f\n\r\t", `"\b\f\n\r\t"`)
+	expectQuoted("\v", `"\u000B"`)
+	expectQuoted("a\vb", `"a\u000Bb"`)
+	expectQuoted("\"\\", `"\"\\"`)
+
+	// JavaScript strings can still use the shorter escape
+	assertEqual(t, Quote("\v"), `"\v"`)
+}
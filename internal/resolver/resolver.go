@@ -27,6 +27,13 @@ type ResolveResult struct {
 
 	// If true, the class field transform should use Object.defineProperty().
 	StrictClassFields bool
+
+	// The "name" and "version" fields from the nearest enclosing "package.json"
+	// file with a name, and the absolute path of the directory containing it.
+	// These are empty if there is no such file.
+	PackageName    string
+	PackageVersion string
+	PackageDir     string
 }
 
 type Resolver interface {
@@ -103,6 +110,19 @@ func (r *resolver) finalizeResolve(path ast.Path, isExternal bool) *ResolveResul
 				}
 			}
 
+			// Remember which package this file belongs to
+			for info := dirInfo; info != nil; info = info.parent {
+				if info.packageJson != nil && info.packageJson.name != "" {
+					result.PackageName = info.packageJson.name
+					result.PackageVersion = info.packageJson.version
+					result.PackageDir = info.absPath
+					if info.absRealPath != "" {
+						result.PackageDir = info.absRealPath
+					}
+					break
+				}
+			}
+
 			// Copy various fields from the nearest enclosing "tsconfig.json" file if present
 			for info := dirInfo; info != nil; info = info.parent {
 				if info.tsConfigJson != nil {
@@ -306,6 +326,10 @@ type packageJson struct {
 	// anything about whether any statements within the file have side effects or
 	// not.
 	sideEffectsMap map[string]bool
+
+	// The "name" and "version" fields, if present
+	name    string
+	version string
 }
 
 type tsConfigJson struct {
@@ -622,6 +646,18 @@ func (r *resolver) parsePackageJSON(path string) *packageJson {
 
 	packageJson := &packageJson{}
 
+	// Read the "name" and "version" properties
+	if nameJson, _, ok := getProperty(json, "name"); ok {
+		if name, ok := getString(nameJson); ok {
+			packageJson.name = name
+		}
+	}
+	if versionJson, _, ok := getProperty(json, "version"); ok {
+		if version, ok := getString(versionJson); ok {
+			packageJson.version = version
+		}
+	}
+
 	// Read the "module" property, or the "main" property as a fallback. We
 	// prefer the "module" property because it's supposed to be ES6 while the
 	// "main" property is supposed to be CommonJS, and ES6 helps us generate
@@ -161,6 +161,23 @@ func code(isES6 bool) string {
 			})
 		}
 
+		// Starts loading the chunks that a dynamically-imported chunk depends on
+		// in parallel instead of waiting for each import to be discovered
+		var __preloaded = {}
+		export var __preload = (load, deps, base) => {
+			if (typeof document !== 'undefined')
+				for (var dep of deps) {
+					var href = new URL(dep, base).href
+					if (!__preloaded[href]) {
+						var link = document.createElement('link')
+						link.rel = 'modulepreload'
+						link.href = __preloaded[href] = href
+						document.head.appendChild(link)
+					}
+				}
+			return load()
+		}
+
 		// This is for the "binary" loader (custom code is ~2x faster than "atob")
 		export var __toBinary = __platform === 'node'
 			? base64 => new Uint8Array(Buffer.from(base64, 'base64'))
@@ -51,6 +51,10 @@ export let startService = (options: types.BrowserServiceOptions): Promise<types.
         new Promise((resolve, reject) =>
           service.transform(input, options, false, (err, res) =>
             err ? reject(err) : resolve(res!))),
+      transformMany: (inputs, options) =>
+        new Promise((resolve, reject) =>
+          service.transformMany(inputs, options, false, (err, res) =>
+            err ? reject(err) : resolve(res!))),
       stop() {
         worker.terminate()
         afterClose()
@@ -7,6 +7,14 @@ function validateTarget(target: string): string {
   return target
 }
 
+// These regular expressions are evaluated in Go, which supports the "i", "m",
+// and "s" flags as a prefix. The other flags don't affect what matches. Syntax
+// that Go doesn't support (e.g. lookahead) is reported as a build error.
+function goRegExp(regExp: RegExp): string {
+  let flags = regExp.flags.replace(/[^ims]/g, '');
+  return flags ? `(?${flags})${regExp.source}` : regExp.source;
+}
+
 function pushCommonFlags(flags: string[], options: types.CommonOptions, isTTY: boolean, logLevelDefault: types.LogLevel): void {
   if (options.target) {
     if (options.target instanceof Array) flags.push(`--target=${Array.from(options.target).map(validateTarget).join(',')}`)
@@ -19,6 +27,9 @@ function pushCommonFlags(flags: string[], options: types.CommonOptions, isTTY: b
   if (options.minifySyntax) flags.push('--minify-syntax');
   if (options.minifyWhitespace) flags.push('--minify-whitespace');
   if (options.minifyIdentifiers) flags.push('--minify-identifiers');
+  if (options.mangleProps) flags.push(`--mangle-props=${goRegExp(options.mangleProps)}`);
+  if (options.reserveProps) flags.push(`--reserve-props=${goRegExp(options.reserveProps)}`);
+  if (options.drop) for (let what of options.drop) flags.push(`--drop:${what}`);
 
   if (options.jsxFactory) flags.push(`--jsx-factory=${options.jsxFactory}`);
   if (options.jsxFragment) flags.push(`--jsx-fragment=${options.jsxFragment}`);
@@ -41,7 +52,22 @@ function flagsForBuildOptions(options: types.BuildOptions, isTTY: boolean): [str
   if (options.globalName) flags.push(`--global-name=${options.globalName}`);
   if (options.bundle) flags.push('--bundle');
   if (options.splitting) flags.push('--splitting');
+  if (options.manualChunks) for (let name in options.manualChunks) for (let pattern of options.manualChunks[name]) flags.push(`--manual-chunk:${name}=${pattern}`);
+  if (options.minChunkSize) flags.push(`--min-chunk-size=${options.minChunkSize}`);
+  if (options.preloadDynamicImports) flags.push('--preload-dynamic-imports');
   if (options.metafile) flags.push(`--metafile=${options.metafile}`);
+  if (options.manifest) flags.push(`--manifest=${options.manifest}`);
+  if (options.depfile) flags.push(`--depfile=${options.depfile}`);
+  if (options.cleanOutdir) flags.push('--clean-outdir');
+  if (options.warnCircularImports) flags.push('--warn-circular-imports');
+  if (options.allowCircularImports) for (let paths of options.allowCircularImports) flags.push(`--allow-circular-import=${paths.join(',')}`);
+  if (options.dedupePackages) flags.push('--dedupe-packages');
+  if (options.sizeLimits) for (let limit of options.sizeLimits) {
+    let value = `${limit.maxBytes}${limit.gzip ? ',gzip' : ''}${limit.warn ? ',warn' : ''}`;
+    if (limit.entryPoint) flags.push(`--max-size:${limit.entryPoint}=${value}`);
+    else if (limit.pattern) flags.push(`--max-size-match:${limit.pattern}=${value}`);
+    else flags.push(`--max-size=${value}`);
+  }
   if (options.outfile) flags.push(`--outfile=${options.outfile}`);
   if (options.outdir) flags.push(`--outdir=${options.outdir}`);
   if (options.platform) flags.push(`--platform=${options.platform}`);
@@ -74,12 +100,49 @@ function flagsForTransformOptions(options: types.TransformOptions, isTTY: boolea
   pushCommonFlags(flags, options, isTTY, 'silent');
 
   if (options.sourcemap) flags.push(`--sourcemap=${options.sourcemap === true ? 'external' : options.sourcemap}`);
+  if (options.format) flags.push(`--format=${options.format}`);
+  if (options.globalName) flags.push(`--global-name=${options.globalName}`);
   if (options.sourcefile) flags.push(`--sourcefile=${options.sourcefile}`);
   if (options.loader) flags.push(`--loader=${options.loader}`);
 
   return flags;
 }
 
+type PluginCallback = (args: any) => any;
+
+// Plugin callbacks stay in this process. Only their filters are sent to the
+// child process, which refers to each callback by its index in "callbacks".
+function registerPlugins(plugins: types.Plugin[], callbacks: PluginCallback[]): protocol.BuildPlugin[] {
+  return plugins.map(plugin => {
+    let result: protocol.BuildPlugin = { name: plugin.name + '', onResolve: [], onLoad: [] };
+    plugin.setup({
+      onResolve(options, callback) {
+        result.onResolve.push({ id: callbacks.length, filter: options.filter.source, namespace: options.namespace || '' });
+        callbacks.push(callback);
+      },
+      onLoad(options, callback) {
+        result.onLoad.push({ id: callbacks.length, filter: options.filter.source, namespace: options.namespace || '' });
+        callbacks.push(callback);
+      },
+    });
+    return result;
+  });
+}
+
+function pluginResponse(result: any, keys: string[]): protocol.Value {
+  let response: { [key: string]: protocol.Value } = {};
+  if (result) for (let key of keys) if (result[key] !== undefined) response[key] = result[key];
+  return response;
+}
+
+function errorText(e: any): string {
+  try {
+    return e + '';
+  } catch {
+    return 'Internal error';
+  }
+}
+
 export interface StreamIn {
   writeToStdin: (data: Uint8Array) => void,
 }
@@ -93,13 +156,16 @@ export interface StreamOut {
 export interface StreamService {
   build(options: types.BuildOptions, isTTY: boolean, callback: (err: Error | null, res: types.BuildResult | null) => void): void;
   transform(input: string, options: types.TransformOptions, isTTY: boolean, callback: (err: Error | null, res: types.TransformResult | null) => void): void;
+  transformMany(inputs: types.TransformInput[], options: types.TransformOptions, isTTY: boolean, callback: (err: Error | null, res: types.TransformManyResult[] | null) => void): void;
 }
 
 // This can't use any promises because it must work for both sync and async code
 export function createChannel(options: StreamIn): StreamOut {
   let callbacks = new Map<number, (error: string | null, response: protocol.Value) => void>();
+  let pluginCallbacks = new Map<number, PluginCallback[]>();
   let isClosed = false;
   let nextID = 0;
+  let nextBuildKey = 0;
 
   // Use a long-lived buffer to store stdout data
   let stdout = new Uint8Array(4096);
@@ -157,16 +223,33 @@ export function createChannel(options: StreamIn): StreamOut {
     // Catch exceptions in the code below so they get passed to the caller
     try {
       switch (command) {
+        case 'resolve': {
+          let { key, id: callbackID, path, importer, namespace, resolveDir } = request as any as protocol.OnResolveRequest;
+          let callback = (pluginCallbacks.get(key) || [])[callbackID];
+          if (!callback) throw new Error(`Invalid plugin callback: ${callbackID}`);
+          new Promise(resolve => resolve(callback({ path, importer, namespace, resolveDir }))).then(
+            result => sendResponse(id, pluginResponse(result, ['path', 'external', 'namespace'])),
+            e => sendResponse(id, { error: errorText(e) }),
+          );
+          break;
+        }
+
+        case 'load': {
+          let { key, id: callbackID, path, namespace } = request as any as protocol.OnLoadRequest;
+          let callback = (pluginCallbacks.get(key) || [])[callbackID];
+          if (!callback) throw new Error(`Invalid plugin callback: ${callbackID}`);
+          new Promise(resolve => resolve(callback({ path, namespace }))).then(
+            result => sendResponse(id, pluginResponse(result, ['contents', 'resolveDir', 'loader'])),
+            e => sendResponse(id, { error: errorText(e) }),
+          );
+          break;
+        }
+
         default:
           throw new Error(`Invalid command: ` + command);
       }
     } catch (e) {
-      let error = 'Internal error'
-      try {
-        error = e + '';
-      } catch {
-      }
-      sendResponse(id, { error });
+      sendResponse(id, { error: errorText(e) });
     }
   };
 
@@ -193,30 +276,71 @@ export function createChannel(options: StreamIn): StreamOut {
       build(options, isTTY, callback) {
         let [flags, stdin, resolveDir] = flagsForBuildOptions(options, isTTY);
         let write = options.write !== false;
+        let mangleCache = options.mangleCache || null;
+        let key = nextBuildKey++;
+        let plugins: protocol.BuildPlugin[] | null = null;
+        if (options.plugins) {
+          let callbacks: PluginCallback[] = [];
+          plugins = registerPlugins(options.plugins, callbacks);
+          pluginCallbacks.set(key, callbacks);
+        }
+        let signal = options.signal;
+        let cancel = () => sendRequest<protocol.CancelRequest, null>(['cancel', { key }], () => { });
         sendRequest<protocol.BuildRequest, protocol.BuildResponse>(
-          ['build', { flags, write, stdin, resolveDir }],
+          ['build', { flags, write, stdin, resolveDir, mangleCache, key, plugins }],
           (error, response) => {
+            pluginCallbacks.delete(key);
+            if (signal) signal.removeEventListener('abort', cancel);
             if (error) return callback(new Error(error), null);
             let errors = response!.errors;
             let warnings = response!.warnings;
             if (errors.length > 0) return callback(failureErrorWithLog('Build failed', errors, warnings), null);
-            let result: types.BuildResult = { warnings };
+            let result: types.BuildResult = { warnings, dependencies: response!.dependencies };
             if (!write) result.outputFiles = response!.outputFiles;
+            if (response!.mangleCache) result.mangleCache = response!.mangleCache;
             callback(null, result);
           },
         );
+        if (signal) {
+          if (signal.aborted) cancel();
+          else signal.addEventListener('abort', cancel);
+        }
       },
 
       transform(input, options, isTTY, callback) {
         let flags = flagsForTransformOptions(options, isTTY);
+        let mangleCache = options.mangleCache || null;
         sendRequest<protocol.TransformRequest, protocol.TransformResponse>(
-          ['transform', { flags, input }],
+          ['transform', { flags, input, mangleCache }],
           (error, response) => {
             if (error) return callback(new Error(error), null);
             let errors = response!.errors;
             let warnings = response!.warnings;
             if (errors.length > 0) return callback(failureErrorWithLog('Transform failed', errors, warnings), null);
-            callback(null, { warnings, js: response!.js, jsSourceMap: response!.jsSourceMap });
+            let result: types.TransformResult = { warnings, js: response!.js, jsSourceMap: response!.jsSourceMap };
+            if (response!.mangleCache) result.mangleCache = response!.mangleCache;
+            callback(null, result);
+          },
+        );
+      },
+
+      transformMany(inputs, options, isTTY, callback) {
+        let flags = flagsForTransformOptions(options, isTTY);
+        let mangleCache = options.mangleCache || null;
+        sendRequest<protocol.TransformManyRequest, protocol.TransformManyResponse>(
+          ['transform-many', { flags, inputs, mangleCache }],
+          (error, response) => {
+            if (error) return callback(new Error(error), null);
+            callback(null, response!.results.map(response => {
+              let result: types.TransformManyResult = {
+                errors: response.errors,
+                warnings: response.warnings,
+                js: response.js,
+                jsSourceMap: response.jsSourceMap,
+              };
+              if (response.mangleCache) result.mangleCache = response.mangleCache;
+              return result;
+            }));
           },
         );
       },
@@ -40,6 +40,8 @@ export let transform: typeof types.transform = (input, options) => {
 };
 
 export let buildSync: typeof types.buildSync = options => {
+  // Plugins need to be called back while the child process is running
+  if (options.plugins) throw new Error('Cannot use plugins with "buildSync"');
   let result: types.BuildResult;
   runServiceSync(service => service.build(options, isTTY(), (err, res) => {
     if (err) throw err;
@@ -82,6 +84,10 @@ export let startService = (): Promise<types.Service> => {
       new Promise((resolve, reject) =>
         service.transform(input, options, isTTY(), (err, res) =>
           err ? reject(err) : resolve(res!))),
+    transformMany: (inputs, options) =>
+      new Promise((resolve, reject) =>
+        service.transformMany(inputs, options, isTTY(), (err, res) =>
+          err ? reject(err) : resolve(res!))),
     stop() { child.kill(); },
   });
 };
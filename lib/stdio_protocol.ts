@@ -10,17 +10,64 @@ export interface BuildRequest {
   write: boolean;
   stdin: string | null;
   resolveDir: string | null;
+  mangleCache: { [key: string]: string | false } | null;
+  key: number;
+  plugins: BuildPlugin[] | null;
+}
+
+export interface CancelRequest {
+  key: number;
+}
+
+export interface BuildPlugin {
+  name: string;
+  onResolve: { id: number, filter: string, namespace: string }[];
+  onLoad: { id: number, filter: string, namespace: string }[];
 }
 
 export interface BuildResponse {
   errors: types.Message[];
   warnings: types.Message[];
   outputFiles: types.OutputFile[];
+  mangleCache: { [key: string]: string | false } | null;
+  dependencies: string[];
+}
+
+// These are sent from the child process to call a plugin callback
+export interface OnResolveRequest {
+  key: number;
+  id: number;
+  path: string;
+  importer: string;
+  namespace: string;
+  resolveDir: string;
+}
+
+export interface OnResolveResponse {
+  error?: string;
+  path?: string;
+  external?: boolean;
+  namespace?: string;
+}
+
+export interface OnLoadRequest {
+  key: number;
+  id: number;
+  path: string;
+  namespace: string;
+}
+
+export interface OnLoadResponse {
+  error?: string;
+  contents?: string | Uint8Array;
+  resolveDir?: string;
+  loader?: string;
 }
 
 export interface TransformRequest {
   flags: string[];
   input: string;
+  mangleCache: { [key: string]: string | false } | null;
 }
 
 export interface TransformResponse {
@@ -28,6 +75,17 @@ export interface TransformResponse {
   warnings: types.Message[];
   js: string;
   jsSourceMap: string;
+  mangleCache: { [key: string]: string | false } | null;
+}
+
+export interface TransformManyRequest {
+  flags: string[];
+  inputs: types.TransformInput[];
+  mangleCache: { [key: string]: string | false } | null;
+}
+
+export interface TransformManyResponse {
+  results: TransformResponse[];
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -3,6 +3,7 @@ export type Format = 'iife' | 'cjs' | 'esm';
 export type Loader = 'js' | 'jsx' | 'ts' | 'tsx' | 'json' | 'text' | 'base64' | 'file' | 'dataurl' | 'binary';
 export type LogLevel = 'info' | 'warning' | 'error' | 'silent';
 export type Strict = 'nullish-coalescing' | 'class-fields';
+export type Drop = 'console' | 'debugger';
 
 export interface CommonOptions {
   sourcemap?: boolean | 'inline' | 'external';
@@ -13,6 +14,10 @@ export interface CommonOptions {
   minifyWhitespace?: boolean;
   minifyIdentifiers?: boolean;
   minifySyntax?: boolean;
+  mangleProps?: RegExp; // Uses Go regular expression syntax (no lookaround)
+  reserveProps?: RegExp; // Uses Go regular expression syntax (no lookaround)
+  mangleCache?: { [key: string]: string | false };
+  drop?: Drop[];
 
   jsxFactory?: string;
   jsxFragment?: string;
@@ -28,8 +33,18 @@ export interface BuildOptions extends CommonOptions {
   globalName?: string;
   bundle?: boolean;
   splitting?: boolean;
+  manualChunks?: { [name: string]: string[] };
+  minChunkSize?: number;
+  preloadDynamicImports?: boolean;
   outfile?: string;
   metafile?: string;
+  manifest?: string;
+  depfile?: string;
+  cleanOutdir?: boolean; // Only when "write" is true
+  warnCircularImports?: boolean;
+  allowCircularImports?: string[][];
+  dedupePackages?: boolean;
+  sizeLimits?: SizeLimit[];
   outdir?: string;
   platform?: Platform;
   format?: Format;
@@ -42,6 +57,26 @@ export interface BuildOptions extends CommonOptions {
 
   entryPoints?: string[];
   stdin?: StdinOptions;
+  plugins?: Plugin[];
+
+  // Aborting this signal cancels the build, which then fails with the error
+  // "The build was cancelled". This is compatible with "AbortSignal".
+  signal?: CancelSignal;
+}
+
+export interface CancelSignal {
+  readonly aborted: boolean;
+  addEventListener(type: 'abort', listener: () => void): void;
+  removeEventListener(type: 'abort', listener: () => void): void;
+}
+
+// The limit applies to the whole output unless "entryPoint" or "pattern" is set
+export interface SizeLimit {
+  entryPoint?: string;
+  pattern?: string;
+  maxBytes: number;
+  gzip?: boolean;
+  warn?: boolean;
 }
 
 export interface StdinOptions {
@@ -51,8 +86,58 @@ export interface StdinOptions {
   loader?: Loader;
 }
 
+// Plugin callbacks run in this process. The filters use Go regular expression
+// syntax since they are matched by esbuild itself, so only the "source" of
+// each RegExp is used.
+export interface Plugin {
+  name: string;
+  setup: (build: PluginBuild) => void;
+}
+
+export interface PluginBuild {
+  onResolve(options: OnResolveOptions, callback: (args: OnResolveArgs) =>
+    OnResolveResult | null | undefined | Promise<OnResolveResult | null | undefined>): void;
+  onLoad(options: OnLoadOptions, callback: (args: OnLoadArgs) =>
+    OnLoadResult | null | undefined | Promise<OnLoadResult | null | undefined>): void;
+}
+
+export interface OnResolveOptions {
+  filter: RegExp;
+  namespace?: string;
+}
+
+export interface OnResolveArgs {
+  path: string;
+  importer: string;
+  namespace: string;
+  resolveDir: string;
+}
+
+export interface OnResolveResult {
+  path?: string; // Leave this out to let the next callback resolve the path
+  external?: boolean;
+  namespace?: string; // Defaults to "file", which means "path" is a file system path
+}
+
+export interface OnLoadOptions {
+  filter: RegExp;
+  namespace?: string;
+}
+
+export interface OnLoadArgs {
+  path: string;
+  namespace: string;
+}
+
+export interface OnLoadResult {
+  contents?: string | Uint8Array; // Leave this out to let the next callback load the file
+  resolveDir?: string;
+  loader?: Loader;
+}
+
 export interface Message {
   text: string;
+  id: string; // Only some warnings have an ID (e.g. "circular-import")
   location: null | {
     file: string;
     line: number; // 1-based
@@ -70,6 +155,8 @@ export interface OutputFile {
 export interface BuildResult {
   warnings: Message[];
   outputFiles?: OutputFile[]; // Only when "write: false"
+  mangleCache?: { [key: string]: string | false }; // Only when "mangleProps" is present
+  dependencies: string[]; // Every file and directory read by the build
 }
 
 export interface BuildFailure extends Error {
@@ -78,6 +165,8 @@ export interface BuildFailure extends Error {
 }
 
 export interface TransformOptions extends CommonOptions {
+  format?: Format;
+  globalName?: string;
   sourcefile?: string;
   loader?: Loader;
 }
@@ -86,6 +175,19 @@ export interface TransformResult {
   js: string;
   jsSourceMap: string;
   warnings: Message[];
+  mangleCache?: { [key: string]: string | false }; // Only when "mangleProps" is present
+}
+
+export interface TransformInput {
+  contents: string;
+  sourcefile?: string;
+  loader?: Loader;
+}
+
+// Unlike "transform", a batch doesn't fail when an input has errors. Instead
+// each result has the errors for that input.
+export interface TransformManyResult extends TransformResult {
+  errors: Message[];
 }
 
 export interface TransformFailure extends Error {
@@ -94,12 +196,17 @@ export interface TransformFailure extends Error {
 }
 
 // This is the type information for the "metafile" JSON format
+export type ImportKind = 'import-statement' | 'require-call' | 'dynamic-import';
+
 export interface Metadata {
+  version: number
   inputs: {
     [path: string]: {
       bytes: number
       imports: {
         path: string
+        kind: ImportKind
+        external?: boolean
       }[]
     }
   }
@@ -113,7 +220,13 @@ export interface Metadata {
       }
       imports: {
         path: string
+        kind: ImportKind
+      }[]
+      transitiveImports?: {
+        path: string
       }[]
+      exports: string[]
+      entryPoint?: string
     }
   }
 }
@@ -122,6 +235,11 @@ export interface Service {
   build(options: BuildOptions): Promise<BuildResult>;
   transform(input: string, options: TransformOptions): Promise<TransformResult>;
 
+  // This transforms many inputs in parallel using the same options. The
+  // "sourcefile" and "loader" options are ignored since each input has its
+  // own. The results are in the same order as the inputs.
+  transformMany(inputs: TransformInput[], options: TransformOptions): Promise<TransformManyResult[]>;
+
   // This stops the service, which kills the long-lived child process. Any
   // pending requests will be aborted.
   stop(): void;
@@ -79,6 +79,8 @@
 //
 package api
 
+import "context"
+
 type SourceMap uint8
 
 const (
@@ -114,6 +116,7 @@ const (
 	LoaderDataURL
 	LoaderFile
 	LoaderBinary
+	LoaderNone // Use the loader for the file extension (for "OnLoadResult")
 )
 
 type Platform uint8
@@ -159,6 +162,7 @@ type Location struct {
 type Message struct {
 	Text     string
 	Location *Location
+	ID       string // Only some warnings have an ID (e.g. "circular-import")
 }
 
 type StderrColor uint8
@@ -200,6 +204,11 @@ type BuildOptions struct {
 	MinifyIdentifiers bool
 	MinifySyntax      bool
 
+	MangleProps  string                 // Regular expression
+	ReserveProps string                 // Regular expression
+	MangleCache  map[string]interface{} // Maps property names to a string or false
+	Drop         []string               // Can contain "console" and/or "debugger"
+
 	JSXFactory  string
 	JSXFragment string
 
@@ -209,8 +218,12 @@ type BuildOptions struct {
 	GlobalName        string
 	Bundle            bool
 	Splitting         bool
+	ManualChunks      map[string][]string // Maps chunk names to patterns for whole module paths relative to the current directory ("*" is a wildcard)
+	MinChunkSize      int
 	Outfile           string
 	Metafile          string
+	Manifest          string // Maps each entry point to the output files it needs
+	Depfile           string // Lists the files read by the build for Make or Ninja
 	Outdir            string
 	Platform          Platform
 	Format            Format
@@ -219,8 +232,54 @@ type BuildOptions struct {
 	ResolveExtensions []string
 	Tsconfig          string
 
+	WarnCircularImports  bool
+	AllowCircularImports [][]string // Each list is a group of files that may import each other
+
+	// Use a single copy of each package that is installed in more than one
+	// place with the same version
+	DedupePackages bool
+
+	// Output files over these limits cause an error or a warning
+	SizeLimits []SizeLimit
+
+	// If true, each dynamic import also starts loading the chunks that the
+	// imported entry point depends on. This requires "Splitting".
+	PreloadDynamicImports bool
+
 	EntryPoints []string
 	Stdin       *StdinOptions
+	Plugins     []Plugin
+
+	FS FS // If nil, the real file system is used
+
+	// If true, the output files are written to the file system as well as
+	// returned. Each file is written atomically and files with unchanged
+	// contents aren't written again, which preserves their modification time.
+	// Nothing is written when there is no output path or the build failed.
+	// Files are written to the real file system, so this can't be used with a
+	// custom "FS" other than "RealFS()".
+	Write bool
+
+	// If true, the output files of the previous build with this option that
+	// this build didn't generate again are deleted from "Outdir" after writing.
+	// The list of output files is kept in a ".esbuild-outputs" file in "Outdir".
+	// This requires "Write" and "Outdir", and the build fails if "Outdir"
+	// contains the current directory or any of the input files.
+	CleanOutdir bool
+
+	// If true, the metadata is returned in "BuildResult.Metafile" even if
+	// "Metafile" is empty. It's only an output file if "Metafile" is set.
+	ReturnMetafile bool
+}
+
+// A limit applies to the total size of all output files unless "EntryPoint"
+// or "Pattern" is present
+type SizeLimit struct {
+	EntryPoint string // Only limit the output file for this entry point and the chunks it imports
+	Pattern    string // Only limit output files with paths matching this regular expression
+	MaxBytes   int
+	Gzip       bool // Measure the size after gzip compression
+	Warn       bool // Report a warning instead of an error
 }
 
 type StdinOptions struct {
@@ -235,6 +294,15 @@ type BuildResult struct {
 	Warnings []Message
 
 	OutputFiles []OutputFile
+	MangleCache map[string]interface{} // Only set if "MangleProps" was used
+	Metafile    []byte                 // Only set if "ReturnMetafile" was used
+
+	// The absolute paths of every file and directory read by the build, in
+	// sorted order. This includes source files, "package.json" and
+	// "tsconfig.json" files, and directories that were searched when resolving
+	// import paths. The current directory and the directories above it are
+	// left out.
+	Dependencies []string
 }
 
 type OutputFile struct {
@@ -243,7 +311,265 @@ type OutputFile struct {
 }
 
 func Build(options BuildOptions) BuildResult {
-	return buildImpl(options)
+	return buildImpl(context.Background(), options)
+}
+
+// This is the same as "Build" except that the build stops early if the
+// context is done before the build finishes. In that case the result has no
+// output files and its only error has the text in "BuildCancelledText".
+func BuildWithContext(ctx context.Context, options BuildOptions) BuildResult {
+	return buildImpl(ctx, options)
+}
+
+const BuildCancelledText = "The build was cancelled"
+
+////////////////////////////////////////////////////////////////////////////////
+// Plugin API
+
+// Each plugin's "Setup" function is called once at the start of each build
+// to register callbacks. Callbacks can be called concurrently from multiple
+// goroutines during the build.
+type Plugin struct {
+	Name  string
+	Setup func(PluginBuild)
+}
+
+type PluginBuild interface {
+	OnResolve(options OnResolveOptions, callback func(OnResolveArgs) (OnResolveResult, error))
+	OnLoad(options OnLoadOptions, callback func(OnLoadArgs) (OnLoadResult, error))
+}
+
+type OnResolveOptions struct {
+	Filter    string // Regular expression
+	Namespace string
+}
+
+type OnResolveArgs struct {
+	Path       string
+	Importer   string
+	Namespace  string
+	ResolveDir string
+}
+
+// Leave "Path" empty to let the next callback resolve the path instead.
+// Paths in the "file" namespace (the default) are file system paths.
+type OnResolveResult struct {
+	Path      string
+	External  bool
+	Namespace string
+}
+
+type OnLoadOptions struct {
+	Filter    string // Regular expression
+	Namespace string
+}
+
+type OnLoadArgs struct {
+	Path      string
+	Namespace string
+}
+
+// Leave "Contents" nil to let the next callback load the file instead
+type OnLoadResult struct {
+	Contents   *string
+	ResolveDir string
+	Loader     Loader
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// File System API
+
+// Builds read all files and directories through this interface, so a build
+// can run against files that are in memory, in a database, or anywhere else.
+// Paths given to "ReadDirectory" and "ReadFile" are absolute paths produced
+// by the other methods. Methods can be called concurrently from multiple
+// goroutines during the build.
+type FS interface {
+	// This returns nil or an empty map if the directory doesn't exist. The
+	// returned map is not mutated.
+	ReadDirectory(path string) map[string]FSEntry
+	ReadFile(path string) (contents string, ok bool)
+
+	// These work like the functions in "path/filepath" for the real file
+	// system and like the ones in "path" for a file system that always uses
+	// forward slashes. "Cwd" is used to resolve relative paths.
+	Abs(path string) (string, bool)
+	Dir(path string) string
+	Base(path string) string
+	Ext(path string) string
+	Join(parts ...string) string
+	Cwd() string
+	Rel(base string, target string) (string, bool)
+}
+
+type FSEntryKind uint8
+
+const (
+	FSEntryDir FSEntryKind = iota + 1
+	FSEntryFile
+)
+
+type FSEntry struct {
+	Kind    FSEntryKind
+	Symlink string // If non-empty, the absolute path that this entry links to
+}
+
+// This is the file system that builds use by default. It can be wrapped to
+// change only part of it.
+func RealFS() FS {
+	return realFSImpl()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Analyze API
+
+type AnalyzeMetafileOptions struct {
+	HTML bool // Generate a standalone HTML page with a treemap instead of text
+}
+
+// This summarizes the metadata file written when "Metafile" is set. The text
+// report lists the size of each output broken down by input, largest first.
+// Inputs from the same package under "node_modules" are grouped together.
+func AnalyzeMetafile(metafile string, options AnalyzeMetafileOptions) (string, error) {
+	return analyzeMetafileImpl(metafile, options)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Why API
+
+type WhyResult struct {
+	Errors   []Message
+	Warnings []Message
+
+	Modules []WhyModule
+}
+
+type WhyModule struct {
+	Path string
+
+	// The shortest chain of imports from each entry point that reaches this
+	// module. A chain is empty if the module is that entry point.
+	ImportChains [][]WhyImport
+
+	// Top-level statements with side effects, which keep the module in the
+	// bundle once it's imported even if none of its exports are used
+	SideEffects []Location
+
+	// Other reasons this module can't be removed by tree shaking
+	Notes []string
+}
+
+type WhyImport struct {
+	Location Location // The location of the import path in the importer
+	Path     string   // The import path as it was written
+}
+
+// This scans the bundle described by the options without generating any
+// output and explains why each module matching "module" is included. The
+// module can be a file path or a package name, in which case every module in
+// that package matches.
+func Why(module string, options BuildOptions) WhyResult {
+	return whyImpl(module, options)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Scan API
+
+type ImportKind uint8
+
+const (
+	ImportStatement ImportKind = iota // "import ... from 'path'" or "import 'path'"
+	ImportReExport                    // "export ... from 'path'"
+	ImportRequire                     // "require('path')"
+	ImportDynamic                     // "import('path')"
+)
+
+type ScanImport struct {
+	Kind     ImportKind
+	Path     string   // The import path as it was written
+	Location Location // The location of the import path
+
+	// The module this import resolved to. This is only set by "ScanBuild" and
+	// is empty for external imports.
+	ResolvedPath string
+}
+
+type ScanResult struct {
+	Errors   []Message
+	Warnings []Message
+
+	Imports []ScanImport
+
+	// The names of the ES6 exports in sorted order. This doesn't include names
+	// that are only re-exported using "export * from 'path'".
+	Exports []string
+
+	// True if the file uses "exports" or "module" or has a top-level return
+	// statement. Calls to "require()" are listed as imports instead.
+	UsesCommonJS   bool
+	UsesImportMeta bool
+}
+
+// This parses the input and reports its imports and exports without
+// resolving, transforming, or printing anything. The loader must be one of
+// the JavaScript or TypeScript loaders.
+func Scan(input string, loader Loader) ScanResult {
+	return scanImpl(input, loader)
+}
+
+type ScanModule struct {
+	Path         string
+	IsEntryPoint bool
+
+	Imports        []ScanImport
+	Exports        []string
+	UsesCommonJS   bool
+	UsesImportMeta bool
+}
+
+type ScanBuildResult struct {
+	Errors   []Message
+	Warnings []Message
+
+	// Every module reachable from the entry points. Tree shaking doesn't run,
+	// so this includes modules that wouldn't end up in the output files.
+	Modules []ScanModule
+}
+
+// This resolves and parses every module reachable from the entry points in
+// the options and reports the import graph without linking or generating any
+// output files. The options must enable bundling.
+func ScanBuild(options BuildOptions) ScanBuildResult {
+	return scanBuildImpl(options)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Parse API
+
+type ParseASTOptions struct {
+	Color      StderrColor
+	ErrorLimit int
+	LogLevel   LogLevel
+
+	Sourcefile string
+	Loader     Loader
+}
+
+type ParseASTResult struct {
+	Errors   []Message
+	Warnings []Message
+
+	// The AST as JSON in the ESTree format. This isn't TS-ESTree since type
+	// annotations are discarded while parsing. The only TypeScript-specific
+	// nodes are the ones for enums, namespaces, and "export =", which use the
+	// same node types as TS-ESTree.
+	AST []byte
+}
+
+// This parses the input and returns its syntax tree without transforming or
+// printing it. The loader must be one of the JavaScript or TypeScript loaders.
+func ParseAST(input string, options ParseASTOptions) ParseASTResult {
+	return parseASTImpl(input, options)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -263,12 +589,23 @@ type TransformOptions struct {
 	MinifyIdentifiers bool
 	MinifySyntax      bool
 
+	MangleProps  string                 // Regular expression
+	ReserveProps string                 // Regular expression
+	MangleCache  map[string]interface{} // Maps property names to a string or false
+	Drop         []string               // Can contain "console" and/or "debugger"
+
 	JSXFactory  string
 	JSXFragment string
 
 	Defines       map[string]string
 	PureFunctions []string
 
+	// The input's import and export syntax is passed through unless a format
+	// is set, in which case it's converted the same way it would be for the
+	// entry point of a bundle. Imports are never bundled.
+	Format     Format
+	GlobalName string
+
 	Sourcefile string
 	Loader     Loader
 }
@@ -279,8 +616,27 @@ type TransformResult struct {
 
 	JS          []byte
 	JSSourceMap []byte
+	MangleCache map[string]interface{} // Only set if "MangleProps" was used
 }
 
 func Transform(input string, options TransformOptions) TransformResult {
 	return transformImpl(input, options)
 }
+
+type TransformInput struct {
+	Contents   string
+	Sourcefile string
+	Loader     Loader
+}
+
+// This is like calling "Transform" for each input, but the options (including
+// the defines) are only processed once and the inputs are transformed in
+// parallel. The results are in the same order as the inputs. The "Sourcefile"
+// and "Loader" options are ignored since each input has its own. Each input
+// starts with the same "MangleCache" and gets its own updated cache in its
+// result. Nothing else is shared between inputs: the parsed runtime code is
+// already cached for the whole process, and transforms don't read any
+// "tsconfig.json" files.
+func TransformMany(inputs []TransformInput, options TransformOptions) []TransformResult {
+	return transformManyImpl(inputs, options)
+}
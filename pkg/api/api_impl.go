@@ -1,11 +1,20 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/evanw/esbuild/internal/analyzer"
 	"github.com/evanw/esbuild/internal/ast"
 	"github.com/evanw/esbuild/internal/bundler"
 	"github.com/evanw/esbuild/internal/compat"
@@ -14,6 +23,7 @@ import (
 	"github.com/evanw/esbuild/internal/lexer"
 	"github.com/evanw/esbuild/internal/logging"
 	"github.com/evanw/esbuild/internal/parser"
+	"github.com/evanw/esbuild/internal/printer"
 	"github.com/evanw/esbuild/internal/resolver"
 )
 
@@ -113,6 +123,8 @@ func validateLoader(value Loader) config.Loader {
 		return config.LoaderFile
 	case LoaderBinary:
 		return config.LoaderBinary
+	case LoaderNone:
+		return config.LoaderNone
 	default:
 		panic("Invalid loader")
 	}
@@ -252,6 +264,172 @@ func validateJSX(log logging.Log, text string, name string) []string {
 	return parts
 }
 
+func validateRegex(log logging.Log, what string, value string) *regexp.Regexp {
+	if value == "" {
+		return nil
+	}
+	regex, err := regexp.Compile(value)
+	if err != nil {
+		log.AddError(nil, ast.Loc{}, fmt.Sprintf("The %q setting is not a valid Go regular expression: %s (%s)", what, value, err.Error()))
+		return nil
+	}
+	return regex
+}
+
+func validateMangleCache(log logging.Log, cache map[string]interface{}) map[string]interface{} {
+	for key, value := range cache {
+		switch v := value.(type) {
+		case string:
+			if lexer.IsIdentifier(v) {
+				continue
+			}
+		case bool:
+			if !v {
+				continue
+			}
+		}
+		log.AddError(nil, ast.Loc{}, fmt.Sprintf(
+			"Expected %q in the mangle cache to map to either an identifier or false", key))
+	}
+	return cache
+}
+
+func validateDrop(log logging.Log, options *config.Options, drop []string) {
+	for _, what := range drop {
+		switch what {
+		case "console":
+			options.DropConsole = true
+		case "debugger":
+			options.DropDebugger = true
+		default:
+			log.AddError(nil, ast.Loc{}, fmt.Sprintf("Invalid drop: %q (valid: console, debugger)", what))
+		}
+	}
+}
+
+func validateManualChunks(log logging.Log, manualChunks map[string][]string) []config.ManualChunk {
+	if len(manualChunks) == 0 {
+		return nil
+	}
+
+	// Sort the chunks by name for determinism
+	names := make([]string, 0, len(manualChunks))
+	for name := range manualChunks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]config.ManualChunk, 0, len(names))
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, "/\\") {
+			log.AddError(nil, ast.Loc{}, fmt.Sprintf("Invalid manual chunk name: %q", name))
+			continue
+		}
+		patterns := manualChunks[name]
+		if len(patterns) == 0 {
+			log.AddError(nil, ast.Loc{}, fmt.Sprintf("The manual chunk %q must have at least one pattern", name))
+			continue
+		}
+
+		// Patterns match the whole path and may contain "*" wildcards
+		sb := strings.Builder{}
+		sb.WriteString("^(?:")
+		for i, pattern := range patterns {
+			if i > 0 {
+				sb.WriteByte('|')
+			}
+			sb.WriteString(strings.ReplaceAll(regexp.QuoteMeta(pattern), "\\*", ".*"))
+		}
+		sb.WriteString(")$")
+		result = append(result, config.ManualChunk{
+			Name:    name,
+			Pattern: regexp.MustCompile(sb.String()),
+		})
+	}
+	return result
+}
+
+type pluginImpl struct {
+	log    logging.Log
+	plugin config.Plugin
+}
+
+func (impl *pluginImpl) validateFilter(filter string) *regexp.Regexp {
+	if filter == "" {
+		impl.log.AddError(nil, ast.Loc{}, fmt.Sprintf("[%s] Callbacks must have a filter", impl.plugin.Name))
+		return nil
+	}
+	regex, err := regexp.Compile(filter)
+	if err != nil {
+		impl.log.AddError(nil, ast.Loc{}, fmt.Sprintf(
+			"[%s] The filter is not a valid Go regular expression: %s", impl.plugin.Name, filter))
+		return nil
+	}
+	return regex
+}
+
+func (impl *pluginImpl) OnResolve(options OnResolveOptions, callback func(OnResolveArgs) (OnResolveResult, error)) {
+	filter := impl.validateFilter(options.Filter)
+	if filter == nil {
+		return
+	}
+	impl.plugin.OnResolve = append(impl.plugin.OnResolve, config.OnResolveCallback{
+		Filter:    filter,
+		Namespace: options.Namespace,
+		Callback: func(args config.OnResolveArgs) (config.OnResolveResult, error) {
+			result, err := callback(OnResolveArgs{
+				Path:       args.Path,
+				Importer:   args.Importer,
+				Namespace:  args.Namespace,
+				ResolveDir: args.ResolveDir,
+			})
+			return config.OnResolveResult{
+				Path:      result.Path,
+				External:  result.External,
+				Namespace: result.Namespace,
+			}, err
+		},
+	})
+}
+
+func (impl *pluginImpl) OnLoad(options OnLoadOptions, callback func(OnLoadArgs) (OnLoadResult, error)) {
+	filter := impl.validateFilter(options.Filter)
+	if filter == nil {
+		return
+	}
+	impl.plugin.OnLoad = append(impl.plugin.OnLoad, config.OnLoadCallback{
+		Filter:    filter,
+		Namespace: options.Namespace,
+		Callback: func(args config.OnLoadArgs) (config.OnLoadResult, error) {
+			result, err := callback(OnLoadArgs{
+				Path:      args.Path,
+				Namespace: args.Namespace,
+			})
+			return config.OnLoadResult{
+				Contents:   result.Contents,
+				ResolveDir: result.ResolveDir,
+				Loader:     validateLoader(result.Loader),
+			}, err
+		},
+	})
+}
+
+func validatePlugins(log logging.Log, plugins []Plugin) []config.Plugin {
+	result := make([]config.Plugin, 0, len(plugins))
+	for i, plugin := range plugins {
+		if plugin.Name == "" {
+			log.AddError(nil, ast.Loc{}, fmt.Sprintf("Plugin at index %d is missing a name", i))
+			continue
+		}
+		impl := &pluginImpl{log: log, plugin: config.Plugin{Name: plugin.Name}}
+		if plugin.Setup != nil {
+			plugin.Setup(impl)
+		}
+		result = append(result, impl.plugin)
+	}
+	return result
+}
+
 func validateDefines(log logging.Log, defines map[string]string, pureFns []string) *config.ProcessedDefines {
 	if len(defines) == 0 && len(pureFns) == 0 {
 		return nil
@@ -340,26 +518,27 @@ func validatePath(log logging.Log, fs fs.FS, relPath string) string {
 	return absPath
 }
 
+func locationOrNil(loc *logging.MsgLocation) *Location {
+	if loc == nil {
+		return nil
+	}
+	return &Location{
+		File:     loc.File,
+		Line:     loc.Line,
+		Column:   loc.Column,
+		Length:   loc.Length,
+		LineText: loc.LineText,
+	}
+}
+
 func messagesOfKind(kind logging.MsgKind, msgs []logging.Msg) []Message {
 	var filtered []Message
 	for _, msg := range msgs {
 		if msg.Kind == kind {
-			var location *Location
-
-			if msg.Location != nil {
-				loc := msg.Location
-				location = &Location{
-					File:     loc.File,
-					Line:     loc.Line,
-					Column:   loc.Column,
-					Length:   loc.Length,
-					LineText: loc.LineText,
-				}
-			}
-
 			filtered = append(filtered, Message{
 				Text:     msg.Text,
-				Location: location,
+				Location: locationOrNil(msg.Location),
+				ID:       msg.ID,
 			})
 		}
 	}
@@ -369,7 +548,49 @@ func messagesOfKind(kind logging.MsgKind, msgs []logging.Msg) []Message {
 ////////////////////////////////////////////////////////////////////////////////
 // Build API
 
-func buildImpl(buildOpts BuildOptions) BuildResult {
+func validateAllowedCircularImports(log logging.Log, fs fs.FS, groups [][]string) [][]string {
+	result := make([][]string, len(groups))
+	for i, group := range groups {
+		for _, path := range group {
+			result[i] = append(result[i], validatePath(log, fs, path))
+		}
+	}
+	return result
+}
+
+func validateSizeLimits(log logging.Log, fs fs.FS, limits []SizeLimit) []config.SizeLimit {
+	result := make([]config.SizeLimit, 0, len(limits))
+	for _, limit := range limits {
+		sizeLimit := config.SizeLimit{
+			MaxBytes:  limit.MaxBytes,
+			Gzip:      limit.Gzip,
+			IsWarning: limit.Warn,
+		}
+		if limit.MaxBytes < 0 {
+			log.AddError(nil, ast.Loc{}, fmt.Sprintf("Invalid size limit: %d", limit.MaxBytes))
+			continue
+		}
+		if limit.EntryPoint != "" && limit.Pattern != "" {
+			log.AddError(nil, ast.Loc{}, "A size limit can't have both an entry point and a pattern")
+			continue
+		}
+		if limit.EntryPoint != "" {
+			sizeLimit.EntryPoint = validatePath(log, fs, limit.EntryPoint)
+		}
+		if limit.Pattern != "" {
+			pattern, err := regexp.Compile(limit.Pattern)
+			if err != nil {
+				log.AddError(nil, ast.Loc{}, fmt.Sprintf("Invalid size limit pattern %q: %s", limit.Pattern, err.Error()))
+				continue
+			}
+			sizeLimit.Pattern = pattern
+		}
+		result = append(result, sizeLimit)
+	}
+	return result
+}
+
+func newBuildLog(buildOpts BuildOptions) logging.Log {
 	var log logging.Log
 	if buildOpts.LogLevel == LogLevelSilent {
 		log = logging.NewDeferLog()
@@ -381,9 +602,11 @@ func buildImpl(buildOpts BuildOptions) BuildResult {
 			LogLevel:      validateLogLevel(buildOpts.LogLevel),
 		})
 	}
+	return log
+}
 
-	// Convert and validate the buildOpts
-	realFS := fs.RealFS()
+// This converts and validates the options shared by "Build" and "Why"
+func validateBuildOptions(log logging.Log, buildFS fs.FS, buildOpts BuildOptions) (config.Options, []string) {
 	options := config.Options{
 		UnsupportedFeatures: validateFeatures(log, buildOpts.Target, buildOpts.Engines),
 		Strict:              validateStrict(buildOpts.Strict),
@@ -397,22 +620,38 @@ func buildImpl(buildOpts BuildOptions) BuildResult {
 		MangleSyntax:      buildOpts.MinifySyntax,
 		RemoveWhitespace:  buildOpts.MinifyWhitespace,
 		MinifyIdentifiers: buildOpts.MinifyIdentifiers,
+		MangleProps:       validateRegex(log, "mangle props", buildOpts.MangleProps),
+		ReserveProps:      validateRegex(log, "reserve props", buildOpts.ReserveProps),
+		MangleCache:       validateMangleCache(log, buildOpts.MangleCache),
 		ModuleName:        buildOpts.GlobalName,
 		IsBundling:        buildOpts.Bundle,
 		CodeSplitting:     buildOpts.Splitting,
+		ManualChunks:      validateManualChunks(log, buildOpts.ManualChunks),
+		MinChunkSize:      buildOpts.MinChunkSize,
 		OutputFormat:      validateFormat(buildOpts.Format),
-		AbsOutputFile:     validatePath(log, realFS, buildOpts.Outfile),
-		AbsOutputDir:      validatePath(log, realFS, buildOpts.Outdir),
-		AbsMetadataFile:   validatePath(log, realFS, buildOpts.Metafile),
+		AbsOutputFile:     validatePath(log, buildFS, buildOpts.Outfile),
+		AbsOutputDir:      validatePath(log, buildFS, buildOpts.Outdir),
+		AbsMetadataFile:   validatePath(log, buildFS, buildOpts.Metafile),
+		AbsManifestFile:   validatePath(log, buildFS, buildOpts.Manifest),
+		DedupePackages:    buildOpts.DedupePackages,
+		SizeLimits:        validateSizeLimits(log, buildFS, buildOpts.SizeLimits),
 		ExtensionToLoader: validateLoaders(log, buildOpts.Loaders),
 		ExtensionOrder:    validateResolveExtensions(log, buildOpts.ResolveExtensions),
-		ExternalModules:   validateExternals(log, realFS, buildOpts.Externals),
-		TsConfigOverride:  validatePath(log, realFS, buildOpts.Tsconfig),
-	}
-	entryPaths := make([]string, len(buildOpts.EntryPoints))
-	for i, entryPoint := range buildOpts.EntryPoints {
-		entryPaths[i] = validatePath(log, realFS, entryPoint)
+		ExternalModules:   validateExternals(log, buildFS, buildOpts.Externals),
+		TsConfigOverride:  validatePath(log, buildFS, buildOpts.Tsconfig),
+		Plugins:           validatePlugins(log, buildOpts.Plugins),
+		Cancel:            &config.CancelFlag{},
+
+		PreloadDynamicImports: buildOpts.PreloadDynamicImports,
+
+		WarnAboutCircularImports: buildOpts.WarnCircularImports,
+		AllowedCircularImports:   validateAllowedCircularImports(log, buildFS, buildOpts.AllowCircularImports),
 	}
+	validateDrop(log, &options, buildOpts.Drop)
+
+	// Entry points are made absolute by the bundler so that plugins can resolve
+	// them first
+	entryPaths := buildOpts.EntryPoints
 	entryPathCount := len(buildOpts.EntryPoints)
 	if buildOpts.Stdin != nil {
 		entryPathCount++
@@ -420,7 +659,7 @@ func buildImpl(buildOpts BuildOptions) BuildResult {
 			Loader:        validateLoader(buildOpts.Stdin.Loader),
 			Contents:      buildOpts.Stdin.Contents,
 			SourceFile:    buildOpts.Stdin.Sourcefile,
-			AbsResolveDir: validatePath(log, realFS, buildOpts.Stdin.ResolveDir),
+			AbsResolveDir: validatePath(log, buildFS, buildOpts.Stdin.ResolveDir),
 		}
 	}
 
@@ -434,7 +673,7 @@ func buildImpl(buildOpts BuildOptions) BuildResult {
 		log.AddError(nil, ast.Loc{}, "Cannot use both \"outfile\" and \"outdir\"")
 	} else if options.AbsOutputFile != "" {
 		// If the output file is specified, use it to derive the output directory
-		options.AbsOutputDir = realFS.Dir(options.AbsOutputFile)
+		options.AbsOutputDir = buildFS.Dir(options.AbsOutputFile)
 	} else if options.AbsOutputDir == "" {
 		options.WriteToStdout = true
 
@@ -445,6 +684,12 @@ func buildImpl(buildOpts BuildOptions) BuildResult {
 		if options.AbsMetadataFile != "" {
 			log.AddError(nil, ast.Loc{}, "Cannot use \"metafile\" without an output path")
 		}
+		if options.AbsManifestFile != "" {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"manifest\" without an output path")
+		}
+		if buildOpts.Depfile != "" {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"depfile\" without an output path")
+		}
 		for _, loader := range options.ExtensionToLoader {
 			if loader == config.LoaderFile {
 				log.AddError(nil, ast.Loc{}, "Cannot use the \"file\" loader without an output path")
@@ -454,7 +699,25 @@ func buildImpl(buildOpts BuildOptions) BuildResult {
 
 		// Use the current directory as the output directory instead of an empty
 		// string because external modules with relative paths need a base directory.
-		options.AbsOutputDir = realFS.Cwd()
+		options.AbsOutputDir = buildFS.Cwd()
+	}
+
+	// Output files are written using the real file system, so writing the
+	// output of a build that reads from another file system would mix the two
+	if buildOpts.Write && buildOpts.FS != nil {
+		if _, ok := buildOpts.FS.(publicFS); !ok {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"write\" with a custom \"fs\"")
+		}
+	}
+
+	if buildOpts.CleanOutdir {
+		if !buildOpts.Write {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"cleanOutdir\" without \"write\"")
+		} else if buildOpts.Outdir == "" {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"cleanOutdir\" without \"outdir\"")
+		} else if isInsideDir(buildFS, options.AbsOutputDir, buildFS.Cwd()) {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"cleanOutdir\" when the output directory contains the current directory")
+		}
 	}
 
 	if !options.IsBundling {
@@ -475,50 +738,316 @@ func buildImpl(buildOpts BuildOptions) BuildResult {
 		}
 	}
 
-	// Code splitting is experimental and currently only enabled for ES6 modules
-	if options.CodeSplitting && options.OutputFormat != config.FormatESModule {
-		log.AddError(nil, ast.Loc{}, "Splitting currently only works with the \"esm\" format")
+	// Chunks are computed by the bundler, so there aren't any without bundling
+	if options.CodeSplitting && !options.IsBundling {
+		log.AddError(nil, ast.Loc{}, "Cannot use \"splitting\" without \"bundle\"")
 	}
+	if !options.CodeSplitting {
+		if len(options.ManualChunks) > 0 {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"manualChunks\" without \"splitting\"")
+		}
+		if options.MinChunkSize != 0 {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"minChunkSize\" without \"splitting\"")
+		}
+		if options.PreloadDynamicImports {
+			log.AddError(nil, ast.Loc{}, "Cannot use \"preloadDynamicImports\" without \"splitting\"")
+		}
+	} else if options.MinChunkSize < 0 {
+		log.AddError(nil, ast.Loc{}, "The \"minChunkSize\" setting must not be negative")
+	}
+	if options.PreloadDynamicImports && options.OutputFormat != config.FormatESModule {
+		log.AddError(nil, ast.Loc{}, "Preloading dynamic imports only works with the \"esm\" format")
+	}
+
+	return options, entryPaths
+}
+
+func buildImpl(ctx context.Context, buildOpts BuildOptions) BuildResult {
+	log := newBuildLog(buildOpts)
+	buildFS := validateFS(buildOpts.FS)
+	options, entryPaths := validateBuildOptions(log, buildFS, buildOpts)
 
 	var outputFiles []OutputFile
+	var mangleCache map[string]interface{}
+	var metafile []byte
+	var dependencies []string
+
+	// The metadata is generated under a placeholder path if it's only returned
+	returnedMetafilePath := ""
+	if buildOpts.ReturnMetafile && !log.HasErrors() {
+		if options.AbsMetadataFile == "" {
+			options.AbsMetadataFile = buildFS.Join(options.AbsOutputDir, "<metafile>")
+		}
+		returnedMetafilePath = options.AbsMetadataFile
+	}
+
+	// Forward cancellation of the context to the bundler
+	if done := ctx.Done(); done != nil {
+		buildDone := make(chan struct{})
+		defer close(buildDone)
+		go func() {
+			select {
+			case <-done:
+				options.Cancel.Cancel()
+			case <-buildDone:
+			}
+		}()
+	}
 
 	// Stop now if there were errors
 	if !log.HasErrors() {
-		// Scan over the bundle
-		resolver := resolver.NewResolver(realFS, log, options)
-		bundle := bundler.ScanBundle(log, realFS, resolver, entryPaths, options)
+		// Scan over the bundle, remembering which files and directories are read
+		recorder := fs.RecordReads(buildFS)
+		resolver := resolver.NewResolver(recorder, log, options)
+		bundle := bundler.ScanBundle(log, recorder, resolver, entryPaths, options)
+		dependencies = withoutAncestorsOfCwd(buildFS, recorder.Paths())
+
+		// Never clean a directory that has input files in it
+		if buildOpts.CleanOutdir {
+			for _, dependency := range dependencies {
+				if dependency != options.AbsOutputDir && isInsideDir(buildFS, options.AbsOutputDir, dependency) {
+					log.AddError(nil, ast.Loc{}, fmt.Sprintf(
+						"Cannot use \"cleanOutdir\" when the output directory contains the input %q", dependency))
+					break
+				}
+			}
+		}
 
 		// Stop now if there were errors
 		if !log.HasErrors() {
 			// Compile the bundle
 			results := bundle.Compile(log, options)
+			mangleCache = bundle.MangleCache()
 
 			// Return the results
-			outputFiles = make([]OutputFile, len(results))
-			for i, result := range results {
+			outputFiles = make([]OutputFile, 0, len(results))
+			for _, result := range results {
+				if result.AbsPath == returnedMetafilePath {
+					metafile = result.Contents
+					if buildOpts.Metafile == "" {
+						continue
+					}
+				}
 				if options.WriteToStdout {
 					result.AbsPath = "<stdout>"
 				}
-				outputFiles[i] = OutputFile{
+				outputFiles = append(outputFiles, OutputFile{
 					Path:     result.AbsPath,
 					Contents: result.Contents,
-				}
+				})
+			}
+
+			// Also generate the dependency file if necessary
+			if buildOpts.Depfile != "" {
+				outputFiles = append(outputFiles, OutputFile{
+					Path:     validatePath(log, buildFS, buildOpts.Depfile),
+					Contents: depfileContents(buildFS, outputFiles, dependencies),
+				})
 			}
 		}
 	}
 
+	// A cancelled build may be incomplete, so don't return any of it
+	didCancel := options.Cancel.DidCancel()
+	if didCancel {
+		log.AddError(nil, ast.Loc{}, BuildCancelledText)
+		outputFiles = nil
+		mangleCache = nil
+		metafile = nil
+		dependencies = nil
+	}
+
+	// Only write the output files if the build succeeded
+	if buildOpts.Write && !options.WriteToStdout && !log.HasErrors() {
+		writeOutputFiles(log, outputFiles)
+		if buildOpts.CleanOutdir {
+			cleanOutdir(log, buildFS, options.AbsOutputDir, outputFiles)
+		}
+	}
+
 	msgs := log.Done()
+	errors := messagesOfKind(logging.Error, msgs)
+
+	// Errors from earlier in a cancelled build may just be a consequence of
+	// the cancellation (e.g. a plugin that was interrupted), so drop them
+	if didCancel {
+		errors = []Message{{Text: BuildCancelledText}}
+	}
+
 	return BuildResult{
-		Errors:      messagesOfKind(logging.Error, msgs),
-		Warnings:    messagesOfKind(logging.Warning, msgs),
-		OutputFiles: outputFiles,
+		Errors:       errors,
+		Warnings:     messagesOfKind(logging.Warning, msgs),
+		OutputFiles:  outputFiles,
+		MangleCache:  mangleCache,
+		Metafile:     metafile,
+		Dependencies: dependencies,
+	}
+}
+
+func writeOutputFiles(log logging.Log, outputFiles []OutputFile) {
+	for _, outputFile := range outputFiles {
+		// Skip files that haven't changed to avoid triggering file watchers
+		if contents, err := ioutil.ReadFile(outputFile.Path); err == nil && bytes.Equal(contents, outputFile.Contents) {
+			continue
+		}
+
+		dir := filepath.Dir(outputFile.Path)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.AddError(nil, ast.Loc{}, fmt.Sprintf("Failed to create output directory: %s", err.Error()))
+			continue
+		}
+
+		// Write to a temporary file in the same directory first and then rename
+		// it over the output file so nothing ever sees a partially-written file
+		if err := writeFileAtomically(dir, outputFile); err != nil {
+			log.AddError(nil, ast.Loc{}, fmt.Sprintf("Failed to write to output file: %s", err.Error()))
+		}
+	}
+}
+
+func writeFileAtomically(dir string, outputFile OutputFile) error {
+	temp, err := ioutil.TempFile(dir, "."+filepath.Base(outputFile.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(outputFile.Contents)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), outputFile.Path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// This file in the output directory lists the output files of the previous
+// build that used "CleanOutdir", one relative path per line
+const cleanOutdirRecord = ".esbuild-outputs"
+
+// This deletes the output files of the previous build that this build didn't
+// generate again. Only files listed in the record written by the previous
+// build are deleted, so files that other tools put in the output directory
+// are left alone. Directories that end up empty are deleted too.
+func cleanOutdir(log logging.Log, buildFS fs.FS, absOutputDir string, outputFiles []OutputFile) {
+	absOutputDir = filepath.Clean(absOutputDir)
+	recordPath := filepath.Join(absOutputDir, cleanOutdirRecord)
+	keep := make(map[string]bool)
+	for _, outputFile := range outputFiles {
+		keep[filepath.Clean(outputFile.Path)] = true
+	}
+
+	if contents, err := ioutil.ReadFile(recordPath); err == nil {
+		for _, relPath := range strings.Split(string(contents), "\n") {
+			if relPath == "" {
+				continue
+			}
+
+			// Ignore anything that isn't inside the output directory
+			absPath := filepath.Join(absOutputDir, filepath.FromSlash(relPath))
+			if absPath == absOutputDir || !isInsideDir(buildFS, absOutputDir, absPath) {
+				continue
+			}
+			if keep[absPath] {
+				continue
+			}
+			if err := os.Remove(absPath); err != nil && !os.IsNotExist(err) {
+				log.AddError(nil, ast.Loc{}, fmt.Sprintf("Failed to remove stale output file: %s", err.Error()))
+				continue
+			}
+
+			// Removing a directory that isn't empty fails, which stops the loop
+			for dir := filepath.Dir(absPath); dir != absOutputDir && os.Remove(dir) == nil; dir = filepath.Dir(dir) {
+			}
+		}
 	}
+
+	// Remember the output files so the next build can clean them up
+	sb := strings.Builder{}
+	for _, outputFile := range outputFiles {
+		if relPath, ok := buildFS.Rel(absOutputDir, outputFile.Path); ok && isInsideDir(buildFS, absOutputDir, outputFile.Path) {
+			sb.WriteString(filepath.ToSlash(relPath))
+			sb.WriteByte('\n')
+		}
+	}
+	record := OutputFile{Path: recordPath, Contents: []byte(sb.String())}
+	if err := writeFileAtomically(absOutputDir, record); err != nil {
+		log.AddError(nil, ast.Loc{}, fmt.Sprintf("Failed to write to output file: %s", err.Error()))
+	}
+}
+
+// This returns true if "path" is "dir" or is somewhere inside of it
+func isInsideDir(fs fs.FS, dir string, path string) bool {
+	relPath, ok := fs.Rel(dir, path)
+	return ok && relPath != ".." && !strings.HasPrefix(relPath, "../") && !strings.HasPrefix(relPath, "..\\")
+}
+
+// The resolver lists the current directory and every directory above it when
+// searching for "node_modules" and "tsconfig.json". These change whenever
+// anything next to them changes (including the output files), so using them
+// as dependencies would make every build look out of date.
+func withoutAncestorsOfCwd(buildFS fs.FS, paths []string) []string {
+	cwd := buildFS.Cwd()
+	end := 0
+	for _, path := range paths {
+		if isInsideDir(buildFS, path, cwd) {
+			continue
+		}
+		paths[end] = path
+		end++
+	}
+	return paths[:end]
+}
+
+// This generates a dependency file in the format used by Make and Ninja. All
+// output files are targets that depend on every file and directory read by
+// the build. Paths are relative to the current directory when possible.
+func depfileContents(buildFS fs.FS, outputFiles []OutputFile, dependencies []string) []byte {
+	escape := func(absPath string) string {
+		if relPath, ok := buildFS.Rel(buildFS.Cwd(), absPath); ok && isInsideDir(buildFS, buildFS.Cwd(), absPath) {
+			absPath = relPath
+		}
+
+		// Backslashes are path separators on Windows, so only escape the
+		// characters that would otherwise end or comment out the path
+		sb := strings.Builder{}
+		for _, c := range absPath {
+			switch c {
+			case ' ', '#':
+				sb.WriteByte('\\')
+			case '$':
+				sb.WriteByte('$')
+			}
+			sb.WriteRune(c)
+		}
+		return sb.String()
+	}
+
+	sb := strings.Builder{}
+	for i, outputFile := range outputFiles {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(escape(outputFile.Path))
+	}
+	sb.WriteByte(':')
+	for _, dependency := range dependencies {
+		sb.WriteString(" \\\n  ")
+		sb.WriteString(escape(dependency))
+	}
+	sb.WriteByte('\n')
+	return []byte(sb.String())
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Transform API
 
-func transformImpl(input string, transformOpts TransformOptions) TransformResult {
+func newTransformLog(transformOpts TransformOptions) logging.Log {
 	var log logging.Log
 	if transformOpts.LogLevel == LogLevelSilent {
 		log = logging.NewDeferLog()
@@ -530,8 +1059,12 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 			LogLevel:      validateLogLevel(transformOpts.LogLevel),
 		})
 	}
+	return log
+}
 
-	// Convert and validate the transformOpts
+// This converts and validates everything except the input itself, which
+// includes "Sourcefile" and "Loader"
+func validateTransformOptions(log logging.Log, transformOpts TransformOptions) config.Options {
 	options := config.Options{
 		UnsupportedFeatures: validateFeatures(log, transformOpts.Target, transformOpts.Engines),
 		Strict:              validateStrict(transformOpts.Strict),
@@ -544,23 +1077,46 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 		MangleSyntax:      transformOpts.MinifySyntax,
 		RemoveWhitespace:  transformOpts.MinifyWhitespace,
 		MinifyIdentifiers: transformOpts.MinifyIdentifiers,
-		AbsOutputFile:     transformOpts.Sourcefile + "-out",
-		Stdin: &config.StdinInfo{
-			Loader:     validateLoader(transformOpts.Loader),
-			Contents:   input,
-			SourceFile: transformOpts.Sourcefile,
-		},
+		MangleProps:       validateRegex(log, "mangle props", transformOpts.MangleProps),
+		ReserveProps:      validateRegex(log, "reserve props", transformOpts.ReserveProps),
+		MangleCache:       validateMangleCache(log, transformOpts.MangleCache),
+		OutputFormat:      validateFormat(transformOpts.Format),
+		ModuleName:        transformOpts.GlobalName,
 	}
+	validateDrop(log, &options, transformOpts.Drop)
 	if options.SourceMap == config.SourceMapLinkedWithComment {
 		// Linked source maps don't make sense because there's no output file name
 		log.AddError(nil, ast.Loc{}, "Cannot transform with linked source maps")
 	}
+	return options
+}
+
+func transformImpl(input string, transformOpts TransformOptions) TransformResult {
+	log := newTransformLog(transformOpts)
+	options := validateTransformOptions(log, transformOpts)
+	return transformWithOptions(log, options, TransformInput{
+		Contents:   input,
+		Sourcefile: transformOpts.Sourcefile,
+		Loader:     transformOpts.Loader,
+	})
+}
+
+// The options have already been validated. They are only read, so this can
+// be called concurrently with the same options.
+func transformWithOptions(log logging.Log, options config.Options, input TransformInput) TransformResult {
+	options.AbsOutputFile = input.Sourcefile + "-out"
+	options.Stdin = &config.StdinInfo{
+		Loader:     validateLoader(input.Loader),
+		Contents:   input.Contents,
+		SourceFile: input.Sourcefile,
+	}
 	if options.SourceMap != config.SourceMapNone && options.Stdin.SourceFile == "" {
 		log.AddError(nil, ast.Loc{},
 			"Must use \"sourcefile\" with \"sourcemap\" to set the original file name")
 	}
 
 	var results []bundler.OutputFile
+	var mangleCache map[string]interface{}
 
 	// Stop now if there were errors
 	if !log.HasErrors() {
@@ -573,6 +1129,7 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 		if !log.HasErrors() {
 			// Compile the bundle
 			results = bundle.Compile(log, options)
+			mangleCache = bundle.MangleCache()
 		}
 	}
 
@@ -598,5 +1155,319 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 		Warnings:    messagesOfKind(logging.Warning, msgs),
 		JS:          js,
 		JSSourceMap: jsSourceMap,
+		MangleCache: mangleCache,
+	}
+}
+
+func transformManyImpl(inputs []TransformInput, transformOpts TransformOptions) []TransformResult {
+	results := make([]TransformResult, len(inputs))
+
+	// Validate the options once for all inputs. Problems with the options are
+	// reported in the result for every input.
+	log := newTransformLog(transformOpts)
+	options := validateTransformOptions(log, transformOpts)
+	msgs := log.Done()
+	errors := messagesOfKind(logging.Error, msgs)
+	warnings := messagesOfKind(logging.Warning, msgs)
+	if len(errors) > 0 {
+		for i := range results {
+			results[i] = TransformResult{Errors: errors, Warnings: warnings}
+		}
+		return results
+	}
+
+	// Transform the inputs in parallel, but don't use more goroutines than
+	// there are processors since each transform is CPU-bound
+	workerCount := runtime.GOMAXPROCS(0)
+	if workerCount > len(inputs) {
+		workerCount = len(inputs)
+	}
+	indices := make(chan int)
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(workerCount)
+	for i := 0; i < workerCount; i++ {
+		go func() {
+			for index := range indices {
+				result := transformWithOptions(newTransformLog(transformOpts), options, inputs[index])
+				result.Warnings = append(append([]Message{}, warnings...), result.Warnings...)
+				results[index] = result
+			}
+			waitGroup.Done()
+		}()
+	}
+	for i := range inputs {
+		indices <- i
+	}
+	close(indices)
+	waitGroup.Wait()
+	return results
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Why API
+
+func whyImpl(module string, buildOpts BuildOptions) WhyResult {
+	log := newBuildLog(buildOpts)
+	buildFS := validateFS(buildOpts.FS)
+	options, entryPaths := validateBuildOptions(log, buildFS, buildOpts)
+	if !options.IsBundling {
+		log.AddError(nil, ast.Loc{}, "Cannot use \"why\" without \"bundle\"")
+	}
+
+	var modules []WhyModule
+
+	// Stop now if there were errors
+	if !log.HasErrors() {
+		// Scan over the bundle
+		resolver := resolver.NewResolver(buildFS, log, options)
+		bundle := bundler.ScanBundle(log, buildFS, resolver, entryPaths, options)
+
+		// Stop now if there were errors
+		if !log.HasErrors() {
+			for _, m := range bundle.Why(log, options, module) {
+				result := WhyModule{Path: m.PrettyPath, Notes: m.Notes}
+				for _, chain := range m.ImportChains {
+					imports := []WhyImport{}
+					for _, step := range chain {
+						imports = append(imports, WhyImport{Location: *locationOrNil(step.Location), Path: step.Path})
+					}
+					result.ImportChains = append(result.ImportChains, imports)
+				}
+				for _, loc := range m.SideEffects {
+					result.SideEffects = append(result.SideEffects, *locationOrNil(loc))
+				}
+				modules = append(modules, result)
+			}
+			if len(modules) == 0 {
+				log.AddError(nil, ast.Loc{}, fmt.Sprintf("No module in the bundle matches %q", module))
+			}
+		}
+	}
+
+	msgs := log.Done()
+	return WhyResult{
+		Errors:   messagesOfKind(logging.Error, msgs),
+		Warnings: messagesOfKind(logging.Warning, msgs),
+		Modules:  modules,
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Scan API
+
+func scanImpl(input string, loader Loader) ScanResult {
+	log := logging.NewDeferLog()
+	var module bundler.ScannedModule
+
+	switch loader {
+	case LoaderJS, LoaderJSX, LoaderTS, LoaderTSX:
+		source := logging.Source{
+			KeyPath:    ast.Path{Text: "<stdin>"},
+			PrettyPath: "<stdin>",
+			Contents:   input,
+		}
+		module = bundler.ScanFile(log, source, validateLoader(loader), config.Options{})
+
+	default:
+		log.AddError(nil, ast.Loc{}, "Only the \"js\", \"jsx\", \"ts\", and \"tsx\" loaders can be scanned")
+	}
+
+	msgs := log.Done()
+	return ScanResult{
+		Errors:         messagesOfKind(logging.Error, msgs),
+		Warnings:       messagesOfKind(logging.Warning, msgs),
+		Imports:        convertScannedImports(module.Imports),
+		Exports:        module.Exports,
+		UsesCommonJS:   module.UsesCommonJS,
+		UsesImportMeta: module.UsesImportMeta,
+	}
+}
+
+func scanBuildImpl(buildOpts BuildOptions) ScanBuildResult {
+	log := newBuildLog(buildOpts)
+	buildFS := validateFS(buildOpts.FS)
+	options, entryPaths := validateBuildOptions(log, buildFS, buildOpts)
+	if !options.IsBundling {
+		log.AddError(nil, ast.Loc{}, "Cannot use \"scan\" without \"bundle\"")
+	}
+
+	var modules []ScanModule
+
+	// Stop now if there were errors
+	if !log.HasErrors() {
+		// Scan over the bundle
+		resolver := resolver.NewResolver(buildFS, log, options)
+		bundle := bundler.ScanBundle(log, buildFS, resolver, entryPaths, options)
+
+		// Stop now if there were errors
+		if !log.HasErrors() {
+			for _, m := range bundle.ScanModules() {
+				modules = append(modules, ScanModule{
+					Path:           m.PrettyPath,
+					IsEntryPoint:   m.IsEntryPoint,
+					Imports:        convertScannedImports(m.Imports),
+					Exports:        m.Exports,
+					UsesCommonJS:   m.UsesCommonJS,
+					UsesImportMeta: m.UsesImportMeta,
+				})
+			}
+		}
+	}
+
+	msgs := log.Done()
+	return ScanBuildResult{
+		Errors:   messagesOfKind(logging.Error, msgs),
+		Warnings: messagesOfKind(logging.Warning, msgs),
+		Modules:  modules,
+	}
+}
+
+func convertScannedImports(scanned []bundler.ScannedImport) []ScanImport {
+	var imports []ScanImport
+	for _, record := range scanned {
+		var kind ImportKind
+		switch record.Kind {
+		case bundler.ScannedImportStmt:
+			kind = ImportStatement
+		case bundler.ScannedReExport:
+			kind = ImportReExport
+		case bundler.ScannedRequire:
+			kind = ImportRequire
+		case bundler.ScannedDynamicImport:
+			kind = ImportDynamic
+		}
+		imports = append(imports, ScanImport{
+			Kind:         kind,
+			Path:         record.Path,
+			Location:     *locationOrNil(record.Location),
+			ResolvedPath: record.ResolvedPath,
+		})
+	}
+	return imports
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Parse API
+
+func parseASTImpl(input string, parseOpts ParseASTOptions) ParseASTResult {
+	log := newTransformLog(TransformOptions{
+		Color:      parseOpts.Color,
+		ErrorLimit: parseOpts.ErrorLimit,
+		LogLevel:   parseOpts.LogLevel,
+	})
+	var js []byte
+
+	sourcefile := parseOpts.Sourcefile
+	if sourcefile == "" {
+		sourcefile = "<stdin>"
+	}
+	source := logging.Source{
+		KeyPath:    ast.Path{Text: sourcefile},
+		PrettyPath: sourcefile,
+		Contents:   input,
+	}
+
+	// Syntax is preserved so the AST matches the input instead of the output
+	options := config.Options{PreserveSyntax: true}
+	switch parseOpts.Loader {
+	case LoaderJS:
+	case LoaderJSX:
+		options.JSX.Parse = true
+	case LoaderTS:
+		options.TS.Parse = true
+	case LoaderTSX:
+		options.TS.Parse = true
+		options.JSX.Parse = true
+	default:
+		log.AddError(nil, ast.Loc{}, "Only the \"js\", \"jsx\", \"ts\", and \"tsx\" loaders can be parsed")
+	}
+
+	// Stop now if there were errors
+	if !log.HasErrors() {
+		if tree, ok := parser.Parse(log, source, options); ok {
+			js = printer.PrintESTree(tree, source)
+		}
+	}
+
+	msgs := log.Done()
+	return ParseASTResult{
+		Errors:   messagesOfKind(logging.Error, msgs),
+		Warnings: messagesOfKind(logging.Warning, msgs),
+		AST:      js,
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// File System API
+
+func validateFS(buildFS FS) fs.FS {
+	if buildFS == nil {
+		return fs.RealFS()
+	}
+	if wrapper, ok := buildFS.(publicFS); ok {
+		return wrapper.FS
+	}
+	return internalFS{buildFS}
+}
+
+func realFSImpl() FS {
+	return publicFS{fs.RealFS()}
+}
+
+// This exposes a file system from the public API to the bundler
+type internalFS struct {
+	FS
+}
+
+func (internal internalFS) ReadDirectory(path string) map[string]fs.Entry {
+	entries := internal.FS.ReadDirectory(path)
+	if entries == nil {
+		return nil
+	}
+	result := make(map[string]fs.Entry, len(entries))
+	for name, entry := range entries {
+		switch entry.Kind {
+		case FSEntryDir:
+			result[name] = fs.Entry{Kind: fs.DirEntry, Symlink: entry.Symlink}
+		case FSEntryFile:
+			result[name] = fs.Entry{Kind: fs.FileEntry, Symlink: entry.Symlink}
+		}
+	}
+	return result
+}
+
+// This exposes a file system from the bundler to the public API
+type publicFS struct {
+	fs.FS
+}
+
+func (public publicFS) ReadDirectory(path string) map[string]FSEntry {
+	entries := public.FS.ReadDirectory(path)
+	if entries == nil {
+		return nil
+	}
+	result := make(map[string]FSEntry, len(entries))
+	for name, entry := range entries {
+		switch entry.Kind {
+		case fs.DirEntry:
+			result[name] = FSEntry{Kind: FSEntryDir, Symlink: entry.Symlink}
+		case fs.FileEntry:
+			result[name] = FSEntry{Kind: FSEntryFile, Symlink: entry.Symlink}
+		}
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Analyze API
+
+func analyzeMetafileImpl(metafile string, options AnalyzeMetafileOptions) (string, error) {
+	outputs, err := analyzer.Analyze([]byte(metafile))
+	if err != nil {
+		return "", err
+	}
+	if options.HTML {
+		return analyzer.HTML(outputs), nil
 	}
+	return analyzer.Text(outputs), nil
 }
@@ -0,0 +1,618 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func assertEqual(t *testing.T, a interface{}, b interface{}) {
+	t.Helper()
+	if a != b {
+		t.Fatalf("%s != %s", a, b)
+	}
+}
+
+// An in-memory file system that uses forward slashes. Every path that a build
+// asks about is recorded so tests can check that nothing else was touched.
+type memFS struct {
+	files    map[string]string
+	symlinks map[string]string
+	cwd      string
+
+	mutex sync.Mutex
+	reads []string
+}
+
+func (fs *memFS) record(p string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.reads = append(fs.reads, p)
+}
+
+// Paths inside a symlinked directory are read from the directory it links to
+func (fs *memFS) realPath(p string) string {
+	for link, target := range fs.symlinks {
+		if p == link {
+			return target
+		}
+		if strings.HasPrefix(p, link+"/") {
+			return target + p[len(link):]
+		}
+	}
+	return p
+}
+
+func (fs *memFS) ReadDirectory(dir string) map[string]FSEntry {
+	fs.record(dir)
+	dir = fs.realPath(dir)
+	entries := make(map[string]FSEntry)
+	add := func(file string, kind FSEntryKind, symlink string) {
+		if path.Dir(file) == dir && file != dir {
+			entries[path.Base(file)] = FSEntry{Kind: kind, Symlink: symlink}
+		}
+		for parent := path.Dir(file); parent != "/"; parent = path.Dir(parent) {
+			if path.Dir(parent) == dir {
+				entries[path.Base(parent)] = FSEntry{Kind: FSEntryDir}
+			}
+		}
+	}
+	for file := range fs.files {
+		add(file, FSEntryFile, "")
+	}
+	for link, target := range fs.symlinks {
+		kind := FSEntryFile
+		if _, ok := fs.files[target]; !ok {
+			kind = FSEntryDir
+		}
+		add(link, kind, target)
+	}
+	return entries
+}
+
+func (fs *memFS) ReadFile(file string) (string, bool) {
+	fs.record(file)
+	contents, ok := fs.files[fs.realPath(file)]
+	return contents, ok
+}
+
+func (fs *memFS) Abs(p string) (string, bool) {
+	if !path.IsAbs(p) {
+		p = path.Join(fs.cwd, p)
+	}
+	return path.Clean(p), true
+}
+
+func (fs *memFS) Dir(p string) string         { return path.Dir(p) }
+func (fs *memFS) Base(p string) string        { return path.Base(p) }
+func (fs *memFS) Ext(p string) string         { return path.Ext(p) }
+func (fs *memFS) Join(parts ...string) string { return path.Clean(path.Join(parts...)) }
+func (fs *memFS) Cwd() string                 { return fs.cwd }
+func (fs *memFS) Rel(base, target string) (string, bool) {
+	// Like "filepath.Rel", both paths must be absolute or both must be relative
+	if path.IsAbs(base) != path.IsAbs(target) {
+		return "", false
+	}
+	split := func(p string) []string {
+		p = strings.Trim(path.Clean(p), "/")
+		if p == "" || p == "." {
+			return nil
+		}
+		return strings.Split(p, "/")
+	}
+	baseParts, targetParts := split(base), split(target)
+	common := 0
+	for common < len(baseParts) && common < len(targetParts) && baseParts[common] == targetParts[common] {
+		common++
+	}
+	var rel []string
+	for range baseParts[common:] {
+		rel = append(rel, "..")
+	}
+	rel = append(rel, targetParts[common:]...)
+	if len(rel) == 0 {
+		return ".", true
+	}
+	return strings.Join(rel, "/"), true
+}
+
+func TestCancelledBuild(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := BuildWithContext(ctx, BuildOptions{
+		FS: &memFS{
+			files: map[string]string{
+				"/src/entry.js":   "import {fn} from './foo'\nfn()",
+				"/src/missing.js": "import './does-not-exist'",
+				"/src/foo.js":     "export function fn() {}",
+			},
+			cwd: "/src",
+		},
+		EntryPoints: []string{"entry.js", "missing.js"},
+		Bundle:      true,
+		Outdir:      "/out",
+		LogLevel:    LogLevelSilent,
+	})
+	assertEqual(t, len(result.Errors), 1)
+	assertEqual(t, result.Errors[0].Text, BuildCancelledText)
+	assertEqual(t, len(result.OutputFiles), 0)
+}
+
+func TestCancelledBuildWhileRunning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	loading := make(chan struct{})
+	go func() {
+		<-loading
+		cancel()
+	}()
+
+	// This plugin blocks the build until it's cancelled
+	result := BuildWithContext(ctx, BuildOptions{
+		FS: &memFS{
+			files: map[string]string{
+				"/src/entry.js": "import {fn} from './slow'\nfn()",
+				"/src/slow.js":  "",
+			},
+			cwd: "/src",
+		},
+		EntryPoints: []string{"entry.js"},
+		Bundle:      true,
+		Outdir:      "/out",
+		LogLevel:    LogLevelSilent,
+		Plugins: []Plugin{{
+			Name: "block",
+			Setup: func(build PluginBuild) {
+				build.OnLoad(OnLoadOptions{Filter: `slow\.js$`}, func(args OnLoadArgs) (OnLoadResult, error) {
+					close(loading)
+					<-ctx.Done()
+					contents := "export function fn() {}"
+					return OnLoadResult{Contents: &contents}, nil
+				})
+			},
+		}},
+	})
+	assertEqual(t, len(result.Errors), 1)
+	assertEqual(t, result.Errors[0].Text, BuildCancelledText)
+	assertEqual(t, len(result.OutputFiles), 0)
+}
+
+func TestDepfile(t *testing.T) {
+	result := Build(BuildOptions{
+		FS: &memFS{
+			files: map[string]string{
+				"/project/src/entry.js":               "import './my file'\nimport './#/x'\nimport './$y'\nimport 'pkg'",
+				"/project/src/my file.js":             "",
+				"/project/src/#/x.js":                 "",
+				"/project/src/$y.js":                  "",
+				"/project/node_modules/pkg/index.js":  "",
+				"/project/node_modules/pkg/README.md": "",
+			},
+			cwd: "/project",
+		},
+		EntryPoints: []string{"src/entry.js"},
+		Bundle:      true,
+		Outfile:     "out dir/out.js",
+		Depfile:     "out dir/out.d",
+		LogLevel:    LogLevelSilent,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors[0].Text)
+	}
+	assertEqual(t, strings.Join(result.Dependencies, "\n"), strings.Join([]string{
+		"/project/node_modules",
+		"/project/node_modules/pkg",
+		"/project/node_modules/pkg/index.js",
+		"/project/src",
+		"/project/src/#",
+		"/project/src/#/x.js",
+		"/project/src/$y.js",
+		"/project/src/entry.js",
+		"/project/src/my file.js",
+	}, "\n"))
+	assertEqual(t, len(result.OutputFiles), 2)
+	assertEqual(t, result.OutputFiles[1].Path, "/project/out dir/out.d")
+	assertEqual(t, string(result.OutputFiles[1].Contents), `out\ dir/out.js: \
+  node_modules \
+  node_modules/pkg \
+  node_modules/pkg/index.js \
+  src \
+  src/\# \
+  src/\#/x.js \
+  src/$$y.js \
+  src/entry.js \
+  src/my\ file.js
+`)
+}
+
+func TestBuildWithCustomFS(t *testing.T) {
+	// None of these paths exist on the real file system
+	memFS := &memFS{
+		files: map[string]string{
+			"/virtual/app/src/entry.js":          "import {x} from './alias'\nimport {y} from 'pkg'\nconsole.log(x, y)",
+			"/virtual/app/src/real.js":           "export let x = 1",
+			"/virtual/packages/pkg/package.json": `{ "main": "lib/main.js" }`,
+			"/virtual/packages/pkg/lib/main.js":  "export let y = 2",
+		},
+		symlinks: map[string]string{
+			"/virtual/app/src/alias.js":     "/virtual/app/src/real.js",
+			"/virtual/app/node_modules/pkg": "/virtual/packages/pkg",
+		},
+		cwd: "/virtual/app",
+	}
+	result := Build(BuildOptions{
+		FS:          memFS,
+		EntryPoints: []string{"src/entry.js"},
+		Bundle:      true,
+		Format:      FormatESModule,
+		Outdir:      "/virtual/app/out",
+		LogLevel:    LogLevelSilent,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors[0].Text)
+	}
+
+	// Symlinks are followed to the files they point to
+	assertEqual(t, len(result.OutputFiles), 1)
+	assertEqual(t, result.OutputFiles[0].Path, "/virtual/app/out/entry.js")
+	assertEqual(t, string(result.OutputFiles[0].Contents), `// src/real.js
+let x = 1;
+
+// ../packages/pkg/lib/main.js
+let y = 2;
+
+// src/entry.js
+console.log(x, y);
+`)
+
+	// A missing directory is reported as a resolve error, not read from disk
+	memFS.files["/virtual/app/src/broken.js"] = "import './missing/file'"
+	result = Build(BuildOptions{
+		FS:          memFS,
+		EntryPoints: []string{"src/broken.js"},
+		Bundle:      true,
+		Outdir:      "/virtual/app/out",
+		LogLevel:    LogLevelSilent,
+	})
+	assertEqual(t, len(result.Errors), 1)
+	assertEqual(t, result.Errors[0].Text, "Could not resolve \"./missing/file\"")
+
+	// Every read went through the in-memory file system
+	readMissing := false
+	for _, read := range memFS.reads {
+		if !strings.HasPrefix(read+"/", "/virtual/") && read != "/" {
+			t.Fatalf("Unexpected read of %q", read)
+		}
+		if read == "/virtual/app/src/missing" {
+			readMissing = true
+		}
+	}
+	assertEqual(t, readMissing, true)
+
+	// Nothing exists on the real file system
+	if _, err := os.Stat("/virtual"); !os.IsNotExist(err) {
+		t.Fatal("Expected \"/virtual\" to not exist on disk")
+	}
+}
+
+func TestWriteWithCustomFS(t *testing.T) {
+	result := Build(BuildOptions{
+		FS:          &memFS{files: map[string]string{"/virtual/entry.js": "console.log(1)"}, cwd: "/virtual"},
+		EntryPoints: []string{"entry.js"},
+		Outdir:      "/virtual/out",
+		Write:       true,
+		LogLevel:    LogLevelSilent,
+	})
+	assertEqual(t, len(result.Errors), 1)
+	assertEqual(t, result.Errors[0].Text, "Cannot use \"write\" with a custom \"fs\"")
+	assertEqual(t, len(result.OutputFiles), 0)
+	if _, err := os.Stat("/virtual"); !os.IsNotExist(err) {
+		t.Fatal("Expected \"/virtual\" to not exist on disk")
+	}
+}
+
+func TestSplittingWithoutBundle(t *testing.T) {
+	result := Build(BuildOptions{
+		FS:          &memFS{files: map[string]string{"/virtual/entry.js": "console.log(1)"}, cwd: "/virtual"},
+		EntryPoints: []string{"entry.js"},
+		Outdir:      "/virtual/out",
+		Format:      FormatESModule,
+		Splitting:   true,
+		LogLevel:    LogLevelSilent,
+	})
+	assertEqual(t, len(result.Errors), 2)
+	assertEqual(t, result.Errors[0].Text, "Cannot use \"format\" without \"bundle\"")
+	assertEqual(t, result.Errors[1].Text, "Cannot use \"splitting\" without \"bundle\"")
+}
+
+func TestManualChunksMatchPathsRelativeToCwd(t *testing.T) {
+	result := Build(BuildOptions{
+		FS: &memFS{files: map[string]string{
+			"/virtual/app/a.js":                     "import {x} from 'x'; import {y} from './lib/node_modules/y.js'; console.log(x, y)",
+			"/virtual/app/b.js":                     "import {x} from 'x'; import {y} from './lib/node_modules/y.js'; console.log(x, y)",
+			"/virtual/app/node_modules/x/index.js":  "export let x = 1",
+			"/virtual/app/lib/node_modules/y.js":    "export let y = 2",
+			"/virtual/node_modules/unused/index.js": "export let z = 3",
+		}, cwd: "/virtual/app"},
+		EntryPoints:  []string{"a.js", "b.js"},
+		Bundle:       true,
+		Splitting:    true,
+		Format:       FormatESModule,
+		Outdir:       "/virtual/app/out",
+		ManualChunks: map[string][]string{"vendor": {"node_modules/*"}},
+		LogLevel:     LogLevelSilent,
+	})
+	expectNoErrors(t, result)
+
+	// Only the package in the top-level "node_modules" directory is moved
+	contents := make(map[string]string)
+	for _, outputFile := range result.OutputFiles {
+		contents[outputFile.Path] = string(outputFile.Contents)
+	}
+	assertEqual(t, strings.Contains(contents["/virtual/app/out/vendor.js"], "// node_modules/x/index.js"), true)
+	assertEqual(t, strings.Contains(contents["/virtual/app/out/vendor.js"], "lib/node_modules/y.js"), false)
+}
+
+func TestWithoutAncestorsOfCwd(t *testing.T) {
+	memFS := &memFS{cwd: "/virtual/..app/src"}
+	paths := withoutAncestorsOfCwd(validateFS(memFS), []string{
+		"/",
+		"/virtual",
+		"/virtual/..app",
+		"/virtual/..app/src",
+		"/virtual/..app/src/entry.js",
+		"/virtual/..lib",
+	})
+	assertEqual(t, strings.Join(paths, ","), "/virtual/..app/src/entry.js,/virtual/..lib")
+}
+
+// These tests write to a temporary directory on the real file system
+func makeTempFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "esbuild-api-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, _ = filepath.EvalSymlinks(dir)
+	for relPath, contents := range files {
+		absPath := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(absPath, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func readTempFile(t *testing.T, absPath string) string {
+	t.Helper()
+	contents, err := ioutil.ReadFile(absPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func expectNoErrors(t *testing.T, result BuildResult) {
+	t.Helper()
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors[0].Text)
+	}
+}
+
+func TestWriteAtomically(t *testing.T) {
+	dir := makeTempFiles(t, map[string]string{"src/entry.js": "console.log(1)"})
+	defer os.RemoveAll(dir)
+	options := BuildOptions{
+		EntryPoints: []string{filepath.Join(dir, "src", "entry.js")},
+		Outdir:      filepath.Join(dir, "out"),
+		Write:       true,
+		LogLevel:    LogLevelSilent,
+	}
+	outPath := filepath.Join(dir, "out", "entry.js")
+	expectNoErrors(t, Build(options))
+	assertEqual(t, readTempFile(t, outPath), "console.log(1);\n")
+
+	// The new file is renamed over the old one instead of being written in
+	// place, so another link to the old file still has the old contents
+	linkPath := filepath.Join(dir, "link.js")
+	if err := os.Link(outPath, linkPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "src", "entry.js"), []byte("console.log(2)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectNoErrors(t, Build(options))
+	assertEqual(t, readTempFile(t, outPath), "console.log(2);\n")
+	assertEqual(t, readTempFile(t, linkPath), "console.log(1);\n")
+
+	// No temporary files are left behind
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(entries), 1)
+	assertEqual(t, entries[0].Name(), "entry.js")
+	assertEqual(t, entries[0].Mode().Perm(), os.FileMode(0644))
+}
+
+func TestWriteSkipsUnchangedFiles(t *testing.T) {
+	dir := makeTempFiles(t, map[string]string{"src/entry.js": "console.log(1)"})
+	defer os.RemoveAll(dir)
+	options := BuildOptions{
+		EntryPoints: []string{filepath.Join(dir, "src", "entry.js")},
+		Outdir:      filepath.Join(dir, "out"),
+		Write:       true,
+		LogLevel:    LogLevelSilent,
+	}
+	outPath := filepath.Join(dir, "out", "entry.js")
+	expectNoErrors(t, Build(options))
+
+	// Rebuilding with the same contents doesn't change the modification time
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(outPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	expectNoErrors(t, Build(options))
+	info, err := os.Stat(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, info.ModTime().Equal(past), true)
+
+	// Rebuilding with different contents does
+	if err := ioutil.WriteFile(filepath.Join(dir, "src", "entry.js"), []byte("console.log(2)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectNoErrors(t, Build(options))
+	info, err = os.Stat(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, info.ModTime().Equal(past), false)
+}
+
+func TestCleanOutdir(t *testing.T) {
+	dir := makeTempFiles(t, map[string]string{
+		"src/a.js":     "console.log('a')",
+		"src/sub/b.js": "console.log('b')",
+		"out/keep.txt": "not generated by esbuild",
+	})
+	defer os.RemoveAll(dir)
+	options := BuildOptions{
+		EntryPoints: []string{filepath.Join(dir, "src", "a.js"), filepath.Join(dir, "src", "sub", "b.js")},
+		Outdir:      filepath.Join(dir, "out"),
+		Write:       true,
+		CleanOutdir: true,
+		LogLevel:    LogLevelSilent,
+	}
+	expectNoErrors(t, Build(options))
+	assertEqual(t, readTempFile(t, filepath.Join(dir, "out", ".esbuild-outputs")), "a.js\nsub/b.js\n")
+
+	// Only the output files of the previous build are deleted
+	options.EntryPoints = options.EntryPoints[:1]
+	expectNoErrors(t, Build(options))
+	assertEqual(t, readTempFile(t, filepath.Join(dir, "out", ".esbuild-outputs")), "a.js\n")
+	assertEqual(t, readTempFile(t, filepath.Join(dir, "out", "a.js")), "console.log(\"a\");\n")
+	assertEqual(t, readTempFile(t, filepath.Join(dir, "out", "keep.txt")), "not generated by esbuild")
+	if _, err := os.Stat(filepath.Join(dir, "out", "sub")); !os.IsNotExist(err) {
+		t.Fatal("Expected the empty directory to be deleted")
+	}
+}
+
+func TestCleanOutdirContainingInputs(t *testing.T) {
+	dir := makeTempFiles(t, map[string]string{"src/entry.js": "console.log(1)"})
+	defer os.RemoveAll(dir)
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Build(BuildOptions{
+		EntryPoints: []string{filepath.Join(dir, "src", "entry.js")},
+		Outdir:      dir,
+		Write:       true,
+		CleanOutdir: true,
+		LogLevel:    LogLevelSilent,
+	})
+	assertEqual(t, len(result.Errors), 1)
+	assertEqual(t, result.Errors[0].Text, fmt.Sprintf(
+		"Cannot use \"cleanOutdir\" when the output directory contains the input %q", filepath.Join(dir, "src")))
+	assertEqual(t, readTempFile(t, filepath.Join(dir, "src", "entry.js")), "console.log(1)")
+
+	result = Build(BuildOptions{
+		EntryPoints: []string{filepath.Join(dir, "src", "entry.js")},
+		Outdir:      filepath.Dir(cwd),
+		Write:       true,
+		CleanOutdir: true,
+		LogLevel:    LogLevelSilent,
+	})
+	assertEqual(t, len(result.Errors), 1)
+	assertEqual(t, result.Errors[0].Text, "Cannot use \"cleanOutdir\" when the output directory contains the current directory")
+}
+
+func TestTransformMany(t *testing.T) {
+	inputs := make([]TransformInput, 100)
+	for i := range inputs {
+		inputs[i] = TransformInput{Contents: fmt.Sprintf("let x = %d", i)}
+	}
+	inputs[1] = TransformInput{Contents: "let x: number = 1", Loader: LoaderTS}
+	inputs[2] = TransformInput{Contents: "let x = <div/>", Loader: LoaderJSX}
+	inputs[3] = TransformInput{Contents: "let x = (", Sourcefile: "bad.js"}
+	results := TransformMany(inputs, TransformOptions{MinifyWhitespace: true, LogLevel: LogLevelSilent})
+
+	// Results are in the same order as the inputs
+	assertEqual(t, len(results), len(inputs))
+	assertEqual(t, string(results[0].JS), "let x=0;\n")
+	assertEqual(t, string(results[1].JS), "let x=1;\n")
+	assertEqual(t, string(results[2].JS), "let x=React.createElement(\"div\",null);\n")
+	for i := 4; i < len(inputs); i++ {
+		assertEqual(t, string(results[i].JS), fmt.Sprintf("let x=%d;\n", i))
+		assertEqual(t, len(results[i].Errors), 0)
+	}
+
+	// An error only affects the result for that input
+	assertEqual(t, len(results[3].Errors), 1)
+	assertEqual(t, results[3].Errors[0].Text, "Unexpected end of file")
+	assertEqual(t, results[3].Errors[0].Location.File, "bad.js")
+	assertEqual(t, len(results[3].JS), 0)
+}
+
+func TestTransformManySourceMaps(t *testing.T) {
+	results := TransformMany([]TransformInput{
+		{Contents: "let a = 1", Sourcefile: "a.js"},
+		{Contents: "let b = 2", Sourcefile: "b.js"},
+		{Contents: "let c = 3"},
+	}, TransformOptions{Sourcemap: SourceMapExternal, LogLevel: LogLevelSilent})
+
+	// Each input has its own source map
+	assertEqual(t, len(results[0].Errors), 0)
+	assertEqual(t, len(results[1].Errors), 0)
+	assertEqual(t, strings.Contains(string(results[0].JSSourceMap), `"sources": ["a.js"]`), true)
+	assertEqual(t, strings.Contains(string(results[1].JSSourceMap), `"sources": ["b.js"]`), true)
+
+	// Source maps need a file name, which is checked for each input
+	assertEqual(t, len(results[2].Errors), 1)
+	assertEqual(t, results[2].Errors[0].Text, "Must use \"sourcefile\" with \"sourcemap\" to set the original file name")
+}
+
+func TestTransformManyOptionErrors(t *testing.T) {
+	results := TransformMany([]TransformInput{
+		{Contents: "a"},
+		{Contents: "b"},
+	}, TransformOptions{JSXFactory: "not valid", LogLevel: LogLevelSilent})
+
+	// A problem with the options is reported for every input
+	assertEqual(t, len(results), 2)
+	for _, result := range results {
+		assertEqual(t, len(result.Errors), 1)
+		assertEqual(t, result.Errors[0].Text, "Invalid JSX factory: \"not valid\"")
+		assertEqual(t, len(result.JS), 0)
+	}
+}
+
+func TestManglePropsUnsupportedRegex(t *testing.T) {
+	result := Transform("x.foo_", TransformOptions{MangleProps: "foo(?=_)", LogLevel: LogLevelSilent})
+	assertEqual(t, len(result.Errors), 1)
+	assertEqual(t, result.Errors[0].Text, "The \"mangle props\" setting is not a valid Go regular expression: "+
+		"foo(?=_) (error parsing regexp: invalid or unsupported Perl syntax: `(?=`)")
+}
+
+func TestManglePropsCache(t *testing.T) {
+	result := Transform("x.foo_ = x.bar_ + x.baz_", TransformOptions{
+		MangleProps: "_$",
+		MangleCache: map[string]interface{}{"foo_": "z", "bar_": false},
+		LogLevel:    LogLevelSilent,
+	})
+	assertEqual(t, len(result.Errors), 0)
+	assertEqual(t, string(result.JS), "x.z = x.bar_ + x.a;\n")
+	assertEqual(t, fmt.Sprintf("%v", result.MangleCache), "map[bar_:false baz_:a foo_:z]")
+}
@@ -69,3 +69,9 @@ func ParseTransformOptions(osArgs []string) (options api.TransformOptions, err e
 	err = parseOptionsImpl(osArgs, nil, &options)
 	return
 }
+
+// This parses the name of a loader (e.g. "tsx") the same way the esbuild CLI
+// does for the "--loader" flag.
+func ParseLoader(text string) (api.Loader, error) {
+	return parseLoader(text)
+}
@@ -1,10 +1,10 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -38,6 +38,29 @@ func parseOptionsImpl(osArgs []string, buildOpts *api.BuildOptions, transformOpt
 		case arg == "--splitting" && buildOpts != nil:
 			buildOpts.Splitting = true
 
+		case arg == "--preload-dynamic-imports" && buildOpts != nil:
+			buildOpts.PreloadDynamicImports = true
+
+		case strings.HasPrefix(arg, "--manual-chunk:") && buildOpts != nil:
+			value := arg[len("--manual-chunk:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return fmt.Errorf("Missing \"=\": %q", value)
+			}
+			if buildOpts.ManualChunks == nil {
+				buildOpts.ManualChunks = make(map[string][]string)
+			}
+			name := value[:equals]
+			buildOpts.ManualChunks[name] = append(buildOpts.ManualChunks[name], value[equals+1:])
+
+		case strings.HasPrefix(arg, "--min-chunk-size=") && buildOpts != nil:
+			value := arg[len("--min-chunk-size="):]
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 0 {
+				return fmt.Errorf("Invalid minimum chunk size: %q", value)
+			}
+			buildOpts.MinChunkSize = size
+
 		case arg == "--minify":
 			if buildOpts != nil {
 				buildOpts.MinifySyntax = true
@@ -107,12 +130,68 @@ func parseOptionsImpl(osArgs []string, buildOpts *api.BuildOptions, transformOpt
 		case strings.HasPrefix(arg, "--resolve-extensions=") && buildOpts != nil:
 			buildOpts.ResolveExtensions = strings.Split(arg[len("--resolve-extensions="):], ",")
 
-		case strings.HasPrefix(arg, "--global-name=") && buildOpts != nil:
-			buildOpts.GlobalName = arg[len("--global-name="):]
+		case strings.HasPrefix(arg, "--global-name="):
+			if buildOpts != nil {
+				buildOpts.GlobalName = arg[len("--global-name="):]
+			} else {
+				transformOpts.GlobalName = arg[len("--global-name="):]
+			}
 
 		case strings.HasPrefix(arg, "--metafile=") && buildOpts != nil:
 			buildOpts.Metafile = arg[len("--metafile="):]
 
+		case strings.HasPrefix(arg, "--manifest=") && buildOpts != nil:
+			buildOpts.Manifest = arg[len("--manifest="):]
+
+		case arg == "--clean-outdir" && buildOpts != nil:
+			buildOpts.CleanOutdir = true
+
+		case strings.HasPrefix(arg, "--depfile=") && buildOpts != nil:
+			buildOpts.Depfile = arg[len("--depfile="):]
+
+		case arg == "--warn-circular-imports" && buildOpts != nil:
+			buildOpts.WarnCircularImports = true
+
+		case strings.HasPrefix(arg, "--allow-circular-import=") && buildOpts != nil:
+			paths := strings.Split(arg[len("--allow-circular-import="):], ",")
+			buildOpts.AllowCircularImports = append(buildOpts.AllowCircularImports, paths)
+
+		case arg == "--dedupe-packages" && buildOpts != nil:
+			buildOpts.DedupePackages = true
+
+		case strings.HasPrefix(arg, "--max-size=") && buildOpts != nil:
+			limit, err := parseSizeLimit(arg[len("--max-size="):])
+			if err != nil {
+				return err
+			}
+			buildOpts.SizeLimits = append(buildOpts.SizeLimits, limit)
+
+		case strings.HasPrefix(arg, "--max-size:") && buildOpts != nil:
+			value := arg[len("--max-size:"):]
+			equals := strings.LastIndexByte(value, '=')
+			if equals == -1 {
+				return fmt.Errorf("Missing \"=\": %q", value)
+			}
+			limit, err := parseSizeLimit(value[equals+1:])
+			if err != nil {
+				return err
+			}
+			limit.EntryPoint = value[:equals]
+			buildOpts.SizeLimits = append(buildOpts.SizeLimits, limit)
+
+		case strings.HasPrefix(arg, "--max-size-match:") && buildOpts != nil:
+			value := arg[len("--max-size-match:"):]
+			equals := strings.LastIndexByte(value, '=')
+			if equals == -1 {
+				return fmt.Errorf("Missing \"=\": %q", value)
+			}
+			limit, err := parseSizeLimit(value[equals+1:])
+			if err != nil {
+				return err
+			}
+			limit.Pattern = value[:equals]
+			buildOpts.SizeLimits = append(buildOpts.SizeLimits, limit)
+
 		case strings.HasPrefix(arg, "--outfile=") && buildOpts != nil:
 			buildOpts.Outfile = arg[len("--outfile="):]
 
@@ -225,22 +304,52 @@ func parseOptionsImpl(osArgs []string, buildOpts *api.BuildOptions, transformOpt
 				return fmt.Errorf("Invalid platform: %q (valid: browser, node)", value)
 			}
 
-		case strings.HasPrefix(arg, "--format=") && buildOpts != nil:
+		case strings.HasPrefix(arg, "--format="):
 			value := arg[len("--format="):]
+			var format api.Format
 			switch value {
 			case "iife":
-				buildOpts.Format = api.FormatIIFE
+				format = api.FormatIIFE
 			case "cjs":
-				buildOpts.Format = api.FormatCommonJS
+				format = api.FormatCommonJS
 			case "esm":
-				buildOpts.Format = api.FormatESModule
+				format = api.FormatESModule
 			default:
 				return fmt.Errorf("Invalid format: %q (valid: iife, cjs, esm)", value)
 			}
+			if buildOpts != nil {
+				buildOpts.Format = format
+			} else {
+				transformOpts.Format = format
+			}
 
 		case strings.HasPrefix(arg, "--external:") && buildOpts != nil:
 			buildOpts.Externals = append(buildOpts.Externals, arg[len("--external:"):])
 
+		case strings.HasPrefix(arg, "--drop:"):
+			value := arg[len("--drop:"):]
+			if buildOpts != nil {
+				buildOpts.Drop = append(buildOpts.Drop, value)
+			} else {
+				transformOpts.Drop = append(transformOpts.Drop, value)
+			}
+
+		case strings.HasPrefix(arg, "--mangle-props="):
+			value := arg[len("--mangle-props="):]
+			if buildOpts != nil {
+				buildOpts.MangleProps = value
+			} else {
+				transformOpts.MangleProps = value
+			}
+
+		case strings.HasPrefix(arg, "--reserve-props="):
+			value := arg[len("--reserve-props="):]
+			if buildOpts != nil {
+				buildOpts.ReserveProps = value
+			} else {
+				transformOpts.ReserveProps = value
+			}
+
 		case strings.HasPrefix(arg, "--jsx-factory="):
 			value := arg[len("--jsx-factory="):]
 			if buildOpts != nil {
@@ -410,6 +519,38 @@ func parseLoader(text string) (api.Loader, error) {
 	}
 }
 
+// Size limits look like "100kb,gzip,warn". The size can end in "b", "kb" (1024
+// bytes), or "mb" (1024 * 1024 bytes), and the other parts are optional.
+func parseSizeLimit(text string) (api.SizeLimit, error) {
+	parts := strings.Split(text, ",")
+	size := strings.ToLower(parts[0])
+	scale := 1
+	switch {
+	case strings.HasSuffix(size, "kb"):
+		size, scale = size[:len(size)-2], 1024
+	case strings.HasSuffix(size, "mb"):
+		size, scale = size[:len(size)-2], 1024*1024
+	case strings.HasSuffix(size, "b"):
+		size = size[:len(size)-1]
+	}
+	value, err := strconv.ParseFloat(size, 64)
+	if err != nil || value < 0 {
+		return api.SizeLimit{}, fmt.Errorf("Invalid size limit: %q", text)
+	}
+	limit := api.SizeLimit{MaxBytes: int(value * float64(scale))}
+	for _, part := range parts[1:] {
+		switch part {
+		case "gzip":
+			limit.Gzip = true
+		case "warn":
+			limit.Warn = true
+		default:
+			return api.SizeLimit{}, fmt.Errorf("Invalid size limit option: %q (valid: gzip, warn)", part)
+		}
+	}
+	return limit, nil
+}
+
 // This returns either BuildOptions, TransformOptions, or an error
 func parseOptionsForRun(osArgs []string) (*api.BuildOptions, *api.TransformOptions, error) {
 	// If there's an entry point or we're bundling, then we're building
@@ -447,7 +588,69 @@ func parseOptionsForRun(osArgs []string) (*api.BuildOptions, *api.TransformOptio
 }
 
 func runImpl(osArgs []string) int {
-	buildOptions, transformOptions, err := parseOptionsForRun(osArgs)
+	// These flags only apply to the command-line interface since they print
+	// or write a report instead of or after the build
+	analyze := false
+	analyzeHTML := ""
+	mangleCacheFile := ""
+	why := ""
+	astFormat := ""
+	otherArgs := make([]string, 0, len(osArgs))
+	for _, arg := range osArgs {
+		switch {
+		case arg == "--analyze":
+			analyze = true
+		case strings.HasPrefix(arg, "--analyze-html="):
+			analyzeHTML = arg[len("--analyze-html="):]
+		case strings.HasPrefix(arg, "--mangle-cache="):
+			mangleCacheFile = arg[len("--mangle-cache="):]
+		case strings.HasPrefix(arg, "--why="):
+			why = arg[len("--why="):]
+		case strings.HasPrefix(arg, "--ast="):
+			astFormat = arg[len("--ast="):]
+		default:
+			otherArgs = append(otherArgs, arg)
+		}
+	}
+
+	buildOptions, transformOptions, err := parseOptionsForRun(otherArgs)
+	if why != "" && err == nil && buildOptions == nil {
+		err = fmt.Errorf("Cannot use \"why\" when transforming stdin")
+		transformOptions = nil
+	}
+	if astFormat != "" && err == nil {
+		if buildOptions != nil {
+			err = fmt.Errorf("Cannot use \"ast\" when building")
+		} else if astFormat != "json" {
+			err = fmt.Errorf("Invalid AST format: %q (valid: json)", astFormat)
+		}
+		if err != nil {
+			buildOptions, transformOptions = nil, nil
+		}
+	}
+	if mangleCacheFile != "" && err == nil {
+		var mangleCache map[string]interface{}
+		if (buildOptions != nil && buildOptions.MangleProps == "") || (transformOptions != nil && transformOptions.MangleProps == "") {
+			err = fmt.Errorf("Cannot use \"mangle-cache\" without \"mangle-props\"")
+		} else {
+			mangleCache, err = readMangleCache(mangleCacheFile)
+		}
+		if err != nil {
+			buildOptions, transformOptions = nil, nil
+		} else if buildOptions != nil {
+			buildOptions.MangleCache = mangleCache
+		} else if transformOptions != nil {
+			transformOptions.MangleCache = mangleCache
+		}
+	}
+	if (analyze || analyzeHTML != "") && err == nil {
+		if buildOptions == nil {
+			err = fmt.Errorf("Cannot use \"analyze\" when transforming stdin")
+			transformOptions = nil
+		} else {
+			buildOptions.ReturnMetafile = true
+		}
+	}
 
 	switch {
 	case buildOptions != nil:
@@ -474,7 +677,19 @@ func runImpl(osArgs []string) int {
 			return 1
 		}
 
-		// Run the build and stop if there were errors
+		// Explain why a module is in the bundle instead of building
+		if why != "" {
+			result := api.Why(why, *buildOptions)
+			if len(result.Errors) > 0 {
+				return 1
+			}
+			os.Stdout.WriteString(whyText(result))
+			return 0
+		}
+
+		// Run the build and stop if there were errors. The output files are
+		// written by the build unless they go to stdout.
+		buildOptions.Write = true
 		result := api.Build(*buildOptions)
 		if len(result.Errors) > 0 {
 			return 1
@@ -489,15 +704,17 @@ func runImpl(osArgs []string) int {
 				logging.PrintErrorToStderr(osArgs, fmt.Sprintf(
 					"Failed to write to stdout: %s", err.Error()))
 			}
-		} else {
-			for _, outputFile := range result.OutputFiles {
-				if err := os.MkdirAll(filepath.Dir(outputFile.Path), 0755); err != nil {
-					result.Errors = append(result.Errors, api.Message{Text: fmt.Sprintf(
-						"Failed to create output directory: %s", err.Error())})
-				} else if err := ioutil.WriteFile(outputFile.Path, outputFile.Contents, 0644); err != nil {
-					logging.PrintErrorToStderr(osArgs, fmt.Sprintf(
-						"Failed to write to output file: %s", err.Error()))
-				}
+		}
+
+		// Save the updated mangle cache for the next build
+		if mangleCacheFile != "" && !writeMangleCache(osArgs, mangleCacheFile, result.MangleCache) {
+			return 1
+		}
+
+		// Summarize the metadata file if requested
+		if analyze || analyzeHTML != "" {
+			if !analyzeMetafile(osArgs, result.Metafile, analyze, analyzeHTML) {
+				return 1
 			}
 		}
 
@@ -510,6 +727,22 @@ func runImpl(osArgs []string) int {
 			return 1
 		}
 
+		// Print the syntax tree instead of transforming
+		if astFormat != "" {
+			result := api.ParseAST(string(bytes), api.ParseASTOptions{
+				Color:      transformOptions.Color,
+				ErrorLimit: transformOptions.ErrorLimit,
+				LogLevel:   transformOptions.LogLevel,
+				Sourcefile: transformOptions.Sourcefile,
+				Loader:     transformOptions.Loader,
+			})
+			if len(result.Errors) > 0 {
+				return 1
+			}
+			os.Stdout.Write(append(result.AST, '\n'))
+			return 0
+		}
+
 		// Run the transform and stop if there were errors
 		result := api.Transform(string(bytes), *transformOptions)
 		if len(result.Errors) > 0 {
@@ -519,6 +752,11 @@ func runImpl(osArgs []string) int {
 		// Write the output to stdout
 		os.Stdout.Write(result.JS)
 
+		// Save the updated mangle cache for the next transform
+		if mangleCacheFile != "" && !writeMangleCache(osArgs, mangleCacheFile, result.MangleCache) {
+			return 1
+		}
+
 	case err != nil:
 		logging.PrintErrorToStderr(osArgs, err.Error())
 		return 1
@@ -526,3 +764,89 @@ func runImpl(osArgs []string) int {
 
 	return 0
 }
+
+func analyzeMetafile(osArgs []string, metafile []byte, analyze bool, analyzeHTML string) bool {
+	if analyze {
+		text, err := api.AnalyzeMetafile(string(metafile), api.AnalyzeMetafileOptions{})
+		if err != nil {
+			logging.PrintErrorToStderr(osArgs, err.Error())
+			return false
+		}
+		os.Stderr.WriteString(text + "\n")
+	}
+
+	if analyzeHTML != "" {
+		html, err := api.AnalyzeMetafile(string(metafile), api.AnalyzeMetafileOptions{HTML: true})
+		if err != nil {
+			logging.PrintErrorToStderr(osArgs, err.Error())
+			return false
+		}
+		if err := ioutil.WriteFile(analyzeHTML, []byte(html), 0644); err != nil {
+			logging.PrintErrorToStderr(osArgs, fmt.Sprintf(
+				"Failed to write to analysis file: %s", err.Error()))
+			return false
+		}
+	}
+
+	return true
+}
+
+// A missing mangle cache file is treated as an empty cache so that the first
+// build creates it
+func readMangleCache(path string) (map[string]interface{}, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from mangle cache file: %s", err.Error())
+	}
+	cache := map[string]interface{}{}
+	if err := json.Unmarshal(bytes, &cache); err != nil {
+		return nil, fmt.Errorf("Invalid mangle cache file %q: %s", path, err.Error())
+	}
+	return cache, nil
+}
+
+func writeMangleCache(osArgs []string, path string, cache map[string]interface{}) bool {
+	bytes, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(path, append(bytes, '\n'), 0644)
+	}
+	if err != nil {
+		logging.PrintErrorToStderr(osArgs, fmt.Sprintf(
+			"Failed to write to mangle cache file: %s", err.Error()))
+		return false
+	}
+	return true
+}
+
+func whyText(result api.WhyResult) string {
+	sb := strings.Builder{}
+	for _, module := range result.Modules {
+		sb.WriteString(fmt.Sprintf("\n%s\n", module.Path))
+
+		for _, chain := range module.ImportChains {
+			if len(chain) == 0 {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("\n  Imported from the entry point %s:\n", chain[0].Location.File))
+			for _, step := range chain {
+				loc := step.Location
+				sb.WriteString(fmt.Sprintf("    %s:%d:%d: %s\n", loc.File, loc.Line, loc.Column, strings.TrimSpace(loc.LineText)))
+			}
+		}
+
+		if len(module.SideEffects) > 0 {
+			sb.WriteString("\n  Side effects that prevent tree shaking:\n")
+			for _, loc := range module.SideEffects {
+				sb.WriteString(fmt.Sprintf("    %s:%d:%d: %s\n", loc.File, loc.Line, loc.Column, strings.TrimSpace(loc.LineText)))
+			}
+		}
+
+		for _, note := range module.Notes {
+			sb.WriteString(fmt.Sprintf("\n  %s\n", note))
+		}
+	}
+	return sb.String()
+}
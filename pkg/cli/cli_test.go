@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func expectSizeLimits(t *testing.T, args []string, expected []api.SizeLimit) {
+	t.Helper()
+	options, err := ParseBuildOptions(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%+v", options.SizeLimits) != fmt.Sprintf("%+v", expected) {
+		t.Fatalf("%+v != %+v", options.SizeLimits, expected)
+	}
+}
+
+func expectSizeLimitError(t *testing.T, args []string, expected string) {
+	t.Helper()
+	_, err := ParseBuildOptions(args)
+	if err == nil {
+		t.Fatalf("Expected the error %q", expected)
+	}
+	if err.Error() != expected {
+		t.Fatalf("%q != %q", err.Error(), expected)
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	expectSizeLimits(t, []string{"--max-size=100"}, []api.SizeLimit{{MaxBytes: 100}})
+	expectSizeLimits(t, []string{"--max-size=100b"}, []api.SizeLimit{{MaxBytes: 100}})
+	expectSizeLimits(t, []string{"--max-size=2kb"}, []api.SizeLimit{{MaxBytes: 2048}})
+	expectSizeLimits(t, []string{"--max-size=1.5MB"}, []api.SizeLimit{{MaxBytes: 1572864}})
+	expectSizeLimits(t, []string{"--max-size=1kb,gzip"}, []api.SizeLimit{{MaxBytes: 1024, Gzip: true}})
+	expectSizeLimits(t, []string{"--max-size=1kb,warn,gzip"}, []api.SizeLimit{{MaxBytes: 1024, Gzip: true, Warn: true}})
+	expectSizeLimits(t, []string{"--max-size=1kb", "--max-size=2kb,gzip"}, []api.SizeLimit{
+		{MaxBytes: 1024},
+		{MaxBytes: 2048, Gzip: true},
+	})
+
+	expectSizeLimitError(t, []string{"--max-size="}, `Invalid size limit: ""`)
+	expectSizeLimitError(t, []string{"--max-size=big"}, `Invalid size limit: "big"`)
+	expectSizeLimitError(t, []string{"--max-size=-1kb"}, `Invalid size limit: "-1kb"`)
+	expectSizeLimitError(t, []string{"--max-size=1kb,brotli"}, `Invalid size limit option: "brotli" (valid: gzip, warn)`)
+}
+
+func TestMaxSizeEntryPoint(t *testing.T) {
+	expectSizeLimits(t, []string{"--max-size:src/app.js=10kb"}, []api.SizeLimit{{EntryPoint: "src/app.js", MaxBytes: 10240}})
+	expectSizeLimits(t, []string{"--max-size:a=b.js=1kb,warn"}, []api.SizeLimit{{EntryPoint: "a=b.js", MaxBytes: 1024, Warn: true}})
+
+	expectSizeLimitError(t, []string{"--max-size:src/app.js"}, `Missing "=": "src/app.js"`)
+	expectSizeLimitError(t, []string{"--max-size:src/app.js=big"}, `Invalid size limit: "big"`)
+}
+
+func TestMaxSizeMatch(t *testing.T) {
+	expectSizeLimits(t, []string{`--max-size-match:^chunk\..*\.js$=5kb,gzip`}, []api.SizeLimit{{Pattern: `^chunk\..*\.js$`, MaxBytes: 5120, Gzip: true}})
+	expectSizeLimits(t, []string{"--max-size-match:.*=1"}, []api.SizeLimit{{Pattern: ".*", MaxBytes: 1}})
+
+	expectSizeLimitError(t, []string{"--max-size-match:.*"}, `Missing "=": ".*"`)
+	expectSizeLimitError(t, []string{"--max-size-match:.*=1kb,zip"}, `Invalid size limit option: "zip" (valid: gzip, warn)`)
+}
+
+func TestMangleCacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esbuild-mangle-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+
+	// A missing file starts out as an empty cache
+	cache, err := readMangleCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache) != 0 {
+		t.Fatalf("Expected an empty cache, got %+v", cache)
+	}
+
+	if !writeMangleCache(nil, path, map[string]interface{}{"foo_": "a", "bar_": false}) {
+		t.Fatal("Failed to write the cache")
+	}
+	cache, err = readMangleCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%v", cache) != "map[bar_:false foo_:a]" {
+		t.Fatalf("Unexpected cache %+v", cache)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readMangleCache(path); err == nil {
+		t.Fatal("Expected an error for an invalid cache file")
+	}
+}
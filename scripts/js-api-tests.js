@@ -414,6 +414,111 @@ console.log("success");
   },
 }
 
+let pluginTests = {
+  async resolveAndLoad({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const output = path.join(testDir, 'out.js')
+    await writeFileAsync(input, 'export {default} from "virtual:value"')
+    await esbuild.build({
+      entryPoints: [input], bundle: true, outfile: output, format: 'cjs', plugins: [{
+        name: 'virtual',
+        setup(build) {
+          build.onResolve({ filter: /^virtual:/ }, args => {
+            assert.strictEqual(args.importer, input)
+            assert.strictEqual(args.namespace, 'file')
+            assert.strictEqual(args.resolveDir, testDir)
+            return { path: args.path, namespace: 'virtual' }
+          })
+          build.onLoad({ filter: /.*/, namespace: 'virtual' }, args => {
+            assert.strictEqual(args.path, 'virtual:value')
+            return { contents: 'export default 123' }
+          })
+        },
+      }],
+    })
+    const result = require(output)
+    assert.strictEqual(result.default, 123)
+  },
+
+  async resolveEntryPoint({ esbuild, testDir }) {
+    const output = path.join(testDir, 'out.js')
+    await esbuild.build({
+      entryPoints: ['entry'], bundle: true, outfile: output, format: 'cjs', plugins: [{
+        name: 'entry',
+        setup(build) {
+          build.onResolve({ filter: /^entry$/ }, args => {
+            assert.strictEqual(args.importer, '')
+            assert.strictEqual(args.namespace, 'file')
+            assert.strictEqual(args.resolveDir, process.cwd())
+            return { path: args.path, namespace: 'entry' }
+          })
+          build.onLoad({ filter: /.*/, namespace: 'entry' }, () => {
+            return { contents: 'export default 123' }
+          })
+        },
+      }],
+    })
+    const result = require(output)
+    assert.strictEqual(result.default, 123)
+  },
+
+  async externalEntryPoint({ esbuild, testDir }) {
+    const output = path.join(testDir, 'out.js')
+    try {
+      await esbuild.build({
+        entryPoints: ['entry'], bundle: true, outfile: output, logLevel: 'silent', plugins: [{
+          name: 'entry',
+          setup(build) {
+            build.onResolve({ filter: /^entry$/ }, args => ({ path: args.path, external: true }))
+          },
+        }],
+      })
+      throw new Error('Expected build failure')
+    } catch (e) {
+      if (!e.errors) throw e
+      assert.strictEqual(e.errors.length, 1)
+      assert.strictEqual(e.errors[0].text, 'Entry points can\'t be external: entry')
+    }
+  },
+
+  async loaderFromExtension({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const data = path.join(testDir, 'data.json')
+    const output = path.join(testDir, 'out.js')
+    await writeFileAsync(input, 'export {default} from "./data.json"')
+    await writeFileAsync(data, '{}')
+    await esbuild.build({
+      entryPoints: [input], bundle: true, outfile: output, format: 'cjs', plugins: [{
+        name: 'json',
+        setup(build) {
+          // No "loader" means the loader for the ".json" extension is used
+          build.onLoad({ filter: /\.json$/ }, () => ({ contents: '{"value": 123}' }))
+        },
+      }],
+    })
+    const result = require(output)
+    assert.deepStrictEqual(result.default, { value: 123 })
+  },
+
+  async loaderOverride({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const text = path.join(testDir, 'data.txt')
+    const output = path.join(testDir, 'out.js')
+    await writeFileAsync(input, 'export {default} from "./data.txt"')
+    await writeFileAsync(text, '')
+    await esbuild.build({
+      entryPoints: [input], bundle: true, outfile: output, format: 'cjs', plugins: [{
+        name: 'js',
+        setup(build) {
+          build.onLoad({ filter: /\.txt$/ }, () => ({ contents: 'export default 1 + 2', loader: 'js' }))
+        },
+      }],
+    })
+    const result = require(output)
+    assert.strictEqual(result.default, 3)
+  },
+}
+
 async function futureSyntax(service, js, targetBelow, targetAbove) {
   failure: {
     try { await service.transform(js, { target: targetBelow }) }
@@ -559,6 +664,30 @@ let transformTests = {
     assert.strictEqual(js2, `foo;\n`)
   },
 
+  async mangleProps({ service }) {
+    const { js, mangleCache } = await service.transform(`x.foo_ = x.BAR_ + x.keep_`, {
+      mangleProps: /_$/, reserveProps: /^KEEP_$/i, mangleCache: { foo_: 'z' },
+    })
+    assert.strictEqual(js, `x.z = x.a + x.keep_;\n`)
+    assert.deepStrictEqual(mangleCache, { foo_: 'z', BAR_: 'a' })
+  },
+
+  async manglePropsFlags({ service }) {
+    const { js } = await service.transform(`x.FOO_ + x.bar_`, { mangleProps: /^foo/i })
+    assert.strictEqual(js, `x.a + x.bar_;\n`)
+  },
+
+  async manglePropsUnsupportedSyntax({ service }) {
+    try {
+      await service.transform(`x.foo_`, { mangleProps: /foo(?=_)/ })
+      throw new Error('Expected transform failure')
+    } catch (e) {
+      if (!e.errors) throw e
+      assert.strictEqual(e.errors.length, 1)
+      assert.ok(e.errors[0].text.startsWith('The "mangle props" setting is not a valid Go regular expression'))
+    }
+  },
+
   async multipleEngineTargets({ service }) {
     const check = async (target, expected) =>
       assert.strictEqual((await service.transform(`foo(a ?? b)`, { target })).js, expected)
@@ -633,6 +762,7 @@ async function main() {
   }
   const tests = [
     ...Object.entries(buildTests),
+    ...Object.entries(pluginTests),
     ...Object.entries(transformTests),
     ...Object.entries(syncTests),
   ]